@@ -123,6 +123,45 @@ var (
 	// MAlertingActiveAlerts is a metric amount of active alerts
 	MAlertingActiveAlerts prometheus.Gauge
 
+	// MNGAlertSchedulerPaused is a metric of whether the ngalert scheduler evaluation is paused
+	MNGAlertSchedulerPaused prometheus.Gauge
+
+	// MNGAlertEvaluationPanics is a metric of the ngalert evaluation panics recovered by the scheduler
+	MNGAlertEvaluationPanics prometheus.Counter
+
+	// MNGAlertTickSkew is a metric histogram of how late the ngalert scheduler handles its ticks
+	MNGAlertTickSkew prometheus.Histogram
+
+	// MNGAlertEvaluationQueueDepth is a metric of the ngalert evaluations waiting for an evaluation slot
+	MNGAlertEvaluationQueueDepth prometheus.Gauge
+
+	// MNGAlertEvaluationQueueWait is a metric histogram of how long the ngalert evaluations wait for an evaluation slot
+	MNGAlertEvaluationQueueWait prometheus.Histogram
+
+	// MNGAlertEvaluations is a metric counter of the ngalert evaluations, by organisation
+	MNGAlertEvaluations *prometheus.CounterVec
+
+	// MNGAlertEvaluationFailures is a metric counter of the failed ngalert evaluations, by organisation
+	MNGAlertEvaluationFailures *prometheus.CounterVec
+
+	// MNGAlertEvaluationDuration is a metric histogram of the ngalert evaluation duration, by organisation
+	MNGAlertEvaluationDuration *prometheus.HistogramVec
+
+	// MNGAlertActiveRoutines is a metric of the ngalert alert definition routines running
+	MNGAlertActiveRoutines prometheus.Gauge
+
+	// MNGAlertStateTransitions is a metric counter of the ngalert alert instance state transitions, by states
+	MNGAlertStateTransitions *prometheus.CounterVec
+
+	// MNGAlertStateCacheSize is a metric of the alert instance states held by the ngalert state tracker
+	MNGAlertStateCacheSize prometheus.Gauge
+
+	// MNGAlertInstanceLimitDrops is a metric counter of the ngalert results dropped by the alert instance limit, by organisation
+	MNGAlertInstanceLimitDrops *prometheus.CounterVec
+
+	// MNGAlertAuditDroppedTransitions is a metric counter of the ngalert state transitions dropped as the audit sink fell behind
+	MNGAlertAuditDroppedTransitions prometheus.Counter
+
 	// MStatTotalDashboards is a metric total amount of dashboards
 	MStatTotalDashboards prometheus.Gauge
 
@@ -409,6 +448,87 @@ func init() {
 		Namespace: ExporterName,
 	})
 
+	MNGAlertSchedulerPaused = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name:      "ngalert_scheduler_paused",
+		Help:      "1 if the evaluation of the ngalert scheduler is paused, 0 otherwise",
+		Namespace: ExporterName,
+	})
+
+	MNGAlertEvaluationPanics = prometheus.NewCounter(prometheus.CounterOpts{
+		Name:      "ngalert_evaluation_panics_total",
+		Help:      "counter for the ngalert evaluation panics recovered by the scheduler",
+		Namespace: ExporterName,
+	})
+
+	MNGAlertTickSkew = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:      "ngalert_scheduler_tick_skew_seconds",
+		Help:      "histogram of the delay between the intended time of the ngalert scheduler ticks and their handling",
+		Buckets:   prometheus.ExponentialBuckets(0.01, 4, 6),
+		Namespace: ExporterName,
+	})
+
+	MNGAlertEvaluationQueueDepth = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name:      "ngalert_evaluation_queue_depth",
+		Help:      "number of ngalert evaluations waiting for an evaluation slot",
+		Namespace: ExporterName,
+	})
+
+	MNGAlertEvaluationQueueWait = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:      "ngalert_evaluation_queue_wait_seconds",
+		Help:      "histogram of how long the ngalert evaluations wait for an evaluation slot",
+		Buckets:   prometheus.ExponentialBuckets(0.01, 4, 6),
+		Namespace: ExporterName,
+	})
+
+	MNGAlertEvaluations = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name:      "ngalert_evaluations_total",
+		Help:      "counter for the ngalert evaluations",
+		Namespace: ExporterName,
+	}, []string{"org"})
+
+	MNGAlertEvaluationFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name:      "ngalert_evaluation_failures_total",
+		Help:      "counter for the ngalert evaluations that failed after all their attempts",
+		Namespace: ExporterName,
+	}, []string{"org"})
+
+	MNGAlertEvaluationDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:      "ngalert_evaluation_duration_seconds",
+		Help:      "histogram of the duration of the ngalert evaluation attempts",
+		Buckets:   prometheus.ExponentialBuckets(0.01, 4, 6),
+		Namespace: ExporterName,
+	}, []string{"org"})
+
+	MNGAlertActiveRoutines = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name:      "ngalert_scheduler_active_routines",
+		Help:      "number of alert definition routines run by the ngalert scheduler",
+		Namespace: ExporterName,
+	})
+
+	MNGAlertStateTransitions = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name:      "ngalert_state_transitions_total",
+		Help:      "counter for the ngalert alert instance state transitions",
+		Namespace: ExporterName,
+	}, []string{"from", "to"})
+
+	MNGAlertStateCacheSize = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name:      "ngalert_state_cache_size",
+		Help:      "number of alert instance states held by the ngalert state tracker",
+		Namespace: ExporterName,
+	})
+
+	MNGAlertInstanceLimitDrops = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name:      "ngalert_instance_limit_dropped_results_total",
+		Help:      "counter for the ngalert results of new alert instances dropped as their rule exceeds the alert instance limit",
+		Namespace: ExporterName,
+	}, []string{"org"})
+
+	MNGAlertAuditDroppedTransitions = prometheus.NewCounter(prometheus.CounterOpts{
+		Name:      "ngalert_audit_dropped_transitions_total",
+		Help:      "counter for the ngalert alert instance state transitions dropped as the audit sink did not keep up",
+		Namespace: ExporterName,
+	})
+
 	MStatTotalDashboards = prometheus.NewGauge(prometheus.GaugeOpts{
 		Name:      "stat_totals_dashboard",
 		Help:      "total amount of dashboards",
@@ -583,6 +703,19 @@ func initMetricVars() {
 		MRenderingSummary,
 		MRenderingQueue,
 		MAlertingActiveAlerts,
+		MNGAlertSchedulerPaused,
+		MNGAlertEvaluationPanics,
+		MNGAlertTickSkew,
+		MNGAlertEvaluationQueueDepth,
+		MNGAlertEvaluationQueueWait,
+		MNGAlertEvaluations,
+		MNGAlertEvaluationFailures,
+		MNGAlertEvaluationDuration,
+		MNGAlertActiveRoutines,
+		MNGAlertStateTransitions,
+		MNGAlertStateCacheSize,
+		MNGAlertInstanceLimitDrops,
+		MNGAlertAuditDroppedTransitions,
 		MStatTotalDashboards,
 		MStatTotalFolders,
 		MStatTotalUsers,
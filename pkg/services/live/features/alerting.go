@@ -0,0 +1,34 @@
+package features
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+
+	"github.com/grafana/grafana/pkg/models"
+)
+
+// AlertingHandler manages the `grafana/alerting/${orgId}/${uid}` channels
+// the state transitions of the alert definitions are published to by the alerting scheduler.
+type AlertingHandler struct{}
+
+// GetHandlerForPath called on init
+func (h *AlertingHandler) GetHandlerForPath(path string) (models.ChannelHandler, error) {
+	return h, nil // all alert definitions share the same handler
+}
+
+// OnSubscribe lets the users subscribe to the alert definitions of their organisation
+func (h *AlertingHandler) OnSubscribe(ctx context.Context, user *models.SignedInUser, e models.SubscribeEvent) (models.SubscribeReply, backend.SubscribeStreamStatus, error) {
+	parts := strings.SplitN(e.Path, "/", 2)
+	if len(parts) != 2 || user == nil || parts[0] != strconv.FormatInt(user.OrgId, 10) {
+		return models.SubscribeReply{}, backend.SubscribeStreamStatusPermissionDenied, nil
+	}
+	return models.SubscribeReply{}, backend.SubscribeStreamStatusOK, nil
+}
+
+// OnPublish denies the publications from the clients: only the scheduler publishes the state transitions
+func (h *AlertingHandler) OnPublish(ctx context.Context, _ *models.SignedInUser, e models.PublishEvent) (models.PublishReply, backend.PublishStreamStatus, error) {
+	return models.PublishReply{}, backend.PublishStreamStatusPermissionDenied, nil
+}
@@ -132,6 +132,7 @@ func (g *GrafanaLive) Init() error {
 	g.GrafanaScope.Features["dashboard"] = dash
 	g.GrafanaScope.Features["broadcast"] = &features.BroadcastRunner{}
 	g.GrafanaScope.Features["measurements"] = &features.MeasurementsRunner{}
+	g.GrafanaScope.Features["alerting"] = &features.AlertingHandler{}
 
 	// Set ConnectHandler called when client successfully connected to Node. Your code
 	// inside handler must be synchronized since it will be called concurrently from
@@ -18,6 +18,7 @@ import (
 	"github.com/grafana/grafana/pkg/services/ngalert/eval"
 	ngmodels "github.com/grafana/grafana/pkg/services/ngalert/models"
 	"github.com/grafana/grafana/pkg/services/ngalert/schedule"
+	"github.com/grafana/grafana/pkg/services/ngalert/state"
 	"github.com/grafana/grafana/pkg/services/ngalert/store"
 	"github.com/grafana/grafana/pkg/setting"
 	"github.com/grafana/grafana/pkg/tsdb"
@@ -33,6 +34,7 @@ type Alertmanager interface {
 	DeleteSilence(silenceID string) error
 	GetSilence(silenceID string) (apimodels.GettableSilence, error)
 	ListSilences(filters []string) (apimodels.GettableSilences, error)
+	SyncAndApplyConfigFromDatabase() error
 }
 
 // API handlers.
@@ -47,6 +49,7 @@ type API struct {
 	AlertingStore   store.AlertingStore
 	DataProxy       *datasourceproxy.DatasourceProxyService
 	Alertmanager    Alertmanager
+	StateTracker    *state.StateTracker
 }
 
 // RegisterAPIEndpoints registers API handlers
@@ -77,12 +80,18 @@ func (api *API) RegisterAPIEndpoints() {
 		alertDefinitions.Get("", middleware.ReqSignedIn, routing.Wrap(api.listAlertDefinitions))
 		alertDefinitions.Get("/eval/:alertDefinitionUID", middleware.ReqSignedIn, api.validateOrgAlertDefinition, routing.Wrap(api.alertDefinitionEvalEndpoint))
 		alertDefinitions.Post("/eval", middleware.ReqSignedIn, binding.Bind(ngmodels.EvalAlertConditionCommand{}), routing.Wrap(api.conditionEvalEndpoint))
+		alertDefinitions.Post("/eval-preview", middleware.ReqSignedIn, binding.Bind(ngmodels.EvalPreviewAlertDefinitionCommand{}), routing.Wrap(api.evalPreviewEndpoint))
 		alertDefinitions.Get("/:alertDefinitionUID", middleware.ReqSignedIn, api.validateOrgAlertDefinition, routing.Wrap(api.getAlertDefinitionEndpoint))
 		alertDefinitions.Delete("/:alertDefinitionUID", middleware.ReqEditorRole, api.validateOrgAlertDefinition, routing.Wrap(api.deleteAlertDefinitionEndpoint))
 		alertDefinitions.Post("/", middleware.ReqEditorRole, binding.Bind(ngmodels.SaveAlertDefinitionCommand{}), routing.Wrap(api.createAlertDefinitionEndpoint))
 		alertDefinitions.Put("/:alertDefinitionUID", middleware.ReqEditorRole, api.validateOrgAlertDefinition, binding.Bind(ngmodels.UpdateAlertDefinitionCommand{}), routing.Wrap(api.updateAlertDefinitionEndpoint))
+		alertDefinitions.Get("/:alertDefinitionUID/versions", middleware.ReqSignedIn, api.validateOrgAlertDefinition, routing.Wrap(api.listAlertDefinitionVersionsEndpoint))
+		alertDefinitions.Get("/:alertDefinitionUID/versions/:version", middleware.ReqSignedIn, api.validateOrgAlertDefinition, routing.Wrap(api.getAlertDefinitionVersionEndpoint))
+		alertDefinitions.Get("/:alertDefinitionUID/diff", middleware.ReqSignedIn, api.validateOrgAlertDefinition, routing.Wrap(api.alertDefinitionVersionsDiffEndpoint))
+		alertDefinitions.Post("/:alertDefinitionUID/restore", middleware.ReqEditorRole, api.validateOrgAlertDefinition, binding.Bind(ngmodels.RestoreAlertDefinitionVersionCommand{}), routing.Wrap(api.restoreAlertDefinitionVersionEndpoint))
 		alertDefinitions.Post("/pause", middleware.ReqEditorRole, binding.Bind(ngmodels.UpdateAlertDefinitionPausedCommand{}), routing.Wrap(api.alertDefinitionPauseEndpoint))
 		alertDefinitions.Post("/unpause", middleware.ReqEditorRole, binding.Bind(ngmodels.UpdateAlertDefinitionPausedCommand{}), routing.Wrap(api.alertDefinitionUnpauseEndpoint))
+		alertDefinitions.Post("/delete", middleware.ReqEditorRole, binding.Bind(ngmodels.DeleteAlertDefinitionsCommand{}), routing.Wrap(api.deleteAlertDefinitionsEndpoint))
 	})
 
 	if api.Cfg.Env == setting.Dev {
@@ -100,10 +109,25 @@ func (api *API) RegisterAPIEndpoints() {
 	api.RouteRegister.Group("/api/ngalert/", func(schedulerRouter routing.RouteRegister) {
 		schedulerRouter.Post("/pause", routing.Wrap(api.pauseScheduler))
 		schedulerRouter.Post("/unpause", routing.Wrap(api.unpauseScheduler))
+		schedulerRouter.Get("/ownership", routing.Wrap(api.schedulerOwnershipEndpoint))
 	}, middleware.ReqOrgAdmin)
 
 	api.RouteRegister.Group("/api/alert-instances", func(alertInstances routing.RouteRegister) {
 		alertInstances.Get("", middleware.ReqSignedIn, routing.Wrap(api.listAlertInstancesEndpoint))
+		alertInstances.Get("/history", middleware.ReqSignedIn, routing.Wrap(api.listAlertStateHistoryEndpoint))
+	})
+
+	api.RouteRegister.Group("/api/alert-mute-timings", func(muteTimings routing.RouteRegister) {
+		muteTimings.Get("", middleware.ReqSignedIn, routing.Wrap(api.listMuteTimingsEndpoint))
+		muteTimings.Put("/:name", middleware.ReqEditorRole, binding.Bind(ngmodels.SaveMuteTimingCommand{}), routing.Wrap(api.saveMuteTimingEndpoint))
+		muteTimings.Delete("/:name", middleware.ReqEditorRole, routing.Wrap(api.deleteMuteTimingEndpoint))
+	})
+
+	api.RouteRegister.Group("/api/alert-folder-settings", func(folderSettings routing.RouteRegister) {
+		folderSettings.Get("", middleware.ReqSignedIn, routing.Wrap(api.listAlertFolderSettingsEndpoint))
+		folderSettings.Get("/:namespaceUID", middleware.ReqSignedIn, routing.Wrap(api.getAlertFolderSettingsEndpoint))
+		folderSettings.Put("/:namespaceUID", middleware.ReqEditorRole, binding.Bind(ngmodels.SaveAlertFolderSettingsCommand{}), routing.Wrap(api.saveAlertFolderSettingsEndpoint))
+		folderSettings.Delete("/:namespaceUID", middleware.ReqEditorRole, routing.Wrap(api.deleteAlertFolderSettingsEndpoint))
 	})
 }
 
@@ -124,7 +148,7 @@ func (api *API) conditionEvalEndpoint(c *models.ReqContext, cmd ngmodels.EvalAle
 	}
 
 	evaluator := eval.Evaluator{Cfg: api.Cfg}
-	evalResults, err := evaluator.ConditionEval(&evalCond, timeNow(), api.DataService)
+	evalResults, err := evaluator.ConditionEval(c.Req.Context(), &evalCond, timeNow(), api.DataService)
 	if err != nil {
 		return response.Error(400, "Failed to evaluate conditions", err)
 	}
@@ -150,7 +174,7 @@ func (api *API) alertDefinitionEvalEndpoint(c *models.ReqContext) response.Respo
 	}
 
 	evaluator := eval.Evaluator{Cfg: api.Cfg}
-	evalResults, err := evaluator.ConditionEval(condition, timeNow(), api.DataService)
+	evalResults, err := evaluator.ConditionEval(c.Req.Context(), condition, timeNow(), api.DataService)
 	if err != nil {
 		return response.Error(400, "Failed to evaluate alert", err)
 	}
@@ -261,6 +285,26 @@ func (api *API) unpauseScheduler() response.Response {
 	return response.JSON(200, util.DynMap{"message": "alert definition scheduler unpaused"})
 }
 
+// schedulerOwnershipEndpoint handles GET /api/ngalert/ownership.
+// It returns the scheduler members and, by alert definition UID of the organisation, the member owning it;
+// the members are empty if the alert definitions are not sharded.
+func (api *API) schedulerOwnershipEndpoint(c *models.ReqContext) response.Response {
+	query := ngmodels.ListAlertDefinitionsQuery{OrgID: c.SignedInUser.OrgId}
+	if err := api.Store.GetOrgAlertDefinitions(&query); err != nil {
+		return response.Error(500, "Failed to list alert definitions", err)
+	}
+
+	members := api.Schedule.ShardMembers()
+	if members == nil {
+		members = []string{}
+	}
+	owners := make(map[string]string, len(query.Result))
+	for _, alertDefinition := range query.Result {
+		owners[alertDefinition.UID] = schedule.ShardOwner(members, alertDefinition.GetKey())
+	}
+	return response.JSON(200, util.DynMap{"members": members, "owners": owners})
+}
+
 // alertDefinitionPauseEndpoint handles POST /api/alert-definitions/pause.
 func (api *API) alertDefinitionPauseEndpoint(c *models.ReqContext, cmd ngmodels.UpdateAlertDefinitionPausedCommand) response.Response {
 	cmd.OrgID = c.SignedInUser.OrgId
@@ -285,6 +329,17 @@ func (api *API) alertDefinitionUnpauseEndpoint(c *models.ReqContext, cmd ngmodel
 	return response.JSON(200, util.DynMap{"message": fmt.Sprintf("%d alert definitions unpaused", cmd.ResultCount)})
 }
 
+// deleteAlertDefinitionsEndpoint handles POST /api/alert-definitions/delete.
+func (api *API) deleteAlertDefinitionsEndpoint(c *models.ReqContext, cmd ngmodels.DeleteAlertDefinitionsCommand) response.Response {
+	cmd.OrgID = c.SignedInUser.OrgId
+
+	err := api.Store.DeleteAlertDefinitions(&cmd)
+	if err != nil {
+		return response.Error(500, "Failed to delete alert definitions", err)
+	}
+	return response.JSON(200, util.DynMap{"message": fmt.Sprintf("%d alert definitions deleted", cmd.ResultCount)})
+}
+
 // LoadAlertCondition returns a Condition object for the given alertDefinitionID.
 func (api *API) LoadAlertCondition(alertDefinitionUID string, orgID int64) (*ngmodels.Condition, error) {
 	q := ngmodels.GetAlertDefinitionByUIDQuery{UID: alertDefinitionUID, OrgID: orgID}
@@ -33,7 +33,7 @@ func (api *API) conditionEvalOldEndpoint(c *models.ReqContext) response.Response
 	//}
 
 	evaluator := eval.Evaluator{Cfg: api.Cfg}
-	evalResults, err := evaluator.ConditionEval(evalCond, timeNow(), api.DataService)
+	evalResults, err := evaluator.ConditionEval(c.Req.Context(), evalCond, timeNow(), api.DataService)
 	if err != nil {
 		return response.Error(400, "Failed to evaluate conditions", err)
 	}
@@ -86,7 +86,7 @@ func (api *API) conditionEvalOldEndpointByID(c *models.ReqContext) response.Resp
 	//}
 
 	evaluator := eval.Evaluator{Cfg: api.Cfg}
-	evalResults, err := evaluator.ConditionEval(evalCond, timeNow(), api.DataService)
+	evalResults, err := evaluator.ConditionEval(c.Req.Context(), evalCond, timeNow(), api.DataService)
 	if err != nil {
 		return response.Error(400, "Failed to evaluate conditions", err)
 	}
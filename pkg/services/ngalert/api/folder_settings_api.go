@@ -0,0 +1,59 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/grafana/grafana/pkg/api/response"
+	"github.com/grafana/grafana/pkg/models"
+	ngmodels "github.com/grafana/grafana/pkg/services/ngalert/models"
+	"github.com/grafana/grafana/pkg/util"
+)
+
+// listAlertFolderSettingsEndpoint handles GET /api/alert-folder-settings.
+func (api *API) listAlertFolderSettingsEndpoint(c *models.ReqContext) response.Response {
+	query := ngmodels.ListAlertFolderSettingsQuery{OrgID: c.SignedInUser.OrgId}
+	if err := api.Store.ListAlertFolderSettings(&query); err != nil {
+		return response.Error(http.StatusInternalServerError, "Failed to list the alert settings of the folders", err)
+	}
+	return response.JSON(http.StatusOK, query.Result)
+}
+
+// getAlertFolderSettingsEndpoint handles GET /api/alert-folder-settings/:namespaceUID.
+func (api *API) getAlertFolderSettingsEndpoint(c *models.ReqContext) response.Response {
+	query := ngmodels.GetAlertFolderSettingsQuery{OrgID: c.SignedInUser.OrgId, NamespaceUID: c.Params(":namespaceUID")}
+	if err := api.Store.GetAlertFolderSettings(&query); err != nil {
+		if errors.Is(err, ngmodels.ErrAlertFolderSettingsNotFound) {
+			return response.Error(http.StatusNotFound, "Alert settings of the folder not found", err)
+		}
+		return response.Error(http.StatusInternalServerError, "Failed to get the alert settings of the folder", err)
+	}
+	return response.JSON(http.StatusOK, query.Result)
+}
+
+// saveAlertFolderSettingsEndpoint handles PUT /api/alert-folder-settings/:namespaceUID.
+// It creates, or replaces, the alert settings of the folder, which the alert definitions
+// of the folder inherit from the next time the scheduler reconciles them.
+func (api *API) saveAlertFolderSettingsEndpoint(c *models.ReqContext, cmd ngmodels.SaveAlertFolderSettingsCommand) response.Response {
+	cmd.OrgID = c.SignedInUser.OrgId
+	cmd.NamespaceUID = c.Params(":namespaceUID")
+	if err := api.Store.SaveAlertFolderSettings(&cmd); err != nil {
+		if errors.Is(err, ngmodels.ErrInvalidAlertFolderSettings) {
+			return response.Error(http.StatusBadRequest, "Invalid alert settings of the folder", err)
+		}
+		return response.Error(http.StatusInternalServerError, "Failed to save the alert settings of the folder", err)
+	}
+	return response.JSON(http.StatusOK, cmd.Result)
+}
+
+// deleteAlertFolderSettingsEndpoint handles DELETE /api/alert-folder-settings/:namespaceUID.
+func (api *API) deleteAlertFolderSettingsEndpoint(c *models.ReqContext) response.Response {
+	cmd := ngmodels.DeleteAlertFolderSettingsCommand{OrgID: c.SignedInUser.OrgId, NamespaceUID: c.Params(":namespaceUID")}
+	if err := api.Store.DeleteAlertFolderSettings(&cmd); err != nil {
+		if errors.Is(err, ngmodels.ErrAlertFolderSettingsNotFound) {
+			return response.Error(http.StatusNotFound, "Alert settings of the folder not found", err)
+		}
+		return response.Error(http.StatusInternalServerError, "Failed to delete the alert settings of the folder", err)
+	}
+	return response.JSON(http.StatusOK, util.DynMap{"message": "alert settings of the folder deleted"})
+}
@@ -1,19 +1,149 @@
 package api
 
 import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/prometheus/common/model"
+
+	"github.com/grafana/grafana/pkg/services/ngalert/eval"
 	ngmodels "github.com/grafana/grafana/pkg/services/ngalert/models"
+	"github.com/grafana/grafana/pkg/services/ngalert/state"
 
 	"github.com/grafana/grafana/pkg/api/response"
 	"github.com/grafana/grafana/pkg/models"
 )
 
+// defaultAlertInstancesLimit is the number of alert instances by page when the limit is not set.
+const defaultAlertInstancesLimit = 1000
+
 // listAlertInstancesEndpoint handles GET /api/alert-instances.
+// It serves the current alert instances of the state tracker, which are ahead of the saved ones, by alert
+// definition UID then labels. They can be filtered by alert definition UID, by state and by label matchers,
+// name=value or name=~regex, both repeatable; limit and page, starting at 1, paginate them.
 func (api *API) listAlertInstancesEndpoint(c *models.ReqContext) response.Response {
-	cmd := ngmodels.ListAlertInstancesQuery{DefinitionOrgID: c.SignedInUser.OrgId}
+	query := state.StateQuery{
+		OrgID: c.SignedInUser.OrgId,
+		UID:   c.Query("definitionUID"),
+	}
+	for _, v := range c.QueryStrings("state") {
+		s, err := parseEvalState(v)
+		if err != nil {
+			return response.Error(400, "Invalid state", err)
+		}
+		query.States = append(query.States, s)
+	}
+	for _, v := range c.QueryStrings("matcher") {
+		m, err := parseMatcher(v)
+		if err != nil {
+			return response.Error(400, "Invalid matcher", err)
+		}
+		query.Matchers = append(query.Matchers, m)
+	}
+	limit, page := c.QueryInt("limit"), c.QueryInt("page")
+	if limit <= 0 {
+		limit = defaultAlertInstancesLimit
+	}
+	if page <= 0 {
+		page = 1
+	}
 
-	if err := api.Store.ListAlertInstances(&cmd); err != nil {
+	states, err := api.StateTracker.Query(query)
+	if err != nil {
 		return response.Error(500, "Failed to list alert instances", err)
 	}
+	start := (page - 1) * limit
+	if start > len(states) {
+		start = len(states)
+	}
+	end := start + limit
+	if end > len(states) {
+		end = len(states)
+	}
+	states = states[start:end]
+
+	titles := make(map[string]string)
+	result := make([]*ngmodels.ListAlertInstancesQueryResult, 0, len(states))
+	for _, s := range states {
+		title, ok := titles[s.UID]
+		if !ok {
+			// the alert instances of a deleted alert definition have no title
+			q := ngmodels.GetAlertDefinitionByUIDQuery{UID: s.UID, OrgID: s.OrgID}
+			if err := api.Store.GetAlertDefinitionByUID(&q); err == nil {
+				title = q.Result.Title
+			}
+			titles[s.UID] = title
+		}
+		labels := ngmodels.InstanceLabels(s.Labels)
+		_, hash, err := labels.StringAndHash()
+		if err != nil {
+			return response.Error(500, "Failed to list alert instances", err)
+		}
+		result = append(result, &ngmodels.ListAlertInstancesQueryResult{
+			DefinitionOrgID:   s.OrgID,
+			DefinitionUID:     s.UID,
+			DefinitionTitle:   title,
+			Labels:            labels,
+			LabelsHash:        hash,
+			CurrentState:      ngmodels.InstanceStateType(s.State.String()),
+			CurrentStateSince: s.StartsAt,
+			CurrentStateEnd:   s.EndsAt,
+			LastEvalTime:      s.LastEvaluationTime,
+			ConditionStart:    s.ConditionStartsAt,
+			Origin:            s.Origin,
+		})
+	}
+
+	return response.JSON(200, result)
+}
+
+// parseEvalState returns the evaluation state with the name.
+func parseEvalState(name string) (eval.State, error) {
+	for s := eval.Normal; s <= eval.Timeout; s++ {
+		if s.String() == name {
+			return s, nil
+		}
+	}
+	return eval.Normal, fmt.Errorf("unknown state %q", name)
+}
+
+// parseMatcher parses a label matcher, name=value or name=~regex, and compiles its regular expression.
+// The name ends at the first =, so the value can hold any character.
+func parseMatcher(s string) (state.Matcher, error) {
+	i := strings.Index(s, string(state.MatchEqual))
+	if i < 0 {
+		return state.Matcher{}, fmt.Errorf("invalid matcher %q, expected name=value or name=~regex", s)
+	}
+	name := s[:i]
+	if !model.LabelName(name).IsValid() {
+		return state.Matcher{}, fmt.Errorf("invalid label name %q in matcher %q", name, s)
+	}
+	if strings.HasPrefix(s[i:], string(state.MatchRegexp)) {
+		return state.NewMatcher(state.MatchRegexp, name, s[i+len(state.MatchRegexp):])
+	}
+	return state.NewMatcher(state.MatchEqual, name, s[i+len(state.MatchEqual):])
+}
+
+// listAlertStateHistoryEndpoint handles GET /api/alert-instances/history.
+// The state transitions can be filtered by alert definition UID, by state (the one transitioned to)
+// and by time range, from and to being epoch milliseconds.
+func (api *API) listAlertStateHistoryEndpoint(c *models.ReqContext) response.Response {
+	cmd := ngmodels.ListAlertStateHistoryQuery{
+		DefinitionOrgID: c.SignedInUser.OrgId,
+		DefinitionUID:   c.Query("definitionUID"),
+		State:           ngmodels.InstanceStateType(c.Query("state")),
+	}
+	if from := c.QueryInt64("from"); from > 0 {
+		cmd.From = time.Unix(0, from*int64(time.Millisecond))
+	}
+	if to := c.QueryInt64("to"); to > 0 {
+		cmd.To = time.Unix(0, to*int64(time.Millisecond))
+	}
+
+	if err := api.Store.ListAlertStateHistory(&cmd); err != nil {
+		return response.Error(500, "Failed to list alert state history", err)
+	}
 
 	return response.JSON(200, cmd.Result)
 }
@@ -0,0 +1,52 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/services/ngalert/eval"
+	"github.com/grafana/grafana/pkg/services/ngalert/state"
+)
+
+func TestParseMatcher(t *testing.T) {
+	testCases := []struct {
+		input       string
+		expected    state.Matcher
+		expectedErr bool
+	}{
+		{input: "team=a", expected: state.Matcher{Type: state.MatchEqual, Name: "team", Value: "a"}},
+		{input: "team=", expected: state.Matcher{Type: state.MatchEqual, Name: "team", Value: ""}},
+		{input: "team=~a|b", expected: state.Matcher{Type: state.MatchRegexp, Name: "team", Value: "a|b"}},
+		{input: "query=a=b", expected: state.Matcher{Type: state.MatchEqual, Name: "query", Value: "a=b"}},
+		{input: "a=b=~c", expected: state.Matcher{Type: state.MatchEqual, Name: "a", Value: "b=~c"}},
+		{input: "team", expectedErr: true},
+		{input: "=a", expectedErr: true},
+		{input: "team-a=b", expectedErr: true},
+		{input: "1team=a", expectedErr: true},
+		{input: "team=~(a", expectedErr: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.input, func(t *testing.T) {
+			m, err := parseMatcher(tc.input)
+			if tc.expectedErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expected.Type, m.Type)
+			require.Equal(t, tc.expected.Name, m.Name)
+			require.Equal(t, tc.expected.Value, m.Value)
+		})
+	}
+}
+
+func TestParseEvalState(t *testing.T) {
+	s, err := parseEvalState("NoData")
+	require.NoError(t, err)
+	require.Equal(t, eval.NoData, s)
+
+	_, err = parseEvalState("Firing")
+	require.Error(t, err)
+}
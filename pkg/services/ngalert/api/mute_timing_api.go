@@ -0,0 +1,84 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/grafana/grafana/pkg/api/response"
+	"github.com/grafana/grafana/pkg/models"
+	ngmodels "github.com/grafana/grafana/pkg/services/ngalert/models"
+	"github.com/grafana/grafana/pkg/services/ngalert/notifier"
+	"github.com/grafana/grafana/pkg/services/ngalert/store"
+	"github.com/grafana/grafana/pkg/util"
+)
+
+// listMuteTimingsEndpoint handles GET /api/alert-mute-timings.
+func (api *API) listMuteTimingsEndpoint(c *models.ReqContext) response.Response {
+	query := ngmodels.ListMuteTimingsQuery{}
+	if err := api.AlertingStore.ListMuteTimings(&query); err != nil {
+		return response.Error(http.StatusInternalServerError, "Failed to list mute timings", err)
+	}
+	return response.JSON(http.StatusOK, query.Result)
+}
+
+// saveMuteTimingEndpoint handles PUT /api/alert-mute-timings/:name.
+// It creates, or replaces, the mute timing and applies it to the notification policies referencing it.
+func (api *API) saveMuteTimingEndpoint(c *models.ReqContext, cmd ngmodels.SaveMuteTimingCommand) response.Response {
+	cmd.Name = c.Params(":name")
+	if err := cmd.Validate(); err != nil {
+		return response.Error(http.StatusBadRequest, "Invalid mute timing", err)
+	}
+
+	if err := api.AlertingStore.SaveMuteTiming(&cmd); err != nil {
+		return response.Error(http.StatusInternalServerError, "Failed to save mute timing", err)
+	}
+
+	if err := api.applyMuteTimings(); err != nil {
+		return response.Error(http.StatusInternalServerError, "Failed to apply mute timings", err)
+	}
+
+	return response.JSON(http.StatusOK, cmd.Result)
+}
+
+// deleteMuteTimingEndpoint handles DELETE /api/alert-mute-timings/:name.
+// A mute timing referenced by notification policies can't be deleted.
+func (api *API) deleteMuteTimingEndpoint(c *models.ReqContext) response.Response {
+	name := c.Params(":name")
+
+	query := ngmodels.GetLatestAlertmanagerConfigurationQuery{}
+	err := api.AlertingStore.GetLatestAlertmanagerConfiguration(&query)
+	switch {
+	case errors.Is(err, store.ErrNoAlertmanagerConfiguration):
+	case err != nil:
+		return response.Error(http.StatusInternalServerError, "Failed to get Alertmanager configuration", err)
+	default:
+		cfg, err := notifier.Load(query.Result.AlertmanagerConfiguration)
+		if err != nil {
+			return response.Error(http.StatusInternalServerError, "Failed to load Alertmanager configuration", err)
+		}
+		if notifier.MuteTimingReferenced(cfg.AlertmanagerConfig.Route, name) {
+			return response.Error(http.StatusConflict, "Mute timing is referenced by notification policies", nil)
+		}
+	}
+
+	if err := api.AlertingStore.DeleteMuteTiming(&ngmodels.DeleteMuteTimingCommand{Name: name}); err != nil {
+		if errors.Is(err, ngmodels.ErrMuteTimingNotFound) {
+			return response.Error(http.StatusNotFound, "Mute timing not found", err)
+		}
+		return response.Error(http.StatusInternalServerError, "Failed to delete mute timing", err)
+	}
+
+	if err := api.applyMuteTimings(); err != nil {
+		return response.Error(http.StatusInternalServerError, "Failed to apply mute timings", err)
+	}
+
+	return response.JSON(http.StatusOK, util.DynMap{"message": "mute timing deleted"})
+}
+
+// applyMuteTimings reloads the Alertmanager configuration with the mute timings, if there is one.
+func (api *API) applyMuteTimings() error {
+	if err := api.Alertmanager.SyncAndApplyConfigFromDatabase(); err != nil && !errors.Is(err, store.ErrNoAlertmanagerConfiguration) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package api
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+
+	"github.com/grafana/grafana/pkg/api/response"
+	"github.com/grafana/grafana/pkg/models"
+	ngmodels "github.com/grafana/grafana/pkg/services/ngalert/models"
+	"github.com/grafana/grafana/pkg/util"
+)
+
+// maxPreviewEvaluations is the maximum number of evaluations of a preview over a time range.
+const maxPreviewEvaluations = 100
+
+// previewUID is the UID of the alert definitions previewed, which aren't saved.
+const previewUID = "preview"
+
+// evalPreviewEndpoint handles POST /api/alert-definitions/eval-preview.
+// It evaluates an alert definition that isn't saved, at a time or over a time range at its interval,
+// and returns the results of every evaluation with the state transitions they would have produced.
+func (api *API) evalPreviewEndpoint(c *models.ReqContext, cmd ngmodels.EvalPreviewAlertDefinitionCommand) response.Response {
+	alertDefinition := &ngmodels.AlertDefinition{
+		// the preview has no title of its own
+		Title:             previewUID,
+		UID:               previewUID,
+		OrgID:             c.SignedInUser.OrgId,
+		Condition:         cmd.Condition,
+		Data:              cmd.Data,
+		IntervalSeconds:   cmd.IntervalSeconds,
+		NoDataState:       cmd.NoDataState,
+		ExecErrState:      cmd.ExecErrState,
+		For:               cmd.For,
+		EvaluationTimeout: cmd.EvaluationTimeout,
+	}
+	if err := api.Store.ValidateAlertDefinition(alertDefinition, false); err != nil {
+		return response.Error(400, "invalid alert definition", err)
+	}
+	evalCond := ngmodels.Condition{
+		Condition: cmd.Condition,
+		OrgID:     c.SignedInUser.OrgId,
+		Data:      cmd.Data,
+	}
+	if err := api.validateCondition(evalCond, c.SignedInUser, c.SkipCache); err != nil {
+		return response.Error(400, "invalid condition", err)
+	}
+
+	times, err := previewTimes(cmd, timeNow())
+	if err != nil {
+		return response.Error(400, "invalid preview time range", err)
+	}
+
+	evaluations := api.Schedule.PreviewAlertDefinition(c.Req.Context(), alertDefinition, times)
+	result := make([]util.DynMap, 0, len(evaluations))
+	for _, e := range evaluations {
+		frame := e.Results.AsDataFrame()
+		evaluation := util.DynMap{
+			"at":          e.At,
+			"instances":   []*data.Frame{&frame},
+			"transitions": e.Transitions,
+		}
+		if e.Err != nil {
+			evaluation["error"] = e.Err.Error()
+		}
+		result = append(result, evaluation)
+	}
+
+	return response.JSONStreaming(200, util.DynMap{
+		"evaluations": result,
+	})
+}
+
+// previewTimes returns the times of the evaluations of the preview: every interval
+// from From to To if the time range is set, Now otherwise, which defaults to now.
+func previewTimes(cmd ngmodels.EvalPreviewAlertDefinitionCommand, now time.Time) ([]time.Time, error) {
+	if cmd.From.IsZero() && cmd.To.IsZero() {
+		if !cmd.Now.IsZero() {
+			now = cmd.Now
+		}
+		return []time.Time{now}, nil
+	}
+
+	if cmd.From.IsZero() || cmd.To.IsZero() {
+		return nil, fmt.Errorf("both from and to are required for a time range")
+	}
+	if cmd.To.Before(cmd.From) {
+		return nil, fmt.Errorf("from %v is after to %v", cmd.From, cmd.To)
+	}
+	if cmd.IntervalSeconds <= 0 {
+		return nil, fmt.Errorf("an interval is required for a time range")
+	}
+	interval := time.Duration(cmd.IntervalSeconds) * time.Second
+	if count := cmd.To.Sub(cmd.From)/interval + 1; count > maxPreviewEvaluations {
+		return nil, fmt.Errorf("the time range has %d evaluations, more than the maximum of %d", count, maxPreviewEvaluations)
+	}
+
+	var times []time.Time
+	for at := cmd.From; !at.After(cmd.To); at = at.Add(interval) {
+		times = append(times, at)
+	}
+	return times, nil
+}
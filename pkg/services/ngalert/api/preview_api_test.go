@@ -0,0 +1,67 @@
+package api
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	ngmodels "github.com/grafana/grafana/pkg/services/ngalert/models"
+)
+
+func TestPreviewTimes(t *testing.T) {
+	now := time.Date(2021, 4, 1, 12, 0, 0, 0, time.UTC)
+	testCases := []struct {
+		desc          string
+		cmd           ngmodels.EvalPreviewAlertDefinitionCommand
+		expectedTimes []time.Time
+		expectedErr   bool
+	}{
+		{
+			desc:          "now by default",
+			expectedTimes: []time.Time{now},
+		},
+		{
+			desc:          "at the given time",
+			cmd:           ngmodels.EvalPreviewAlertDefinitionCommand{Now: now.Add(-time.Hour)},
+			expectedTimes: []time.Time{now.Add(-time.Hour)},
+		},
+		{
+			desc:          "every interval of the time range",
+			cmd:           ngmodels.EvalPreviewAlertDefinitionCommand{From: now, To: now.Add(150 * time.Second), IntervalSeconds: 60},
+			expectedTimes: []time.Time{now, now.Add(time.Minute), now.Add(2 * time.Minute)},
+		},
+		{
+			desc:        "time range without interval",
+			cmd:         ngmodels.EvalPreviewAlertDefinitionCommand{From: now, To: now.Add(time.Minute)},
+			expectedErr: true,
+		},
+		{
+			desc:        "time range without end",
+			cmd:         ngmodels.EvalPreviewAlertDefinitionCommand{From: now, IntervalSeconds: 60},
+			expectedErr: true,
+		},
+		{
+			desc:        "reversed time range",
+			cmd:         ngmodels.EvalPreviewAlertDefinitionCommand{From: now, To: now.Add(-time.Minute), IntervalSeconds: 60},
+			expectedErr: true,
+		},
+		{
+			desc:        "too many evaluations",
+			cmd:         ngmodels.EvalPreviewAlertDefinitionCommand{From: now, To: now.Add(maxPreviewEvaluations * time.Minute), IntervalSeconds: 60},
+			expectedErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			times, err := previewTimes(tc.cmd, now)
+			if tc.expectedErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedTimes, times)
+		})
+	}
+}
@@ -0,0 +1,118 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+
+	ngmodels "github.com/grafana/grafana/pkg/services/ngalert/models"
+
+	"github.com/grafana/grafana/pkg/api/response"
+	"github.com/grafana/grafana/pkg/components/dashdiffs"
+	"github.com/grafana/grafana/pkg/components/simplejson"
+	"github.com/grafana/grafana/pkg/models"
+)
+
+// listAlertDefinitionVersionsEndpoint handles GET /api/alert-definitions/:alertDefinitionUID/versions.
+func (api *API) listAlertDefinitionVersionsEndpoint(c *models.ReqContext) response.Response {
+	query := ngmodels.ListAlertDefinitionVersionsQuery{
+		UID:   c.Params(":alertDefinitionUID"),
+		OrgID: c.SignedInUser.OrgId,
+	}
+
+	if err := api.Store.ListAlertDefinitionVersions(&query); err != nil {
+		return response.Error(500, "Failed to list alert definition versions", err)
+	}
+
+	return response.JSON(200, query.Result)
+}
+
+// getAlertDefinitionVersionEndpoint handles GET /api/alert-definitions/:alertDefinitionUID/versions/:version.
+func (api *API) getAlertDefinitionVersionEndpoint(c *models.ReqContext) response.Response {
+	query := ngmodels.GetAlertDefinitionVersionQuery{
+		UID:     c.Params(":alertDefinitionUID"),
+		OrgID:   c.SignedInUser.OrgId,
+		Version: c.ParamsInt64(":version"),
+	}
+
+	if err := api.Store.GetAlertDefinitionVersion(&query); err != nil {
+		if errors.Is(err, ngmodels.ErrAlertDefinitionVersionNotFound) {
+			return response.Error(404, "Alert definition version not found", err)
+		}
+		return response.Error(500, "Failed to get alert definition version", err)
+	}
+
+	return response.JSON(200, query.Result)
+}
+
+// alertDefinitionVersionsDiffEndpoint handles GET /api/alert-definitions/:alertDefinitionUID/diff.
+// It returns the diff of the base and new versions in the diffType, as for the dashboard versions:
+// basic (the default) and json are HTML, delta is JSON.
+func (api *API) alertDefinitionVersionsDiffEndpoint(c *models.ReqContext) response.Response {
+	versions := make([]*simplejson.Json, 0, 2)
+	for _, v := range []int64{c.QueryInt64("base"), c.QueryInt64("new")} {
+		query := ngmodels.GetAlertDefinitionVersionQuery{
+			UID:     c.Params(":alertDefinitionUID"),
+			OrgID:   c.SignedInUser.OrgId,
+			Version: v,
+		}
+		if err := api.Store.GetAlertDefinitionVersion(&query); err != nil {
+			if errors.Is(err, ngmodels.ErrAlertDefinitionVersionNotFound) {
+				return response.Error(404, "Alert definition version not found", err)
+			}
+			return response.Error(500, "Failed to get alert definition version", err)
+		}
+
+		data, err := alertDefinitionVersionData(query.Result)
+		if err != nil {
+			return response.Error(500, "Unable to compute diff", err)
+		}
+		versions = append(versions, data)
+	}
+
+	diffType := dashdiffs.ParseDiffType(c.Query("diffType"))
+	result, err := dashdiffs.CalculateJSONDiff(versions[0], versions[1], diffType)
+	if err != nil {
+		if errors.Is(err, dashdiffs.ErrNilDiff) {
+			return response.Error(400, "The alert definition versions are identical", err)
+		}
+		return response.Error(500, "Unable to compute diff", err)
+	}
+
+	if diffType == dashdiffs.DiffDelta {
+		return response.Respond(200, result.Delta).SetHeader("Content-Type", "application/json")
+	}
+
+	return response.Respond(200, result.Delta).SetHeader("Content-Type", "text/html")
+}
+
+// restoreAlertDefinitionVersionEndpoint handles POST /api/alert-definitions/:alertDefinitionUID/restore.
+func (api *API) restoreAlertDefinitionVersionEndpoint(c *models.ReqContext, cmd ngmodels.RestoreAlertDefinitionVersionCommand) response.Response {
+	cmd.UID = c.Params(":alertDefinitionUID")
+	cmd.OrgID = c.SignedInUser.OrgId
+
+	if err := api.Store.RestoreAlertDefinitionVersion(&cmd); err != nil {
+		if errors.Is(err, ngmodels.ErrAlertDefinitionVersionNotFound) {
+			return response.Error(404, "Alert definition version not found", err)
+		}
+		return response.Error(500, "Failed to restore alert definition version", err)
+	}
+
+	return response.JSON(200, cmd.Result)
+}
+
+// alertDefinitionVersionData returns the JSON of the alert definition fields of the version,
+// without the ones identifying the version, that would always differ.
+func alertDefinitionVersionData(version *ngmodels.AlertDefinitionVersion) (*simplejson.Json, error) {
+	b, err := json.Marshal(version)
+	if err != nil {
+		return nil, err
+	}
+	data, err := simplejson.NewJson(b)
+	if err != nil {
+		return nil, err
+	}
+	for _, key := range []string{"id", "alertDefinitionId", "alertDefinitionUid", "parentVersion", "restoredFrom", "version", "created"} {
+		data.Del(key)
+	}
+	return data, nil
+}
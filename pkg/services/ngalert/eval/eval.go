@@ -4,6 +4,7 @@ package eval
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"sort"
 	"time"
@@ -18,12 +19,46 @@ import (
 	"github.com/grafana/grafana/pkg/expr"
 )
 
+// alertingEvaluationTimeout is the timeout of the evaluations whose context has no deadline.
 const alertingEvaluationTimeout = 30 * time.Second
 
+// ServiceIdentityLogin is the login of the service identity alert evaluations run as.
+const ServiceIdentityLogin = "grafana-alerting"
+
+type evaluationIdentityKey struct{}
+
+// EvaluationIdentity identifies the tenant an alert evaluation runs for,
+// so that tenant-aware data sources can scope their queries.
+type EvaluationIdentity struct {
+	OrgID int64
+	Login string
+}
+
+// WithEvaluationIdentity returns a copy of the context carrying the evaluation identity
+// of the organisation and the alerting service identity.
+func WithEvaluationIdentity(ctx context.Context, orgID int64) context.Context {
+	return context.WithValue(ctx, evaluationIdentityKey{}, EvaluationIdentity{OrgID: orgID, Login: ServiceIdentityLogin})
+}
+
+// EvaluationIdentityFromContext returns the evaluation identity carried by the context, if any.
+func EvaluationIdentityFromContext(ctx context.Context) (EvaluationIdentity, bool) {
+	if ctx == nil {
+		return EvaluationIdentity{}, false
+	}
+	identity, ok := ctx.Value(evaluationIdentityKey{}).(EvaluationIdentity)
+	return identity, ok
+}
+
 type Evaluator struct {
 	Cfg *setting.Cfg
 }
 
+// ErrNoData is returned, wrapped, when the queries and expressions of a condition return no frames.
+var ErrNoData = errors.New("no GEL results")
+
+// ErrTimeout is returned, wrapped, when the execution of a condition exceeds the deadline of its context.
+var ErrTimeout = errors.New("alert evaluation timed out")
+
 // invalidEvalResultFormatError is an error for invalid format of the alert definition evaluation results.
 type invalidEvalResultFormatError struct {
 	refID  string
@@ -62,6 +97,8 @@ type Result struct {
 	Instance    data.Labels
 	State       State // Enum
 	EvaluatedAt time.Time
+	// Value is the value of the condition, nil if there is none.
+	Value *float64
 }
 
 // State is an enum of the evaluation State for an alert instance.
@@ -83,10 +120,18 @@ const (
 	// Error is the eval state for an alert rule condition
 	// that evaluated to Error.
 	Error
+
+	// Pending is the state of an alert instance whose condition evaluated to true (Alerting)
+	// for less than the pending period of its alert rule.
+	Pending
+
+	// Timeout is the eval state for an alert rule condition
+	// whose evaluation timed out.
+	Timeout
 )
 
 func (s State) String() string {
-	return [...]string{"Normal", "Alerting", "NoData", "Error"}[s]
+	return [...]string{"Normal", "Alerting", "NoData", "Error", "Pending", "Timeout"}[s]
 }
 
 // AlertExecCtx is the context provided for executing an alert condition.
@@ -110,6 +155,9 @@ func GetQueryDataRequest(ctx AlertExecCtx, c *models.Condition, now time.Time) (
 		},
 		Queries: []backend.DataQuery{},
 	}
+	if identity, ok := EvaluationIdentityFromContext(ctx.Ctx); ok {
+		queryDataReq.PluginContext.User = &backend.User{Login: identity.Login}
+	}
 
 	for i := range c.Data {
 		q := c.Data[i]
@@ -165,9 +213,8 @@ func execute(ctx AlertExecCtx, c *models.Condition, now time.Time, dataService *
 	}
 
 	if len(result.Results) == 0 {
-		err = fmt.Errorf("no GEL results")
-		result.Error = err
-		return &result, err
+		result.Error = ErrNoData
+		return &result, ErrNoData
 	}
 
 	return &result, nil
@@ -210,6 +257,7 @@ func evaluateExecutionResult(results *ExecutionResults, ts time.Time) (Results,
 		r := Result{
 			Instance:    f.Fields[0].Labels,
 			EvaluatedAt: ts,
+			Value:       val,
 		}
 
 		switch {
@@ -263,15 +311,28 @@ func (evalResults Results) AsDataFrame() data.Frame {
 	return *frame
 }
 
+// withDefaultTimeout returns a copy of the context that times out after the default evaluation timeout
+// if it has no deadline. The deadline of the context, if any, is kept even if it's later than the default timeout.
+func withDefaultTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if _, ok := ctx.Deadline(); ok {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, alertingEvaluationTimeout)
+}
+
 // ConditionEval executes conditions and evaluates the result.
-func (e *Evaluator) ConditionEval(condition *models.Condition, now time.Time, dataService *tsdb.Service) (Results, error) {
-	alertCtx, cancelFn := context.WithTimeout(context.Background(), alertingEvaluationTimeout)
+// The execution is cancelled with the context, and times out at its deadline, or after the default timeout if it has none.
+func (e *Evaluator) ConditionEval(ctx context.Context, condition *models.Condition, now time.Time, dataService *tsdb.Service) (Results, error) {
+	alertCtx, cancelFn := withDefaultTimeout(WithEvaluationIdentity(ctx, condition.OrgID))
 	defer cancelFn()
 
 	alertExecCtx := AlertExecCtx{OrgID: condition.OrgID, Ctx: alertCtx, ExpressionsEnabled: e.Cfg.ExpressionsEnabled}
 
 	execResult, err := execute(alertExecCtx, condition, now, dataService)
 	if err != nil {
+		if errors.Is(alertCtx.Err(), context.DeadlineExceeded) {
+			return nil, fmt.Errorf("failed to execute conditions: %w: %s", ErrTimeout, err)
+		}
 		return nil, fmt.Errorf("failed to execute conditions: %w", err)
 	}
 
@@ -0,0 +1,68 @@
+package eval
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana/pkg/services/ngalert/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEvaluationIdentity(t *testing.T) {
+	t.Run("context without evaluation identity", func(t *testing.T) {
+		_, ok := EvaluationIdentityFromContext(context.Background())
+		assert.False(t, ok)
+	})
+
+	t.Run("context with evaluation identity carries the org and the service identity", func(t *testing.T) {
+		identity, ok := EvaluationIdentityFromContext(WithEvaluationIdentity(context.Background(), 42))
+		require.True(t, ok)
+		assert.Equal(t, EvaluationIdentity{OrgID: 42, Login: ServiceIdentityLogin}, identity)
+	})
+
+	t.Run("query data request carries the org and the service identity", func(t *testing.T) {
+		condition := &models.Condition{
+			Condition: "A",
+			OrgID:     42,
+			Data: []models.AlertQuery{
+				{
+					RefID: "A",
+					Model: json.RawMessage(`{
+						"datasource": "__expr__",
+						"type":"math",
+						"expression":"2 + 2 > 1"
+					}`),
+				},
+			},
+		}
+		execCtx := AlertExecCtx{OrgID: condition.OrgID, Ctx: WithEvaluationIdentity(context.Background(), condition.OrgID)}
+		req, err := GetQueryDataRequest(execCtx, condition, time.Now())
+		require.NoError(t, err)
+		assert.Equal(t, int64(42), req.PluginContext.OrgID)
+		require.NotNil(t, req.PluginContext.User)
+		assert.Equal(t, ServiceIdentityLogin, req.PluginContext.User.Login)
+	})
+}
+
+func TestWithDefaultTimeout(t *testing.T) {
+	t.Run("context without deadline times out after the default timeout", func(t *testing.T) {
+		ctx, cancel := withDefaultTimeout(context.Background())
+		defer cancel()
+		deadline, ok := ctx.Deadline()
+		require.True(t, ok)
+		assert.LessOrEqual(t, int64(time.Until(deadline)), int64(alertingEvaluationTimeout))
+	})
+
+	t.Run("context deadline later than the default timeout is kept", func(t *testing.T) {
+		parent, cancelParent := context.WithTimeout(context.Background(), 45*time.Second)
+		defer cancelParent()
+		ctx, cancel := withDefaultTimeout(parent)
+		defer cancel()
+		deadline, ok := ctx.Deadline()
+		require.True(t, ok)
+		assert.Greater(t, int64(time.Until(deadline)), int64(alertingEvaluationTimeout))
+	})
+}
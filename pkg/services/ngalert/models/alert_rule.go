@@ -35,6 +35,8 @@ func (executionErrorState ExecutionErrorState) String() string {
 const (
 	AlertingErrState      ExecutionErrorState = "Alerting"
 	KeepLastStateErrState ExecutionErrorState = "KeepLastState"
+	OKErrState            ExecutionErrorState = "OK"
+	ErrorErrState         ExecutionErrorState = "Error"
 )
 
 // AlertRule is the model for alert rules in unified alerting.
@@ -52,6 +54,40 @@ type AlertRule struct {
 	RuleGroup       string
 	NoDataState     NoDataState
 	ExecErrState    ExecutionErrorState
+	For             time.Duration
+}
+
+// AlertRuleSettings are the evaluation settings of an alert rule
+// that can be shared by all the alert rules of a folder.
+// Zero values mean that the setting is not specified.
+type AlertRuleSettings struct {
+	IntervalSeconds int64
+	For             time.Duration
+	NoDataState     NoDataState
+}
+
+// EffectiveSettings returns the evaluation settings of the alert rule.
+// Settings not specified by the rule are inherited from the folder defaults.
+func (alertRule *AlertRule) EffectiveSettings(folderDefaults AlertRuleSettings) AlertRuleSettings {
+	return AlertRuleSettings{
+		IntervalSeconds: alertRule.IntervalSeconds,
+		For:             alertRule.For,
+		NoDataState:     alertRule.NoDataState,
+	}.withDefaults(folderDefaults)
+}
+
+// withDefaults returns the settings with the ones not specified taken from the defaults.
+func (settings AlertRuleSettings) withDefaults(defaults AlertRuleSettings) AlertRuleSettings {
+	if settings.IntervalSeconds == 0 {
+		settings.IntervalSeconds = defaults.IntervalSeconds
+	}
+	if settings.For == 0 {
+		settings.For = defaults.For
+	}
+	if settings.NoDataState == "" {
+		settings.NoDataState = defaults.NoDataState
+	}
+	return settings
 }
 
 // AlertRuleKey is the alert definition identifier
@@ -100,6 +136,7 @@ type AlertRuleVersion struct {
 	IntervalSeconds int64
 	NoDataState     NoDataState
 	ExecErrState    ExecutionErrorState
+	For             time.Duration
 }
 
 // GetAlertRuleByUIDQuery is the query for retrieving/deleting an alert rule by UID and organisation ID.
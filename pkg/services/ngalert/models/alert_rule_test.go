@@ -0,0 +1,90 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAlertRuleEffectiveSettings(t *testing.T) {
+	folderDefaults := AlertRuleSettings{
+		IntervalSeconds: 120,
+		For:             5 * time.Minute,
+		NoDataState:     OK,
+	}
+
+	testCases := []struct {
+		desc             string
+		alertRule        AlertRule
+		folderDefaults   AlertRuleSettings
+		expectedSettings AlertRuleSettings
+	}{
+		{
+			desc:             "given a rule without settings it inherits the folder defaults",
+			alertRule:        AlertRule{},
+			folderDefaults:   folderDefaults,
+			expectedSettings: folderDefaults,
+		},
+		{
+			desc: "given a rule with all settings it overrides the folder defaults",
+			alertRule: AlertRule{
+				IntervalSeconds: 60,
+				For:             time.Minute,
+				NoDataState:     Alerting,
+			},
+			folderDefaults: folderDefaults,
+			expectedSettings: AlertRuleSettings{
+				IntervalSeconds: 60,
+				For:             time.Minute,
+				NoDataState:     Alerting,
+			},
+		},
+		{
+			desc: "given a rule with some settings it inherits only the missing ones",
+			alertRule: AlertRule{
+				IntervalSeconds: 60,
+			},
+			folderDefaults: folderDefaults,
+			expectedSettings: AlertRuleSettings{
+				IntervalSeconds: 60,
+				For:             5 * time.Minute,
+				NoDataState:     OK,
+			},
+		},
+		{
+			desc: "given a folder without defaults the rule settings are kept",
+			alertRule: AlertRule{
+				NoDataState: KeepLastState,
+			},
+			folderDefaults: AlertRuleSettings{},
+			expectedSettings: AlertRuleSettings{
+				NoDataState: KeepLastState,
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			assert.Equal(t, tc.expectedSettings, tc.alertRule.EffectiveSettings(tc.folderDefaults))
+		})
+	}
+}
+
+func TestAlertDefinitionEffectiveSettings(t *testing.T) {
+	folderDefaults := AlertRuleSettings{
+		IntervalSeconds: 120,
+		For:             5 * time.Minute,
+		NoDataState:     OK,
+	}
+
+	alertDefinition := AlertDefinition{IntervalSeconds: 60}
+	assert.Equal(t, AlertRuleSettings{
+		IntervalSeconds: 60,
+		For:             5 * time.Minute,
+		NoDataState:     OK,
+	}, alertDefinition.EffectiveSettings(folderDefaults))
+
+	alertDefinition = AlertDefinition{}
+	assert.Equal(t, folderDefaults, alertDefinition.EffectiveSettings(folderDefaults))
+}
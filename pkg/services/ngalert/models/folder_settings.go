@@ -0,0 +1,88 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	// ErrAlertFolderSettingsNotFound is an error for a folder without alert settings.
+	ErrAlertFolderSettingsNotFound = errors.New("could not find the alert settings of the folder")
+	// ErrInvalidAlertFolderSettings is an error for invalid alert settings of a folder.
+	ErrInvalidAlertFolderSettings = errors.New("invalid alert settings of the folder")
+)
+
+// AlertFolderSettings are the alert settings of a folder: the defaults of the evaluation settings
+// the alert definitions of the folder don't specify. Zero values mean that the setting is not specified.
+type AlertFolderSettings struct {
+	ID              int64         `xorm:"pk autoincr 'id'" json:"-"`
+	OrgID           int64         `xorm:"org_id" json:"-"`
+	NamespaceUID    string        `xorm:"namespace_uid" json:"namespaceUid"`
+	IntervalSeconds int64         `json:"intervalSeconds"`
+	For             time.Duration `json:"for"`
+	NoDataState     NoDataState   `json:"noDataState"`
+	Updated         time.Time     `json:"updated"`
+}
+
+// TableName returns the name of the table of the alert settings of the folders.
+func (AlertFolderSettings) TableName() string {
+	return "alert_folder_settings"
+}
+
+// Settings returns the evaluation settings the alert settings of the folder default to.
+func (s *AlertFolderSettings) Settings() AlertRuleSettings {
+	return AlertRuleSettings{IntervalSeconds: s.IntervalSeconds, For: s.For, NoDataState: s.NoDataState}
+}
+
+// ListAlertFolderSettingsQuery is the query for listing the alert settings of the folders
+// of the organisation, or of every organisation if OrgID is zero.
+type ListAlertFolderSettingsQuery struct {
+	OrgID int64
+
+	Result []*AlertFolderSettings
+}
+
+// GetAlertFolderSettingsQuery is the query for retrieving the alert settings of a folder.
+type GetAlertFolderSettingsQuery struct {
+	OrgID        int64
+	NamespaceUID string
+
+	Result *AlertFolderSettings
+}
+
+// SaveAlertFolderSettingsCommand is the command for creating, or replacing, the alert settings of a folder.
+type SaveAlertFolderSettingsCommand struct {
+	OrgID           int64         `json:"-"`
+	NamespaceUID    string        `json:"-"`
+	IntervalSeconds int64         `json:"intervalSeconds"`
+	For             time.Duration `json:"for"`
+	NoDataState     NoDataState   `json:"noDataState"`
+
+	Result *AlertFolderSettings
+}
+
+// Validate returns an error wrapping ErrInvalidAlertFolderSettings if the alert settings are invalid.
+func (cmd *SaveAlertFolderSettingsCommand) Validate() error {
+	if cmd.NamespaceUID == "" {
+		return fmt.Errorf("%w: no folder is set", ErrInvalidAlertFolderSettings)
+	}
+	if cmd.IntervalSeconds < 0 {
+		return fmt.Errorf("%w: interval %d should not be negative", ErrInvalidAlertFolderSettings, cmd.IntervalSeconds)
+	}
+	if cmd.For < 0 {
+		return fmt.Errorf("%w: for duration %v should not be negative", ErrInvalidAlertFolderSettings, cmd.For)
+	}
+	switch cmd.NoDataState {
+	case "", NoData, OK, Alerting, KeepLastState:
+	default:
+		return fmt.Errorf("%w: no data state %q", ErrInvalidAlertFolderSettings, cmd.NoDataState)
+	}
+	return nil
+}
+
+// DeleteAlertFolderSettingsCommand is the command for deleting the alert settings of a folder.
+type DeleteAlertFolderSettingsCommand struct {
+	OrgID        int64
+	NamespaceUID string
+}
@@ -0,0 +1,38 @@
+package models
+
+import (
+	"time"
+)
+
+// AlertStateHistoryEntry is a state transition of an alert instance, in the alert state history.
+type AlertStateHistoryEntry struct {
+	ID              int64             `xorm:"pk autoincr 'id'" json:"id"`
+	DefinitionOrgID int64             `xorm:"def_org_id" json:"definitionOrgId"`
+	DefinitionUID   string            `xorm:"def_uid" json:"definitionUid"`
+	Labels          InstanceLabels    `json:"labels"`
+	LabelsHash      string            `json:"labelsHash"`
+	PrevState       InstanceStateType `json:"prevState"`
+	State           InstanceStateType `json:"state"`
+	At              time.Time         `json:"at"`
+	// Origin is the ID of the Grafana instance that produced the state transition.
+	Origin string `json:"origin,omitempty"`
+}
+
+// SaveAlertStateHistoryCommand is the command for appending state transitions to the alert state history.
+type SaveAlertStateHistoryCommand struct {
+	Entries []AlertStateHistoryEntry
+}
+
+// ListAlertStateHistoryQuery is the query for listing the alert state history of an organisation,
+// oldest first. The zero values of the filters match all the entries.
+type ListAlertStateHistoryQuery struct {
+	DefinitionOrgID int64
+	DefinitionUID   string
+	// From and To bound the time of the state transitions, inclusively.
+	From time.Time
+	To   time.Time
+	// State matches the entries transitioning to the state.
+	State InstanceStateType
+
+	Result []*AlertStateHistoryEntry
+}
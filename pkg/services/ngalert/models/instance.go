@@ -15,8 +15,26 @@ type AlertInstance struct {
 	CurrentStateSince time.Time
 	CurrentStateEnd   time.Time
 	LastEvalTime      time.Time
+	FormatVersion     int
+	// ConditionStart is when the condition of the alert instance started to evaluate to true;
+	// it's zero while the condition evaluates to false.
+	ConditionStart time.Time
+	// Origin is the ID of the Grafana instance that produced the alert instance state.
+	Origin string
 }
 
+// InstanceFormatVersion is the version of the format alert instances are persisted with.
+const (
+	// InstanceFormatVersionUnversioned is for the alert instances persisted before they were versioned;
+	// they may lack the current state end.
+	InstanceFormatVersionUnversioned = 0
+	// InstanceFormatVersionStateEnd is for the alert instances persisted with their current state end.
+	InstanceFormatVersionStateEnd = 1
+
+	// InstanceFormatVersionCurrent is the format version new alert instances are persisted with.
+	InstanceFormatVersionCurrent = InstanceFormatVersionStateEnd
+)
+
 // InstanceStateType is an enum for instance states.
 type InstanceStateType string
 
@@ -25,13 +43,25 @@ const (
 	InstanceStateFiring InstanceStateType = "Alerting"
 	// InstanceStateNormal is for a normal alert.
 	InstanceStateNormal InstanceStateType = "Normal"
+	// InstanceStateNoData is for an alert whose condition has no data.
+	InstanceStateNoData InstanceStateType = "NoData"
+	// InstanceStateError is for an alert whose condition failed to evaluate.
+	InstanceStateError InstanceStateType = "Error"
+	// InstanceStatePending is for an alert waiting for the pending period of its alert definition.
+	InstanceStatePending InstanceStateType = "Pending"
+	// InstanceStateTimeout is for an alert whose condition evaluation timed out.
+	InstanceStateTimeout InstanceStateType = "Timeout"
 )
 
 // IsValid checks that the value of InstanceStateType is a valid
 // string.
 func (i InstanceStateType) IsValid() bool {
 	return i == InstanceStateFiring ||
-		i == InstanceStateNormal
+		i == InstanceStateNormal ||
+		i == InstanceStateNoData ||
+		i == InstanceStateError ||
+		i == InstanceStatePending ||
+		i == InstanceStateTimeout
 }
 
 // SaveAlertInstanceCommand is the query for saving a new alert instance.
@@ -43,6 +73,20 @@ type SaveAlertInstanceCommand struct {
 	LastEvalTime      time.Time
 	CurrentStateSince time.Time
 	CurrentStateEnd   time.Time
+	ConditionStart    time.Time
+	Origin            string
+}
+
+// SaveAlertInstancesCommand is the command for saving alert instances at once.
+type SaveAlertInstancesCommand struct {
+	Instances []SaveAlertInstanceCommand
+}
+
+// DeleteAlertInstancesCommand is the command for deleting the alert instances of an alert definition by their labels.
+type DeleteAlertInstancesCommand struct {
+	DefinitionOrgID int64
+	DefinitionUID   string
+	Labels          []InstanceLabels
 }
 
 // GetAlertInstanceQuery is the query for retrieving/deleting an alert definition by ID.
@@ -60,6 +104,12 @@ type ListAlertInstancesQuery struct {
 	DefinitionOrgID int64 `json:"-"`
 	DefinitionUID   string
 	State           InstanceStateType
+	// Limit, if set, is the maximum number of alert instances to return, ordered by definition UID
+	// and labels hash, starting after the one of AfterDefinitionUID and AfterLabelsHash, if set.
+	// It's meant for listing the alert instances by pages.
+	Limit              int
+	AfterDefinitionUID string
+	AfterLabelsHash    string
 
 	Result []*ListAlertInstancesQueryResult
 }
@@ -79,6 +129,9 @@ type ListAlertInstancesQueryResult struct {
 	CurrentStateSince time.Time         `json:"currentStateSince"`
 	CurrentStateEnd   time.Time         `json:"currentStateEnd"`
 	LastEvalTime      time.Time         `json:"lastEvalTime"`
+	FormatVersion     int               `json:"-"`
+	ConditionStart    time.Time         `json:"conditionStart"`
+	Origin            string            `json:"origin"`
 }
 
 type FetchUniqueOrgIdsQueryResult struct {
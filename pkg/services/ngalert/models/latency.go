@@ -0,0 +1,35 @@
+package models
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrAlertDefinitionLatencyNotFound is an error for unknown alert definition evaluation latency stats.
+var ErrAlertDefinitionLatencyNotFound = errors.New("could not find alert definition latency")
+
+// AlertDefinitionLatency are the evaluation latency stats of an alert definition,
+// computed over its recent evaluations.
+type AlertDefinitionLatency struct {
+	DefinitionOrgID int64
+	DefinitionUID   string
+	P50             time.Duration
+	P95             time.Duration
+	Max             time.Duration
+	// Samples is the number of evaluations the stats are computed over.
+	Samples int
+	Updated time.Time
+}
+
+// SaveAlertDefinitionLatencyCommand is the command for saving the evaluation latency stats of an alert definition.
+type SaveAlertDefinitionLatencyCommand struct {
+	Latency AlertDefinitionLatency
+}
+
+// GetAlertDefinitionLatencyQuery is the query for retrieving the evaluation latency stats of an alert definition.
+type GetAlertDefinitionLatencyQuery struct {
+	DefinitionOrgID int64
+	DefinitionUID   string
+
+	Result *AlertDefinitionLatency
+}
@@ -11,21 +11,33 @@ var (
 	ErrAlertDefinitionNotFound = fmt.Errorf("could not find alert definition")
 	// ErrAlertDefinitionFailedGenerateUniqueUID is an error for failure to generate alert definition UID
 	ErrAlertDefinitionFailedGenerateUniqueUID = errors.New("failed to generate alert definition UID")
+	// ErrAlertDefinitionVersionNotFound is an error for an unknown alert definition version.
+	ErrAlertDefinitionVersionNotFound = errors.New("could not find alert definition version")
 )
 
 // AlertDefinition is the model for alert definitions in Alerting NG.
 // Legacy model; It will be removed in v8
 type AlertDefinition struct {
-	ID              int64        `xorm:"pk autoincr 'id'" json:"id"`
-	OrgID           int64        `xorm:"org_id" json:"orgId"`
-	Title           string       `json:"title"`
-	Condition       string       `json:"condition"`
-	Data            []AlertQuery `json:"data"`
-	Updated         time.Time    `json:"updated"`
-	IntervalSeconds int64        `json:"intervalSeconds"`
-	Version         int64        `json:"version"`
-	UID             string       `xorm:"uid" json:"uid"`
-	Paused          bool         `json:"paused"`
+	ID                int64               `xorm:"pk autoincr 'id'" json:"id"`
+	OrgID             int64               `xorm:"org_id" json:"orgId"`
+	Title             string              `json:"title"`
+	Condition         string              `json:"condition"`
+	Data              []AlertQuery        `json:"data"`
+	Updated           time.Time           `json:"updated"`
+	IntervalSeconds   int64               `json:"intervalSeconds"`
+	Version           int64               `json:"version"`
+	UID               string              `xorm:"uid" json:"uid"`
+	Paused            bool                `json:"paused"`
+	NoDataState       NoDataState         `json:"noDataState"`
+	ExecErrState      ExecutionErrorState `json:"execErrState"`
+	For               time.Duration       `json:"for"`
+	EvaluationTimeout time.Duration       `json:"evaluationTimeout"`
+	Record            RecordingRule       `xorm:"'record' json" json:"record"`
+	// MaxInstances is the maximum number of alert instances of the alert definition;
+	// zero applies the default limit of the alert instances of a rule.
+	MaxInstances int64 `json:"maxInstances"`
+	// NamespaceUID is the UID of the folder of the alert definition, whose alert settings it inherits.
+	NamespaceUID string `xorm:"namespace_uid" json:"namespaceUid"`
 }
 
 // AlertDefinitionKey is the alert definition identifier
@@ -43,6 +55,16 @@ func (alertDefinition *AlertDefinition) GetKey() AlertDefinitionKey {
 	return AlertDefinitionKey{OrgID: alertDefinition.OrgID, DefinitionUID: alertDefinition.UID}
 }
 
+// EffectiveSettings returns the evaluation settings of the alert definition.
+// Settings not specified by the alert definition are inherited from the folder defaults.
+func (alertDefinition *AlertDefinition) EffectiveSettings(folderDefaults AlertRuleSettings) AlertRuleSettings {
+	return AlertRuleSettings{
+		IntervalSeconds: alertDefinition.IntervalSeconds,
+		For:             alertDefinition.For,
+		NoDataState:     alertDefinition.NoDataState,
+	}.withDefaults(folderDefaults)
+}
+
 // PreSave sets datasource and loads the updated model for each alert query.
 func (alertDefinition *AlertDefinition) PreSave(timeNow func() time.Time) error {
 	for i, q := range alertDefinition.Data {
@@ -59,18 +81,24 @@ func (alertDefinition *AlertDefinition) PreSave(timeNow func() time.Time) error
 // AlertDefinitionVersion is the model for alert definition versions in Alerting NG.
 // Legacy model; It will be removed in v8
 type AlertDefinitionVersion struct {
-	ID                 int64  `xorm:"pk autoincr 'id'"`
-	AlertDefinitionID  int64  `xorm:"alert_definition_id"`
-	AlertDefinitionUID string `xorm:"alert_definition_uid"`
-	ParentVersion      int64
-	RestoredFrom       int64
-	Version            int64
-
-	Created         time.Time
-	Title           string
-	Condition       string
-	Data            []AlertQuery
-	IntervalSeconds int64
+	ID                 int64  `xorm:"pk autoincr 'id'" json:"id"`
+	AlertDefinitionID  int64  `xorm:"alert_definition_id" json:"alertDefinitionId"`
+	AlertDefinitionUID string `xorm:"alert_definition_uid" json:"alertDefinitionUid"`
+	ParentVersion      int64  `json:"parentVersion"`
+	RestoredFrom       int64  `json:"restoredFrom"`
+	Version            int64  `json:"version"`
+
+	Created           time.Time           `json:"created"`
+	Title             string              `json:"title"`
+	Condition         string              `json:"condition"`
+	Data              []AlertQuery        `json:"data"`
+	IntervalSeconds   int64               `json:"intervalSeconds"`
+	NoDataState       NoDataState         `json:"noDataState"`
+	ExecErrState      ExecutionErrorState `json:"execErrState"`
+	For               time.Duration       `json:"for"`
+	EvaluationTimeout time.Duration       `json:"evaluationTimeout"`
+	Record            RecordingRule       `xorm:"'record' json" json:"record"`
+	MaxInstances      int64               `json:"maxInstances"`
 }
 
 // GetAlertDefinitionByUIDQuery is the query for retrieving/deleting an alert definition by UID and organisation ID.
@@ -92,11 +120,20 @@ type DeleteAlertDefinitionByUIDCommand struct {
 // SaveAlertDefinitionCommand is the query for saving a new alert definition.
 // Legacy model; It will be removed in v8
 type SaveAlertDefinitionCommand struct {
-	Title           string       `json:"title"`
-	OrgID           int64        `json:"-"`
-	Condition       string       `json:"condition"`
-	Data            []AlertQuery `json:"data"`
-	IntervalSeconds *int64       `json:"intervalSeconds"`
+	Title             string              `json:"title"`
+	OrgID             int64               `json:"-"`
+	Condition         string              `json:"condition"`
+	Data              []AlertQuery        `json:"data"`
+	IntervalSeconds   *int64              `json:"intervalSeconds"`
+	NoDataState       NoDataState         `json:"noDataState"`
+	ExecErrState      ExecutionErrorState `json:"execErrState"`
+	For               time.Duration       `json:"for"`
+	EvaluationTimeout time.Duration       `json:"evaluationTimeout"`
+	Record            *RecordingRule      `json:"record"`
+	MaxInstances      int64               `json:"maxInstances"`
+	// NamespaceUID is the UID of the folder of the alert definition. Its interval
+	// is inherited from the alert settings of the folder if it's not set.
+	NamespaceUID string `json:"namespaceUid"`
 
 	Result *AlertDefinition
 }
@@ -104,12 +141,48 @@ type SaveAlertDefinitionCommand struct {
 // UpdateAlertDefinitionCommand is the query for updating an existing alert definition.
 // Legacy model; It will be removed in v8
 type UpdateAlertDefinitionCommand struct {
-	Title           string       `json:"title"`
-	OrgID           int64        `json:"-"`
-	Condition       string       `json:"condition"`
-	Data            []AlertQuery `json:"data"`
-	IntervalSeconds *int64       `json:"intervalSeconds"`
-	UID             string       `json:"-"`
+	Title             string              `json:"title"`
+	OrgID             int64               `json:"-"`
+	Condition         string              `json:"condition"`
+	Data              []AlertQuery        `json:"data"`
+	IntervalSeconds   *int64              `json:"intervalSeconds"`
+	NoDataState       NoDataState         `json:"noDataState"`
+	ExecErrState      ExecutionErrorState `json:"execErrState"`
+	For               *time.Duration      `json:"for"`
+	UID               string              `json:"-"`
+	EvaluationTimeout *time.Duration      `json:"evaluationTimeout"`
+	Record            *RecordingRule      `json:"record"`
+	MaxInstances      *int64              `json:"maxInstances"`
+	NamespaceUID      *string             `json:"namespaceUid"`
+
+	Result *AlertDefinition
+}
+
+// ListAlertDefinitionVersionsQuery is the query for listing the versions of an alert definition, latest first.
+// Legacy model; It will be removed in v8
+type ListAlertDefinitionVersionsQuery struct {
+	UID   string
+	OrgID int64
+
+	Result []*AlertDefinitionVersion
+}
+
+// GetAlertDefinitionVersionQuery is the query for retrieving a version of an alert definition.
+// Legacy model; It will be removed in v8
+type GetAlertDefinitionVersionQuery struct {
+	UID     string
+	OrgID   int64
+	Version int64
+
+	Result *AlertDefinitionVersion
+}
+
+// RestoreAlertDefinitionVersionCommand is the command for restoring an alert definition to one of its versions.
+// Legacy model; It will be removed in v8
+type RestoreAlertDefinitionVersionCommand struct {
+	UID     string `json:"-"`
+	OrgID   int64  `json:"-"`
+	Version int64  `json:"version"`
 
 	Result *AlertDefinition
 }
@@ -123,11 +196,26 @@ type ListAlertDefinitionsQuery struct {
 }
 
 // UpdateAlertDefinitionPausedCommand is the command for updating an alert definitions
+// The alert definitions are the ones of the UIDs, of the folder and querying the datasource, filtered by all the selectors that are set.
 // Legacy model; It will be removed in v8
 type UpdateAlertDefinitionPausedCommand struct {
-	OrgID  int64    `json:"-"`
-	UIDs   []string `json:"uids"`
-	Paused bool     `json:"-"`
+	OrgID         int64    `json:"-"`
+	UIDs          []string `json:"uids"`
+	NamespaceUID  string   `json:"namespaceUid"`
+	DatasourceUID string   `json:"datasourceUid"`
+	Paused        bool     `json:"-"`
+
+	ResultCount int64
+}
+
+// DeleteAlertDefinitionsCommand is the command for deleting alert definitions at once.
+// The alert definitions are the ones of the UIDs, of the folder and querying the datasource, filtered by all the selectors that are set.
+// Legacy model; It will be removed in v8
+type DeleteAlertDefinitionsCommand struct {
+	OrgID         int64    `json:"-"`
+	UIDs          []string `json:"uids"`
+	NamespaceUID  string   `json:"namespaceUid"`
+	DatasourceUID string   `json:"datasourceUid"`
 
 	ResultCount int64
 }
@@ -139,3 +227,19 @@ type EvalAlertConditionCommand struct {
 	Data      []AlertQuery `json:"data"`
 	Now       time.Time    `json:"now"`
 }
+
+// EvalPreviewAlertDefinitionCommand is the command for previewing the evaluations of an alert definition
+// that isn't saved: at Now, or at every interval from From to To.
+// Legacy model; It will be removed in v8
+type EvalPreviewAlertDefinitionCommand struct {
+	Condition         string              `json:"condition"`
+	Data              []AlertQuery        `json:"data"`
+	IntervalSeconds   int64               `json:"intervalSeconds"`
+	NoDataState       NoDataState         `json:"noDataState"`
+	ExecErrState      ExecutionErrorState `json:"execErrState"`
+	For               time.Duration       `json:"for"`
+	EvaluationTimeout time.Duration       `json:"evaluationTimeout"`
+	Now               time.Time           `json:"now"`
+	From              time.Time           `json:"from"`
+	To                time.Time           `json:"to"`
+}
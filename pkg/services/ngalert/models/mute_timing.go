@@ -0,0 +1,62 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/prometheus/alertmanager/timeinterval"
+)
+
+// ErrMuteTimingNotFound is an error for an unknown mute timing.
+var ErrMuteTimingNotFound = errors.New("could not find mute timing")
+
+// MuteTiming is a named set of time intervals during which the notifications of the notification policies
+// referencing it, by name in their mute_time_intervals, are muted. The time intervals are the ones of the
+// Alertmanager mute time intervals, such as weekends, nights or holidays, in the time zone of the mute timing.
+type MuteTiming struct {
+	ID            int64                       `xorm:"pk autoincr 'id'" json:"-"`
+	Name          string                      `json:"name"`
+	TimeIntervals []timeinterval.TimeInterval `xorm:"'time_intervals' json" json:"time_intervals"`
+	// Location is the name of the time zone of the time intervals, from the IANA time zone database; UTC if it's empty.
+	Location string    `json:"location"`
+	Updated  time.Time `json:"updated"`
+}
+
+// TableName returns the name of the table of the mute timings.
+func (t MuteTiming) TableName() string {
+	return "alert_mute_timing"
+}
+
+// ListMuteTimingsQuery is the query for listing the mute timings, by name.
+type ListMuteTimingsQuery struct {
+	Result []*MuteTiming
+}
+
+// SaveMuteTimingCommand is the command for creating, or replacing, the mute timing with the name.
+type SaveMuteTimingCommand struct {
+	Name          string                      `json:"-"`
+	TimeIntervals []timeinterval.TimeInterval `json:"time_intervals"`
+	Location      string                      `json:"location"`
+
+	Result *MuteTiming
+}
+
+// DeleteMuteTimingCommand is the command for deleting the mute timing with the name.
+type DeleteMuteTimingCommand struct {
+	Name string
+}
+
+// Validate checks that the mute timing has a name, time intervals and a known time zone.
+func (cmd *SaveMuteTimingCommand) Validate() error {
+	if cmd.Name == "" {
+		return fmt.Errorf("the mute timing has no name")
+	}
+	if len(cmd.TimeIntervals) == 0 {
+		return fmt.Errorf("the mute timing %q has no time intervals", cmd.Name)
+	}
+	if _, err := time.LoadLocation(cmd.Location); err != nil {
+		return fmt.Errorf("invalid location of the mute timing %q: %w", cmd.Name, err)
+	}
+	return nil
+}
@@ -0,0 +1,15 @@
+package models
+
+// RecordingRule makes an alert definition a recording rule: instead of producing alert instances,
+// the values of its condition are written to the target datasource as series of the metric,
+// with the labels of the instances. The zero value is for the alert definitions that aren't recording rules.
+// Legacy model; It will be removed in v8
+type RecordingRule struct {
+	Metric              string `json:"metric"`
+	TargetDatasourceUID string `json:"targetDatasourceUid"`
+}
+
+// IsRecording returns true if the alert definition is a recording rule.
+func (alertDefinition *AlertDefinition) IsRecording() bool {
+	return alertDefinition.Record != RecordingRule{}
+}
@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// SchedulerMember is a Grafana instance running the scheduler, sharing the evaluation of the alert definitions
+// with the other members.
+type SchedulerMember struct {
+	InstanceID    string
+	LastHeartbeat time.Time
+}
+
+// SaveSchedulerMemberCommand is the command for registering a scheduler member or renewing its membership.
+type SaveSchedulerMemberCommand struct {
+	Member SchedulerMember
+}
+
+// ListSchedulerMembersQuery is the query for listing the scheduler members whose last heartbeat is not before Since.
+type ListSchedulerMembersQuery struct {
+	Since time.Time
+
+	Result []*SchedulerMember
+}
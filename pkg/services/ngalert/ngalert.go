@@ -13,6 +13,7 @@ import (
 	"github.com/grafana/grafana/pkg/registry"
 	"github.com/grafana/grafana/pkg/services/datasourceproxy"
 	"github.com/grafana/grafana/pkg/services/datasources"
+	"github.com/grafana/grafana/pkg/services/live"
 	"github.com/grafana/grafana/pkg/services/ngalert/api"
 	"github.com/grafana/grafana/pkg/services/ngalert/eval"
 	"github.com/grafana/grafana/pkg/services/ngalert/notifier"
@@ -45,6 +46,7 @@ type AlertNG struct {
 	DataService     *tsdb.Service                           `inject:""`
 	Alertmanager    *notifier.Alertmanager                  `inject:""`
 	DataProxy       *datasourceproxy.DatasourceProxyService `inject:""`
+	Live            *live.GrafanaLive                       `inject:""`
 	Log             log.Logger
 	schedule        schedule.ScheduleService
 	stateTracker    *state.StateTracker
@@ -57,19 +59,57 @@ func init() {
 // Init initializes the AlertingService.
 func (ng *AlertNG) Init() error {
 	ng.Log = log.New("ngalert")
-	ng.stateTracker = state.NewStateTracker(ng.Log)
+	c := clock.New()
+	ng.stateTracker = state.NewStateTrackerWithClock(ng.Log, c)
+	ng.stateTracker.SetInstanceID(setting.InstanceName)
+	ng.stateTracker.SetInstanceLimit(setting.AlertingMaxInstancesPerRule)
+	ng.stateTracker.SetMinWriteInterval(setting.AlertingStateMinWriteInterval)
+	ng.stateTracker.SetStaleSeries(state.StaleSeries{MissedEvaluations: setting.AlertingStaleMissedEvaluations})
 	baseInterval := baseIntervalSeconds * time.Second
 
 	store := store.DBstore{BaseInterval: baseInterval, DefaultIntervalSeconds: defaultIntervalSeconds, SQLStore: ng.SQLStore}
+	historySink := state.NewHistorySink(store, ng.Log)
+	if setting.AlertingStateWebhookURL == "" {
+		ng.stateTracker.SetAuditSink(historySink)
+	} else {
+		webhookSink, err := state.NewWebhookSink(state.WebhookConfig{
+			URL:         setting.AlertingStateWebhookURL,
+			Template:    setting.AlertingStateWebhookTemplate,
+			Secret:      setting.AlertingStateWebhookSecret,
+			MaxAttempts: setting.AlertingStateWebhookMaxAttempts,
+		}, ng.Log)
+		if err != nil {
+			return err
+		}
+		ng.stateTracker.SetAuditSink(state.NewMultiAuditSink(historySink, webhookSink))
+	}
+	ng.stateTracker.SetAuditBatching(true)
 
 	schedCfg := schedule.SchedulerCfg{
-		C:            clock.New(),
+		C:            c,
 		BaseInterval: baseInterval,
 		Logger:       ng.Log,
 		MaxAttempts:  maxAttempts,
-		Evaluator:    eval.Evaluator{Cfg: ng.Cfg},
+		Evaluator:    &eval.Evaluator{Cfg: ng.Cfg},
 		Store:        store,
 		Notifier:     ng.Alertmanager,
+
+		DatasourceCache:   ng.DatasourceCache,
+		EvaluationJitter:  setting.UnifiedAlertingEvaluationJitter,
+		EvaluationTimeout: setting.UnifiedAlertingEvaluationTimeout,
+		StateCacheWarming: &schedule.StateCacheWarming{
+			PageSize:    setting.AlertingStateCacheWarmingPageSize,
+			Parallelism: setting.AlertingStateCacheWarmingParallelism,
+		},
+		WaitForStateCache:  true,
+		SeriesWriter:       schedule.NewRemoteWriter(ng.DatasourceCache),
+		StateFlushInterval: setting.AlertingStateFlushInterval,
+	}
+	if ng.Live != nil && ng.Live.IsEnabled() {
+		schedCfg.LivePublisher = ng.Live
+	}
+	if ng.Cfg.FeatureToggles["ngalertSharding"] {
+		schedCfg.Sharding = &schedule.Sharding{InstanceID: setting.InstanceName}
 	}
 	ng.schedule = schedule.NewScheduler(schedCfg, ng.DataService)
 
@@ -84,6 +124,7 @@ func (ng *AlertNG) Init() error {
 		RuleStore:       store,
 		AlertingStore:   store,
 		Alertmanager:    ng.Alertmanager,
+		StateTracker:    ng.stateTracker,
 	}
 	api.RegisterAPIEndpoints()
 
@@ -93,7 +134,8 @@ func (ng *AlertNG) Init() error {
 // Run starts the scheduler
 func (ng *AlertNG) Run(ctx context.Context) error {
 	ng.Log.Debug("ngalert starting")
-	ng.schedule.WarmStateCache(ng.stateTracker)
+	// the ticker waits for the cache to be warmed
+	go ng.schedule.WarmStateCache(ng.stateTracker)
 	return ng.schedule.Ticker(ctx, ng.stateTracker)
 }
 
@@ -115,6 +157,14 @@ func (ng *AlertNG) AddMigration(mg *migrator.Migrator) {
 	store.AddAlertDefinitionVersionMigrations(mg)
 	// Create alert_instance table
 	store.AlertInstanceMigration(mg)
+	// Create alert_folder_settings table
+	store.AlertFolderSettingsMigration(mg)
+	// Create alert_definition_latency table
+	store.AlertDefinitionLatencyMigration(mg)
+	// Create alert_state_history table
+	store.AlertStateHistoryMigration(mg)
+	// Create alert_scheduler_member table
+	store.AlertSchedulerMemberMigration(mg)
 
 	// Create alert_rule
 	store.AddAlertRuleMigrations(mg, defaultIntervalSeconds)
@@ -3,6 +3,7 @@ package notifier
 import (
 	"context"
 	"fmt"
+	"os"
 	"path/filepath"
 	"sync"
 	"time"
@@ -14,6 +15,7 @@ import (
 	gokit_log "github.com/go-kit/kit/log"
 	"github.com/grafana/alerting-api/pkg/api"
 	"github.com/pkg/errors"
+	"github.com/prometheus/alertmanager/config"
 	"github.com/prometheus/alertmanager/dispatch"
 	"github.com/prometheus/alertmanager/nflog"
 	"github.com/prometheus/alertmanager/nflog/nflogpb"
@@ -37,6 +39,9 @@ const (
 	workingDir = "alerting"
 	// How long should we keep silences and notification entries on-disk after they've served their purpose.
 	retentionNotificationsAndSilences = 5 * 24 * time.Hour
+	// How often should we garbage collect the expired silences and snapshot the silences on-disk.
+	maintenanceSilences = 15 * time.Minute
+	silencesFilename    = "silences"
 )
 
 type Alertmanager struct {
@@ -88,7 +93,7 @@ func (am *Alertmanager) Init() (err error) {
 		return errors.Wrap(err, "unable to initialize the notification log component of alerting")
 	}
 	am.silences, err = silence.New(silence.Options{
-		SnapshotFile: filepath.Join(am.WorkingDirPath(), "silences"),
+		SnapshotFile: filepath.Join(am.WorkingDirPath(), silencesFilename),
 		Retention:    retentionNotificationsAndSilences,
 	})
 	if err != nil {
@@ -109,10 +114,15 @@ func (am *Alertmanager) Run(ctx context.Context) error {
 		return err
 	}
 
+	silencesStopc := make(chan struct{})
+	silencesDone := am.maintainSilences(maintenanceSilences, silencesStopc)
+
 	for {
 		select {
 		case <-ctx.Done():
 			am.StopAndWait()
+			close(silencesStopc)
+			<-silencesDone
 			return nil
 		case <-time.After(1 * time.Minute):
 			// TODO: once we have a check to skip reload on same config, uncomment this.
@@ -126,9 +136,26 @@ func (am *Alertmanager) Run(ctx context.Context) error {
 	}
 }
 
+// maintainSilences garbage collects the expired silences and snapshots the silences on-disk every interval,
+// and once more when stopc is closed, so that they survive restarts. The returned channel is closed once it's done.
+func (am *Alertmanager) maintainSilences(interval time.Duration, stopc <-chan struct{}) <-chan struct{} {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if err := os.MkdirAll(am.WorkingDirPath(), 0750); err != nil {
+			am.logger.Error("unable to create the working directory, the silences won't be saved", "err", err)
+			<-stopc
+			return
+		}
+		am.silences.Maintenance(interval, filepath.Join(am.WorkingDirPath(), silencesFilename), stopc)
+	}()
+	return done
+}
+
 // AddMigration runs the database migrations as the service starts.
 func (am *Alertmanager) AddMigration(mg *migrator.Migrator) {
 	alertmanagerConfigurationMigration(mg)
+	muteTimingMigration(mg)
 }
 
 func (am *Alertmanager) StopAndWait() {
@@ -191,13 +218,29 @@ func (am *Alertmanager) applyConfig(cfg *api.PostableUserConfig) error {
 	if err != nil {
 		return err
 	}
+	// The notification policies can only be muted by the existing mute timings.
+	timingsQuery := &ngmodels.ListMuteTimingsQuery{}
+	if err := am.Store.ListMuteTimings(timingsQuery); err != nil {
+		return errors.Wrap(err, "list mute timings")
+	}
+	timeMutingStage, err := newTimeMuteStage(timingsQuery.Result)
+	if err != nil {
+		return err
+	}
+	if err := timeMutingStage.validateMuteTimeIntervals(cfg.AlertmanagerConfig.Route); err != nil {
+		return err
+	}
+	if err := validateRouteReceivers(cfg.AlertmanagerConfig.Route, integrationsMap); err != nil {
+		return err
+	}
+
 	// Now, let's put together our notification pipeline
 	routingStage := make(notify.RoutingStage, len(integrationsMap))
 
 	silencingStage := notify.NewMuteStage(silence.NewSilencer(am.silences, am.marker, gokit_log.NewNopLogger()))
 	for name := range integrationsMap {
 		stage := am.createReceiverStage(name, integrationsMap[name], waitFunc, am.notificationLog)
-		routingStage[name] = notify.MultiStage{silencingStage, stage}
+		routingStage[name] = notify.MultiStage{timeMutingStage, silencingStage, stage}
 	}
 
 	am.alerts.SetStage(routingStage)
@@ -234,6 +277,33 @@ func (am *Alertmanager) buildIntegrationsMap(receivers []*api.PostableApiReceive
 	return integrationsMap, nil
 }
 
+// validateRouteReceivers checks that the root route has a receiver, the default one,
+// and that the receivers of the route and its child routes are configured.
+func validateRouteReceivers(route *config.Route, integrationsMap map[string][]notify.Integration) error {
+	if route == nil {
+		return errors.New("configuration must specify a root route")
+	}
+	if route.Receiver == "" {
+		return errors.New("root route must specify a default receiver")
+	}
+	return checkRouteReceivers(route, integrationsMap)
+}
+
+func checkRouteReceivers(route *config.Route, integrationsMap map[string][]notify.Integration) error {
+	// the child routes without a receiver inherit the one of their parent
+	if route.Receiver != "" {
+		if _, ok := integrationsMap[route.Receiver]; !ok {
+			return fmt.Errorf("undefined receiver %q used in route", route.Receiver)
+		}
+	}
+	for _, r := range route.Routes {
+		if err := checkRouteReceivers(r, integrationsMap); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // buildReceiverIntegrations builds a list of integration notifiers off of a receiver config.
 func (am *Alertmanager) buildReceiverIntegrations(receiver *api.PostableApiReceiver, _ *template.Template) ([]notify.Integration, error) {
 	var integrations []notify.Integration
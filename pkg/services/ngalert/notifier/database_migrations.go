@@ -15,3 +15,22 @@ func alertmanagerConfigurationMigration(mg *migrator.Migrator) {
 
 	mg.AddMigration("create_alert_configuration_table", migrator.NewAddTableMigration(alertConfiguration))
 }
+
+func muteTimingMigration(mg *migrator.Migrator) {
+	muteTiming := migrator.Table{
+		Name: "alert_mute_timing",
+		Columns: []*migrator.Column{
+			{Name: "id", Type: migrator.DB_BigInt, IsPrimaryKey: true, IsAutoIncrement: true},
+			{Name: "name", Type: migrator.DB_NVarchar, Length: 190, Nullable: false},
+			{Name: "time_intervals", Type: migrator.DB_Text, Nullable: false},
+			{Name: "location", Type: migrator.DB_NVarchar, Length: 40, Nullable: false},
+			{Name: "updated", Type: migrator.DB_DateTime, Nullable: false},
+		},
+		Indices: []*migrator.Index{
+			{Cols: []string{"name"}, Type: migrator.UniqueIndex},
+		},
+	}
+
+	mg.AddMigration("create_alert_mute_timing_table", migrator.NewAddTableMigration(muteTiming))
+	mg.AddMigration("add unique index in alert_mute_timing on name", migrator.NewAddIndexMigration(muteTiming, muteTiming.Indices[0]))
+}
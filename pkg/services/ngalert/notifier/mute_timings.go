@@ -0,0 +1,111 @@
+package notifier
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	gokit_log "github.com/go-kit/kit/log"
+	"github.com/prometheus/alertmanager/config"
+	"github.com/prometheus/alertmanager/notify"
+	"github.com/prometheus/alertmanager/timeinterval"
+	"github.com/prometheus/alertmanager/types"
+
+	ngmodels "github.com/grafana/grafana/pkg/services/ngalert/models"
+)
+
+// muteTiming is a mute timing with its time zone loaded.
+type muteTiming struct {
+	intervals []timeinterval.TimeInterval
+	location  *time.Location
+}
+
+// timeMuteStage mutes the notifications of the routes during the time intervals of their mute timings,
+// in the time zone of each mute timing. The muted alerts aren't notified, the still firing ones are
+// notified again on the next flush of their group that is out of the time intervals.
+type timeMuteStage struct {
+	timings map[string]muteTiming
+}
+
+// newTimeMuteStage returns the timeMuteStage of the mute timings;
+// it fails if the time zone of one of them is unknown.
+func newTimeMuteStage(timings []*ngmodels.MuteTiming) (*timeMuteStage, error) {
+	stage := &timeMuteStage{timings: make(map[string]muteTiming, len(timings))}
+	for _, t := range timings {
+		loc, err := time.LoadLocation(t.Location)
+		if err != nil {
+			return nil, fmt.Errorf("invalid location of the mute timing %q: %w", t.Name, err)
+		}
+		stage.timings[t.Name] = muteTiming{intervals: t.TimeIntervals, location: loc}
+	}
+	return stage, nil
+}
+
+// Exec implements the notify.Stage interface.
+func (tms *timeMuteStage) Exec(ctx context.Context, l gokit_log.Logger, alerts ...*types.Alert) (context.Context, []*types.Alert, error) {
+	names, ok := notify.MuteTimeIntervalNames(ctx)
+	if !ok {
+		return ctx, alerts, nil
+	}
+	now, ok := notify.Now(ctx)
+	if !ok {
+		return ctx, alerts, fmt.Errorf("missing now timestamp")
+	}
+	if tms.muted(names, now) {
+		return ctx, nil, nil
+	}
+	return ctx, alerts, nil
+}
+
+// muted returns whether the time is in one of the time intervals of the named mute timings.
+func (tms *timeMuteStage) muted(names []string, now time.Time) bool {
+	for _, name := range names {
+		timing, ok := tms.timings[name]
+		if !ok {
+			continue
+		}
+		local := now.In(timing.location)
+		for _, interval := range timing.intervals {
+			if interval.ContainsTime(local) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// validateMuteTimeIntervals checks that the mute timings referenced by the route and its child routes exist.
+func (tms *timeMuteStage) validateMuteTimeIntervals(route *config.Route) error {
+	if route == nil {
+		return nil
+	}
+	for _, name := range route.MuteTimeIntervals {
+		if _, ok := tms.timings[name]; !ok {
+			return fmt.Errorf("undefined mute timing %q referenced by the notification policy of the receiver %q", name, route.Receiver)
+		}
+	}
+	for _, r := range route.Routes {
+		if err := tms.validateMuteTimeIntervals(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MuteTimingReferenced returns whether the route or one of its child routes references the mute timing.
+func MuteTimingReferenced(route *config.Route, name string) bool {
+	if route == nil {
+		return false
+	}
+	for _, n := range route.MuteTimeIntervals {
+		if n == name {
+			return true
+		}
+	}
+	for _, r := range route.Routes {
+		if MuteTimingReferenced(r, name) {
+			return true
+		}
+	}
+	return false
+}
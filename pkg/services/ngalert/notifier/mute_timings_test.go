@@ -0,0 +1,116 @@
+package notifier
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	gokit_log "github.com/go-kit/kit/log"
+	"github.com/prometheus/alertmanager/config"
+	"github.com/prometheus/alertmanager/notify"
+	"github.com/prometheus/alertmanager/timeinterval"
+	"github.com/prometheus/alertmanager/types"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+
+	ngmodels "github.com/grafana/grafana/pkg/services/ngalert/models"
+)
+
+func TestTimeMuteStage(t *testing.T) {
+	var nights []timeinterval.TimeInterval
+	require.NoError(t, yaml.Unmarshal([]byte(`
+- times:
+  - start_time: "22:00"
+    end_time: "24:00"
+  - start_time: "00:00"
+    end_time: "06:00"
+- weekdays: ["saturday", "sunday"]
+`), &nights))
+
+	stage, err := newTimeMuteStage([]*ngmodels.MuteTiming{
+		{Name: "nights-and-weekends", TimeIntervals: nights, Location: "Europe/Paris"},
+	})
+	require.NoError(t, err)
+
+	paris, err := time.LoadLocation("Europe/Paris")
+	require.NoError(t, err)
+	alerts := []*types.Alert{{}}
+
+	testCases := []struct {
+		desc  string
+		names []string
+		now   time.Time
+		muted bool
+	}{
+		{
+			desc:  "notification policy without mute timings",
+			now:   time.Date(2021, 4, 7, 23, 0, 0, 0, paris),
+			muted: false,
+		},
+		{
+			desc:  "night in the time zone of the mute timing",
+			names: []string{"nights-and-weekends"},
+			// it's 21:30 in UTC
+			now:   time.Date(2021, 4, 7, 23, 30, 0, 0, paris),
+			muted: true,
+		},
+		{
+			desc:  "day in the time zone of the mute timing",
+			names: []string{"nights-and-weekends"},
+			// it's 04:30 in UTC
+			now:   time.Date(2021, 4, 7, 6, 30, 0, 0, paris),
+			muted: false,
+		},
+		{
+			desc:  "weekend",
+			names: []string{"nights-and-weekends"},
+			now:   time.Date(2021, 4, 10, 14, 0, 0, 0, paris),
+			muted: true,
+		},
+		{
+			desc:  "unknown mute timing",
+			names: []string{"holidays"},
+			now:   time.Date(2021, 4, 7, 23, 30, 0, 0, paris),
+			muted: false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			ctx := notify.WithNow(context.Background(), tc.now.UTC())
+			if tc.names != nil {
+				ctx = notify.WithMuteTimeIntervals(ctx, tc.names)
+			}
+			_, res, err := stage.Exec(ctx, gokit_log.NewNopLogger(), alerts...)
+			require.NoError(t, err)
+			if tc.muted {
+				require.Empty(t, res)
+			} else {
+				require.Equal(t, alerts, res)
+			}
+		})
+	}
+}
+
+func TestTimeMuteStageValidation(t *testing.T) {
+	_, err := newTimeMuteStage([]*ngmodels.MuteTiming{{Name: "nights", Location: "Mars/Olympus_Mons"}})
+	require.Error(t, err)
+
+	stage, err := newTimeMuteStage([]*ngmodels.MuteTiming{{Name: "nights"}})
+	require.NoError(t, err)
+
+	route := &config.Route{
+		Receiver: "default",
+		Routes: []*config.Route{
+			{Receiver: "team-a", MuteTimeIntervals: []string{"nights"}},
+			{Receiver: "team-b", Routes: []*config.Route{{Receiver: "team-b-oncall", MuteTimeIntervals: []string{"holidays"}}}},
+		},
+	}
+	require.EqualError(t, stage.validateMuteTimeIntervals(route), `undefined mute timing "holidays" referenced by the notification policy of the receiver "team-b-oncall"`)
+	require.True(t, MuteTimingReferenced(route, "nights"))
+	require.True(t, MuteTimingReferenced(route, "holidays"))
+	require.False(t, MuteTimingReferenced(route, "weekends"))
+
+	route.Routes = route.Routes[:1]
+	require.NoError(t, stage.validateMuteTimeIntervals(route))
+}
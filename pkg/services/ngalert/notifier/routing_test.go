@@ -0,0 +1,158 @@
+package notifier
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	gokit_log "github.com/go-kit/kit/log"
+	"github.com/prometheus/alertmanager/api/v2/models"
+	"github.com/prometheus/alertmanager/dispatch"
+	"github.com/prometheus/alertmanager/notify"
+	"github.com/prometheus/alertmanager/types"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+
+	apimodels "github.com/grafana/alerting-api/pkg/api"
+	ngmodels "github.com/grafana/grafana/pkg/services/ngalert/models"
+	"github.com/grafana/grafana/pkg/services/ngalert/store"
+	"github.com/grafana/grafana/pkg/setting"
+)
+
+// recordingStage records the groups of alerts notified to each receiver.
+type recordingStage struct {
+	mu     sync.Mutex
+	groups map[string][]string
+}
+
+func (s *recordingStage) Exec(ctx context.Context, _ gokit_log.Logger, alerts ...*types.Alert) (context.Context, []*types.Alert, error) {
+	receiver, _ := notify.ReceiverName(ctx)
+	names := make([]string, 0, len(alerts))
+	for _, a := range alerts {
+		names = append(names, string(a.Labels["instance"]))
+	}
+	sort.Strings(names)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.groups[receiver] = append(s.groups[receiver], strings.Join(names, ","))
+	return ctx, alerts, nil
+}
+
+func (s *recordingStage) notified() map[string][]string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	groups := make(map[string][]string, len(s.groups))
+	for receiver, g := range s.groups {
+		groups[receiver] = append([]string(nil), g...)
+		sort.Strings(groups[receiver])
+	}
+	return groups
+}
+
+func TestRoutingTree(t *testing.T) {
+	cfg, err := Load(`
+alertmanager_config:
+  route:
+    receiver: default
+    group_by: ["alertname"]
+    group_wait: 10ms
+    group_interval: 1h
+    repeat_interval: 1h
+    routes:
+    - receiver: team-a
+      group_by: ["alertname", "cluster"]
+      matchers: ["team=\"a\""]
+    - receiver: team-b
+      match_re:
+        team: "b|c"
+  receivers:
+  - name: default
+  - name: team-a
+  - name: team-b
+`)
+	require.NoError(t, err)
+
+	marker := types.NewMarker(prometheus.NewRegistry())
+	provider, err := NewAlertProvider(nil, marker)
+	require.NoError(t, err)
+	stage := &recordingStage{groups: map[string][]string{}}
+	routingStage := notify.RoutingStage{"default": stage, "team-a": stage, "team-b": stage}
+	route := dispatch.NewRoute(cfg.AlertmanagerConfig.Route, nil)
+	dispatcher := dispatch.NewDispatcher(provider, route, routingStage, marker, timeoutFunc, gokit_log.NewNopLogger(), dispatch.NewDispatcherMetrics(prometheus.NewRegistry()))
+	go dispatcher.Run()
+	t.Cleanup(dispatcher.Stop)
+
+	alert := func(instance string, labels models.LabelSet) *PostableAlert {
+		labels["instance"] = instance
+		return &PostableAlert{PostableAlert: models.PostableAlert{Alert: models.Alert{Labels: labels}}}
+	}
+	require.NoError(t, provider.PutPostableAlert(
+		alert("a1", models.LabelSet{"alertname": "HighLatency", "team": "a", "cluster": "eu"}),
+		alert("a2", models.LabelSet{"alertname": "HighLatency", "team": "a", "cluster": "eu"}),
+		alert("a3", models.LabelSet{"alertname": "HighLatency", "team": "a", "cluster": "us"}),
+		alert("b1", models.LabelSet{"alertname": "HighLatency", "team": "b"}),
+		alert("c1", models.LabelSet{"alertname": "HighLatency", "team": "c"}),
+		alert("d1", models.LabelSet{"alertname": "HighLatency", "team": "d"}),
+		alert("d2", models.LabelSet{"alertname": "DiskFull", "team": "d"}),
+	))
+
+	expected := map[string][]string{
+		"team-a":  {"a1,a2", "a3"},
+		"team-b":  {"b1,c1"},
+		"default": {"d1", "d2"},
+	}
+	require.Eventually(t, func() bool {
+		return len(stage.notified()["team-a"]) == 2 && len(stage.notified()["team-b"]) == 1 && len(stage.notified()["default"]) == 2
+	}, time.Second, 10*time.Millisecond)
+	require.Equal(t, expected, stage.notified())
+}
+
+// fakeMuteTimingStore is an alerting store without mute timings.
+type fakeMuteTimingStore struct {
+	store.AlertingStore
+}
+
+func (fakeMuteTimingStore) ListMuteTimings(query *ngmodels.ListMuteTimingsQuery) error {
+	query.Result = nil
+	return nil
+}
+
+func TestApplyConfigValidatesRouteReceivers(t *testing.T) {
+	am := &Alertmanager{Settings: &setting.Cfg{DataPath: t.TempDir()}}
+	require.NoError(t, am.Init())
+	am.Store = fakeMuteTimingStore{}
+
+	load := func(route string) *apimodels.PostableUserConfig {
+		cfg, err := Load(`
+alertmanager_config:
+  route:
+` + route + `
+  receivers:
+  - name: default
+`)
+		require.NoError(t, err)
+		return cfg
+	}
+
+	err := am.ApplyConfig(load(`
+    receiver: default
+    routes:
+    - matchers: ["team=\"a\""]
+      routes:
+      - receiver: team-a`))
+	require.EqualError(t, err, `undefined receiver "team-a" used in route`)
+
+	err = am.ApplyConfig(load(`
+    routes:
+    - receiver: default`))
+	require.EqualError(t, err, "root route must specify a default receiver")
+
+	err = am.ApplyConfig(load(`
+    receiver: default
+    routes:
+    - matchers: ["team=\"a\""]`))
+	require.NoError(t, err)
+}
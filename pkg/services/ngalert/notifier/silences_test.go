@@ -0,0 +1,45 @@
+package notifier
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-openapi/strfmt"
+	apimodels "github.com/grafana/alerting-api/pkg/api"
+	amv2 "github.com/prometheus/alertmanager/api/v2/models"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/setting"
+)
+
+func TestSilencesSurviveRestarts(t *testing.T) {
+	cfg := &setting.Cfg{DataPath: t.TempDir()}
+	am := &Alertmanager{Settings: cfg}
+	require.NoError(t, am.Init())
+
+	startsAt, endsAt := strfmt.DateTime(time.Now()), strfmt.DateTime(time.Now().Add(time.Hour))
+	comment, createdBy := "maintenance", "admin"
+	name, value, isRegex := "env", "staging.*", true
+	silenceID, err := am.CreateSilence(&apimodels.PostableSilence{Silence: amv2.Silence{
+		Comment:   &comment,
+		CreatedBy: &createdBy,
+		StartsAt:  &startsAt,
+		EndsAt:    &endsAt,
+		Matchers:  amv2.Matchers{{Name: &name, Value: &value, IsRegex: &isRegex}},
+	}})
+	require.NoError(t, err)
+
+	// the silences are snapshotted once more when the maintenance stops
+	stopc := make(chan struct{})
+	done := am.maintainSilences(time.Hour, stopc)
+	close(stopc)
+	<-done
+
+	restarted := &Alertmanager{Settings: cfg}
+	require.NoError(t, restarted.Init())
+	silence, err := restarted.GetSilence(silenceID)
+	require.NoError(t, err)
+	require.Equal(t, comment, *silence.Comment)
+	require.Len(t, silence.Matchers, 1)
+	require.Equal(t, value, *silence.Matchers[0].Value)
+}
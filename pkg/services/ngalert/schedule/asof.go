@@ -0,0 +1,33 @@
+package schedule
+
+import (
+	"context"
+	"time"
+
+	"github.com/grafana/grafana/pkg/services/ngalert/eval"
+	"github.com/grafana/grafana/pkg/services/ngalert/models"
+	"github.com/grafana/grafana/pkg/services/ngalert/state"
+)
+
+// EvaluateAsOf evaluates the latest version of the alert definition as of the given time rather than now,
+// for reproducible alerting windows. It returns the results and the alert states the alert definition
+// would have had at that time, from scratch; the live states are untouched, and nothing is saved or sent.
+func (sch *schedule) EvaluateAsOf(key models.AlertDefinitionKey, at time.Time) (eval.Results, []state.AlertState, error) {
+	q := models.GetAlertDefinitionByUIDQuery{OrgID: key.OrgID, UID: key.DefinitionUID}
+	if err := sch.store.GetAlertDefinitionByUID(&q); err != nil {
+		return nil, nil, err
+	}
+
+	condition := models.Condition{
+		Condition: q.Result.Condition,
+		OrgID:     q.Result.OrgID,
+		Data:      q.Result.Data,
+	}
+	ctx, cancel := sch.withEvaluationTimeout(context.Background(), q.Result)
+	defer cancel()
+	results, err := sch.conditionEval(ctx, key, &condition, at)
+	if err != nil {
+		return nil, nil, err
+	}
+	return results, state.PreviewStates(key.DefinitionUID, results, condition, sch.log), nil
+}
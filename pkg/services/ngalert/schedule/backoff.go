@@ -0,0 +1,72 @@
+package schedule
+
+import (
+	"time"
+
+	"github.com/grafana/grafana/pkg/services/ngalert/models"
+)
+
+// ErrorBackoff configures the throttling of failing alert definitions: after an evaluation
+// fails, the alert definition is not evaluated for Initial, and the delay doubles after every
+// consecutive failure, up to Max. A successful evaluation clears the backoff.
+type ErrorBackoff struct {
+	Initial time.Duration
+	Max     time.Duration
+}
+
+// backoff holds the error backoff state of an alert definition
+type backoff struct {
+	failures int
+	until    time.Time
+}
+
+// applyBackoff updates the backoff of the alert definition given the outcome of its evaluation at now
+func (sch *schedule) applyBackoff(key models.AlertDefinitionKey, now time.Time, evalErr error) {
+	if sch.errorBackoff == nil {
+		return
+	}
+	var applied, cleared bool
+	var until time.Time
+	sch.registry.updateBackoff(key, func(b *backoff) {
+		if evalErr == nil {
+			cleared = b.failures > 0
+			*b = backoff{}
+			return
+		}
+		delay := sch.errorBackoff.Initial
+		for i := 0; i < b.failures && delay < sch.errorBackoff.Max; i++ {
+			delay *= 2
+		}
+		if delay > sch.errorBackoff.Max {
+			delay = sch.errorBackoff.Max
+		}
+		b.failures++
+		b.until = now.Add(delay)
+		applied, until = true, b.until
+	})
+
+	switch {
+	case applied:
+		sch.log.Debug("alert definition evaluation failed, backing off", "key", key, "until", until)
+		sch.backoffApplied(key, until)
+	case cleared:
+		sch.log.Debug("alert definition evaluation succeeded, clearing backoff", "key", key)
+		sch.backoffCleared(key)
+	}
+}
+
+func (sch *schedule) backoffApplied(alertDefKey models.AlertDefinitionKey, until time.Time) {
+	if sch.backoffAppliedFunc == nil {
+		return
+	}
+
+	sch.backoffAppliedFunc(alertDefKey, until)
+}
+
+func (sch *schedule) backoffCleared(alertDefKey models.AlertDefinitionKey) {
+	if sch.backoffClearedFunc == nil {
+		return
+	}
+
+	sch.backoffClearedFunc(alertDefKey)
+}
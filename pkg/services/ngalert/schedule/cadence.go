@@ -0,0 +1,70 @@
+package schedule
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/grafana/grafana/pkg/services/ngalert/eval"
+	"github.com/grafana/grafana/pkg/services/ngalert/models"
+)
+
+// AdaptiveCadence configures the adaptive evaluation interval of alert definitions:
+// after StableEvaluations consecutive evaluations with unchanged results the effective
+// interval is doubled, up to MaxIntervalMultiplier times the alert definition interval.
+// A change in the results restores the alert definition interval.
+type AdaptiveCadence struct {
+	StableEvaluations     int
+	MaxIntervalMultiplier int64
+}
+
+// cadence holds the adaptive cadence state of an alert definition
+type cadence struct {
+	multiplier  int64
+	stableCount int
+	lastResults string
+	evaluated   bool
+}
+
+// adaptCadence updates the effective interval of the alert definition given its latest results
+func (sch *schedule) adaptCadence(key models.AlertDefinitionKey, results eval.Results) {
+	if sch.adaptiveCadence == nil {
+		return
+	}
+	fingerprint := resultsFingerprint(results)
+	sch.registry.updateCadence(key, func(c *cadence) {
+		if c.multiplier < 1 {
+			c.multiplier = 1
+		}
+		if !c.evaluated || c.lastResults != fingerprint {
+			if c.multiplier > 1 {
+				sch.log.Debug("alert definition results changed, restoring interval", "key", key)
+			}
+			c.evaluated = true
+			c.lastResults = fingerprint
+			c.multiplier = 1
+			c.stableCount = 0
+			return
+		}
+
+		c.stableCount++
+		if c.stableCount < sch.adaptiveCadence.StableEvaluations || c.multiplier >= sch.adaptiveCadence.MaxIntervalMultiplier {
+			return
+		}
+		c.stableCount = 0
+		c.multiplier *= 2
+		if c.multiplier > sch.adaptiveCadence.MaxIntervalMultiplier {
+			c.multiplier = sch.adaptiveCadence.MaxIntervalMultiplier
+		}
+		sch.log.Debug("alert definition results are stable, widening interval", "key", key, "multiplier", c.multiplier)
+	})
+}
+
+// resultsFingerprint returns a string that identifies the instances and states of the results
+func resultsFingerprint(results eval.Results) string {
+	instances := make([]string, 0, len(results))
+	for _, r := range results {
+		instances = append(instances, r.Instance.String()+"="+r.State.String())
+	}
+	sort.Strings(instances)
+	return strings.Join(instances, ";")
+}
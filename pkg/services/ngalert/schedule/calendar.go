@@ -0,0 +1,95 @@
+package schedule
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/grafana/grafana/pkg/services/ngalert/models"
+)
+
+// TimeInterval is a window of the week, such as the business hours.
+type TimeInterval struct {
+	// Weekdays are the days of the week the interval applies to; all of them if empty.
+	Weekdays []time.Weekday
+	// Start and End are the times of the day the interval starts and ends at, as offsets from midnight.
+	// End is excluded.
+	Start time.Duration
+	End   time.Duration
+}
+
+// Calendar is a set of time intervals the alert definitions attached to it are evaluated within.
+type Calendar struct {
+	Intervals []TimeInterval
+	// Location is the time zone of the intervals; UTC if it's nil.
+	Location *time.Location
+}
+
+// Contains returns true if the time falls within one of the time intervals of the calendar.
+func (c Calendar) Contains(t time.Time) bool {
+	loc := c.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+	t = t.In(loc)
+	year, month, day := t.Date()
+	sinceMidnight := t.Sub(time.Date(year, month, day, 0, 0, 0, 0, loc))
+	for _, interval := range c.Intervals {
+		if !interval.onWeekday(t.Weekday()) {
+			continue
+		}
+		if sinceMidnight >= interval.Start && sinceMidnight < interval.End {
+			return true
+		}
+	}
+	return false
+}
+
+func (i TimeInterval) onWeekday(weekday time.Weekday) bool {
+	if len(i.Weekdays) == 0 {
+		return true
+	}
+	for _, d := range i.Weekdays {
+		if d == weekday {
+			return true
+		}
+	}
+	return false
+}
+
+type calendars struct {
+	// byName holds the calendars of the scheduler configuration; it's read only
+	byName map[string]Calendar
+
+	mu       sync.Mutex
+	attached map[models.AlertDefinitionKey]string
+}
+
+// AttachCalendar restricts the evaluation of the alert definition to the time intervals
+// of the calendar with the given name, from the scheduler configuration; outside of them
+// the alert definition is not evaluated at all. An empty name detaches the calendar.
+// The attachment is kept in memory only.
+func (sch *schedule) AttachCalendar(key models.AlertDefinitionKey, name string) error {
+	sch.calendars.mu.Lock()
+	defer sch.calendars.mu.Unlock()
+	if name == "" {
+		delete(sch.calendars.attached, key)
+		return nil
+	}
+	if _, ok := sch.calendars.byName[name]; !ok {
+		return fmt.Errorf("unknown calendar %q", name)
+	}
+	sch.calendars.attached[key] = name
+	return nil
+}
+
+// inCalendar returns false only if the alert definition is attached to a calendar that doesn't contain the time.
+func (sch *schedule) inCalendar(key models.AlertDefinitionKey, t time.Time) bool {
+	sch.calendars.mu.Lock()
+	name, ok := sch.calendars.attached[key]
+	sch.calendars.mu.Unlock()
+	if !ok {
+		return true
+	}
+	return sch.calendars.byName[name].Contains(t)
+}
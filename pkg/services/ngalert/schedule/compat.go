@@ -8,21 +8,49 @@ import (
 	"github.com/prometheus/alertmanager/api/v2/models"
 )
 
+const (
+	// AlertNameLabel is the label of the alerts sent to the notifier that holds the title of their alert definition,
+	// as the Alertmanager expects for grouping them by alert rule.
+	AlertNameLabel = "alertname"
+	// AlertRuleUIDLabel is the label of the alerts sent to the notifier that holds the UID of their alert definition.
+	AlertRuleUIDLabel = "__alert_rule_uid__"
+)
+
+// FromAlertStateToPostableAlerts returns the alerts of the firing states, labelled with the UID of their
+// alert definition in addition to the labels of their alert instance, for the notification policies to route them.
 func FromAlertStateToPostableAlerts(firingStates []state.AlertState) []*notifier.PostableAlert {
 	alerts := make([]*notifier.PostableAlert, 0, len(firingStates))
 	for _, alertState := range firingStates {
 		if alertState.State == eval.Alerting {
-			alerts = append(alerts, &notifier.PostableAlert{
-				PostableAlert: models.PostableAlert{
-					Annotations: models.LabelSet{}, //TODO: add annotations to evaluation results, add them to the alertState struct, and then set them before sending to the notifier
-					StartsAt:    strfmt.DateTime(alertState.StartsAt),
-					EndsAt:      strfmt.DateTime(alertState.EndsAt),
-					Alert: models.Alert{
-						Labels: models.LabelSet(alertState.Labels),
-					},
-				},
-			})
+			alerts = append(alerts, postableAlert(alertState))
 		}
 	}
 	return alerts
 }
+
+// postableAlert returns the alert of the alert state, labelled with the UID of its alert definition.
+func postableAlert(alertState state.AlertState) *notifier.PostableAlert {
+	lbs := make(models.LabelSet, len(alertState.Labels)+1)
+	for k, v := range alertState.Labels {
+		lbs[k] = v
+	}
+	lbs[AlertRuleUIDLabel] = alertState.UID
+	return &notifier.PostableAlert{
+		PostableAlert: models.PostableAlert{
+			Annotations: models.LabelSet{}, //TODO: add annotations to evaluation results, add them to the alertState struct, and then set them before sending to the notifier
+			StartsAt:    strfmt.DateTime(alertState.StartsAt),
+			EndsAt:      strfmt.DateTime(alertState.EndsAt),
+			Alert: models.Alert{
+				Labels: lbs,
+			},
+		},
+	}
+}
+
+// withAlertName labels the alerts with the title of their alert definition.
+func withAlertName(alerts []*notifier.PostableAlert, title string) []*notifier.PostableAlert {
+	for _, a := range alerts {
+		a.Labels[AlertNameLabel] = title
+	}
+	return alerts
+}
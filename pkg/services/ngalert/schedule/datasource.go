@@ -0,0 +1,68 @@
+package schedule
+
+import (
+	"errors"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+
+	apimodels "github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/services/ngalert/eval"
+	"github.com/grafana/grafana/pkg/services/ngalert/models"
+)
+
+// MissingDatasourcePolicy is how the scheduler handles the alert definitions
+// whose queries reference datasources that don't exist, for instance because they were deleted.
+type MissingDatasourcePolicy int
+
+const (
+	// MissingDatasourceEvaluate keeps evaluating these alert definitions, which fail.
+	MissingDatasourceEvaluate MissingDatasourcePolicy = iota
+	// MissingDatasourcePause skips the evaluation of these alert definitions.
+	MissingDatasourcePause
+	// MissingDatasourceDegrade skips the evaluation of these alert definitions too
+	// but records an error result for them instead, without querying.
+	MissingDatasourceDegrade
+)
+
+// referencesMissingDatasource returns true if the alert definition queries reference a datasource
+// that doesn't exist and the missing datasource policy applies. The alert definitions are checked
+// whenever they are due, so they are evaluated again as soon as their datasources are back.
+// The changes between missing and available datasources are logged.
+func (sch *schedule) referencesMissingDatasource(alertDefinition *models.AlertDefinition) bool {
+	if sch.missingDatasourcePolicy == MissingDatasourceEvaluate || sch.datasourceCache == nil {
+		return false
+	}
+
+	key := alertDefinition.GetKey()
+	missing := ""
+	for i := range alertDefinition.Data {
+		q := &alertDefinition.Data[i]
+		if isExpr, err := q.IsExpression(); err != nil || isExpr {
+			continue
+		}
+		uid, err := q.GetDatasource()
+		if err != nil {
+			continue
+		}
+		_, err = sch.datasourceCache.GetDatasourceByUID(uid, &apimodels.SignedInUser{OrgId: key.OrgID}, false)
+		if errors.Is(err, apimodels.ErrDataSourceNotFound) {
+			missing = uid
+			break
+		}
+	}
+
+	if sch.registry.setDatasourceMissing(key, missing != "") {
+		if missing != "" {
+			sch.log.Warn("alert definition references a missing datasource, it won't be evaluated until it's back", "key", key, "datasourceUid", missing)
+		} else {
+			sch.log.Info("alert definition datasources are back, resuming its evaluation", "key", key)
+		}
+	}
+	return missing != ""
+}
+
+// errorResults are the results of the alert definitions that errored without results of their own.
+func errorResults(now time.Time) eval.Results {
+	return eval.Results{{Instance: data.Labels{}, State: eval.Error, EvaluatedAt: now}}
+}
@@ -0,0 +1,87 @@
+package schedule
+
+import (
+	"sync"
+	"time"
+
+	"github.com/grafana/grafana/pkg/services/ngalert/models"
+)
+
+// SkipReason is the reason an alert definition was not evaluated on a tick.
+type SkipReason string
+
+const (
+	// SkipReasonPaused is for alert definitions that are paused.
+	SkipReasonPaused SkipReason = "paused"
+	// SkipReasonSchedulerPaused is for all alert definitions while the scheduler evaluation is paused.
+	SkipReasonSchedulerPaused SkipReason = "scheduler-paused"
+	// SkipReasonOrgDisabled is for alert definitions of organisations alerting is not enabled for.
+	SkipReasonOrgDisabled SkipReason = "org-disabled"
+	// SkipReasonNotDue is for alert definitions whose interval does not match the tick.
+	SkipReasonNotDue SkipReason = "not-due"
+	// SkipReasonMaintenance is for alert definitions attached to a calendar that doesn't contain the tick:
+	// the times out of the calendar, such as a maintenance window or out of business hours, are not evaluated.
+	SkipReasonMaintenance SkipReason = "maintenance"
+	// SkipReasonBackoff is for failing alert definitions throttled by the error backoff.
+	SkipReasonBackoff SkipReason = "backoff"
+	// SkipReasonDatasourceMissing is for alert definitions referencing missing datasources.
+	SkipReasonDatasourceMissing SkipReason = "datasource-missing"
+	// SkipReasonOverlap is for alert definitions whose previous evaluation was still running.
+	SkipReasonOverlap SkipReason = "overlap-skipped"
+	// SkipReasonNoSubscribers is for alert definitions that require subscribers but have none.
+	SkipReasonNoSubscribers SkipReason = "no-subscribers"
+	// SkipReasonInvalidInterval is for alert definitions whose interval is not a multiple of the base interval.
+	SkipReasonInvalidInterval SkipReason = "invalid-interval"
+	// SkipReasonNotOwned is for alert definitions owned by another scheduler member when sharding.
+	SkipReasonNotOwned SkipReason = "not-owned"
+)
+
+// EvalDecision is the decision the scheduler took for an alert definition on a tick.
+type EvalDecision struct {
+	Tick      time.Time
+	Evaluated bool
+	// Reason is empty if the alert definition was evaluated.
+	Reason SkipReason
+}
+
+type evalDecisions struct {
+	mu        sync.Mutex
+	decisions map[models.AlertDefinitionKey]EvalDecision
+}
+
+func (d *evalDecisions) evaluate(key models.AlertDefinitionKey, tick time.Time) {
+	d.set(key, EvalDecision{Tick: tick, Evaluated: true})
+}
+
+func (d *evalDecisions) skip(key models.AlertDefinitionKey, tick time.Time, reason SkipReason) {
+	d.set(key, EvalDecision{Tick: tick, Reason: reason})
+}
+
+func (d *evalDecisions) set(key models.AlertDefinitionKey, decision EvalDecision) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.decisions[key] = decision
+}
+
+func (d *evalDecisions) get(key models.AlertDefinitionKey) (EvalDecision, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	decision, ok := d.decisions[key]
+	return decision, ok
+}
+
+// retain removes the decisions of the alert definitions not in the list.
+func (d *evalDecisions) retain(alertDefinitions []*models.AlertDefinition) {
+	keys := make(map[models.AlertDefinitionKey]struct{}, len(alertDefinitions))
+	for _, item := range alertDefinitions {
+		keys[item.GetKey()] = struct{}{}
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for key := range d.decisions {
+		if _, ok := keys[key]; !ok {
+			delete(d.decisions, key)
+		}
+	}
+}
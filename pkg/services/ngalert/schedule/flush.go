@@ -0,0 +1,54 @@
+package schedule
+
+import (
+	"time"
+
+	"github.com/grafana/grafana/pkg/services/ngalert/models"
+	"github.com/grafana/grafana/pkg/services/ngalert/state"
+)
+
+// stateFlushBatchSize is the number of alert states the flushes save at once.
+const stateFlushBatchSize = 100
+
+// instanceCommand returns the command saving the alert state as its alert instance.
+func instanceCommand(s state.AlertState) models.SaveAlertInstanceCommand {
+	return models.SaveAlertInstanceCommand{
+		DefinitionOrgID:   s.OrgID,
+		DefinitionUID:     s.UID,
+		Labels:            models.InstanceLabels(s.Labels),
+		State:             models.InstanceStateType(s.State.String()),
+		LastEvalTime:      s.LastEvaluationTime,
+		CurrentStateSince: s.StartsAt,
+		CurrentStateEnd:   s.EndsAt,
+		ConditionStart:    s.ConditionStartsAt,
+		Origin:            s.Origin,
+	}
+}
+
+// flushStates saves the alert states whose writes were coalesced since their last evaluation,
+// so that they survive a crash before the next evaluation of their alert definition.
+func (sch *schedule) flushStates(stateTracker *state.StateTracker, now time.Time) {
+	sch.flushAlertStates(stateTracker.StatesToFlush(now))
+}
+
+// flushAlertStates saves the alert states by batches of stateFlushBatchSize, each in a single transaction.
+// The batches that fail to save are logged and skipped.
+func (sch *schedule) flushAlertStates(states []state.AlertState) {
+	if len(states) == 0 {
+		return
+	}
+	sch.log.Debug("flushing alert states", "count", len(states))
+	for start := 0; start < len(states); start += stateFlushBatchSize {
+		end := start + stateFlushBatchSize
+		if end > len(states) {
+			end = len(states)
+		}
+		cmd := models.SaveAlertInstancesCommand{Instances: make([]models.SaveAlertInstanceCommand, 0, end-start)}
+		for _, s := range states[start:end] {
+			cmd.Instances = append(cmd.Instances, instanceCommand(s))
+		}
+		if err := sch.store.SaveAlertInstances(&cmd); err != nil {
+			sch.log.Error("failed to flush alert states", "count", len(cmd.Instances), "msg", err.Error())
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package schedule
+
+import (
+	"errors"
+	"time"
+
+	"github.com/grafana/grafana/pkg/services/ngalert/models"
+)
+
+// folderKey identifies a folder of an organisation.
+type folderKey struct {
+	orgID        int64
+	namespaceUID string
+}
+
+// fetchFolderDefaults returns the alert settings of the folders, the defaults of the alert definitions in them.
+func (sch *schedule) fetchFolderDefaults(now time.Time) map[folderKey]models.AlertRuleSettings {
+	q := models.ListAlertFolderSettingsQuery{}
+	if err := sch.store.ListAlertFolderSettings(&q); err != nil {
+		sch.log.Error("failed to fetch the alert settings of the folders", "now", now, "err", err)
+		return nil
+	}
+	defaults := make(map[folderKey]models.AlertRuleSettings, len(q.Result))
+	for _, s := range q.Result {
+		defaults[folderKey{orgID: s.OrgID, namespaceUID: s.NamespaceUID}] = s.Settings()
+	}
+	return defaults
+}
+
+// folderDefaultsOf returns the alert settings of the folder of the alert definition, if it has any.
+func (sch *schedule) folderDefaultsOf(alertDefinition *models.AlertDefinition) models.AlertRuleSettings {
+	if alertDefinition.NamespaceUID == "" {
+		return models.AlertRuleSettings{}
+	}
+	q := models.GetAlertFolderSettingsQuery{OrgID: alertDefinition.OrgID, NamespaceUID: alertDefinition.NamespaceUID}
+	if err := sch.store.GetAlertFolderSettings(&q); err != nil {
+		if !errors.Is(err, models.ErrAlertFolderSettingsNotFound) {
+			sch.log.Error("failed to fetch the alert settings of the folder", "key", alertDefinition.GetKey(), "namespaceUid", alertDefinition.NamespaceUID, "err", err)
+		}
+		return models.AlertRuleSettings{}
+	}
+	return q.Result.Settings()
+}
+
+// withFolderDefaults returns a copy of the alert definition with its effective settings:
+// the ones it doesn't specify are inherited from the folder defaults.
+func withFolderDefaults(alertDefinition *models.AlertDefinition, folderDefaults models.AlertRuleSettings) *models.AlertDefinition {
+	settings := alertDefinition.EffectiveSettings(folderDefaults)
+	effective := *alertDefinition
+	effective.IntervalSeconds = settings.IntervalSeconds
+	effective.For = settings.For
+	effective.NoDataState = settings.NoDataState
+	return &effective
+}
@@ -0,0 +1,43 @@
+package schedule
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/grafana/grafana/pkg/services/ngalert/models"
+)
+
+// instanceUpgrades are the upgrades of the persisted alert instances, by the format version they upgrade from.
+var instanceUpgrades = map[int]func(entry *models.ListAlertInstancesQueryResult){
+	models.InstanceFormatVersionUnversioned: upgradeUnversionedInstance,
+}
+
+// upgradeInstance upgrades the alert instance, one format version at a time, to the current format.
+// It returns an error for the alert instances persisted with an unknown format version.
+func upgradeInstance(entry *models.ListAlertInstancesQueryResult) error {
+	if entry.FormatVersion < 0 || entry.FormatVersion > models.InstanceFormatVersionCurrent {
+		return fmt.Errorf("unknown alert instance format version %d", entry.FormatVersion)
+	}
+	for entry.FormatVersion < models.InstanceFormatVersionCurrent {
+		upgrade, ok := instanceUpgrades[entry.FormatVersion]
+		if !ok {
+			return fmt.Errorf("no upgrade from alert instance format version %d", entry.FormatVersion)
+		}
+		upgrade(entry)
+		entry.FormatVersion++
+	}
+	return nil
+}
+
+// upgradeUnversionedInstance sets the current state end of the alert instances persisted without it:
+// firing instances end where the state tracker would have set it, the other ones have none.
+func upgradeUnversionedInstance(entry *models.ListAlertInstancesQueryResult) {
+	if entry.CurrentStateEnd.After(time.Unix(0, 0)) {
+		return
+	}
+	if entry.CurrentState == models.InstanceStateFiring {
+		entry.CurrentStateEnd = entry.LastEvalTime.Add(40 * time.Second)
+		return
+	}
+	entry.CurrentStateEnd = time.Time{}
+}
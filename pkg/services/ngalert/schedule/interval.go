@@ -0,0 +1,53 @@
+package schedule
+
+import (
+	"time"
+
+	"github.com/grafana/grafana/pkg/services/ngalert/models"
+)
+
+// IntervalPolicy is how the scheduler handles the alert definitions with
+// a zero interval or an interval not divided exactly by its base interval.
+type IntervalPolicy int
+
+const (
+	// IntervalPolicyIgnore never evaluates these alert definitions.
+	IntervalPolicyIgnore IntervalPolicy = iota
+	// IntervalPolicyReject doesn't register these alert definitions and logs them as errors.
+	IntervalPolicyReject
+	// IntervalPolicyDefault logs these alert definitions as warnings and evaluates them every base interval.
+	IntervalPolicyDefault
+)
+
+// checkInterval applies the interval policy to the alert definition interval. It returns the interval,
+// in seconds, the alert definition is scheduled with and false if the alert definition must not be registered.
+// Offending alert definitions are logged the first time they are found.
+func (sch *schedule) checkInterval(key models.AlertDefinitionKey, intervalSeconds int64) (int64, bool) {
+	zero := intervalSeconds == 0
+	invalid := intervalSeconds%int64(sch.baseInterval.Seconds()) != 0
+	if !zero && !invalid {
+		return intervalSeconds, true
+	}
+
+	interval := time.Duration(intervalSeconds) * time.Second
+	switch sch.intervalPolicy {
+	case IntervalPolicyReject:
+		if d, ok := sch.decisions.get(key); !ok || d.Reason != SkipReasonInvalidInterval {
+			sch.log.Error("alert definition with zero or invalid interval rejected: interval should be divided exactly by scheduler interval", "key", key, "interval", interval, "scheduler interval", sch.baseInterval)
+		}
+		return 0, false
+	case IntervalPolicyDefault:
+		if !sch.registry.exists(key) {
+			sch.log.Warn("alert definition with zero or invalid interval will be evaluated every scheduler interval", "key", key, "interval", interval, "scheduler interval", sch.baseInterval)
+		}
+		return int64(sch.baseInterval.Seconds()), true
+	default:
+		if invalid {
+			// this is expected to be always false
+			// give that we validate interval during alert definition updates
+			sch.log.Debug("alert definition with invalid interval will be ignored: interval should be divided exactly by scheduler interval", "key", key, "interval", interval, "scheduler interval", sch.baseInterval)
+			return 0, false
+		}
+		return 0, true
+	}
+}
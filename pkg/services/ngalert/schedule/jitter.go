@@ -0,0 +1,29 @@
+package schedule
+
+import (
+	"hash/fnv"
+	"strconv"
+
+	"github.com/grafana/grafana/pkg/services/ngalert/models"
+)
+
+// EvaluationOffset returns the tick, within its interval of frequency ticks, the alert definition is evaluated on
+// when the evaluation jitter is enabled: the alert definitions with the same interval are spread across the ticks
+// of the interval, by a hash of their key, instead of all being evaluated on its first tick.
+func EvaluationOffset(key models.AlertDefinitionKey, frequency int64) int64 {
+	if frequency <= 1 {
+		return 0
+	}
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(strconv.FormatInt(key.OrgID, 10) + "/" + key.DefinitionUID))
+	return int64(h.Sum64() % uint64(frequency))
+}
+
+// isDue returns true if the alert definition, evaluated every frequency ticks, is due on the tick.
+func (sch *schedule) isDue(key models.AlertDefinitionKey, tickNum int64, frequency int64) bool {
+	var offset int64
+	if sch.evaluationJitter {
+		offset = EvaluationOffset(key, frequency)
+	}
+	return tickNum%frequency == offset
+}
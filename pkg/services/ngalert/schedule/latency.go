@@ -0,0 +1,118 @@
+package schedule
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/grafana/grafana/pkg/services/ngalert/models"
+)
+
+// defaultLatencyWindow is the number of recent evaluations the latency stats are computed over
+// when the window of the LatencyStats is not set.
+const defaultLatencyWindow = 100
+
+// LatencyStats configures the persistence of the evaluation latency of the alert definitions,
+// for capacity planning: the p50, p95 and max durations of the last Window evaluations of every
+// alert definition are saved to the store every FlushInterval, which defaults to the reconcile interval.
+// Only the alert definitions evaluated since the last flush are saved.
+type LatencyStats struct {
+	Window        int
+	FlushInterval time.Duration
+}
+
+// latencyRecorder holds, by alert definition, the durations of its recent evaluations
+// and whether they changed since they were last flushed to the store.
+type latencyRecorder struct {
+	mu        sync.Mutex
+	durations map[models.AlertDefinitionKey][]time.Duration
+	dirty     map[models.AlertDefinitionKey]struct{}
+	lastFlush time.Time
+}
+
+// recordLatency records the duration of an evaluation of the alert definition.
+func (sch *schedule) recordLatency(key models.AlertDefinitionKey, d time.Duration) {
+	if sch.latencyStats == nil {
+		return
+	}
+	window := sch.latencyStats.Window
+	if window <= 0 {
+		window = defaultLatencyWindow
+	}
+
+	r := &sch.latency
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	durations := r.durations[key]
+	if len(durations) < window {
+		durations = append(durations, d)
+	} else {
+		copy(durations, durations[1:])
+		durations[window-1] = d
+	}
+	r.durations[key] = durations
+	r.dirty[key] = struct{}{}
+}
+
+// forgetLatency drops the durations of a deleted alert definition.
+func (sch *schedule) forgetLatency(key models.AlertDefinitionKey) {
+	r := &sch.latency
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.durations, key)
+	delete(r.dirty, key)
+}
+
+// flushLatency saves the latency stats of the alert definitions evaluated since the last flush
+// if the flush interval elapsed at the tick, or regardless of it if force is set.
+func (sch *schedule) flushLatency(tick time.Time, force bool) {
+	if sch.latencyStats == nil {
+		return
+	}
+	interval := sch.latencyStats.FlushInterval
+	if interval <= 0 {
+		interval = sch.reconcileInterval
+	}
+
+	r := &sch.latency
+	r.mu.Lock()
+	if !force && !r.lastFlush.IsZero() && tick.Sub(r.lastFlush) < interval {
+		r.mu.Unlock()
+		return
+	}
+	r.lastFlush = tick
+	stats := make([]models.AlertDefinitionLatency, 0, len(r.dirty))
+	for key := range r.dirty {
+		stats = append(stats, latencyOf(key, r.durations[key], tick))
+	}
+	r.dirty = make(map[models.AlertDefinitionKey]struct{})
+	r.mu.Unlock()
+
+	for _, l := range stats {
+		if err := sch.store.SaveAlertDefinitionLatency(&models.SaveAlertDefinitionLatencyCommand{Latency: l}); err != nil {
+			sch.log.Error("failed to save alert definition latency", "uid", l.DefinitionUID, "orgId", l.DefinitionOrgID, "err", err)
+		}
+	}
+}
+
+// latencyOf computes the latency stats of the durations, using the nearest-rank percentiles.
+func latencyOf(key models.AlertDefinitionKey, durations []time.Duration, now time.Time) models.AlertDefinitionLatency {
+	sorted := append([]time.Duration{}, durations...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	percentile := func(p float64) time.Duration {
+		if len(sorted) == 0 {
+			return 0
+		}
+		return sorted[int(math.Ceil(p*float64(len(sorted))))-1]
+	}
+	return models.AlertDefinitionLatency{
+		DefinitionOrgID: key.OrgID,
+		DefinitionUID:   key.DefinitionUID,
+		P50:             percentile(0.5),
+		P95:             percentile(0.95),
+		Max:             percentile(1),
+		Samples:         len(sorted),
+		Updated:         now,
+	}
+}
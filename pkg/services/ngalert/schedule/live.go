@@ -0,0 +1,112 @@
+package schedule
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/grafana/grafana/pkg/services/ngalert/eval"
+	"github.com/grafana/grafana/pkg/services/ngalert/models"
+	"github.com/grafana/grafana/pkg/services/ngalert/state"
+)
+
+// LivePublisher publishes data to Grafana Live channels, as GrafanaLive.Publish does.
+type LivePublisher interface {
+	Publish(channel string, data []byte) error
+}
+
+// LiveChannel returns the Grafana Live channel the state transitions of the alert definition are published to.
+func LiveChannel(key models.AlertDefinitionKey) string {
+	return fmt.Sprintf("grafana/alerting/%d/%s", key.OrgID, key.DefinitionUID)
+}
+
+type livePublishing struct {
+	mu   sync.Mutex
+	keys map[models.AlertDefinitionKey]struct{}
+}
+
+// SetLivePublishing sets whether the state transitions of the alert definition are published
+// to its Live channel, with the configured LivePublisher, so that dashboards can follow them in real time.
+func (sch *schedule) SetLivePublishing(key models.AlertDefinitionKey, publish bool) {
+	sch.livePublishing.mu.Lock()
+	defer sch.livePublishing.mu.Unlock()
+	if publish {
+		sch.livePublishing.keys[key] = struct{}{}
+		return
+	}
+	delete(sch.livePublishing.keys, key)
+}
+
+// publishesLive returns true if the state transitions of the alert definition are published to Live.
+func (sch *schedule) publishesLive(key models.AlertDefinitionKey) bool {
+	if sch.livePublisher == nil {
+		return false
+	}
+	sch.livePublishing.mu.Lock()
+	defer sch.livePublishing.mu.Unlock()
+	_, ok := sch.livePublishing.keys[key]
+	return ok
+}
+
+// liveSnapshot returns, by cache ID, the states of the alert instances of the evaluation results
+// before they are processed, or nil if the alert definition doesn't publish to Live.
+func (sch *schedule) liveSnapshot(key models.AlertDefinitionKey, results eval.Results, stateTracker *state.StateTracker) map[string]eval.State {
+	if !sch.publishesLive(key) {
+		return nil
+	}
+	return stateSnapshot(key, results, stateTracker)
+}
+
+// stateSnapshot returns, by cache ID, the states of the alert instances of the evaluation results
+// before they are processed.
+func stateSnapshot(key models.AlertDefinitionKey, results eval.Results, stateTracker *state.StateTracker) map[string]eval.State {
+	previous := make(map[string]eval.State, len(results))
+	for _, r := range results {
+		cacheId := state.CacheID(key.DefinitionUID, r.Instance)
+		previous[cacheId] = stateTracker.Get(key.OrgID, cacheId).State
+	}
+	return previous
+}
+
+// transitionsFrom returns the state transitions of the alert instances whose state changed from the snapshot;
+// the new alert instances are considered to have been Normal.
+func transitionsFrom(previous map[string]eval.State, states []state.AlertState, now time.Time) []state.StateTransition {
+	var transitions []state.StateTransition
+	for _, s := range states {
+		if s.State == previous[s.CacheId] {
+			continue
+		}
+		transitions = append(transitions, state.StateTransition{
+			OrgID:   s.OrgID,
+			UID:     s.UID,
+			CacheId: s.CacheId,
+			Labels:  s.Labels,
+			From:    previous[s.CacheId],
+			To:      s.State,
+			At:      now,
+			Actor:   eval.ServiceIdentityLogin,
+			Origin:  s.Origin,
+		})
+	}
+	return transitions
+}
+
+// publishTransitions publishes to the Live channel of the alert definition a frame for every alert instance
+// whose state changed from the snapshot; the new alert instances are considered to have been Normal.
+func (sch *schedule) publishTransitions(key models.AlertDefinitionKey, previous map[string]eval.State, states []state.AlertState, now time.Time) {
+	if previous == nil {
+		return
+	}
+	channel := LiveChannel(key)
+	for _, t := range transitionsFrom(previous, states, now) {
+		frame, err := json.Marshal(t)
+		if err != nil {
+			sch.log.Error("failed to encode state transition", "cacheId", t.CacheId, "err", err)
+			continue
+		}
+		if err := sch.livePublisher.Publish(channel, frame); err != nil {
+			sch.log.Error("failed to publish state transition to Live", "channel", channel, "cacheId", t.CacheId, "err", err)
+		}
+	}
+}
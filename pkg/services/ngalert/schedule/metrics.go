@@ -0,0 +1,28 @@
+package schedule
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/grafana/grafana/pkg/infra/metrics"
+	"github.com/grafana/grafana/pkg/services/ngalert/models"
+)
+
+// orgLabel returns the value of the org label of the metrics of the alert definition.
+func orgLabel(key models.AlertDefinitionKey) string {
+	return strconv.FormatInt(key.OrgID, 10)
+}
+
+// observeEvaluationDuration records the duration of an evaluation attempt of the alert definition in the metrics.
+func observeEvaluationDuration(key models.AlertDefinitionKey, d time.Duration) {
+	metrics.MNGAlertEvaluationDuration.WithLabelValues(orgLabel(key)).Observe(d.Seconds())
+}
+
+// countEvaluation records an evaluation of the alert definition in the metrics,
+// as failed if none of its attempts succeeded.
+func countEvaluation(key models.AlertDefinitionKey, failed bool) {
+	metrics.MNGAlertEvaluations.WithLabelValues(orgLabel(key)).Inc()
+	if failed {
+		metrics.MNGAlertEvaluationFailures.WithLabelValues(orgLabel(key)).Inc()
+	}
+}
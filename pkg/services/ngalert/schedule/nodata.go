@@ -0,0 +1,71 @@
+package schedule
+
+import (
+	"errors"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+
+	"github.com/grafana/grafana/pkg/services/ngalert/eval"
+	"github.com/grafana/grafana/pkg/services/ngalert/models"
+	"github.com/grafana/grafana/pkg/services/ngalert/state"
+)
+
+// noDataResults returns the results of a condition whose queries and expressions returned no frames.
+func noDataResults(now time.Time) eval.Results {
+	return eval.Results{{Instance: data.Labels{}, State: eval.NoData, EvaluatedAt: now}}
+}
+
+// applyNoDataState sets the state of the NoData results to the one of the no data policy of the alert definition.
+// With the KeepLastState policy, the results of the alert instances without a state are dropped.
+func applyNoDataState(alertDefinition *models.AlertDefinition, results eval.Results, stateTracker *state.StateTracker) eval.Results {
+	var noDataState eval.State
+	switch alertDefinition.NoDataState {
+	case models.OK:
+		noDataState = eval.Normal
+	case models.Alerting:
+		noDataState = eval.Alerting
+	case models.KeepLastState:
+	default:
+		return results
+	}
+
+	applied := make(eval.Results, 0, len(results))
+	for _, r := range results {
+		if r.State == eval.NoData {
+			if alertDefinition.NoDataState != models.KeepLastState {
+				r.State = noDataState
+			} else {
+				s := stateTracker.Get(alertDefinition.OrgID, state.CacheID(alertDefinition.UID, r.Instance))
+				if s.CacheId == "" {
+					continue
+				}
+				r.State = s.State
+			}
+		}
+		applied = append(applied, r)
+	}
+	return applied
+}
+
+// execErrResults returns the results of a condition that failed to evaluate, per the execution error
+// policy of the alert definition. It returns nil if the alert states are to be left as they are.
+// The evaluations that timed out get the Timeout state, unless the policy is another state.
+func execErrResults(alertDefinition *models.AlertDefinition, now time.Time, err error) eval.Results {
+	var execErrState eval.State
+	switch {
+	case alertDefinition.ExecErrState == models.AlertingErrState:
+		execErrState = eval.Alerting
+	case alertDefinition.ExecErrState == models.OKErrState:
+		execErrState = eval.Normal
+	case alertDefinition.ExecErrState == models.KeepLastStateErrState:
+		return nil
+	case errors.Is(err, eval.ErrTimeout):
+		execErrState = eval.Timeout
+	case alertDefinition.ExecErrState == models.ErrorErrState:
+		execErrState = eval.Error
+	default:
+		return nil
+	}
+	return eval.Results{{Instance: data.Labels{}, State: execErrState, EvaluatedAt: now}}
+}
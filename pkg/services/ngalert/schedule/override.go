@@ -0,0 +1,55 @@
+package schedule
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/grafana/grafana/pkg/services/ngalert/models"
+)
+
+// intervalOverride is a temporary evaluation interval of an alert definition.
+type intervalOverride struct {
+	interval time.Duration
+	until    time.Time
+}
+
+type intervalOverrides struct {
+	mu        sync.Mutex
+	overrides map[models.AlertDefinitionKey]intervalOverride
+}
+
+// OverrideInterval evaluates the alert definition with the given interval, instead of its own,
+// until the given time. The override is kept in memory only: it doesn't change the stored
+// alert definition and it's lost on restart. A zero interval removes the override.
+func (sch *schedule) OverrideInterval(key models.AlertDefinitionKey, interval time.Duration, until time.Time) error {
+	sch.intervalOverrides.mu.Lock()
+	defer sch.intervalOverrides.mu.Unlock()
+	if interval == 0 {
+		delete(sch.intervalOverrides.overrides, key)
+		return nil
+	}
+	if interval < 0 || interval%sch.baseInterval != 0 {
+		return fmt.Errorf("invalid interval override %v: it should be divided exactly by the scheduler interval %v", interval, sch.baseInterval)
+	}
+	sch.intervalOverrides.overrides[key] = intervalOverride{interval: interval, until: until}
+	sch.log.Info("alert definition interval overridden", "key", key, "interval", interval, "until", until)
+	return nil
+}
+
+// overriddenIntervalSeconds returns the interval override of the alert definition
+// in seconds, if it has one that is active at now; expired overrides are removed.
+func (sch *schedule) overriddenIntervalSeconds(key models.AlertDefinitionKey, now time.Time) (int64, bool) {
+	sch.intervalOverrides.mu.Lock()
+	defer sch.intervalOverrides.mu.Unlock()
+	override, ok := sch.intervalOverrides.overrides[key]
+	if !ok {
+		return 0, false
+	}
+	if !now.Before(override.until) {
+		sch.log.Info("alert definition interval override expired", "key", key, "interval", override.interval, "until", override.until)
+		delete(sch.intervalOverrides.overrides, key)
+		return 0, false
+	}
+	return int64(override.interval.Seconds()), true
+}
@@ -0,0 +1,41 @@
+package schedule
+
+import (
+	"context"
+
+	"github.com/grafana/grafana/pkg/infra/metrics"
+)
+
+// evaluationPool bounds the number of alert definitions evaluated at once: the routines of the
+// alert definitions take a slot of the pool for their evaluations, waiting for one if they are all taken.
+// A nil pool doesn't bound the evaluations.
+type evaluationPool struct {
+	slots chan struct{}
+}
+
+func newEvaluationPool(size int) *evaluationPool {
+	if size <= 0 {
+		return nil
+	}
+	return &evaluationPool{slots: make(chan struct{}, size)}
+}
+
+// acquire waits for a slot of the pool and returns the function releasing it,
+// or the error of the context if it's done first.
+func (p *evaluationPool) acquire(ctx context.Context) (func(), error) {
+	release := func() {}
+	if p == nil {
+		return release, nil
+	}
+
+	start := timeNow()
+	metrics.MNGAlertEvaluationQueueDepth.Inc()
+	defer metrics.MNGAlertEvaluationQueueDepth.Dec()
+	select {
+	case p.slots <- struct{}{}:
+		metrics.MNGAlertEvaluationQueueWait.Observe(timeNow().Sub(start).Seconds())
+		return func() { <-p.slots }, nil
+	case <-ctx.Done():
+		return release, ctx.Err()
+	}
+}
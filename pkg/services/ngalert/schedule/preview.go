@@ -0,0 +1,64 @@
+package schedule
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/grafana/grafana/pkg/services/ngalert/eval"
+	"github.com/grafana/grafana/pkg/services/ngalert/models"
+	"github.com/grafana/grafana/pkg/services/ngalert/state"
+)
+
+// PreviewEvaluation is an evaluation of the preview of an alert definition: its results at a time,
+// or the error it failed with, and the state transitions they produced.
+type PreviewEvaluation struct {
+	At          time.Time
+	Results     eval.Results
+	Err         error
+	Transitions []state.StateTransition
+}
+
+// PreviewAlertDefinition evaluates the alert definition, which doesn't have to be saved, at each of the times in order,
+// and returns the evaluations with the state transitions the state tracker would have produced, from scratch,
+// with the pending period and the no data and execution error policies of the alert definition,
+// the ones it doesn't specify being inherited from the alert settings of its folder.
+// The live states are untouched, and nothing is saved or sent.
+func (sch *schedule) PreviewAlertDefinition(ctx context.Context, alertDefinition *models.AlertDefinition, times []time.Time) []PreviewEvaluation {
+	alertDefinition = withFolderDefaults(alertDefinition, sch.folderDefaultsOf(alertDefinition))
+	key := alertDefinition.GetKey()
+	condition := models.Condition{
+		Condition: alertDefinition.Condition,
+		OrgID:     alertDefinition.OrgID,
+		Data:      alertDefinition.Data,
+	}
+	stateTracker := state.NewPreviewStateTracker(sch.log)
+	stateTracker.SetPendingPeriod(key.OrgID, key.DefinitionUID, alertDefinition.For)
+	stateTracker.SetRuleInstanceLimit(key.OrgID, key.DefinitionUID, int(alertDefinition.MaxInstances))
+
+	evaluations := make([]PreviewEvaluation, 0, len(times))
+	for _, at := range times {
+		evalCtx, cancel := sch.withEvaluationTimeout(ctx, alertDefinition)
+		results, err := sch.conditionEval(evalCtx, key, &condition, at)
+		cancel()
+		if errors.Is(err, eval.ErrNoData) && alertDefinition.NoDataState != "" {
+			results, err = noDataResults(at), nil
+		}
+		evaluation := PreviewEvaluation{At: at, Results: results, Err: err}
+		if err != nil {
+			results = execErrResults(alertDefinition, at, err)
+		} else {
+			results = applyNoDataState(alertDefinition, results, stateTracker)
+		}
+		if len(results) > 0 {
+			previous := stateSnapshot(key, results, stateTracker)
+			states := stateTracker.ProcessEvalResults(key.DefinitionUID, results, condition)
+			evaluation.Transitions = transitionsFrom(previous, states, at)
+		}
+		evaluations = append(evaluations, evaluation)
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return evaluations
+}
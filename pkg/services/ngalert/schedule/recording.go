@@ -0,0 +1,65 @@
+package schedule
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+
+	"github.com/grafana/grafana/pkg/services/ngalert/eval"
+	"github.com/grafana/grafana/pkg/services/ngalert/models"
+)
+
+// MetricNameLabel is the label of the samples written by recording rules that holds the metric name.
+const MetricNameLabel = "__name__"
+
+var errNoSeriesWriter = errors.New("no series writer is configured for recording rules")
+
+// Sample is a value of a series written by a recording rule.
+type Sample struct {
+	Labels    data.Labels
+	Value     float64
+	Timestamp time.Time
+}
+
+// SeriesWriter writes the series of the recording rules to their target datasource.
+type SeriesWriter interface {
+	WriteSeries(ctx context.Context, orgID int64, datasourceUID string, samples []Sample) error
+}
+
+// recordingSamples returns the samples of the evaluation results of the recording rule:
+// one per alert instance with a value, labelled with the metric name and the labels of the instance.
+func recordingSamples(record models.RecordingRule, results eval.Results) []Sample {
+	samples := make([]Sample, 0, len(results))
+	for _, r := range results {
+		if r.Value == nil {
+			continue
+		}
+		lbs := make(data.Labels, len(r.Instance)+1)
+		for k, v := range r.Instance {
+			lbs[k] = v
+		}
+		lbs[MetricNameLabel] = record.Metric
+		samples = append(samples, Sample{Labels: lbs, Value: *r.Value, Timestamp: r.EvaluatedAt})
+	}
+	return samples
+}
+
+// writeRecording writes the samples of the evaluation results of the recording rule
+// to its target datasource, with the configured SeriesWriter.
+func (sch *schedule) writeRecording(ctx context.Context, key models.AlertDefinitionKey, alertDefinition *models.AlertDefinition, results eval.Results) error {
+	if sch.seriesWriter == nil {
+		return errNoSeriesWriter
+	}
+	samples := recordingSamples(alertDefinition.Record, results)
+	if len(samples) == 0 {
+		return nil
+	}
+	if err := sch.seriesWriter.WriteSeries(ctx, key.OrgID, alertDefinition.Record.TargetDatasourceUID, samples); err != nil {
+		sch.log.Error("failed to write the series of the recording rule", "key", key, "metric", alertDefinition.Record.Metric,
+			"datasourceUid", alertDefinition.Record.TargetDatasourceUID, "samples", len(samples), "error", err)
+		return err
+	}
+	return nil
+}
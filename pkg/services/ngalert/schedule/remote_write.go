@@ -0,0 +1,97 @@
+package schedule
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/golang/snappy"
+	"github.com/prometheus/prometheus/prompb"
+
+	apimodels "github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/services/datasources"
+)
+
+// remoteWritePath is the path, relative to the datasource URL, of the Prometheus remote write endpoint.
+const remoteWritePath = "/api/v1/write"
+
+// RemoteWriter is the SeriesWriter that writes the series with the Prometheus remote write protocol,
+// to the remote write endpoint of the target datasource, with its HTTP client.
+type RemoteWriter struct {
+	datasourceCache datasources.CacheService
+}
+
+// NewRemoteWriter returns a RemoteWriter looking up the target datasources with the datasource cache.
+func NewRemoteWriter(datasourceCache datasources.CacheService) *RemoteWriter {
+	return &RemoteWriter{datasourceCache: datasourceCache}
+}
+
+// WriteSeries writes the samples to the remote write endpoint of the datasource of the organisation.
+func (w *RemoteWriter) WriteSeries(ctx context.Context, orgID int64, datasourceUID string, samples []Sample) error {
+	ds, err := w.datasourceCache.GetDatasourceByUID(datasourceUID, &apimodels.SignedInUser{OrgId: orgID}, false)
+	if err != nil {
+		return fmt.Errorf("failed to get the target datasource %s: %w", datasourceUID, err)
+	}
+	client, err := ds.GetHttpClient()
+	if err != nil {
+		return fmt.Errorf("failed to get the HTTP client of the target datasource %s: %w", datasourceUID, err)
+	}
+
+	body, err := encodeWriteRequest(samples)
+	if err != nil {
+		return err
+	}
+	url := strings.TrimSuffix(ds.Url, "/") + remoteWritePath
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Encoding", "snappy")
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+	if ds.BasicAuth {
+		req.SetBasicAuth(ds.BasicAuthUser, ds.DecryptedBasicAuthPassword())
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_, _ = io.Copy(ioutil.Discard, resp.Body)
+		_ = resp.Body.Close()
+	}()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("unexpected status from the remote write endpoint of the target datasource %s: %s", datasourceUID, resp.Status)
+	}
+	return nil
+}
+
+// encodeWriteRequest returns the snappy compressed remote write request of the samples,
+// one time series each, with their labels sorted by name as the protocol requires.
+func encodeWriteRequest(samples []Sample) ([]byte, error) {
+	req := prompb.WriteRequest{Timeseries: make([]prompb.TimeSeries, 0, len(samples))}
+	for _, s := range samples {
+		lbs := make([]prompb.Label, 0, len(s.Labels))
+		for k, v := range s.Labels {
+			lbs = append(lbs, prompb.Label{Name: k, Value: v})
+		}
+		sort.Slice(lbs, func(i, j int) bool {
+			return lbs[i].Name < lbs[j].Name
+		})
+		req.Timeseries = append(req.Timeseries, prompb.TimeSeries{
+			Labels:  lbs,
+			Samples: []prompb.Sample{{Value: s.Value, Timestamp: s.Timestamp.UnixNano() / 1e6}},
+		})
+	}
+	data, err := req.Marshal()
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode the remote write request: %w", err)
+	}
+	return snappy.Encode(nil, data), nil
+}
@@ -0,0 +1,168 @@
+// Package schedtest provides a harness for the tests of the alert definition scheduler.
+package schedtest
+
+import (
+	"context"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/grafana/grafana/pkg/infra/log"
+	"github.com/grafana/grafana/pkg/services/ngalert/models"
+	"github.com/grafana/grafana/pkg/services/ngalert/schedule"
+	"github.com/grafana/grafana/pkg/services/ngalert/state"
+)
+
+// timeout is how long the harness waits for the expected evaluations and stops.
+const timeout = time.Second
+
+// Evaluation is an evaluation of an alert definition for a tick.
+type Evaluation struct {
+	Key models.AlertDefinitionKey
+	Now time.Time
+}
+
+// Harness runs a scheduler driven by a mock clock and records
+// the evaluations, the stops and the state transitions of its alert definitions.
+type Harness struct {
+	t testing.TB
+
+	Clock        *clock.Mock
+	Scheduler    schedule.ScheduleService
+	StateTracker *state.StateTracker
+	baseInterval time.Duration
+
+	evaluations chan Evaluation
+	stops       chan models.AlertDefinitionKey
+
+	mu          sync.Mutex
+	transitions []state.StateTransition
+}
+
+// New starts the ticker of a scheduler configured with cfg, whose clock and applied functions are
+// replaced by the harness ones; the base interval defaults to a second. The scheduler stops with the test.
+func New(t testing.TB, cfg schedule.SchedulerCfg) *Harness {
+	t.Helper()
+
+	h := &Harness{
+		t:           t,
+		Clock:       clock.NewMock(),
+		evaluations: make(chan Evaluation, 100),
+		stops:       make(chan models.AlertDefinitionKey, 100),
+	}
+	if cfg.BaseInterval == 0 {
+		cfg.BaseInterval = time.Second
+	}
+	if cfg.Logger == nil {
+		cfg.Logger = log.New("ngalert schedule test harness")
+	}
+	h.baseInterval = cfg.BaseInterval
+	cfg.C = h.Clock
+	cfg.EvalAppliedFunc = func(key models.AlertDefinitionKey, now time.Time) {
+		h.evaluations <- Evaluation{Key: key, Now: now}
+	}
+	cfg.StopAppliedFunc = func(key models.AlertDefinitionKey) {
+		h.stops <- key
+	}
+	h.Scheduler = schedule.NewScheduler(cfg, nil)
+	h.StateTracker = state.NewStateTrackerWithClock(cfg.Logger, h.Clock)
+	h.StateTracker.SetAuditSink(h)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	t.Cleanup(func() {
+		// the ticker saves the alert states when it stops, which must not leak into the next tests;
+		// it's not waited for longer than the timeout as some tests leave evaluations running
+		cancel()
+		select {
+		case <-stopped:
+		case <-time.After(timeout):
+		}
+	})
+	go func() {
+		defer close(stopped)
+		_ = h.Scheduler.Ticker(ctx, h.StateTracker)
+	}()
+	runtime.Gosched()
+	return h
+}
+
+// RecordTransition records the state transitions of the alert instances.
+func (h *Harness) RecordTransition(t state.StateTransition) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.transitions = append(h.transitions, t)
+}
+
+// Transitions returns the state transitions recorded so far, in order.
+func (h *Harness) Transitions() []state.StateTransition {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]state.StateTransition{}, h.transitions...)
+}
+
+// Advance advances the clock by the base interval and returns the new tick.
+func (h *Harness) Advance() time.Time {
+	h.Clock.Add(h.baseInterval)
+	return h.Clock.Now()
+}
+
+// AdvanceAndExpect advances the clock by the base interval and asserts that exactly
+// the alert definitions with the given keys are evaluated for the new tick, which it returns.
+func (h *Harness) AdvanceAndExpect(keys ...models.AlertDefinitionKey) time.Time {
+	h.t.Helper()
+	tick := h.Advance()
+	h.ExpectEvaluated(tick, keys...)
+	return tick
+}
+
+// ExpectEvaluated asserts that exactly the alert definitions with the given keys are evaluated for the tick.
+// Without keys, it asserts that no alert definition is evaluated within the timeout.
+func (h *Harness) ExpectEvaluated(tick time.Time, keys ...models.AlertDefinitionKey) {
+	h.t.Helper()
+	expected := keySet(keys)
+	deadline := time.After(timeout)
+	for len(expected) > 0 || len(keys) == 0 {
+		select {
+		case e := <-h.evaluations:
+			_, ok := expected[e.Key]
+			assert.Truef(h.t, ok, "alert definition %v was unexpectedly evaluated", e.Key)
+			assert.Equal(h.t, tick, e.Now)
+			delete(expected, e.Key)
+		case <-deadline:
+			if len(expected) > 0 {
+				h.t.Fatalf("alert definitions %v were not evaluated for tick %v", expected, tick)
+			}
+			return
+		}
+	}
+}
+
+// ExpectStopped asserts that the routines of the alert definitions with the given keys are stopped.
+func (h *Harness) ExpectStopped(keys ...models.AlertDefinitionKey) {
+	h.t.Helper()
+	expected := keySet(keys)
+	deadline := time.After(timeout)
+	for len(expected) > 0 {
+		select {
+		case key := <-h.stops:
+			_, ok := expected[key]
+			assert.Truef(h.t, ok, "alert definition %v was unexpectedly stopped", key)
+			delete(expected, key)
+		case <-deadline:
+			h.t.Fatalf("alert definitions %v were not stopped", expected)
+		}
+	}
+}
+
+func keySet(keys []models.AlertDefinitionKey) map[models.AlertDefinitionKey]struct{} {
+	set := make(map[models.AlertDefinitionKey]struct{}, len(keys))
+	for _, k := range keys {
+		set[k] = struct{}{}
+	}
+	return set
+}
@@ -2,8 +2,13 @@ package schedule
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"runtime"
+	"runtime/debug"
+	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/grafana/grafana-plugin-sdk-go/data"
@@ -18,7 +23,9 @@ import (
 	"github.com/grafana/grafana/pkg/services/ngalert/store"
 
 	"github.com/grafana/grafana/pkg/infra/log"
+	"github.com/grafana/grafana/pkg/infra/metrics"
 	"github.com/grafana/grafana/pkg/services/alerting"
+	"github.com/grafana/grafana/pkg/services/datasources"
 	"github.com/grafana/grafana/pkg/services/ngalert/eval"
 	"github.com/grafana/grafana/pkg/tsdb"
 )
@@ -32,6 +39,16 @@ type ScheduleService interface {
 	Pause() error
 	Unpause() error
 	WarmStateCache(*state.StateTracker)
+	StateCacheWarmed() <-chan struct{}
+	LastDecision(models.AlertDefinitionKey) (EvalDecision, bool)
+	SetSubscribersRequired(models.AlertDefinitionKey, bool)
+	SetPaused(bool)
+	OverrideInterval(key models.AlertDefinitionKey, interval time.Duration, until time.Time) error
+	EvaluateAsOf(key models.AlertDefinitionKey, at time.Time) (eval.Results, []state.AlertState, error)
+	PreviewAlertDefinition(ctx context.Context, alertDefinition *models.AlertDefinition, times []time.Time) []PreviewEvaluation
+	AttachCalendar(key models.AlertDefinitionKey, name string) error
+	SetLivePublishing(key models.AlertDefinitionKey, publish bool)
+	ShardMembers() []string
 
 	// the following are used by tests only used for tests
 	evalApplied(models.AlertDefinitionKey, time.Time)
@@ -43,8 +60,6 @@ func (sch *schedule) definitionRoutine(grafanaCtx context.Context, key models.Al
 	sch.log.Debug("alert definition routine started", "key", key)
 
 	evalRunning := false
-	var start, end time.Time
-	var attempt int64
 	var alertDefinition *models.AlertDefinition
 	for {
 		select {
@@ -53,60 +68,35 @@ func (sch *schedule) definitionRoutine(grafanaCtx context.Context, key models.Al
 				continue
 			}
 
-			evaluate := func(attempt int64) error {
-				start = timeNow()
-
-				// fetch latest alert definition version
-				if alertDefinition == nil || alertDefinition.Version < ctx.version {
-					q := models.GetAlertDefinitionByUIDQuery{OrgID: key.OrgID, UID: key.DefinitionUID}
-					err := sch.store.GetAlertDefinitionByUID(&q)
-					if err != nil {
-						sch.log.Error("failed to fetch alert definition", "key", key)
-						return err
-					}
-					alertDefinition = q.Result
-					sch.log.Debug("new alert definition version fetched", "title", alertDefinition.Title, "key", key, "version", alertDefinition.Version)
-				}
-
-				condition := models.Condition{
-					Condition: alertDefinition.Condition,
-					OrgID:     alertDefinition.OrgID,
-					Data:      alertDefinition.Data,
-				}
-				results, err := sch.evaluator.ConditionEval(&condition, ctx.now, sch.dataService)
-				end = timeNow()
-				if err != nil {
-					// consider saving alert instance on error
-					sch.log.Error("failed to evaluate alert definition", "title", alertDefinition.Title,
-						"key", key, "attempt", attempt, "now", ctx.now, "duration", end.Sub(start), "error", err)
-					return err
-				}
-
-				processedStates := stateTracker.ProcessEvalResults(key.DefinitionUID, results, condition)
-				sch.saveAlertStates(processedStates)
-				alerts := FromAlertStateToPostableAlerts(processedStates)
-				sch.log.Debug("sending alerts to notifier", "count", len(alerts))
-				err = sch.sendAlerts(alerts)
-				if err != nil {
-					sch.log.Error("failed to put alerts in the notifier", "count", len(alerts), "err", err)
-				}
-				return nil
-			}
-
-			func() {
+			stopped := func() (stopped bool) {
 				evalRunning = true
 				defer func() {
 					evalRunning = false
 					sch.evalApplied(key, ctx.now)
 				}()
 
-				for attempt = 0; attempt < sch.maxAttempts; attempt++ {
-					err := evaluate(attempt)
-					if err == nil {
-						break
-					}
+				sch.registry.setEvalRunning(key, true)
+				defer sch.registry.setEvalRunning(key, false)
+
+				// the evaluation is cancelled if the routine is stopped meanwhile
+				evalCtx, stopWatch := cancelOnStop(grafanaCtx, stopCh)
+				defer func() { stopped = stopWatch() }()
+
+				release, err := sch.evaluationPool.acquire(evalCtx)
+				if err != nil {
+					return
 				}
+				defer release()
+
+				alertDefinition, err = sch.evaluateDefinition(evalCtx, key, ctx, alertDefinition, stateTracker)
+				sch.applyBackoff(key, ctx.now, err)
+				return
 			}()
+			if stopped {
+				sch.stopApplied(key)
+				sch.log.Debug("stopping alert definition routine, its evaluation was cancelled", "key", key)
+				return nil
+			}
 		case <-stopCh:
 			sch.stopApplied(key)
 			sch.log.Debug("stopping alert definition routine", "key", key)
@@ -118,6 +108,176 @@ func (sch *schedule) definitionRoutine(grafanaCtx context.Context, key models.Al
 	}
 }
 
+// evaluateDefinition evaluates the alert definition, making up to the max attempts, and processes its results.
+// It returns the alert definition version used, which is fetched only if it is older than the one of the evalContext,
+// and the error of the last attempt if all of them failed. The no data results are processed per the no data
+// policy of the alert definition and, if all the attempts failed to evaluate the condition, the alert states
+// are set per its execution error policy. Every attempt times out after the evaluation timeout of the alert
+// definition; the attempts stop when grafanaCtx is cancelled, leaving the alert states as they are.
+func (sch *schedule) evaluateDefinition(grafanaCtx context.Context, key models.AlertDefinitionKey, ctx *evalContext, alertDefinition *models.AlertDefinition, stateTracker *state.StateTracker) (*models.AlertDefinition, error) {
+	var start, end time.Time
+	var condition models.Condition
+	// evalFailed is set when the last attempt failed to evaluate the condition
+	var evalFailed bool
+	// evaluated is set once an attempt evaluated the condition
+	var evaluated bool
+	evaluate := func(attempt int64) error {
+		start = timeNow()
+
+		// fetch latest alert definition version
+		if alertDefinition == nil || alertDefinition.Version < ctx.version {
+			q := models.GetAlertDefinitionByUIDQuery{OrgID: key.OrgID, UID: key.DefinitionUID}
+			err := sch.store.GetAlertDefinitionByUID(&q)
+			if err != nil {
+				sch.log.Error("failed to fetch alert definition", "key", key)
+				return err
+			}
+			alertDefinition = q.Result
+			sch.log.Debug("new alert definition version fetched", "title", alertDefinition.Title, "key", key, "version", alertDefinition.Version)
+		}
+		// the settings the alert definition doesn't specify are inherited from its folder, which may change at any time
+		effective := withFolderDefaults(alertDefinition, ctx.folderDefaults)
+		stateTracker.SetPendingPeriod(key.OrgID, key.DefinitionUID, effective.For)
+		stateTracker.SetRuleInstanceLimit(key.OrgID, key.DefinitionUID, int(alertDefinition.MaxInstances))
+
+		condition = models.Condition{
+			Condition: alertDefinition.Condition,
+			OrgID:     alertDefinition.OrgID,
+			Data:      alertDefinition.Data,
+		}
+		if sch.referencesMissingDatasource(alertDefinition) {
+			sch.decisions.skip(key, ctx.now, SkipReasonDatasourceMissing)
+			if sch.missingDatasourcePolicy == MissingDatasourceDegrade && !alertDefinition.IsRecording() {
+				processedStates := stateTracker.ProcessEvalResults(key.DefinitionUID, errorResults(ctx.now), condition)
+				sch.saveAlertStates(stateTracker.StatesToWrite(processedStates, ctx.now))
+			}
+			return nil
+		}
+		attemptCtx, cancel := sch.withEvaluationTimeout(grafanaCtx, alertDefinition)
+		evaluated = true
+		results, err := sch.conditionEval(attemptCtx, key, &condition, ctx.now)
+		cancel()
+		end = timeNow()
+		sch.recordLatency(key, end.Sub(start))
+		observeEvaluationDuration(key, end.Sub(start))
+		if errors.Is(err, eval.ErrNoData) && effective.NoDataState != "" {
+			results, err = noDataResults(ctx.now), nil
+		}
+		if err != nil {
+			// consider saving alert instance on error
+			sch.log.Error("failed to evaluate alert definition", "title", alertDefinition.Title,
+				"key", key, "attempt", attempt, "now", ctx.now, "duration", end.Sub(start), "error", err)
+			evalFailed = true
+			return err
+		}
+		evalFailed = false
+
+		sch.adaptCadence(key, results)
+
+		// recording rules write their series instead of producing alert instances
+		if alertDefinition.IsRecording() {
+			writeCtx, cancel := sch.withEvaluationTimeout(grafanaCtx, alertDefinition)
+			defer cancel()
+			return sch.writeRecording(writeCtx, key, alertDefinition, results)
+		}
+
+		sch.processResults(key, alertDefinition.Title, ctx.now, condition, applyNoDataState(effective, results, stateTracker), stateTracker)
+		return nil
+	}
+
+	var err error
+	for attempt := int64(0); attempt < sch.maxAttempts; attempt++ {
+		err = evaluate(attempt)
+		if err == nil || errors.Is(err, errEvaluationStuck) || grafanaCtx.Err() != nil {
+			break
+		}
+	}
+	if evaluated {
+		countEvaluation(key, err != nil && evalFailed)
+	}
+	if err != nil && evalFailed && grafanaCtx.Err() == nil && !alertDefinition.IsRecording() {
+		if results := execErrResults(alertDefinition, ctx.now, err); results != nil {
+			sch.processResults(key, alertDefinition.Title, ctx.now, condition, results, stateTracker)
+		}
+	}
+	return alertDefinition, err
+}
+
+// processResults processes the evaluation results of the alert definition in the state tracker,
+// saves, publishes and notifies the alert states changed, labelled with the title of the alert definition,
+// and resolves the alert states of the series that stopped reporting.
+func (sch *schedule) processResults(key models.AlertDefinitionKey, title string, now time.Time, condition models.Condition, results eval.Results, stateTracker *state.StateTracker) {
+	previous := sch.liveSnapshot(key, results, stateTracker)
+	processedStates := stateTracker.ProcessEvalResults(key.DefinitionUID, results, condition)
+	sch.saveAlertStates(stateTracker.StatesToWrite(processedStates, now))
+	sch.publishTransitions(key, previous, processedStates, now)
+	alerts := FromAlertStateToPostableAlerts(processedStates)
+	alerts = withAlertName(append(alerts, sch.resolveMissedSeries(key, now, results, stateTracker)...), title)
+	sch.log.Debug("sending alerts to notifier", "count", len(alerts))
+	if err := sch.sendAlerts(alerts); err != nil {
+		sch.log.Error("failed to put alerts in the notifier", "count", len(alerts), "err", err)
+	}
+}
+
+// errEvaluationStuck is returned for the evaluations abandoned after the evaluation hard timeout.
+var errEvaluationStuck = errors.New("alert definition evaluation exceeded the hard timeout")
+
+// conditionEval evaluates the condition. If the evaluation hard timeout is set and the
+// evaluation doesn't return before it, the evaluation is abandoned, with a stack dump
+// of the running goroutines, and errEvaluationStuck is returned to free the routine.
+func (sch *schedule) conditionEval(ctx context.Context, key models.AlertDefinitionKey, condition *models.Condition, now time.Time) (eval.Results, error) {
+	if sch.evaluationHardTimeout <= 0 {
+		return sch.isolatedConditionEval(ctx, key, condition, now)
+	}
+
+	type evalResult struct {
+		results eval.Results
+		err     error
+	}
+	timer := sch.clock.Timer(sch.evaluationHardTimeout)
+	// buffered so that an abandoned evaluation can still return
+	resultCh := make(chan evalResult, 1)
+	go func() {
+		results, err := sch.isolatedConditionEval(ctx, key, condition, now)
+		resultCh <- evalResult{results: results, err: err}
+	}()
+
+	select {
+	case r := <-resultCh:
+		// the timer is only stopped if it hasn't fired, as the clock owns the timers that fired
+		timer.Stop()
+		return r.results, r.err
+	case <-timer.C:
+		buf := make([]byte, 1<<20)
+		buf = buf[:runtime.Stack(buf, true)]
+		sch.log.Error("alert definition evaluation is stuck, abandoning it", "key", key, "now", now,
+			"hardTimeout", sch.evaluationHardTimeout, "stack", string(buf))
+		return nil, errEvaluationStuck
+	}
+}
+
+// isolatedConditionEval evaluates the condition. If the evaluations are isolated, a panic of the evaluation
+// is recovered, with its stack logged, and the alert definition is errored instead of crashing the scheduler.
+func (sch *schedule) isolatedConditionEval(ctx context.Context, key models.AlertDefinitionKey, condition *models.Condition, now time.Time) (results eval.Results, err error) {
+	if sch.isolateEvaluations {
+		defer func() {
+			if r := recover(); r != nil {
+				metrics.MNGAlertEvaluationPanics.Inc()
+				sch.log.Error("alert definition evaluation panicked", "key", key, "now", now,
+					"panic", r, "stack", string(debug.Stack()))
+				results = errorResults(now)
+				err = nil
+			}
+		}()
+	}
+	return sch.evaluator.ConditionEval(ctx, condition, now, sch.dataService)
+}
+
+// Evaluator evaluates the condition of an alert definition; the evaluation is cancelled with the context.
+type Evaluator interface {
+	ConditionEval(ctx context.Context, condition *models.Condition, now time.Time, dataService *tsdb.Service) (eval.Results, error)
+}
+
 // Notifier handles the delivery of alert notifications to the end user
 type Notifier interface {
 	PutAlerts(alerts ...*notifier.PostableAlert) error
@@ -148,13 +308,75 @@ type schedule struct {
 
 	log log.Logger
 
-	evaluator eval.Evaluator
+	evaluator Evaluator
 
 	store store.Store
 
 	dataService *tsdb.Service
 
 	notifier Notifier
+
+	decisions evalDecisions
+
+	adaptiveCadence *AdaptiveCadence
+
+	subscriberPresence  SubscriberPresence
+	subscribersRequired subscribersRequired
+
+	isOrgEnabled func(orgID int64) bool
+
+	evaluationHardTimeout time.Duration
+	evaluationTimeout     time.Duration
+
+	reconcileInterval time.Duration
+
+	intervalOverrides intervalOverrides
+
+	intervalPolicy IntervalPolicy
+
+	synchronousEval bool
+
+	errorBackoff *ErrorBackoff
+
+	isolateEvaluations bool
+
+	datasourceCache         datasources.CacheService
+	missingDatasourcePolicy MissingDatasourcePolicy
+
+	// tickSkewFunc, if set, is called with the skew of every tick
+	tickSkewFunc func(tick time.Time, skew time.Duration)
+
+	calendars calendars
+
+	latencyStats *LatencyStats
+	latency      latencyRecorder
+
+	livePublisher  LivePublisher
+	livePublishing livePublishing
+
+	evaluationJitter bool
+
+	evaluationPool *evaluationPool
+
+	sharding *Sharding
+	members  shardMembers
+
+	// backoffAppliedFunc and backoffClearedFunc, if set, are called
+	// when an alert definition enters and leaves the error backoff.
+	backoffAppliedFunc func(models.AlertDefinitionKey, time.Time)
+	backoffClearedFunc func(models.AlertDefinitionKey)
+
+	stateCacheWarming    *StateCacheWarming
+	waitForStateCache    bool
+	stateCacheWarmed     chan struct{}
+	stateCacheWarmedOnce sync.Once
+
+	seriesWriter SeriesWriter
+
+	stateFlushInterval time.Duration
+
+	// evaluationPaused is set to 1 while the evaluation of all alert definitions is paused
+	evaluationPaused int32
 }
 
 // SchedulerCfg is the scheduler configuration.
@@ -165,9 +387,75 @@ type SchedulerCfg struct {
 	EvalAppliedFunc func(models.AlertDefinitionKey, time.Time)
 	MaxAttempts     int64
 	StopAppliedFunc func(models.AlertDefinitionKey)
-	Evaluator       eval.Evaluator
+	Evaluator       Evaluator
 	Store           store.Store
 	Notifier        Notifier
+	// AdaptiveCadence, if set, makes the evaluation interval of
+	// alert definitions with stable results adapt to their changes.
+	AdaptiveCadence *AdaptiveCadence
+	// SubscriberPresence reports whether alert definitions have consumers;
+	// it's consulted for the alert definitions that require subscribers.
+	SubscriberPresence SubscriberPresence
+	// IsOrgEnabled reports whether alerting is enabled for an organisation;
+	// if it's nil alerting is enabled for all of them.
+	IsOrgEnabled func(orgID int64) bool
+	// EvaluationHardTimeout, if set, is how long an evaluation can run before
+	// it's considered stuck: it's then abandoned and the alert definition errored,
+	// even if the evaluation ignores the cancellation of its context.
+	EvaluationHardTimeout time.Duration
+	// EvaluationTimeout, if set, is how long an evaluation can run before its context is cancelled
+	// and the alert definition gets the Timeout state, unless its execution error policy is another one.
+	// The alert definitions with an evaluation timeout of their own use it instead.
+	EvaluationTimeout time.Duration
+	// ReconcileInterval is how often the alert definitions are fetched from the store
+	// to pick up their changes; it defaults to, and can't be less than, BaseInterval.
+	ReconcileInterval time.Duration
+	// IntervalPolicy is how the alert definitions with a zero or invalid interval are handled.
+	IntervalPolicy IntervalPolicy
+	// SynchronousEval, if set, makes the alert definitions ready to run on a tick be evaluated
+	// one after the other, in key order, on the ticker goroutine instead of their own routines.
+	// It's meant for tests that need a deterministic evaluation order.
+	SynchronousEval bool
+	// ErrorBackoff, if set, throttles the evaluation of failing alert definitions.
+	ErrorBackoff       *ErrorBackoff
+	BackoffAppliedFunc func(models.AlertDefinitionKey, time.Time)
+	BackoffClearedFunc func(models.AlertDefinitionKey)
+	// IsolateEvaluations, if set, recovers the panics of the evaluations: the alert definition
+	// whose evaluation panicked gets an error result instead of crashing the scheduler.
+	IsolateEvaluations bool
+	// DatasourceCache looks up the datasources the alert definitions queries reference
+	// for the MissingDatasourcePolicy, which applies only if it's set.
+	DatasourceCache         datasources.CacheService
+	MissingDatasourcePolicy MissingDatasourcePolicy
+	// TickSkewFunc, if set, is called with the skew of every tick: how late, on the clock,
+	// the scheduler handles the tick compared to its intended time.
+	TickSkewFunc func(tick time.Time, skew time.Duration)
+	// Calendars are the named calendars alert definitions can be attached to, by name.
+	Calendars map[string]Calendar
+	// LatencyStats, if set, makes the evaluation latency stats of the alert definitions be saved to the store.
+	LatencyStats *LatencyStats
+	// LivePublisher, if set, publishes the state transitions of the alert definitions
+	// set with SetLivePublishing to their Live channel.
+	LivePublisher LivePublisher
+	// EvaluationJitter, if set, spreads the evaluations of the alert definitions with the same interval
+	// across the ticks of their interval, by a hash of their key, to avoid load spikes on the datasources.
+	// It's unset by default so that the alert definitions are evaluated on predictable ticks.
+	EvaluationJitter bool
+	// MaxConcurrentEvaluations, if set, is the maximum number of alert definitions evaluated at once:
+	// the other alert definitions due wait for one of them to finish. The alert definitions waiting
+	// for their evaluation count as running, so their next ticks are skipped.
+	MaxConcurrentEvaluations int
+	// Sharding, if set, shards the alert definitions across the Grafana instances running the scheduler.
+	Sharding *Sharding
+	// StateCacheWarming configures how WarmStateCache loads the saved alert instances.
+	StateCacheWarming *StateCacheWarming
+	// WaitForStateCache, if set, makes the ticker wait for WarmStateCache to finish before handling any tick.
+	WaitForStateCache bool
+	// SeriesWriter writes the series of the recording rules; they fail to run if it isn't set.
+	SeriesWriter SeriesWriter
+	// StateFlushInterval, if set, is how often the alert states whose writes the state tracker coalesced
+	// within its minimum write interval are saved, by batches, instead of waiting for their next evaluation.
+	StateFlushInterval time.Duration
 }
 
 // NewScheduler returns a new schedule.
@@ -186,6 +474,54 @@ func NewScheduler(cfg SchedulerCfg, dataService *tsdb.Service) *schedule {
 		store:           cfg.Store,
 		dataService:     dataService,
 		notifier:        cfg.Notifier,
+		decisions:       evalDecisions{decisions: make(map[models.AlertDefinitionKey]EvalDecision)},
+		adaptiveCadence: cfg.AdaptiveCadence,
+
+		subscriberPresence:  cfg.SubscriberPresence,
+		subscribersRequired: subscribersRequired{keys: make(map[models.AlertDefinitionKey]struct{})},
+		isOrgEnabled:        cfg.IsOrgEnabled,
+
+		evaluationHardTimeout: cfg.EvaluationHardTimeout,
+		evaluationTimeout:     cfg.EvaluationTimeout,
+		reconcileInterval:     cfg.ReconcileInterval,
+		intervalOverrides:     intervalOverrides{overrides: make(map[models.AlertDefinitionKey]intervalOverride)},
+		intervalPolicy:        cfg.IntervalPolicy,
+		synchronousEval:       cfg.SynchronousEval,
+		errorBackoff:          cfg.ErrorBackoff,
+		backoffAppliedFunc:    cfg.BackoffAppliedFunc,
+		backoffClearedFunc:    cfg.BackoffClearedFunc,
+		isolateEvaluations:    cfg.IsolateEvaluations,
+
+		datasourceCache:         cfg.DatasourceCache,
+		missingDatasourcePolicy: cfg.MissingDatasourcePolicy,
+
+		tickSkewFunc: cfg.TickSkewFunc,
+		calendars:    calendars{byName: cfg.Calendars, attached: make(map[models.AlertDefinitionKey]string)},
+
+		latencyStats: cfg.LatencyStats,
+		latency: latencyRecorder{
+			durations: make(map[models.AlertDefinitionKey][]time.Duration),
+			dirty:     make(map[models.AlertDefinitionKey]struct{}),
+		},
+
+		livePublisher:  cfg.LivePublisher,
+		livePublishing: livePublishing{keys: make(map[models.AlertDefinitionKey]struct{})},
+
+		evaluationJitter: cfg.EvaluationJitter,
+		evaluationPool:   newEvaluationPool(cfg.MaxConcurrentEvaluations),
+
+		sharding: cfg.Sharding,
+
+		stateCacheWarming: cfg.StateCacheWarming,
+		waitForStateCache: cfg.WaitForStateCache,
+		stateCacheWarmed:  make(chan struct{}),
+
+		seriesWriter: cfg.SeriesWriter,
+
+		stateFlushInterval: cfg.StateFlushInterval,
+	}
+	if sch.reconcileInterval < sch.baseInterval {
+		sch.reconcileInterval = sch.baseInterval
 	}
 	return &sch
 }
@@ -232,14 +568,63 @@ func (sch *schedule) Unpause() error {
 	return nil
 }
 
+// SetPaused pauses or resumes the evaluation of all alert definitions.
+// Unlike Pause, the ticker keeps running so the alert definitions keep being
+// reconciled with the store, and their state is left intact.
+func (sch *schedule) SetPaused(paused bool) {
+	var value int32
+	if paused {
+		value = 1
+	}
+	if atomic.SwapInt32(&sch.evaluationPaused, value) == value {
+		return
+	}
+	metrics.MNGAlertSchedulerPaused.Set(float64(value))
+	sch.log.Info("alert definition evaluation paused", "paused", paused, "now", sch.clock.Now())
+}
+
+func (sch *schedule) isPaused() bool {
+	return atomic.LoadInt32(&sch.evaluationPaused) == 1
+}
+
 func (sch *schedule) Ticker(grafanaCtx context.Context, stateTracker *state.StateTracker) error {
+	if sch.waitForStateCache {
+		select {
+		case <-sch.stateCacheWarmed:
+		case <-grafanaCtx.Done():
+			return nil
+		}
+	}
 	dispatcherGroup, ctx := errgroup.WithContext(grafanaCtx)
+
+	// the alert states whose writes were coalesced are flushed on the state flush interval, if set
+	var flushC <-chan time.Time
+	if sch.stateFlushInterval > 0 {
+		flushTicker := sch.clock.Ticker(sch.stateFlushInterval)
+		defer flushTicker.Stop()
+		flushC = flushTicker.C
+	}
+
+	// the alert definitions are reconciled with the store on the reconcile interval;
+	// in between, the ticks are scheduled with the alert definitions last fetched
+	var alertDefinitions []*models.AlertDefinition
+	var folderDefaults map[folderKey]models.AlertRuleSettings
+	var lastReconcile time.Time
+	reconciled := false
 	for {
 		select {
 		case tick := <-sch.heartbeat.C:
+			sch.measureTickSkew(tick)
+			sch.flushLatency(tick, false)
+			sch.heartbeatMembership(tick)
 			tickNum := tick.Unix() / int64(sch.baseInterval.Seconds())
-			alertDefinitions := sch.fetchAllDetails(tick)
-			sch.log.Debug("alert definitions fetched", "count", len(alertDefinitions))
+			if !reconciled || tick.Sub(lastReconcile) >= sch.reconcileInterval {
+				alertDefinitions = sch.fetchAllDetails(tick)
+				folderDefaults = sch.fetchFolderDefaults(tick)
+				lastReconcile = tick
+				reconciled = true
+				sch.log.Debug("alert definitions fetched", "count", len(alertDefinitions))
+			}
 
 			// registeredDefinitions is a map used for finding deleted alert definitions
 			// initially it is assigned to all known alert definitions from the previous cycle
@@ -250,41 +635,93 @@ func (sch *schedule) Ticker(grafanaCtx context.Context, stateTracker *state.Stat
 			type readyToRunItem struct {
 				key            models.AlertDefinitionKey
 				definitionInfo alertDefinitionInfo
+				folderDefaults models.AlertRuleSettings
 			}
 			readyToRun := make([]readyToRunItem, 0)
 			for _, item := range alertDefinitions {
+				key := item.GetKey()
 				if item.Paused {
+					sch.decisions.skip(key, tick, SkipReasonPaused)
+					stateTracker.ExpectEvaluations(key.OrgID, key.DefinitionUID, 0, tick)
+					continue
+				}
+
+				defaults := folderDefaults[folderKey{orgID: item.OrgID, namespaceUID: item.NamespaceUID}]
+				intervalSeconds, validInterval := sch.checkInterval(key, item.EffectiveSettings(defaults).IntervalSeconds)
+				if !validInterval {
+					sch.decisions.skip(key, tick, SkipReasonInvalidInterval)
+					stateTracker.ExpectEvaluations(key.OrgID, key.DefinitionUID, 0, tick)
+					continue
+				}
+
+				// the routines of the alert definitions owned by another instance are stopped
+				if !sch.owns(key) {
+					sch.decisions.skip(key, tick, SkipReasonNotOwned)
+					stateTracker.ExpectEvaluations(key.OrgID, key.DefinitionUID, 0, tick)
 					continue
 				}
 
-				key := item.GetKey()
 				itemVersion := item.Version
 				newRoutine := !sch.registry.exists(key)
 				definitionInfo := sch.registry.getOrCreateInfo(key, itemVersion)
-				invalidInterval := item.IntervalSeconds%int64(sch.baseInterval.Seconds()) != 0
 
-				if newRoutine && !invalidInterval {
+				if newRoutine && !sch.synchronousEval {
 					dispatcherGroup.Go(func() error {
+						metrics.MNGAlertActiveRoutines.Inc()
+						defer metrics.MNGAlertActiveRoutines.Dec()
 						return sch.definitionRoutine(ctx, key, definitionInfo.evalCh, definitionInfo.stopCh, stateTracker)
 					})
 				}
 
-				if invalidInterval {
-					// this is expected to be always false
-					// give that we validate interval during alert definition updates
-					sch.log.Debug("alert definition with invalid interval will be ignored: interval should be divided exactly by scheduler interval", "key", key, "interval", time.Duration(item.IntervalSeconds)*time.Second, "scheduler interval", sch.baseInterval)
-					continue
+				itemFrequency := intervalSeconds / int64(sch.baseInterval.Seconds()) * sch.registry.intervalMultiplier(key)
+				if overridden, ok := sch.overriddenIntervalSeconds(key, tick); ok {
+					// overrides are meant to be followed: they don't adapt to the results
+					intervalSeconds = overridden
+					itemFrequency = intervalSeconds / int64(sch.baseInterval.Seconds())
 				}
-
-				itemFrequency := item.IntervalSeconds / int64(sch.baseInterval.Seconds())
-				if item.IntervalSeconds != 0 && tickNum%itemFrequency == 0 {
-					readyToRun = append(readyToRun, readyToRunItem{key: key, definitionInfo: definitionInfo})
+				// the alert definitions deliberately not evaluated don't trip their dead man's switch
+				expectedInterval := time.Duration(itemFrequency) * sch.baseInterval
+				if sch.isPaused() || (sch.isOrgEnabled != nil && !sch.isOrgEnabled(key.OrgID)) || !sch.inCalendar(key, tick) {
+					expectedInterval = 0
+				}
+				stateTracker.ExpectEvaluations(key.OrgID, key.DefinitionUID, expectedInterval, tick)
+				switch {
+				case sch.isPaused():
+					sch.decisions.skip(key, tick, SkipReasonSchedulerPaused)
+				case sch.isOrgEnabled != nil && !sch.isOrgEnabled(key.OrgID):
+					sch.decisions.skip(key, tick, SkipReasonOrgDisabled)
+				case intervalSeconds == 0 || !sch.isDue(key, tickNum, itemFrequency):
+					sch.decisions.skip(key, tick, SkipReasonNotDue)
+				case !sch.inCalendar(key, tick):
+					sch.decisions.skip(key, tick, SkipReasonMaintenance)
+				case tick.Before(sch.registry.backoffUntil(key)):
+					sch.decisions.skip(key, tick, SkipReasonBackoff)
+				case !sch.hasSubscribers(key):
+					sch.decisions.skip(key, tick, SkipReasonNoSubscribers)
+				case sch.registry.isEvalRunning(key):
+					sch.log.Debug("alert definition evaluation is still running, skipping tick", "key", key, "tick", tick)
+					sch.decisions.skip(key, tick, SkipReasonOverlap)
+				default:
+					sch.decisions.evaluate(key, tick)
+					readyToRun = append(readyToRun, readyToRunItem{key: key, definitionInfo: definitionInfo, folderDefaults: defaults})
 				}
 
 				// remove the alert definition from the registered alert definitions
 				delete(registeredDefinitions, key)
 			}
 
+			if sch.synchronousEval {
+				sort.Slice(readyToRun, func(i, j int) bool {
+					return lessKey(readyToRun[i].key, readyToRun[j].key)
+				})
+				for _, item := range readyToRun {
+					_, err := sch.evaluateDefinition(grafanaCtx, item.key, &evalContext{now: tick, version: item.definitionInfo.version, folderDefaults: item.folderDefaults}, nil, stateTracker)
+					sch.applyBackoff(item.key, tick, err)
+					sch.evalApplied(item.key, tick)
+				}
+				readyToRun = nil
+			}
+
 			var step int64 = 0
 			if len(readyToRun) > 0 {
 				step = sch.baseInterval.Nanoseconds() / int64(len(readyToRun))
@@ -294,28 +731,87 @@ func (sch *schedule) Ticker(grafanaCtx context.Context, stateTracker *state.Stat
 				item := readyToRun[i]
 
 				time.AfterFunc(time.Duration(int64(i)*step), func() {
-					item.definitionInfo.evalCh <- &evalContext{now: tick, version: item.definitionInfo.version}
+					item.definitionInfo.evalCh <- &evalContext{now: tick, version: item.definitionInfo.version, folderDefaults: item.folderDefaults}
 				})
 			}
 
-			// unregister and stop routines of the deleted alert definitions
+			// unregister and stop routines of the deleted alert definitions,
+			// and of the ones paused, with an invalid interval or owned by another instance
 			for key := range registeredDefinitions {
 				definitionInfo, err := sch.registry.get(key)
 				if err != nil {
 					sch.log.Error("failed to get alert definition routine information", "err", err)
 					continue
 				}
-				definitionInfo.stopCh <- struct{}{}
+				if sch.synchronousEval {
+					// there is no routine to stop
+					sch.stopApplied(key)
+				} else {
+					definitionInfo.stopCh <- struct{}{}
+				}
 				sch.registry.del(key)
+				sch.forgetLatency(key)
+				stateTracker.SetPendingPeriod(key.OrgID, key.DefinitionUID, 0)
+				stateTracker.SetRuleInstanceLimit(key.OrgID, key.DefinitionUID, 0)
+				stateTracker.ExpectEvaluations(key.OrgID, key.DefinitionUID, 0, tick)
+				// the owner of the alert definition keeps its alert states from now on
+				if !sch.owns(key) {
+					stateTracker.ResetRule(key.OrgID, key.DefinitionUID)
+				}
 			}
+			sch.checkDeadMansSwitch(stateTracker, tick)
+			metrics.MNGAlertStateCacheSize.Set(float64(stateTracker.CacheSize()))
+
+			// forget the decisions of the alert definitions that no longer exist
+			sch.decisions.retain(alertDefinitions)
+		case now := <-flushC:
+			sch.flushStates(stateTracker, now)
 		case <-grafanaCtx.Done():
 			err := dispatcherGroup.Wait()
-			sch.saveAlertStates(stateTracker.GetAll())
+			sch.flushAlertStates(sch.ownedStates(stateTracker.GetAll()))
+			sch.flushLatency(sch.clock.Now(), true)
 			return err
 		}
 	}
 }
 
+// measureTickSkew reports how late the tick is handled compared to its intended time,
+// for instance because the ticker goroutine was not scheduled in time or the previous tick took long.
+func (sch *schedule) measureTickSkew(tick time.Time) {
+	skew := sch.clock.Now().Sub(tick)
+	metrics.MNGAlertTickSkew.Observe(skew.Seconds())
+	if sch.tickSkewFunc != nil {
+		sch.tickSkewFunc(tick, skew)
+	}
+}
+
+// checkDeadMansSwitch saves and sends the alert states synthesized for the alert definitions that stopped being evaluated.
+func (sch *schedule) checkDeadMansSwitch(stateTracker *state.StateTracker, tick time.Time) {
+	states := stateTracker.CheckDeadMansSwitch(tick)
+	if len(states) == 0 {
+		return
+	}
+	sch.saveAlertStates(states)
+	alerts := FromAlertStateToPostableAlerts(states)
+	if err := sch.sendAlerts(alerts); err != nil {
+		sch.log.Error("failed to put dead man's switch alerts in the notifier", "count", len(alerts), "err", err)
+	}
+}
+
+// LastDecision returns whether the alert definition was evaluated on the last tick
+// and, if it was not, the reason. It returns false if there is no decision for the alert definition.
+func (sch *schedule) LastDecision(key models.AlertDefinitionKey) (EvalDecision, bool) {
+	return sch.decisions.get(key)
+}
+
+// lessKey orders the alert definition keys by organisation then UID.
+func lessKey(a, b models.AlertDefinitionKey) bool {
+	if a.OrgID != b.OrgID {
+		return a.OrgID < b.OrgID
+	}
+	return a.DefinitionUID < b.DefinitionUID
+}
+
 func (sch *schedule) sendAlerts(alerts []*notifier.PostableAlert) error {
 	return sch.notifier.PutAlerts(alerts...)
 }
@@ -323,15 +819,7 @@ func (sch *schedule) sendAlerts(alerts []*notifier.PostableAlert) error {
 func (sch *schedule) saveAlertStates(states []state.AlertState) {
 	sch.log.Debug("saving alert states", "count", len(states))
 	for _, s := range states {
-		cmd := models.SaveAlertInstanceCommand{
-			DefinitionOrgID:   s.OrgID,
-			DefinitionUID:     s.UID,
-			Labels:            models.InstanceLabels(s.Labels),
-			State:             models.InstanceStateType(s.State.String()),
-			LastEvalTime:      s.LastEvaluationTime,
-			CurrentStateSince: s.StartsAt,
-			CurrentStateEnd:   s.EndsAt,
-		}
+		cmd := instanceCommand(s)
 		err := sch.store.SaveAlertInstance(&cmd)
 		if err != nil {
 			sch.log.Error("failed to save alert state", "uid", s.UID, "orgId", s.OrgID, "labels", s.Labels.String(), "state", s.State.String(), "msg", err.Error())
@@ -347,48 +835,18 @@ func dataLabelsFromInstanceLabels(il models.InstanceLabels) data.Labels {
 	return lbs
 }
 
-func (sch *schedule) WarmStateCache(st *state.StateTracker) {
-	sch.log.Info("warming cache for startup")
-	st.ResetCache()
-
-	orgIdsCmd := models.FetchUniqueOrgIdsQuery{}
-	if err := sch.store.FetchOrgIds(&orgIdsCmd); err != nil {
-		sch.log.Error("unable to fetch orgIds", "msg", err.Error())
-	}
-
-	var states []state.AlertState
-	for _, orgIdResult := range orgIdsCmd.Result {
-		cmd := models.ListAlertInstancesQuery{
-			DefinitionOrgID: orgIdResult.DefinitionOrgID,
-		}
-		if err := sch.store.ListAlertInstances(&cmd); err != nil {
-			sch.log.Error("unable to fetch previous state", "msg", err.Error())
-		}
-		for _, entry := range cmd.Result {
-			lbs := dataLabelsFromInstanceLabels(entry.Labels)
-			stateForEntry := state.AlertState{
-				UID:                entry.DefinitionUID,
-				OrgID:              entry.DefinitionOrgID,
-				CacheId:            fmt.Sprintf("%s %s", entry.DefinitionUID, lbs),
-				Labels:             lbs,
-				State:              translateInstanceState(entry.CurrentState),
-				Results:            []state.StateEvaluation{},
-				StartsAt:           entry.CurrentStateSince,
-				EndsAt:             entry.CurrentStateEnd,
-				LastEvaluationTime: entry.LastEvalTime,
-			}
-			states = append(states, stateForEntry)
-		}
-	}
-	st.Put(states)
-}
-
 func translateInstanceState(state models.InstanceStateType) eval.State {
 	switch {
 	case state == models.InstanceStateFiring:
 		return eval.Alerting
 	case state == models.InstanceStateNormal:
 		return eval.Normal
+	case state == models.InstanceStateNoData:
+		return eval.NoData
+	case state == models.InstanceStatePending:
+		return eval.Pending
+	case state == models.InstanceStateTimeout:
+		return eval.Timeout
 	default:
 		return eval.Error
 	}
@@ -468,13 +926,100 @@ func (r *alertDefinitionRegistry) keyMap() map[models.AlertDefinitionKey]struct{
 	return definitionsIDs
 }
 
+// setEvalRunning marks whether the routine of the alert definition is evaluating
+func (r *alertDefinitionRegistry) setEvalRunning(key models.AlertDefinitionKey, running bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	info, ok := r.alertDefinitionInfo[key]
+	if !ok {
+		return
+	}
+	info.evalRunning = running
+	r.alertDefinitionInfo[key] = info
+}
+
+func (r *alertDefinitionRegistry) isEvalRunning(key models.AlertDefinitionKey) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.alertDefinitionInfo[key].evalRunning
+}
+
+// intervalMultiplier returns the factor the alert definition interval is widened by
+func (r *alertDefinitionRegistry) intervalMultiplier(key models.AlertDefinitionKey) int64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if m := r.alertDefinitionInfo[key].cadence.multiplier; m > 1 {
+		return m
+	}
+	return 1
+}
+
+// updateCadence applies the function to the cadence of the alert definition
+func (r *alertDefinitionRegistry) updateCadence(key models.AlertDefinitionKey, update func(*cadence)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	info, ok := r.alertDefinitionInfo[key]
+	if !ok {
+		return
+	}
+	update(&info.cadence)
+	r.alertDefinitionInfo[key] = info
+}
+
+// backoffUntil returns the time the alert definition is throttled until by the error backoff
+func (r *alertDefinitionRegistry) backoffUntil(key models.AlertDefinitionKey) time.Time {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.alertDefinitionInfo[key].backoff.until
+}
+
+// updateBackoff applies the function to the error backoff of the alert definition
+func (r *alertDefinitionRegistry) updateBackoff(key models.AlertDefinitionKey, update func(*backoff)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	info, ok := r.alertDefinitionInfo[key]
+	if !ok {
+		return
+	}
+	update(&info.backoff)
+	r.alertDefinitionInfo[key] = info
+}
+
+// setDatasourceMissing records whether the alert definition references a missing datasource
+// and returns true if that changed.
+func (r *alertDefinitionRegistry) setDatasourceMissing(key models.AlertDefinitionKey, missing bool) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	info, ok := r.alertDefinitionInfo[key]
+	if !ok || info.datasourceMissing == missing {
+		return false
+	}
+	info.datasourceMissing = missing
+	r.alertDefinitionInfo[key] = info
+	return true
+}
+
 type alertDefinitionInfo struct {
-	evalCh  chan *evalContext
-	stopCh  chan struct{}
-	version int64
+	evalCh      chan *evalContext
+	stopCh      chan struct{}
+	version     int64
+	evalRunning bool
+	cadence     cadence
+	backoff     backoff
+	// datasourceMissing is set while the alert definition references a missing datasource
+	datasourceMissing bool
 }
 
 type evalContext struct {
 	now     time.Time
 	version int64
+	// folderDefaults are the alert settings of the folder of the alert definition as of now
+	folderDefaults models.AlertRuleSettings
 }
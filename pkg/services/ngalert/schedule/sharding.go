@@ -0,0 +1,150 @@
+package schedule
+
+import (
+	"hash/fnv"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/grafana/grafana/pkg/services/ngalert/models"
+	"github.com/grafana/grafana/pkg/services/ngalert/state"
+)
+
+// defaultMemberTTLMultiple is how many heartbeat intervals a scheduler member remains one without heartbeat
+// when the member TTL of the Sharding is not set.
+const defaultMemberTTLMultiple = 3
+
+// Sharding configures the sharding of the alert definitions across the Grafana instances running the scheduler,
+// so that each alert definition is evaluated by a single one of them instead of all of them. The instances
+// register as scheduler members in the store, renewing their membership every HeartbeatInterval, which
+// defaults to the reconcile interval, and remain members for MemberTTL after their last heartbeat, which
+// defaults to three heartbeat intervals. Every member evaluates the alert definitions it owns, by rendezvous
+// hashing of their key over the members, so that only the alert definitions of the members joining or
+// leaving move when the membership changes.
+type Sharding struct {
+	// InstanceID identifies the instance among the scheduler members; it must be unique.
+	InstanceID        string
+	HeartbeatInterval time.Duration
+	MemberTTL         time.Duration
+}
+
+// shardMembers holds the scheduler members last listed from the store.
+type shardMembers struct {
+	mu            sync.Mutex
+	ids           []string
+	lastHeartbeat time.Time
+}
+
+// ShardOwner returns the scheduler member owning the alert definition: the one with the highest hash
+// of its instance ID and the key of the alert definition. It returns an empty string without members.
+func ShardOwner(members []string, key models.AlertDefinitionKey) string {
+	var owner string
+	var ownerHash uint64
+	for _, member := range members {
+		h := fnv.New64a()
+		_, _ = h.Write([]byte(member + "/" + strconv.FormatInt(key.OrgID, 10) + "/" + key.DefinitionUID))
+		if sum := h.Sum64(); owner == "" || sum > ownerHash {
+			owner, ownerHash = member, sum
+		}
+	}
+	return owner
+}
+
+// ShardMembers returns the instance IDs of the scheduler members sharing the alert definitions,
+// sorted, or nil if the sharding is not enabled.
+func (sch *schedule) ShardMembers() []string {
+	if sch.sharding == nil {
+		return nil
+	}
+	sch.members.mu.Lock()
+	defer sch.members.mu.Unlock()
+	return append([]string(nil), sch.members.ids...)
+}
+
+// heartbeatMembership renews the membership of the instance, if its heartbeat interval elapsed,
+// and lists the current scheduler members from the store. If the members can't be listed,
+// the ones last listed are kept; the instance is always one of them.
+func (sch *schedule) heartbeatMembership(tick time.Time) {
+	if sch.sharding == nil {
+		return
+	}
+	interval := sch.sharding.HeartbeatInterval
+	if interval <= 0 {
+		interval = sch.reconcileInterval
+	}
+	ttl := sch.sharding.MemberTTL
+	if ttl <= 0 {
+		ttl = defaultMemberTTLMultiple * interval
+	}
+
+	m := &sch.members
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.lastHeartbeat.IsZero() && tick.Sub(m.lastHeartbeat) < interval {
+		return
+	}
+	m.lastHeartbeat = tick
+
+	self := sch.sharding.InstanceID
+	cmd := models.SaveSchedulerMemberCommand{Member: models.SchedulerMember{InstanceID: self, LastHeartbeat: tick}}
+	if err := sch.store.SaveSchedulerMember(&cmd); err != nil {
+		sch.log.Error("failed to renew the scheduler membership", "instanceId", self, "err", err)
+	}
+	q := models.ListSchedulerMembersQuery{Since: tick.Add(-ttl)}
+	if err := sch.store.ListSchedulerMembers(&q); err != nil {
+		sch.log.Error("failed to list the scheduler members", "err", err)
+		if len(m.ids) == 0 {
+			m.ids = []string{self}
+		}
+		return
+	}
+
+	ids := []string{self}
+	for _, member := range q.Result {
+		if member.InstanceID != self {
+			ids = append(ids, member.InstanceID)
+		}
+	}
+	sort.Strings(ids)
+	if !equalMembers(ids, m.ids) {
+		sch.log.Info("scheduler members changed, rebalancing the alert definitions", "members", ids, "previous", m.ids)
+	}
+	m.ids = ids
+}
+
+// owns returns true if the instance owns the alert definition; all of them are owned without sharding.
+func (sch *schedule) owns(key models.AlertDefinitionKey) bool {
+	if sch.sharding == nil {
+		return true
+	}
+	sch.members.mu.Lock()
+	defer sch.members.mu.Unlock()
+	return ShardOwner(sch.members.ids, key) == sch.sharding.InstanceID
+}
+
+// ownedStates returns the alert states of the alert definitions the instance owns.
+func (sch *schedule) ownedStates(states []state.AlertState) []state.AlertState {
+	if sch.sharding == nil {
+		return states
+	}
+	owned := make([]state.AlertState, 0, len(states))
+	for _, s := range states {
+		if sch.owns(models.AlertDefinitionKey{OrgID: s.OrgID, DefinitionUID: s.UID}) {
+			owned = append(owned, s)
+		}
+	}
+	return owned
+}
+
+func equalMembers(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
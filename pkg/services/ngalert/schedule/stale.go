@@ -0,0 +1,34 @@
+package schedule
+
+import (
+	"time"
+
+	"github.com/grafana/grafana/pkg/services/ngalert/eval"
+	"github.com/grafana/grafana/pkg/services/ngalert/models"
+	"github.com/grafana/grafana/pkg/services/ngalert/notifier"
+	"github.com/grafana/grafana/pkg/services/ngalert/state"
+)
+
+// resolveMissedSeries resolves the alert states of the alert definition whose series stopped reporting
+// for the missed evaluations of the state tracker, and deletes their alert instances. It returns the alerts
+// resolving the ones that were firing, ending at now, for the notifier to notify their resolution.
+func (sch *schedule) resolveMissedSeries(key models.AlertDefinitionKey, now time.Time, results eval.Results, stateTracker *state.StateTracker) []*notifier.PostableAlert {
+	resolved := stateTracker.ResolveMissedSeries(key.OrgID, key.DefinitionUID, results, now)
+	if len(resolved) == 0 {
+		return nil
+	}
+	sch.log.Debug("resolving alert states of series that stopped reporting", "key", key, "count", len(resolved))
+
+	cmd := models.DeleteAlertInstancesCommand{DefinitionOrgID: key.OrgID, DefinitionUID: key.DefinitionUID}
+	var alerts []*notifier.PostableAlert
+	for _, s := range resolved {
+		cmd.Labels = append(cmd.Labels, models.InstanceLabels(s.Labels))
+		if s.From == eval.Alerting {
+			alerts = append(alerts, postableAlert(s.AlertState))
+		}
+	}
+	if err := sch.store.DeleteAlertInstances(&cmd); err != nil {
+		sch.log.Error("failed to delete alert instances of series that stopped reporting", "key", key, "count", len(cmd.Labels), "msg", err.Error())
+	}
+	return alerts
+}
@@ -0,0 +1,43 @@
+package schedule
+
+import (
+	"sync"
+
+	"github.com/grafana/grafana/pkg/services/ngalert/models"
+)
+
+// SubscriberPresence reports whether an alert definition has active consumers of its state.
+type SubscriberPresence interface {
+	HasSubscribers(models.AlertDefinitionKey) bool
+}
+
+type subscribersRequired struct {
+	mu   sync.Mutex
+	keys map[models.AlertDefinitionKey]struct{}
+}
+
+// SetSubscribersRequired sets whether the alert definition is evaluated only while it has subscribers,
+// as reported by the configured SubscriberPresence.
+func (sch *schedule) SetSubscribersRequired(key models.AlertDefinitionKey, required bool) {
+	sch.subscribersRequired.mu.Lock()
+	defer sch.subscribersRequired.mu.Unlock()
+	if required {
+		sch.subscribersRequired.keys[key] = struct{}{}
+		return
+	}
+	delete(sch.subscribersRequired.keys, key)
+}
+
+// hasSubscribers returns false only if the alert definition requires subscribers and has none
+func (sch *schedule) hasSubscribers(key models.AlertDefinitionKey) bool {
+	if sch.subscriberPresence == nil {
+		return true
+	}
+	sch.subscribersRequired.mu.Lock()
+	_, required := sch.subscribersRequired.keys[key]
+	sch.subscribersRequired.mu.Unlock()
+	if !required {
+		return true
+	}
+	return sch.subscriberPresence.HasSubscribers(key)
+}
@@ -0,0 +1,50 @@
+package schedule
+
+import (
+	"context"
+	"time"
+
+	"github.com/grafana/grafana/pkg/services/ngalert/models"
+)
+
+// evaluationTimeoutOf returns the evaluation timeout of the alert definition: its own if it's set,
+// the one of the scheduler otherwise. Zero means that the evaluations don't time out.
+func (sch *schedule) evaluationTimeoutOf(alertDefinition *models.AlertDefinition) time.Duration {
+	if alertDefinition.EvaluationTimeout > 0 {
+		return alertDefinition.EvaluationTimeout
+	}
+	return sch.evaluationTimeout
+}
+
+// withEvaluationTimeout returns a copy of the context that times out after the evaluation timeout
+// of the alert definition, if any.
+func (sch *schedule) withEvaluationTimeout(ctx context.Context, alertDefinition *models.AlertDefinition) (context.Context, context.CancelFunc) {
+	timeout := sch.evaluationTimeoutOf(alertDefinition)
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// cancelOnStop returns a copy of the context that is cancelled if the routine is stopped, through stopCh,
+// before the returned function is called. The function releases the context and returns whether
+// the routine was stopped; stopCh is not received from after it returns.
+func cancelOnStop(ctx context.Context, stopCh <-chan struct{}) (context.Context, func() bool) {
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	stoppedCh := make(chan bool, 1)
+	go func() {
+		select {
+		case <-stopCh:
+			cancel()
+			stoppedCh <- true
+		case <-done:
+			stoppedCh <- false
+		}
+	}()
+	return ctx, func() bool {
+		close(done)
+		cancel()
+		return <-stoppedCh
+	}
+}
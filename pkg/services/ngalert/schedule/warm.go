@@ -0,0 +1,131 @@
+package schedule
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/grafana/grafana/pkg/services/ngalert/models"
+	"github.com/grafana/grafana/pkg/services/ngalert/state"
+)
+
+// defaultStateCacheWarmingPageSize is the number of alert instances loaded at once
+// when the page size of the StateCacheWarming is not set.
+const defaultStateCacheWarmingPageSize = 1000
+
+// StateCacheWarming configures how the saved alert instances are loaded into the state cache at startup:
+// by pages of PageSize alert instances, for up to Parallelism organisations at once, which defaults to one.
+type StateCacheWarming struct {
+	PageSize    int
+	Parallelism int
+}
+
+// StateCacheWarmed returns a channel that is closed once WarmStateCache finished loading the alert instances.
+func (sch *schedule) StateCacheWarmed() <-chan struct{} {
+	return sch.stateCacheWarmed
+}
+
+// WarmStateCache loads the saved alert instances of all organisations into the state cache, by pages,
+// logging its progress by organisation. The state cache is usable, partially warmed, while it runs.
+func (sch *schedule) WarmStateCache(st *state.StateTracker) {
+	start := timeNow()
+	sch.log.Info("warming cache for startup")
+	st.ResetCache()
+
+	orgIdsCmd := models.FetchUniqueOrgIdsQuery{}
+	if err := sch.store.FetchOrgIds(&orgIdsCmd); err != nil {
+		sch.log.Error("unable to fetch orgIds", "msg", err.Error())
+	}
+
+	pageSize, parallelism := defaultStateCacheWarmingPageSize, 1
+	if sch.stateCacheWarming != nil {
+		if sch.stateCacheWarming.PageSize > 0 {
+			pageSize = sch.stateCacheWarming.PageSize
+		}
+		if sch.stateCacheWarming.Parallelism > 0 {
+			parallelism = sch.stateCacheWarming.Parallelism
+		}
+	}
+
+	orgIDs := make(chan int64)
+	var warmedOrgs, warmedInstances int64
+	var wg sync.WaitGroup
+	for i := 0; i < parallelism; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for orgID := range orgIDs {
+				count := sch.warmOrgStateCache(st, orgID, pageSize)
+				orgs := atomic.AddInt64(&warmedOrgs, 1)
+				instances := atomic.AddInt64(&warmedInstances, int64(count))
+				sch.log.Info("warming cache for startup", "orgId", orgID, "orgInstances", count,
+					"orgs", orgs, "totalOrgs", len(orgIdsCmd.Result), "instances", instances)
+			}
+		}()
+	}
+	for _, orgIdResult := range orgIdsCmd.Result {
+		orgIDs <- orgIdResult.DefinitionOrgID
+	}
+	close(orgIDs)
+	wg.Wait()
+
+	sch.log.Info("cache warmed for startup", "orgs", warmedOrgs, "instances", warmedInstances, "duration", timeNow().Sub(start))
+	sch.stateCacheWarmedOnce.Do(func() {
+		close(sch.stateCacheWarmed)
+	})
+}
+
+// warmOrgStateCache loads the saved alert instances of the organisation into the state cache,
+// page by page, and returns how many it loaded.
+func (sch *schedule) warmOrgStateCache(st *state.StateTracker, orgID int64, pageSize int) int {
+	count := 0
+	cmd := models.ListAlertInstancesQuery{
+		DefinitionOrgID: orgID,
+		Limit:           pageSize,
+	}
+	for {
+		if err := sch.store.ListAlertInstances(&cmd); err != nil {
+			sch.log.Error("unable to fetch previous state", "orgId", orgID, "msg", err.Error())
+			return count
+		}
+		states := make([]state.AlertState, 0, len(cmd.Result))
+		for _, entry := range cmd.Result {
+			if err := upgradeInstance(entry); err != nil {
+				sch.log.Error("skipping alert instance", "uid", entry.DefinitionUID, "orgId", entry.DefinitionOrgID, "labels", entry.Labels, "msg", err.Error())
+				continue
+			}
+			states = append(states, alertStateFromInstance(entry))
+		}
+		st.Put(states)
+		count += len(states)
+
+		if len(cmd.Result) < pageSize {
+			return count
+		}
+		last := cmd.Result[len(cmd.Result)-1]
+		cmd.AfterDefinitionUID, cmd.AfterLabelsHash = last.DefinitionUID, last.LabelsHash
+		cmd.Result = nil
+	}
+}
+
+// alertStateFromInstance returns the alert state of a saved alert instance.
+func alertStateFromInstance(entry *models.ListAlertInstancesQueryResult) state.AlertState {
+	lbs := dataLabelsFromInstanceLabels(entry.Labels)
+	stateForEntry := state.AlertState{
+		UID:                entry.DefinitionUID,
+		OrgID:              entry.DefinitionOrgID,
+		CacheId:            state.CacheID(entry.DefinitionUID, lbs),
+		Labels:             lbs,
+		State:              translateInstanceState(entry.CurrentState),
+		Results:            []state.StateEvaluation{},
+		StartsAt:           entry.CurrentStateSince,
+		EndsAt:             entry.CurrentStateEnd,
+		LastEvaluationTime: entry.LastEvalTime,
+		Origin:             entry.Origin,
+	}
+	// the alert instances persisted before the condition start have none
+	if entry.ConditionStart.After(time.Unix(0, 0)) {
+		stateForEntry.ConditionStartsAt = entry.ConditionStart
+	}
+	return stateForEntry
+}
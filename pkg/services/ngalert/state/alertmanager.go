@@ -0,0 +1,52 @@
+package state
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/grafana/grafana/pkg/services/ngalert/eval"
+	"github.com/grafana/grafana/pkg/setting"
+)
+
+// AMAlert is an alert in the format of the Alertmanager API.
+type AMAlert struct {
+	Labels       map[string]string `json:"labels"`
+	Annotations  map[string]string `json:"annotations"`
+	StartsAt     time.Time         `json:"startsAt"`
+	EndsAt       time.Time         `json:"endsAt"`
+	GeneratorURL string            `json:"generatorURL"`
+}
+
+// AsAlertmanagerAlerts returns the firing alert states of the organisation as Alertmanager alerts,
+// sorted by their cache ID.
+func (st *StateTracker) AsAlertmanagerAlerts(orgID int64) []AMAlert {
+	st.stateCache.mu.Lock()
+	firing := make([]AlertState, 0)
+	for _, s := range st.stateCache.orgs[orgID] {
+		if s.State == eval.Alerting {
+			firing = append(firing, s)
+		}
+	}
+	st.stateCache.mu.Unlock()
+
+	sort.Slice(firing, func(i, j int) bool {
+		return firing[i].CacheId < firing[j].CacheId
+	})
+
+	alerts := make([]AMAlert, 0, len(firing))
+	for _, s := range firing {
+		labels := make(map[string]string, len(s.Labels))
+		for k, v := range s.Labels {
+			labels[k] = v
+		}
+		alerts = append(alerts, AMAlert{
+			Labels:       labels,
+			Annotations:  map[string]string{},
+			StartsAt:     s.StartsAt,
+			EndsAt:       s.EndsAt,
+			GeneratorURL: setting.ToAbsUrl(fmt.Sprintf("alerting/%s/edit", s.UID)),
+		})
+	}
+	return alerts
+}
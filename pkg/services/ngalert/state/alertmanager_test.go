@@ -0,0 +1,52 @@
+package state
+
+import (
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/grafana/grafana/pkg/infra/log"
+	"github.com/grafana/grafana/pkg/services/ngalert/eval"
+	"github.com/grafana/grafana/pkg/services/ngalert/models"
+	"github.com/grafana/grafana/pkg/setting"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAsAlertmanagerAlerts(t *testing.T) {
+	evaluationTime, err := time.Parse("2006-01-02", "2021-03-25")
+	require.NoError(t, err)
+
+	origAppURL := setting.AppUrl
+	setting.AppUrl = "http://localhost:3000/"
+	t.Cleanup(func() { setting.AppUrl = origAppURL })
+
+	st := NewStateTracker(log.New("test_state_tracker"))
+	st.ProcessEvalResults("test_uid", eval.Results{
+		{Instance: data.Labels{"label1": "value1"}, State: eval.Normal, EvaluatedAt: evaluationTime},
+		{Instance: data.Labels{"label1": "value1"}, State: eval.Alerting, EvaluatedAt: evaluationTime.Add(time.Minute)},
+		{Instance: data.Labels{"label2": "value2"}, State: eval.Normal, EvaluatedAt: evaluationTime},
+	}, models.Condition{Condition: "A", OrgID: 1})
+	st.ProcessEvalResults("other_uid", eval.Results{
+		{Instance: data.Labels{"label1": "value1"}, State: eval.Alerting, EvaluatedAt: evaluationTime},
+	}, models.Condition{Condition: "A", OrgID: 2})
+
+	t.Run("a firing state maps to an Alertmanager alert", func(t *testing.T) {
+		alerts := st.AsAlertmanagerAlerts(1)
+		require.Len(t, alerts, 1)
+		assert.Equal(t, AMAlert{
+			Labels:       map[string]string{"label1": "value1"},
+			Annotations:  map[string]string{},
+			StartsAt:     evaluationTime.Add(time.Minute),
+			EndsAt:       evaluationTime.Add(100 * time.Second),
+			GeneratorURL: "http://localhost:3000/alerting/test_uid/edit",
+		}, alerts[0])
+	})
+
+	t.Run("only the states of the organisation are returned", func(t *testing.T) {
+		alerts := st.AsAlertmanagerAlerts(2)
+		require.Len(t, alerts, 1)
+		assert.Equal(t, "http://localhost:3000/alerting/other_uid/edit", alerts[0].GeneratorURL)
+		assert.Empty(t, st.AsAlertmanagerAlerts(3))
+	})
+}
@@ -0,0 +1,318 @@
+package state
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/grafana/grafana/pkg/infra/log"
+	"github.com/grafana/grafana/pkg/infra/metrics"
+	"github.com/grafana/grafana/pkg/services/ngalert/eval"
+)
+
+const (
+	// auditBufferSize is the number of state transitions buffered for the audit sink.
+	auditBufferSize = 1000
+	// auditBlockTimeout is how long recording the state transitions of an evaluation waits
+	// for room in the buffer of the audit sink before they are spilled or dropped.
+	auditBlockTimeout = time.Second
+)
+
+// StateTransition is a change of the state of an alert instance.
+type StateTransition struct {
+	OrgID   int64       `json:"orgId"`
+	UID     string      `json:"uid"`
+	CacheId string      `json:"cacheId"`
+	Labels  data.Labels `json:"labels"`
+	From    eval.State  `json:"-"`
+	To      eval.State  `json:"-"`
+	At      time.Time   `json:"at"`
+	// Actor is the login of the identity the state changed on behalf of.
+	Actor string `json:"actor"`
+	// Origin is the ID of the Grafana instance that produced the transition.
+	Origin string `json:"origin,omitempty"`
+}
+
+// MarshalJSON encodes the states of the transition by name.
+func (t StateTransition) MarshalJSON() ([]byte, error) {
+	type transition StateTransition
+	return json.Marshal(struct {
+		transition
+		From string `json:"from"`
+		To   string `json:"to"`
+	}{transition: transition(t), From: t.From.String(), To: t.To.String()})
+}
+
+// AuditSink receives the state transitions of the alert instances, in order.
+type AuditSink interface {
+	RecordTransition(StateTransition)
+}
+
+// BatchAuditSink is an audit sink that can receive the state transitions of an evaluation at once.
+type BatchAuditSink interface {
+	AuditSink
+	RecordTransitions([]StateTransition)
+}
+
+// SpillAuditSink is an audit sink that must not lose state transitions: those that don't fit
+// in the buffer of the sink in time are recorded synchronously with SpillTransitions instead of being dropped.
+// The spilled transitions may be recorded before transitions buffered earlier.
+type SpillAuditSink interface {
+	AuditSink
+	SpillTransitions([]StateTransition)
+}
+
+// AuditGap is a run of state transitions dropped as the audit sink fell behind.
+type AuditGap struct {
+	Dropped int `json:"dropped"`
+	// From and To are the times of the first and the last dropped transitions.
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+}
+
+// add extends the gap with the dropped transitions.
+func (g *AuditGap) add(transitions []StateTransition) {
+	for _, t := range transitions {
+		if g.Dropped == 0 || t.At.Before(g.From) {
+			g.From = t.At
+		}
+		if t.At.After(g.To) {
+			g.To = t.At
+		}
+		g.Dropped++
+	}
+}
+
+// GapAuditSink is an audit sink that records the gaps of the state transitions it lost,
+// once it catches up, so that the loss can be detected.
+type GapAuditSink interface {
+	AuditSink
+	RecordGap(AuditGap)
+}
+
+// auditBatch is a set of state transitions delivered to the sink at once, if batch is set,
+// or the gap of the transitions dropped since the previous batch.
+type auditBatch struct {
+	transitions []StateTransition
+	batch       bool
+	gap         *AuditGap
+}
+
+// auditor delivers the state transitions to the audit sink from its own goroutine,
+// so that a slow sink doesn't block the evaluations.
+type auditor struct {
+	mu sync.RWMutex
+	ch chan auditBatch
+	// spill is the sink, if it must not lose transitions
+	spill SpillAuditSink
+	// batching is set if the sink receives the transitions of an evaluation at once
+	batching bool
+	// blockTimeout is how long recording waits for room in the buffer
+	blockTimeout time.Duration
+	// gap is the run of transitions dropped and not yet reported to the sink
+	gap    AuditGap
+	gapMu  sync.Mutex
+	logger log.Logger
+}
+
+func (a *auditor) setSink(sink AuditSink) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.ch != nil {
+		// the previous sink still gets the transitions already buffered
+		close(a.ch)
+		a.ch = nil
+		a.spill = nil
+	}
+	a.gapMu.Lock()
+	a.gap = AuditGap{}
+	a.gapMu.Unlock()
+	if sink == nil {
+		return
+	}
+	ch := make(chan auditBatch, auditBufferSize)
+	batchSink, _ := sink.(BatchAuditSink)
+	gapSink, _ := sink.(GapAuditSink)
+	logger := a.logger
+	go func() {
+		for b := range ch {
+			if b.gap != nil {
+				logger.Warn("audit sink caught up after dropping state transitions", "count", b.gap.Dropped, "from", b.gap.From, "to", b.gap.To)
+				if gapSink != nil {
+					gapSink.RecordGap(*b.gap)
+				}
+				continue
+			}
+			if b.batch && batchSink != nil {
+				batchSink.RecordTransitions(b.transitions)
+				continue
+			}
+			for _, t := range b.transitions {
+				sink.RecordTransition(t)
+			}
+		}
+	}()
+	a.ch = ch
+	a.spill, _ = sink.(SpillAuditSink)
+}
+
+func (a *auditor) setBatching(batching bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.batching = batching
+}
+
+// record queues the state transitions of an evaluation for the sink. While the buffer is full,
+// it waits for room up to the block timeout; the transitions that still don't fit are spilled.
+func (a *auditor) record(transitions []StateTransition) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	if a.ch == nil || len(transitions) == 0 {
+		return
+	}
+	deadline := time.Now().Add(a.blockTimeout)
+	if a.batching {
+		a.send(auditBatch{transitions: transitions, batch: true}, deadline)
+		return
+	}
+	for _, t := range transitions {
+		a.send(auditBatch{transitions: []StateTransition{t}}, deadline)
+	}
+}
+
+// send queues the batch, after the gap of the transitions dropped before it, if any.
+// The caller must hold the read lock.
+func (a *auditor) send(b auditBatch, deadline time.Time) {
+	a.reportGap()
+	select {
+	case a.ch <- b:
+		return
+	default:
+	}
+	if wait := time.Until(deadline); wait > 0 {
+		timer := time.NewTimer(wait)
+		select {
+		case a.ch <- b:
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+	}
+	a.overflow(b.transitions)
+}
+
+// overflow spills the transitions that don't fit in the buffer to the sink, if it must not lose them,
+// and drops them otherwise. The caller must hold the read lock.
+func (a *auditor) overflow(transitions []StateTransition) {
+	if a.spill != nil {
+		a.logger.Warn("audit sink is not keeping up, recording state transitions synchronously", "uid", transitions[0].UID, "count", len(transitions), "at", transitions[0].At)
+		a.spill.SpillTransitions(transitions)
+		return
+	}
+	a.logger.Warn("audit sink is not keeping up, dropping state transitions", "uid", transitions[0].UID, "count", len(transitions), "at", transitions[0].At)
+	metrics.MNGAlertAuditDroppedTransitions.Add(float64(len(transitions)))
+	a.gapMu.Lock()
+	defer a.gapMu.Unlock()
+	a.gap.add(transitions)
+}
+
+// reportGap queues the gap of the dropped transitions, if any and if there is room in the buffer.
+// The caller must hold the read lock.
+func (a *auditor) reportGap() {
+	a.gapMu.Lock()
+	defer a.gapMu.Unlock()
+	if a.gap.Dropped == 0 {
+		return
+	}
+	gap := a.gap
+	select {
+	case a.ch <- auditBatch{gap: &gap}:
+		a.gap = AuditGap{}
+	default:
+	}
+}
+
+// SetAuditSink sets the sink receiving every state transition of the alert instances;
+// nil removes it. The transitions are delivered asynchronously. If the sink falls more than
+// auditBufferSize transitions behind, recording waits up to auditBlockTimeout for it; the transitions
+// that still don't fit are spilled synchronously to a SpillAuditSink, and dropped otherwise.
+// The dropped transitions are counted in a metric and reported to a GapAuditSink once it catches up.
+func (st *StateTracker) SetAuditSink(sink AuditSink) {
+	st.auditor.setSink(sink)
+}
+
+// SetAuditBatching sets whether the state transitions of an evaluation of a rule are delivered at once,
+// with a single call to RecordTransitions, to the audit sinks implementing BatchAuditSink, to avoid
+// a notification storm when many series transition together. The other sinks still get them one by one.
+// When batching, the buffer of the sink holds up to auditBufferSize evaluations instead of transitions.
+func (st *StateTracker) SetAuditBatching(batching bool) {
+	st.auditor.setBatching(batching)
+}
+
+type jsonAuditSink struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+// NewJSONAuditSink returns an audit sink appending the state transitions to the writer, one JSON object per line.
+// The gaps of the dropped transitions are appended as {"gap": ...} lines.
+func NewJSONAuditSink(w io.Writer) GapAuditSink {
+	return &jsonAuditSink{enc: json.NewEncoder(w)}
+}
+
+func (s *jsonAuditSink) RecordTransition(t StateTransition) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// the sink has no way to report errors; the writer is expected to handle them
+	_ = s.enc.Encode(t)
+}
+
+// RecordGap appends the gap of the dropped transitions as a line of its own.
+func (s *jsonAuditSink) RecordGap(gap AuditGap) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_ = s.enc.Encode(struct {
+		Gap AuditGap `json:"gap"`
+	}{Gap: gap})
+}
+
+// multiAuditSink fans the state transitions out to several sinks.
+type multiAuditSink []AuditSink
+
+// NewMultiAuditSink returns an audit sink delivering the state transitions to each of the sinks, in turn;
+// those implementing BatchAuditSink get the transitions of an evaluation at once with the audit batching.
+// The transitions that don't fit in the buffer are spilled to the sinks implementing SpillAuditSink
+// and dropped for the others.
+func NewMultiAuditSink(sinks ...AuditSink) BatchAuditSink {
+	return multiAuditSink(sinks)
+}
+
+func (m multiAuditSink) RecordTransition(t StateTransition) {
+	for _, s := range m {
+		s.RecordTransition(t)
+	}
+}
+
+func (m multiAuditSink) RecordTransitions(transitions []StateTransition) {
+	for _, s := range m {
+		if batchSink, ok := s.(BatchAuditSink); ok {
+			batchSink.RecordTransitions(transitions)
+			continue
+		}
+		for _, t := range transitions {
+			s.RecordTransition(t)
+		}
+	}
+}
+
+func (m multiAuditSink) SpillTransitions(transitions []StateTransition) {
+	for _, s := range m {
+		if spillSink, ok := s.(SpillAuditSink); ok {
+			spillSink.SpillTransitions(transitions)
+			continue
+		}
+		metrics.MNGAlertAuditDroppedTransitions.Add(float64(len(transitions)))
+	}
+}
@@ -0,0 +1,298 @@
+package state
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/grafana/grafana/pkg/infra/log"
+	"github.com/grafana/grafana/pkg/infra/metrics"
+	"github.com/grafana/grafana/pkg/services/ngalert/eval"
+	"github.com/grafana/grafana/pkg/services/ngalert/models"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type recordingAuditSink struct {
+	mu          sync.Mutex
+	transitions []StateTransition
+}
+
+func (s *recordingAuditSink) RecordTransition(t StateTransition) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.transitions = append(s.transitions, t)
+}
+
+func (s *recordingAuditSink) recorded() []StateTransition {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]StateTransition{}, s.transitions...)
+}
+
+func TestAuditSink(t *testing.T) {
+	evaluationTime, err := time.Parse("2006-01-02", "2021-03-25")
+	require.NoError(t, err)
+	at := func(i int) time.Time {
+		return evaluationTime.Add(time.Duration(i) * time.Minute)
+	}
+	condition := models.Condition{Condition: "A", OrgID: 123}
+	labels := data.Labels{"label1": "value1"}
+
+	st := NewStateTracker(log.New("test_state_tracker"))
+	sink := &recordingAuditSink{}
+	st.SetAuditSink(sink)
+
+	states := []eval.State{eval.Normal, eval.Alerting, eval.Alerting, eval.Normal, eval.Normal, eval.Alerting}
+	for i, s := range states {
+		st.ProcessEvalResults("test_uid", eval.Results{
+			{Instance: labels, State: s, EvaluatedAt: at(i)},
+			{Instance: data.Labels{"label1": "value2"}, State: eval.Normal, EvaluatedAt: at(i)},
+		}, condition)
+	}
+
+	expected := []StateTransition{
+		{From: eval.Normal, To: eval.Alerting, At: at(1)},
+		{From: eval.Alerting, To: eval.Normal, At: at(3)},
+		{From: eval.Normal, To: eval.Alerting, At: at(5)},
+	}
+	for i := range expected {
+		expected[i].OrgID = 123
+		expected[i].UID = "test_uid"
+		expected[i].CacheId = "test_uid label1=value1"
+		expected[i].Labels = labels
+		expected[i].Actor = eval.ServiceIdentityLogin
+	}
+
+	t.Run("every transition is recorded exactly once", func(t *testing.T) {
+		require.Eventually(t, func() bool {
+			return len(sink.recorded()) >= len(expected)
+		}, time.Second, 10*time.Millisecond)
+		// let a duplicate, if any, be delivered
+		time.Sleep(50 * time.Millisecond)
+		assert.Equal(t, expected, sink.recorded())
+	})
+
+	t.Run("replaying the history is not recorded", func(t *testing.T) {
+		st.ReplayHistory("test_uid", 123, labels, []StateEvaluation{
+			{EvaluationTime: at(10), EvaluationState: eval.Normal},
+			{EvaluationTime: at(11), EvaluationState: eval.Alerting},
+		})
+		time.Sleep(50 * time.Millisecond)
+		assert.Len(t, sink.recorded(), len(expected))
+	})
+
+	t.Run("the JSON sink appends one line per transition", func(t *testing.T) {
+		var buf bytes.Buffer
+		jsonSink := NewJSONAuditSink(&buf)
+		for _, tr := range expected {
+			jsonSink.RecordTransition(tr)
+		}
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		require.Len(t, lines, len(expected))
+
+		var first map[string]interface{}
+		require.NoError(t, json.Unmarshal([]byte(lines[0]), &first))
+		assert.Equal(t, "Normal", first["from"])
+		assert.Equal(t, "Alerting", first["to"])
+		assert.Equal(t, "test_uid label1=value1", first["cacheId"])
+		assert.Equal(t, eval.ServiceIdentityLogin, first["actor"])
+	})
+}
+
+type recordingBatchAuditSink struct {
+	recordingAuditSink
+	batches [][]StateTransition
+}
+
+func (s *recordingBatchAuditSink) RecordTransitions(transitions []StateTransition) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.batches = append(s.batches, transitions)
+}
+
+func (s *recordingBatchAuditSink) recordedBatches() [][]StateTransition {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([][]StateTransition{}, s.batches...)
+}
+
+func TestAuditBatching(t *testing.T) {
+	evaluationTime, err := time.Parse("2006-01-02", "2021-03-25")
+	require.NoError(t, err)
+	condition := models.Condition{Condition: "A", OrgID: 123}
+
+	// evaluate evaluates the rule with many series transitioning from normal to alerting at once
+	evaluate := func(st *StateTracker) {
+		for i, s := range []eval.State{eval.Normal, eval.Alerting} {
+			var results eval.Results
+			for j := 0; j < 10; j++ {
+				results = append(results, eval.Result{
+					Instance:    data.Labels{"series": strconv.Itoa(j)},
+					State:       s,
+					EvaluatedAt: evaluationTime.Add(time.Duration(i) * time.Minute),
+				})
+			}
+			st.ProcessEvalResults("test_uid", results, condition)
+		}
+	}
+
+	t.Run("the transitions of an evaluation are delivered in a single batch", func(t *testing.T) {
+		st := NewStateTracker(log.New("test_state_tracker"))
+		sink := &recordingBatchAuditSink{}
+		st.SetAuditSink(sink)
+		st.SetAuditBatching(true)
+		evaluate(st)
+
+		require.Eventually(t, func() bool {
+			return len(sink.recordedBatches()) > 0
+		}, time.Second, 10*time.Millisecond)
+		time.Sleep(50 * time.Millisecond)
+		batches := sink.recordedBatches()
+		require.Len(t, batches, 1)
+		assert.Len(t, batches[0], 10)
+		assert.Empty(t, sink.recorded())
+	})
+
+	t.Run("the transitions are delivered one by one per series", func(t *testing.T) {
+		st := NewStateTracker(log.New("test_state_tracker"))
+		sink := &recordingBatchAuditSink{}
+		st.SetAuditSink(sink)
+		evaluate(st)
+
+		require.Eventually(t, func() bool {
+			return len(sink.recorded()) == 10
+		}, time.Second, 10*time.Millisecond)
+		assert.Empty(t, sink.recordedBatches())
+	})
+
+	t.Run("sinks that can't batch get the transitions one by one", func(t *testing.T) {
+		st := NewStateTracker(log.New("test_state_tracker"))
+		sink := &recordingAuditSink{}
+		st.SetAuditSink(sink)
+		st.SetAuditBatching(true)
+		evaluate(st)
+
+		require.Eventually(t, func() bool {
+			return len(sink.recorded()) == 10
+		}, time.Second, 10*time.Millisecond)
+	})
+}
+
+func TestMultiAuditSink(t *testing.T) {
+	sink := &recordingAuditSink{}
+	batchSink := &recordingBatchAuditSink{}
+	multi := NewMultiAuditSink(sink, batchSink)
+
+	transitions := []StateTransition{
+		{UID: "uid-1", CacheId: "a", From: eval.Normal, To: eval.Alerting},
+		{UID: "uid-1", CacheId: "b", From: eval.Normal, To: eval.Alerting},
+	}
+	multi.RecordTransitions(transitions)
+	multi.RecordTransition(StateTransition{UID: "uid-1", CacheId: "a", From: eval.Alerting, To: eval.Normal})
+
+	require.Len(t, sink.recorded(), 3)
+	require.Len(t, batchSink.recordedBatches(), 1)
+	require.Equal(t, transitions, batchSink.recordedBatches()[0])
+
+	// the transitions that don't fit in the buffer are spilled to the sinks that must not lose them only
+	store := &fakeHistoryStore{}
+	dropped := testutil.ToFloat64(metrics.MNGAlertAuditDroppedTransitions)
+	NewMultiAuditSink(sink, NewHistorySink(store, log.New("test_state_tracker"))).(SpillAuditSink).SpillTransitions(transitions)
+	require.Len(t, store.saved(), 1)
+	require.Len(t, sink.recorded(), 3)
+	require.Equal(t, dropped+2, testutil.ToFloat64(metrics.MNGAlertAuditDroppedTransitions))
+}
+
+// gatedAuditSink is an audit sink that blocks on its first transition until the gate is opened.
+type gatedAuditSink struct {
+	recordingAuditSink
+	entered chan struct{}
+	gate    chan struct{}
+	once    sync.Once
+	gaps    []AuditGap
+}
+
+func newGatedAuditSink() *gatedAuditSink {
+	return &gatedAuditSink{entered: make(chan struct{}), gate: make(chan struct{})}
+}
+
+func (s *gatedAuditSink) RecordTransition(t StateTransition) {
+	s.once.Do(func() {
+		close(s.entered)
+		<-s.gate
+	})
+	s.recordingAuditSink.RecordTransition(t)
+}
+
+func (s *gatedAuditSink) RecordGap(gap AuditGap) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.gaps = append(s.gaps, gap)
+}
+
+func (s *gatedAuditSink) recordedGaps() []AuditGap {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]AuditGap{}, s.gaps...)
+}
+
+func TestAuditSinkOverflow(t *testing.T) {
+	evaluationTime, err := time.Parse("2006-01-02", "2021-03-25")
+	require.NoError(t, err)
+	transitions := func(from, count int) []StateTransition {
+		transitions := make([]StateTransition, 0, count)
+		for i := from; i < from+count; i++ {
+			transitions = append(transitions, StateTransition{
+				OrgID:   123,
+				UID:     "test_uid",
+				CacheId: strconv.Itoa(i),
+				From:    eval.Normal,
+				To:      eval.Alerting,
+				At:      evaluationTime.Add(time.Duration(i) * time.Second),
+			})
+		}
+		return transitions
+	}
+
+	st := newStateTracker(log.New("test_state_tracker"))
+	st.auditor.blockTimeout = 10 * time.Millisecond
+	sink := newGatedAuditSink()
+	st.SetAuditSink(sink)
+
+	// the sink is stuck on the first transition while the buffer fills up
+	st.auditor.record(transitions(0, 1))
+	<-sink.entered
+	dropped := testutil.ToFloat64(metrics.MNGAlertAuditDroppedTransitions)
+	st.auditor.record(transitions(1, auditBufferSize+3))
+	assert.Equal(t, dropped+3, testutil.ToFloat64(metrics.MNGAlertAuditDroppedTransitions))
+
+	// once the sink catches up, the gap is reported before the next transitions
+	close(sink.gate)
+	require.Eventually(t, func() bool {
+		return len(sink.recorded()) == auditBufferSize+1
+	}, time.Second, 10*time.Millisecond)
+	st.auditor.record(transitions(auditBufferSize+4, 1))
+	require.Eventually(t, func() bool {
+		return len(sink.recorded()) == auditBufferSize+2
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, []AuditGap{{
+		Dropped: 3,
+		From:    evaluationTime.Add(time.Duration(auditBufferSize+1) * time.Second),
+		To:      evaluationTime.Add(time.Duration(auditBufferSize+3) * time.Second),
+	}}, sink.recordedGaps())
+
+	t.Run("the JSON sink appends the gaps", func(t *testing.T) {
+		var buf bytes.Buffer
+		NewJSONAuditSink(&buf).RecordGap(AuditGap{Dropped: 3, From: evaluationTime, To: evaluationTime.Add(time.Minute)})
+		var line map[string]map[string]interface{}
+		require.NoError(t, json.Unmarshal(buf.Bytes(), &line))
+		assert.Equal(t, 3.0, line["gap"]["dropped"])
+	})
+}
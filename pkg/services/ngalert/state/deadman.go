@@ -0,0 +1,161 @@
+package state
+
+import (
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+
+	"github.com/grafana/grafana/pkg/services/ngalert/eval"
+)
+
+// DeadMansSwitchLabel labels, with the UID of the rule, the alert states synthesized for the rules that stopped being evaluated.
+// It's reserved, like the other labels starting with __, so the series of a rule can't collide with its dead man's switch.
+const DeadMansSwitchLabel = "__dead_mans_switch__"
+
+// DeadMansSwitch configures the alerting on the rules that silently stop being evaluated,
+// for instance because of a scheduler bug or a datasource gone.
+type DeadMansSwitch struct {
+	// IntervalMultiple is how many intervals of a rule can elapse without an evaluation
+	// before its dead man's switch fires. Zero disables the dead man's switch.
+	IntervalMultiple int
+}
+
+// expectedEvaluations holds the interval of a rule expected to be evaluated
+// and since when it's expected to.
+type expectedEvaluations struct {
+	interval time.Duration
+	since    time.Time
+}
+
+// deadMansSwitch holds, by rule, the expected evaluations and the time of the last one.
+// It's guarded by the lock of the cache.
+type deadMansSwitch struct {
+	cfg            DeadMansSwitch
+	expected       map[ruleKey]expectedEvaluations
+	lastEvaluation map[ruleKey]time.Time
+}
+
+// SetDeadMansSwitch configures the dead man's switch of the rules expected to be evaluated.
+func (st *StateTracker) SetDeadMansSwitch(cfg DeadMansSwitch) {
+	st.stateCache.mu.Lock()
+	defer st.stateCache.mu.Unlock()
+	st.deadMansSwitch.cfg = cfg
+}
+
+// ExpectEvaluations sets the interval the rule is expected to be evaluated on, from now if it wasn't expected to.
+// A zero interval stops expecting evaluations of the rule, and resolves its dead man's switch on the next check.
+func (st *StateTracker) ExpectEvaluations(orgID int64, uid string, interval time.Duration, now time.Time) {
+	st.stateCache.mu.Lock()
+	defer st.stateCache.mu.Unlock()
+	key := ruleKey{orgID: orgID, uid: uid}
+	if interval <= 0 {
+		delete(st.deadMansSwitch.expected, key)
+		return
+	}
+	expected, ok := st.deadMansSwitch.expected[key]
+	if !ok {
+		expected.since = now
+	}
+	expected.interval = interval
+	st.deadMansSwitch.expected[key] = expected
+}
+
+// recordEvaluation records the evaluation of the rule at the latest time of its results.
+// The caller must hold the lock of the cache.
+func (st *StateTracker) recordEvaluation(orgID int64, uid string, results eval.Results) {
+	key := ruleKey{orgID: orgID, uid: uid}
+	last := st.deadMansSwitch.lastEvaluation[key]
+	for _, r := range results {
+		if r.EvaluatedAt.After(last) {
+			last = r.EvaluatedAt
+		}
+	}
+	st.deadMansSwitch.lastEvaluation[key] = last
+}
+
+// isDeadMansSwitch returns true if the alert state is the dead man's switch of its rule rather than one of its series.
+// The per-rule paths over the series of a rule, such as the instance limit or the missed series, skip it.
+func (s AlertState) isDeadMansSwitch() bool {
+	return s.Labels[DeadMansSwitchLabel] != ""
+}
+
+// CheckDeadMansSwitch fires the dead man's switch of the rules expected to be evaluated that were not evaluated
+// for more than their interval multiple by now, and resolves the ones of the rules evaluated since or no longer expected to.
+// The last evaluation of a rule is also found from the LastEvaluationTime of its alert states,
+// so the dead man's switch keeps working after the alert states are restored from the store.
+// It returns the alert states synthesized for the rules whose dead man's switch changed.
+func (st *StateTracker) CheckDeadMansSwitch(now time.Time) []AlertState {
+	st.stateCache.mu.Lock()
+	defer st.stateCache.mu.Unlock()
+	cfg := st.deadMansSwitch.cfg
+	if cfg.IntervalMultiple <= 0 {
+		return nil
+	}
+
+	lastEvaluations := make(map[ruleKey]time.Time, len(st.deadMansSwitch.lastEvaluation))
+	for key, t := range st.deadMansSwitch.lastEvaluation {
+		lastEvaluations[key] = t
+	}
+	var switches []AlertState
+	for orgID, orgStates := range st.stateCache.orgs {
+		for _, s := range orgStates {
+			key := ruleKey{orgID: orgID, uid: s.UID}
+			if s.isDeadMansSwitch() {
+				switches = append(switches, s)
+				continue
+			}
+			if s.LastEvaluationTime.After(lastEvaluations[key]) {
+				lastEvaluations[key] = s.LastEvaluationTime
+			}
+		}
+	}
+
+	var changed []AlertState
+	for key, expected := range st.deadMansSwitch.expected {
+		last := lastEvaluations[key]
+		// the rules are not expected to be evaluated while the tracker doesn't expect them to, for instance before a restart
+		if expected.since.After(last) {
+			last = expected.since
+		}
+		deadline := last.Add(time.Duration(cfg.IntervalMultiple) * expected.interval)
+		if !now.After(deadline) {
+			continue
+		}
+		labels := data.Labels{DeadMansSwitchLabel: key.uid}
+		cacheId := CacheID(key.uid, labels)
+		s, ok := st.stateCache.orgs[key.orgID][cacheId]
+		if ok && s.State == eval.Alerting {
+			continue
+		}
+		st.Log.Debug("rule stopped being evaluated, firing its dead man's switch", "uid", key.uid, "orgId", key.orgID, "lastEvaluation", last)
+		s = AlertState{
+			UID:                key.uid,
+			OrgID:              key.orgID,
+			CacheId:            cacheId,
+			Labels:             labels,
+			State:              eval.Alerting,
+			Results:            []StateEvaluation{},
+			StartsAt:           deadline,
+			LastEvaluationTime: now,
+		}
+		st.stateCache.put(st.stamp(s))
+		changed = append(changed, st.stateCache.orgs[key.orgID][cacheId])
+	}
+
+	for _, s := range switches {
+		if s.State != eval.Alerting {
+			continue
+		}
+		key := ruleKey{orgID: s.OrgID, uid: s.UID}
+		if expected, ok := st.deadMansSwitch.expected[key]; ok && now.After(lastEvaluations[key].Add(time.Duration(cfg.IntervalMultiple)*expected.interval)) {
+			continue
+		}
+		st.Log.Debug("rule is evaluated again or no longer expected to be, resolving its dead man's switch", "uid", s.UID, "orgId", s.OrgID)
+		s.State = eval.Normal
+		s.EndsAt = now
+		s.LastEvaluationTime = now
+		st.stateCache.put(s)
+		changed = append(changed, s)
+	}
+	return changed
+}
@@ -0,0 +1,133 @@
+package state
+
+import (
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/grafana/grafana/pkg/infra/log"
+	"github.com/grafana/grafana/pkg/services/ngalert/eval"
+	"github.com/grafana/grafana/pkg/services/ngalert/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeadMansSwitch(t *testing.T) {
+	evaluationTime, err := time.Parse("2006-01-02", "2021-03-25")
+	require.NoError(t, err)
+	at := func(i int) time.Time {
+		return evaluationTime.Add(time.Duration(i) * time.Minute)
+	}
+	condition := models.Condition{Condition: "A", OrgID: 123}
+	labels := data.Labels{"label1": "value1"}
+	switchId := CacheID("test_uid", data.Labels{DeadMansSwitchLabel: "test_uid"})
+
+	st := newStateTracker(log.New("test_state_tracker"))
+	st.SetDeadMansSwitch(DeadMansSwitch{IntervalMultiple: 3})
+	st.ExpectEvaluations(123, "test_uid", time.Minute, at(0))
+	evaluate := func(i int) {
+		st.ProcessEvalResults("test_uid", eval.Results{{Instance: labels, State: eval.Normal, EvaluatedAt: at(i)}}, condition)
+	}
+
+	t.Run("the switch doesn't fire while the rule is evaluated", func(t *testing.T) {
+		for i := 1; i <= 5; i++ {
+			evaluate(i)
+			assert.Empty(t, st.CheckDeadMansSwitch(at(i).Add(30*time.Second)))
+		}
+	})
+
+	t.Run("the switch fires after the interval multiple without evaluations", func(t *testing.T) {
+		assert.Empty(t, st.CheckDeadMansSwitch(at(8)), "the rule is within the threshold")
+
+		changed := st.CheckDeadMansSwitch(at(8).Add(time.Second))
+		require.Len(t, changed, 1)
+		assert.Equal(t, switchId, changed[0].CacheId)
+		assert.Equal(t, eval.Alerting, changed[0].State)
+		assert.Equal(t, at(8), changed[0].StartsAt)
+		assert.Equal(t, eval.Alerting, st.Get(123, switchId).State)
+
+		assert.Empty(t, st.CheckDeadMansSwitch(at(9)), "the switch fires once")
+	})
+
+	t.Run("the switch survives the restoration of the alert states", func(t *testing.T) {
+		restored := newStateTracker(log.New("test_state_tracker"))
+		restored.SetDeadMansSwitch(DeadMansSwitch{IntervalMultiple: 3})
+		restored.Put(st.GetAll())
+		restored.ExpectEvaluations(123, "test_uid", time.Minute, at(10))
+		assert.Empty(t, restored.CheckDeadMansSwitch(at(10)))
+		assert.Equal(t, eval.Alerting, restored.Get(123, switchId).State)
+	})
+
+	t.Run("the switch resolves when the rule is evaluated again", func(t *testing.T) {
+		evaluate(10)
+		changed := st.CheckDeadMansSwitch(at(10).Add(time.Second))
+		require.Len(t, changed, 1)
+		assert.Equal(t, eval.Normal, changed[0].State)
+		assert.Equal(t, at(10).Add(time.Second), changed[0].EndsAt)
+	})
+
+	t.Run("the switch doesn't fire for the rules no longer expected to be evaluated", func(t *testing.T) {
+		st.ExpectEvaluations(123, "test_uid", 0, at(11))
+		assert.Empty(t, st.CheckDeadMansSwitch(at(20)))
+	})
+
+	t.Run("the switch fires for the rules never evaluated", func(t *testing.T) {
+		st.ExpectEvaluations(123, "other_uid", time.Minute, at(20))
+		assert.Empty(t, st.CheckDeadMansSwitch(at(23)))
+		changed := st.CheckDeadMansSwitch(at(23).Add(time.Second))
+		require.Len(t, changed, 1)
+		assert.Equal(t, "other_uid", changed[0].UID)
+	})
+
+	t.Run("the switch isn't a series of the rule", func(t *testing.T) {
+		st := newStateTracker(log.New("test_state_tracker"))
+		st.SetDeadMansSwitch(DeadMansSwitch{IntervalMultiple: 3})
+		st.SetRuleInstanceLimit(123, "test_uid", 2)
+		st.ExpectEvaluations(123, "test_uid", time.Minute, at(0))
+		evaluate := func(i int, instances ...data.Labels) []AlertState {
+			results := make(eval.Results, 0, len(instances))
+			for _, instance := range instances {
+				results = append(results, eval.Result{Instance: instance, State: eval.Normal, EvaluatedAt: at(i)})
+			}
+			return st.ProcessEvalResults("test_uid", results, condition)
+		}
+		evaluate(1, labels)
+		changed := st.CheckDeadMansSwitch(at(4).Add(time.Second))
+		require.Len(t, changed, 1)
+		require.Equal(t, eval.Alerting, st.Get(123, switchId).State)
+
+		assert.Equal(t, []string{"label1"}, st.LabelKeysForRule(123, "test_uid"))
+
+		otherLabels := data.Labels{"label1": "value2"}
+		evaluate(5, labels, otherLabels)
+		assert.Equal(t, at(5), st.Get(123, CacheID("test_uid", otherLabels)).LastEvaluationTime,
+			"the switch doesn't count toward the instance limit")
+
+		st.SetStaleSeries(StaleSeries{MissedEvaluations: 1})
+		results := eval.Results{{Instance: labels, State: eval.Normal, EvaluatedAt: at(6)}, {Instance: otherLabels, State: eval.Normal, EvaluatedAt: at(6)}}
+		assert.Empty(t, st.ResolveMissedSeries(123, "test_uid", results, at(6)), "the switch isn't a missed series")
+		assert.Equal(t, 0, st.Get(123, switchId).MissedEvaluations)
+
+		changed = st.CheckDeadMansSwitch(at(6).Add(time.Second))
+		require.Len(t, changed, 1)
+		assert.Equal(t, switchId, changed[0].CacheId)
+		assert.Equal(t, eval.Normal, changed[0].State)
+	})
+
+	t.Run("the series labelled like the switch don't collide with it", func(t *testing.T) {
+		st := newStateTracker(log.New("test_state_tracker"))
+		st.SetDeadMansSwitch(DeadMansSwitch{IntervalMultiple: 3})
+		st.ExpectEvaluations(123, "test_uid", time.Minute, at(0))
+		seriesLabels := data.Labels{"deadmansswitch": "test_uid"}
+		st.ProcessEvalResults("test_uid", eval.Results{{Instance: seriesLabels, State: eval.Alerting, EvaluatedAt: at(1)}}, condition)
+		seriesId := CacheID("test_uid", seriesLabels)
+		require.NotEqual(t, switchId, seriesId)
+
+		changed := st.CheckDeadMansSwitch(at(4).Add(time.Second))
+		require.Len(t, changed, 1)
+		assert.Equal(t, switchId, changed[0].CacheId)
+		series := st.Get(123, seriesId)
+		assert.Equal(t, seriesLabels, series.Labels)
+		assert.Equal(t, at(1), series.LastEvaluationTime)
+	})
+}
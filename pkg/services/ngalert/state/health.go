@@ -0,0 +1,89 @@
+package state
+
+import (
+	"sync"
+	"time"
+
+	"github.com/grafana/grafana/pkg/services/ngalert/eval"
+)
+
+// maxHealthSamples bounds the number of evaluations kept per rule for its health ratio.
+const maxHealthSamples = 1000
+
+type healthSample struct {
+	evaluatedAt time.Time
+	succeeded   bool
+}
+
+type ruleKey struct {
+	orgID int64
+	uid   string
+}
+
+// health holds the recent evaluations of the rules, by rule.
+type health struct {
+	samples map[ruleKey][]healthSample
+	// window is the window of the evaluations the health ratio is computed over.
+	window time.Duration
+	mu     sync.Mutex
+}
+
+// record adds the outcomes of the evaluation results of the rule,
+// dropping the evaluations that fell out of the window.
+func (h *health) record(orgID int64, uid string, results eval.Results) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	key := ruleKey{orgID: orgID, uid: uid}
+	samples := h.samples[key]
+	for _, r := range results {
+		samples = append(samples, healthSample{
+			evaluatedAt: r.EvaluatedAt,
+			succeeded:   r.State == eval.Normal || r.State == eval.Alerting,
+		})
+	}
+	if h.window > 0 && len(samples) > 0 {
+		since := samples[len(samples)-1].evaluatedAt.Add(-h.window)
+		i := 0
+		for i < len(samples) && samples[i].evaluatedAt.Before(since) {
+			i++
+		}
+		samples = samples[i:]
+	}
+	if len(samples) > maxHealthSamples {
+		samples = samples[len(samples)-maxHealthSamples:]
+	}
+	h.samples[key] = samples
+}
+
+// SetHealthWindow sets the window of the evaluations HealthRatio is computed over,
+// ending at the latest evaluation of the rule. Zero computes it over the last evaluations kept.
+func (st *StateTracker) SetHealthWindow(window time.Duration) {
+	st.health.mu.Lock()
+	defer st.health.mu.Unlock()
+	st.health.window = window
+}
+
+// HealthRatio returns the fraction of the recent evaluations of the alert rule, across its alert instances,
+// that succeeded, that is evaluated to Normal or Alerting rather than NoData or Error.
+// It returns 1 if the rule has no evaluations.
+func (st *StateTracker) HealthRatio(orgID int64, uid string) float64 {
+	st.health.mu.Lock()
+	defer st.health.mu.Unlock()
+	samples := st.health.samples[ruleKey{orgID: orgID, uid: uid}]
+	if len(samples) == 0 {
+		return 1
+	}
+
+	since := samples[len(samples)-1].evaluatedAt.Add(-st.health.window)
+	var total, succeeded int
+	for _, s := range samples {
+		if st.health.window > 0 && s.evaluatedAt.Before(since) {
+			continue
+		}
+		total++
+		if s.succeeded {
+			succeeded++
+		}
+	}
+	return float64(succeeded) / float64(total)
+}
@@ -0,0 +1,53 @@
+package state
+
+import (
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/grafana/grafana/pkg/infra/log"
+	"github.com/grafana/grafana/pkg/services/ngalert/eval"
+	"github.com/grafana/grafana/pkg/services/ngalert/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHealthRatio(t *testing.T) {
+	evaluationTime, err := time.Parse("2006-01-02", "2021-03-25")
+	require.NoError(t, err)
+	at := func(i int) time.Time {
+		return evaluationTime.Add(time.Duration(i) * time.Minute)
+	}
+	condition := models.Condition{Condition: "A", OrgID: 123}
+
+	st := NewStateTracker(log.New("test_state_tracker"))
+	assert.Equal(t, float64(1), st.HealthRatio(123, "test_uid"))
+
+	// two instances: 6 of their 8 evaluations succeeded, 3 of the 4 last ones
+	states := []eval.State{eval.Error, eval.Normal, eval.Alerting, eval.NoData}
+	for i, s := range states {
+		st.ProcessEvalResults("test_uid", eval.Results{
+			{Instance: data.Labels{"label1": "value1"}, State: s, EvaluatedAt: at(i)},
+			{Instance: data.Labels{"label1": "value2"}, State: eval.Normal, EvaluatedAt: at(i)},
+		}, condition)
+	}
+	st.ProcessEvalResults("other_uid", eval.Results{
+		{Instance: data.Labels{"label1": "value1"}, State: eval.Error, EvaluatedAt: at(3)},
+	}, condition)
+
+	t.Run("without a window the ratio covers all the evaluations", func(t *testing.T) {
+		assert.Equal(t, 0.75, st.HealthRatio(123, "test_uid"))
+	})
+
+	t.Run("the ratio covers only the evaluations within the window", func(t *testing.T) {
+		st.SetHealthWindow(time.Minute)
+		assert.Equal(t, 0.75, st.HealthRatio(123, "test_uid"))
+		st.SetHealthWindow(30 * time.Second)
+		assert.Equal(t, 0.5, st.HealthRatio(123, "test_uid"))
+	})
+
+	t.Run("the ratio is per rule and organisation", func(t *testing.T) {
+		assert.Equal(t, float64(0), st.HealthRatio(123, "other_uid"))
+		assert.Equal(t, float64(1), st.HealthRatio(1, "test_uid"))
+	})
+}
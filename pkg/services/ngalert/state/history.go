@@ -0,0 +1,50 @@
+package state
+
+import (
+	"github.com/grafana/grafana/pkg/infra/log"
+	ngModels "github.com/grafana/grafana/pkg/services/ngalert/models"
+)
+
+// HistoryStore is the store the alert state history is appended to.
+type HistoryStore interface {
+	SaveAlertStateHistory(*ngModels.SaveAlertStateHistoryCommand) error
+}
+
+type historySink struct {
+	store  HistoryStore
+	logger log.Logger
+}
+
+// NewHistorySink returns an audit sink appending the state transitions to the alert state history of the store.
+// With the audit batching, the transitions of an evaluation are appended at once.
+// The history doesn't lose transitions: those that don't fit in the buffer are appended synchronously.
+func NewHistorySink(store HistoryStore, logger log.Logger) BatchAuditSink {
+	return &historySink{store: store, logger: logger}
+}
+
+func (s *historySink) RecordTransition(t StateTransition) {
+	s.RecordTransitions([]StateTransition{t})
+}
+
+// SpillTransitions appends the transitions that don't fit in the buffer to the history synchronously.
+func (s *historySink) SpillTransitions(transitions []StateTransition) {
+	s.RecordTransitions(transitions)
+}
+
+func (s *historySink) RecordTransitions(transitions []StateTransition) {
+	entries := make([]ngModels.AlertStateHistoryEntry, 0, len(transitions))
+	for _, t := range transitions {
+		entries = append(entries, ngModels.AlertStateHistoryEntry{
+			DefinitionOrgID: t.OrgID,
+			DefinitionUID:   t.UID,
+			Labels:          ngModels.InstanceLabels(t.Labels),
+			PrevState:       ngModels.InstanceStateType(t.From.String()),
+			State:           ngModels.InstanceStateType(t.To.String()),
+			At:              t.At,
+			Origin:          t.Origin,
+		})
+	}
+	if err := s.store.SaveAlertStateHistory(&ngModels.SaveAlertStateHistoryCommand{Entries: entries}); err != nil {
+		s.logger.Error("failed to save alert state history", "uid", transitions[0].UID, "count", len(entries), "err", err)
+	}
+}
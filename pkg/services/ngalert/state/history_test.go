@@ -0,0 +1,134 @@
+package state
+
+import (
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/grafana/grafana/pkg/infra/log"
+	"github.com/grafana/grafana/pkg/services/ngalert/eval"
+	"github.com/grafana/grafana/pkg/services/ngalert/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeHistoryStore struct {
+	mu       sync.Mutex
+	commands []models.SaveAlertStateHistoryCommand
+	// gate, if set, blocks the first save until it's closed
+	gate    chan struct{}
+	entered chan struct{}
+	gated   int32
+}
+
+func (s *fakeHistoryStore) SaveAlertStateHistory(cmd *models.SaveAlertStateHistoryCommand) error {
+	if s.gate != nil && atomic.CompareAndSwapInt32(&s.gated, 0, 1) {
+		close(s.entered)
+		<-s.gate
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.commands = append(s.commands, *cmd)
+	return nil
+}
+
+func (s *fakeHistoryStore) saved() []models.SaveAlertStateHistoryCommand {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]models.SaveAlertStateHistoryCommand{}, s.commands...)
+}
+
+func TestHistorySink(t *testing.T) {
+	evaluationTime, err := time.Parse("2006-01-02", "2021-03-25")
+	require.NoError(t, err)
+	at := func(i int) time.Time {
+		return evaluationTime.Add(time.Duration(i) * time.Minute)
+	}
+	condition := models.Condition{Condition: "A", OrgID: 123}
+	labels1 := data.Labels{"label1": "value1"}
+	labels2 := data.Labels{"label1": "value2"}
+
+	store := &fakeHistoryStore{}
+	st := NewStateTracker(log.New("test_state_tracker"))
+	st.SetInstanceID("instance-a")
+	st.SetAuditSink(NewHistorySink(store, st.Log))
+	st.SetAuditBatching(true)
+
+	for i, s := range []eval.State{eval.Normal, eval.Alerting, eval.Normal} {
+		st.ProcessEvalResults("test_uid", eval.Results{
+			{Instance: labels1, State: s, EvaluatedAt: at(i)},
+			{Instance: labels2, State: s, EvaluatedAt: at(i)},
+		}, condition)
+	}
+
+	entry := func(labels data.Labels, from, to models.InstanceStateType, i int) models.AlertStateHistoryEntry {
+		return models.AlertStateHistoryEntry{
+			DefinitionOrgID: 123,
+			DefinitionUID:   "test_uid",
+			Labels:          models.InstanceLabels(labels),
+			PrevState:       from,
+			State:           to,
+			At:              at(i),
+			Origin:          "instance-a",
+		}
+	}
+	expected := []models.SaveAlertStateHistoryCommand{
+		{Entries: []models.AlertStateHistoryEntry{
+			entry(labels1, models.InstanceStateNormal, models.InstanceStateFiring, 1),
+			entry(labels2, models.InstanceStateNormal, models.InstanceStateFiring, 1),
+		}},
+		{Entries: []models.AlertStateHistoryEntry{
+			entry(labels1, models.InstanceStateFiring, models.InstanceStateNormal, 2),
+			entry(labels2, models.InstanceStateFiring, models.InstanceStateNormal, 2),
+		}},
+	}
+	require.Eventually(t, func() bool {
+		return len(store.saved()) == len(expected)
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, expected, store.saved(), "the transitions of an evaluation are appended at once")
+}
+
+func TestHistorySinkOverflow(t *testing.T) {
+	evaluationTime, err := time.Parse("2006-01-02", "2021-03-25")
+	require.NoError(t, err)
+	transitions := func(from, count int) []StateTransition {
+		transitions := make([]StateTransition, 0, count)
+		for i := from; i < from+count; i++ {
+			transitions = append(transitions, StateTransition{
+				OrgID:  123,
+				UID:    "test_uid",
+				Labels: data.Labels{"series": strconv.Itoa(i)},
+				From:   eval.Normal,
+				To:     eval.Alerting,
+				At:     evaluationTime,
+			})
+		}
+		return transitions
+	}
+
+	store := &fakeHistoryStore{gate: make(chan struct{}), entered: make(chan struct{})}
+	st := newStateTracker(log.New("test_state_tracker"))
+	st.auditor.blockTimeout = 10 * time.Millisecond
+	st.SetAuditSink(NewHistorySink(store, st.Log))
+
+	// the store is stuck on the first transition while more transitions than the buffer holds are recorded
+	st.auditor.record(transitions(0, 1))
+	<-store.entered
+	total := auditBufferSize + 4
+	st.auditor.record(transitions(1, total-1))
+	close(store.gate)
+
+	require.Eventually(t, func() bool {
+		return len(store.saved()) == total
+	}, time.Second, 10*time.Millisecond)
+	series := make(map[string]struct{}, total)
+	for _, cmd := range store.saved() {
+		for _, entry := range cmd.Entries {
+			series[entry.Labels["series"]] = struct{}{}
+		}
+	}
+	assert.Len(t, series, total, "no transition is lost")
+}
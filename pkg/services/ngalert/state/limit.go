@@ -0,0 +1,110 @@
+package state
+
+import (
+	"strconv"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/grafana/grafana/pkg/infra/metrics"
+	"github.com/grafana/grafana/pkg/services/ngalert/eval"
+)
+
+// instanceLimit holds the maximum number of alert instances of the rules and the rules exceeding it.
+// It's guarded by the lock of the cache.
+type instanceLimit struct {
+	// limit is the default maximum number of alert instances of a rule
+	limit int
+	// limits are the maximum numbers of alert instances of the rules that override the default one
+	limits   map[ruleKey]int
+	exceeded map[ruleKey]struct{}
+}
+
+// of returns the maximum number of alert instances of the rule.
+func (l instanceLimit) of(key ruleKey) int {
+	if limit, ok := l.limits[key]; ok {
+		return limit
+	}
+	return l.limit
+}
+
+// SetInstanceLimit sets the default maximum number of alert instances of a rule, to protect the cache from
+// the queries exploding into many series. Zero, the default, disables the limit.
+// Each rule is limited to its own number of alert instances, so that a rule exploding into many series
+// doesn't starve the others. The results of the new alert instances beyond the limit are dropped, and the rule
+// gets an Error result for its alert instance without labels; it gets a Normal one once back within the limit.
+func (st *StateTracker) SetInstanceLimit(limit int) {
+	st.stateCache.mu.Lock()
+	defer st.stateCache.mu.Unlock()
+	st.instanceLimit.limit = limit
+}
+
+// SetRuleInstanceLimit sets the maximum number of alert instances of the rule of the organisation with the given UID,
+// overriding the default one; zero applies the default one.
+func (st *StateTracker) SetRuleInstanceLimit(orgID int64, uid string, limit int) {
+	st.stateCache.mu.Lock()
+	defer st.stateCache.mu.Unlock()
+	key := ruleKey{orgID: orgID, uid: uid}
+	if limit <= 0 {
+		delete(st.instanceLimit.limits, key)
+		return
+	}
+	st.instanceLimit.limits[key] = limit
+}
+
+// applyInstanceLimit returns the results of the rule within its instance limit, with an Error result
+// for the alert instance without labels if it dropped any of them, or a Normal one once it no longer does.
+// The caller must hold the lock of the cache.
+func (st *StateTracker) applyInstanceLimit(orgID int64, uid string, results eval.Results) eval.Results {
+	key := ruleKey{orgID: orgID, uid: uid}
+	_, exceeded := st.instanceLimit.exceeded[key]
+	limit := st.instanceLimit.of(key)
+	if limit <= 0 && !exceeded {
+		return results
+	}
+
+	count := 0
+	for _, s := range st.stateCache.orgs[orgID] {
+		if s.UID == uid && !s.isDeadMansSwitch() {
+			count++
+		}
+	}
+
+	kept := make(eval.Results, 0, len(results))
+	dropped := 0
+	for _, result := range results {
+		if _, ok := st.stateCache.orgs[orgID][CacheID(uid, result.Instance)]; !ok {
+			if limit > 0 && count >= limit {
+				dropped++
+				continue
+			}
+			count++
+		}
+		kept = append(kept, result)
+	}
+	if dropped == 0 {
+		if !exceeded || len(results) == 0 {
+			return results
+		}
+		st.Log.Info("rule is back within the alert instance limit", "uid", uid, "orgId", orgID, "limit", limit)
+		delete(st.instanceLimit.exceeded, key)
+		for _, result := range results {
+			if len(result.Instance) == 0 {
+				return results
+			}
+		}
+		return append(results[:len(results):len(results)], eval.Result{Instance: data.Labels{}, State: eval.Normal, EvaluatedAt: results[0].EvaluatedAt})
+	}
+
+	st.Log.Warn("rule exceeds the alert instance limit, dropping the results of its new alert instances",
+		"uid", uid, "orgId", orgID, "limit", limit, "dropped", dropped)
+	metrics.MNGAlertInstanceLimitDrops.WithLabelValues(strconv.FormatInt(orgID, 10)).Add(float64(dropped))
+	st.instanceLimit.exceeded[key] = struct{}{}
+
+	errorResult := eval.Result{Instance: data.Labels{}, State: eval.Error, EvaluatedAt: results[0].EvaluatedAt}
+	for i, result := range kept {
+		if len(result.Instance) == 0 {
+			kept[i] = errorResult
+			return kept
+		}
+	}
+	return append(kept, errorResult)
+}
@@ -0,0 +1,85 @@
+package state
+
+import (
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/grafana/grafana/pkg/infra/log"
+	"github.com/grafana/grafana/pkg/infra/metrics"
+	"github.com/grafana/grafana/pkg/services/ngalert/eval"
+	"github.com/grafana/grafana/pkg/services/ngalert/models"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInstanceLimit(t *testing.T) {
+	evaluationTime, err := time.Parse("2006-01-02", "2021-03-25")
+	require.NoError(t, err)
+	condition := models.Condition{Condition: "A", OrgID: 123}
+	results := func(instances ...string) eval.Results {
+		r := make(eval.Results, 0, len(instances))
+		for _, instance := range instances {
+			r = append(r, eval.Result{Instance: data.Labels{"instance": instance}, State: eval.Alerting, EvaluatedAt: evaluationTime})
+		}
+		return r
+	}
+	cacheID := func(instance string) string {
+		return CacheID("test_uid", data.Labels{"instance": instance})
+	}
+	drops := func() float64 {
+		return testutil.ToFloat64(metrics.MNGAlertInstanceLimitDrops.WithLabelValues("123"))
+	}
+
+	st := NewStateTracker(log.New("test_state_tracker"))
+	st.SetInstanceLimit(2)
+
+	t.Run("a rule within the limit gets all its alert instances", func(t *testing.T) {
+		dropped := drops()
+		st.ProcessEvalResults("test_uid", results("a", "b"), condition)
+		assert.Equal(t, eval.Alerting, st.Get(123, cacheID("a")).State)
+		assert.Equal(t, eval.Alerting, st.Get(123, cacheID("b")).State)
+		assert.Equal(t, dropped, drops())
+	})
+
+	t.Run("a rule beyond the limit doesn't get new alert instances and is errored", func(t *testing.T) {
+		dropped := drops()
+		st.ProcessEvalResults("test_uid", results("a", "b", "c", "d"), condition)
+		assert.Equal(t, eval.Alerting, st.Get(123, cacheID("a")).State)
+		assert.Equal(t, eval.Alerting, st.Get(123, cacheID("b")).State)
+		assert.Empty(t, st.Get(123, cacheID("c")).CacheId)
+		assert.Empty(t, st.Get(123, cacheID("d")).CacheId)
+		assert.Equal(t, eval.Error, st.Get(123, CacheID("test_uid", data.Labels{})).State)
+		assert.Equal(t, dropped+2, drops())
+	})
+
+	t.Run("a rule back within the limit is no longer errored", func(t *testing.T) {
+		st.ProcessEvalResults("test_uid", results("a", "b"), condition)
+		assert.Equal(t, eval.Normal, st.Get(123, CacheID("test_uid", data.Labels{})).State)
+	})
+
+	t.Run("the other rules have their own limit", func(t *testing.T) {
+		st.ProcessEvalResults("other_uid", results("a", "b"), condition)
+		assert.Equal(t, eval.Alerting, st.Get(123, CacheID("other_uid", data.Labels{"instance": "b"})).State)
+	})
+
+	t.Run("a rule can override the limit", func(t *testing.T) {
+		// the alert instance without labels of the error counts in the limit
+		st.SetRuleInstanceLimit(123, "test_uid", 4)
+		st.ProcessEvalResults("test_uid", results("a", "b", "c", "d"), condition)
+		assert.Equal(t, eval.Alerting, st.Get(123, cacheID("c")).State)
+		assert.Empty(t, st.Get(123, cacheID("d")).CacheId)
+		// the rule of another organisation with the same UID keeps the default limit
+		otherOrg := models.Condition{Condition: "A", OrgID: 456}
+		st.ProcessEvalResults("test_uid", results("a", "b", "c"), otherOrg)
+		assert.Empty(t, st.Get(456, cacheID("c")).CacheId)
+		st.SetRuleInstanceLimit(123, "test_uid", 0)
+	})
+
+	t.Run("disabling the limit lets the rules get new alert instances", func(t *testing.T) {
+		st.SetInstanceLimit(0)
+		st.ProcessEvalResults("test_uid", results("a", "b", "c"), condition)
+		assert.Equal(t, eval.Alerting, st.Get(123, cacheID("c")).State)
+	})
+}
@@ -0,0 +1,26 @@
+package state
+
+import (
+	"github.com/grafana/grafana/pkg/infra/metrics"
+)
+
+// countTransitions records the state transitions in the metrics, by states, unless the state tracker is a preview one.
+func (st *StateTracker) countTransitions(transitions []StateTransition) {
+	if st.preview {
+		return
+	}
+	for _, t := range transitions {
+		metrics.MNGAlertStateTransitions.WithLabelValues(t.From.String(), t.To.String()).Inc()
+	}
+}
+
+// CacheSize returns the number of alert states held by the state tracker, of all the organisations.
+func (st *StateTracker) CacheSize() int {
+	st.stateCache.mu.Lock()
+	defer st.stateCache.mu.Unlock()
+	size := 0
+	for _, states := range st.stateCache.orgs {
+		size += len(states)
+	}
+	return size
+}
@@ -0,0 +1,42 @@
+package state
+
+import (
+	"time"
+
+	"github.com/grafana/grafana/pkg/services/ngalert/eval"
+)
+
+// SetPendingPeriod sets how long the condition of the alert instances of the rule must evaluate to true (Alerting)
+// before they transition to Alerting; in the meantime they are Pending. A zero period transitions them right away.
+func (st *StateTracker) SetPendingPeriod(orgID int64, uid string, period time.Duration) {
+	st.stateCache.mu.Lock()
+	defer st.stateCache.mu.Unlock()
+	key := ruleKey{orgID: orgID, uid: uid}
+	if period <= 0 {
+		delete(st.pendingPeriods, key)
+		return
+	}
+	st.pendingPeriods[key] = period
+}
+
+// setPendingState sets the next state of an alert instance not Alerting whose condition evaluated to true (Alerting),
+// for a rule with a pending period: the instance is Pending until its condition has been true for the period.
+// It returns the state and whether a state transition occurred. The caller must hold the lock of the cache.
+func (st *StateTracker) setPendingState(currentState AlertState, result eval.Result, period time.Duration) (AlertState, bool) {
+	if currentState.State != eval.Pending {
+		st.Log.Debug("state transition to pending", "cacheId", currentState.CacheId, "from", currentState.State.String())
+		currentState.State = eval.Pending
+		currentState.StartsAt = result.EvaluatedAt
+		currentState.ConditionStartsAt = result.EvaluatedAt
+		return st.keepState(currentState, result), true
+	}
+	if result.EvaluatedAt.Sub(currentState.ConditionStartsAt) < period {
+		st.Log.Debug("alert state is pending", "cacheId", currentState.CacheId, "conditionStartsAt", currentState.ConditionStartsAt)
+		return st.keepState(currentState, result), false
+	}
+	st.Log.Debug("state transition from pending to alerting", "cacheId", currentState.CacheId)
+	currentState.State = eval.Alerting
+	currentState.StartsAt = result.EvaluatedAt
+	currentState.EndsAt = result.EvaluatedAt.Add(40 * time.Second)
+	return st.keepState(currentState, result), true
+}
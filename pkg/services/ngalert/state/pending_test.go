@@ -0,0 +1,92 @@
+package state
+
+import (
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/grafana/grafana/pkg/infra/log"
+	"github.com/grafana/grafana/pkg/services/ngalert/eval"
+	"github.com/grafana/grafana/pkg/services/ngalert/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPendingPeriod(t *testing.T) {
+	evaluationTime, err := time.Parse("2006-01-02", "2021-03-25")
+	require.NoError(t, err)
+	at := func(i int) time.Time {
+		return evaluationTime.Add(time.Duration(i) * time.Minute)
+	}
+	condition := models.Condition{Condition: "A", OrgID: 123}
+	labels := data.Labels{"label1": "value1"}
+	cacheId := CacheID("test_uid", labels)
+
+	st := newStateTracker(log.New("test_state_tracker"))
+	st.SetPendingPeriod(123, "test_uid", 2*time.Minute)
+	evaluate := func(st *StateTracker, i int, state eval.State) AlertState {
+		changed := st.ProcessEvalResults("test_uid", eval.Results{{Instance: labels, State: state, EvaluatedAt: at(i)}}, condition)
+		require.Len(t, changed, 1)
+		return changed[0]
+	}
+
+	t.Run("the alert state is pending for the pending period", func(t *testing.T) {
+		s := evaluate(st, 0, eval.Alerting)
+		assert.Equal(t, eval.Pending, s.State)
+		assert.Equal(t, at(0), s.StartsAt)
+		assert.Equal(t, at(0), s.ConditionStartsAt)
+
+		s = evaluate(st, 1, eval.Alerting)
+		assert.Equal(t, eval.Pending, s.State)
+		assert.Equal(t, at(0), s.StartsAt)
+	})
+
+	t.Run("the pending period survives the restoration of the alert states", func(t *testing.T) {
+		restored := newStateTracker(log.New("test_state_tracker"))
+		restored.SetPendingPeriod(123, "test_uid", 2*time.Minute)
+		restored.Put(st.GetAll())
+		s := evaluate(restored, 2, eval.Alerting)
+		assert.Equal(t, eval.Alerting, s.State)
+	})
+
+	t.Run("the alert state is alerting after the pending period", func(t *testing.T) {
+		s := evaluate(st, 2, eval.Alerting)
+		assert.Equal(t, eval.Alerting, s.State)
+		assert.Equal(t, at(2), s.StartsAt)
+		assert.Equal(t, at(0), s.ConditionStartsAt)
+		assert.Equal(t, at(2).Add(40*time.Second), s.EndsAt)
+
+		s = evaluate(st, 3, eval.Normal)
+		assert.Equal(t, eval.Normal, s.State)
+		assert.True(t, s.ConditionStartsAt.IsZero())
+	})
+
+	t.Run("the alert state that stops alerting while pending never alerts", func(t *testing.T) {
+		assert.Equal(t, eval.Pending, evaluate(st, 4, eval.Alerting).State)
+		assert.Equal(t, eval.Normal, evaluate(st, 5, eval.Normal).State)
+		s := evaluate(st, 6, eval.Alerting)
+		assert.Equal(t, eval.Pending, s.State)
+		assert.Equal(t, at(6), s.ConditionStartsAt)
+	})
+
+	t.Run("the alert state that never fired isn't resolved", func(t *testing.T) {
+		st := newStateTracker(log.New("test_state_tracker"))
+		st.SetPendingPeriod(123, "test_uid", 2*time.Minute)
+		st.SetResolvedRetention(time.Minute)
+		assert.Equal(t, eval.Pending, evaluate(st, 0, eval.Alerting).State)
+		s := evaluate(st, 1, eval.Normal)
+		assert.Equal(t, eval.Normal, s.State)
+		assert.True(t, s.ConditionStartsAt.IsZero())
+		assert.True(t, s.EndsAt.IsZero(), "the alert state never started alerting")
+
+		st.evictResolved(at(10))
+		_, ok := st.stateCache.orgs[123][cacheId]
+		assert.True(t, ok, "the alert state is not evicted as resolved")
+	})
+
+	t.Run("without a pending period the alert state alerts right away", func(t *testing.T) {
+		st.SetPendingPeriod(123, "test_uid", 0)
+		assert.Equal(t, eval.Alerting, evaluate(st, 7, eval.Alerting).State)
+		assert.Equal(t, eval.Alerting, st.Get(123, cacheId).State)
+	})
+}
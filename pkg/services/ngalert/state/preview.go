@@ -0,0 +1,22 @@
+package state
+
+import (
+	"github.com/grafana/grafana/pkg/infra/log"
+	"github.com/grafana/grafana/pkg/services/ngalert/eval"
+	ngModels "github.com/grafana/grafana/pkg/services/ngalert/models"
+)
+
+// PreviewStates returns the alert states the evaluation results of the rule produce
+// from scratch, without any previous state, and without touching any state tracker.
+func PreviewStates(uid string, results eval.Results, condition ngModels.Condition, logger log.Logger) []AlertState {
+	return NewPreviewStateTracker(logger).ProcessEvalResults(uid, results, condition)
+}
+
+// NewPreviewStateTracker returns a state tracker without its cleanup process, to preview from scratch
+// the alert states of a rule over successive evaluations; its state transitions aren't counted in the metrics.
+// It's meant to be discarded after the preview.
+func NewPreviewStateTracker(logger log.Logger) *StateTracker {
+	tracker := newStateTracker(logger)
+	tracker.preview = true
+	return tracker
+}
@@ -0,0 +1,61 @@
+package state
+
+import (
+	"sort"
+
+	"github.com/grafana/grafana/pkg/services/ngalert/eval"
+)
+
+// StateQuery filters the current alert states of an organisation.
+type StateQuery struct {
+	OrgID int64
+	// UID, if set, only keeps the alert states of the alert definition.
+	UID string
+	// States, if set, only keeps the alert states in one of them.
+	States []eval.State
+	// Matchers only keep the alert states whose labels match all of them.
+	Matchers []Matcher
+}
+
+// Query returns the current alert states of the organisation matching the query, ordered by cache ID,
+// thus by alert definition UID then labels. Matchers with an invalid regular expression are rejected.
+func (st *StateTracker) Query(q StateQuery) ([]AlertState, error) {
+	matchers := make([]Matcher, 0, len(q.Matchers))
+	for _, m := range q.Matchers {
+		c, err := m.compile()
+		if err != nil {
+			return nil, err
+		}
+		matchers = append(matchers, c)
+	}
+
+	st.stateCache.mu.Lock()
+	states := make([]AlertState, 0)
+	for _, s := range st.stateCache.orgs[q.OrgID] {
+		if q.UID != "" && s.UID != q.UID {
+			continue
+		}
+		if len(q.States) > 0 && !hasState(q.States, s.State) {
+			continue
+		}
+		if !matchAll(matchers, s.Labels) {
+			continue
+		}
+		states = append(states, s)
+	}
+	st.stateCache.mu.Unlock()
+
+	sort.Slice(states, func(i, j int) bool {
+		return states[i].CacheId < states[j].CacheId
+	})
+	return states, nil
+}
+
+func hasState(states []eval.State, state eval.State) bool {
+	for _, s := range states {
+		if s == state {
+			return true
+		}
+	}
+	return false
+}
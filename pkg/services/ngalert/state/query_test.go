@@ -0,0 +1,71 @@
+package state
+
+import (
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/grafana/grafana/pkg/infra/log"
+	"github.com/grafana/grafana/pkg/services/ngalert/eval"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQuery(t *testing.T) {
+	now := time.Unix(1617883200, 0)
+	st := NewStateTracker(log.New("test_state_tracker"))
+	st.Put([]AlertState{
+		{UID: "uid-2", OrgID: 1, CacheId: "uid-2 team=a", Labels: data.Labels{"team": "a"}, State: eval.Alerting, LastEvaluationTime: now},
+		{UID: "uid-1", OrgID: 1, CacheId: "uid-1 team=b", Labels: data.Labels{"team": "b"}, State: eval.Normal, LastEvaluationTime: now},
+		{UID: "uid-1", OrgID: 1, CacheId: "uid-1 team=a", Labels: data.Labels{"team": "a"}, State: eval.Alerting, LastEvaluationTime: now},
+		{UID: "uid-1", OrgID: 1, CacheId: "uid-1 team=c", Labels: data.Labels{"team": "c"}, State: eval.NoData, LastEvaluationTime: now},
+		{UID: "uid-1", OrgID: 2, CacheId: "uid-1 team=a", Labels: data.Labels{"team": "a"}, State: eval.Alerting, LastEvaluationTime: now},
+	})
+
+	testCases := []struct {
+		desc     string
+		query    StateQuery
+		expected []string
+	}{
+		{
+			desc:     "all the alert states of the organisation, by cache ID",
+			query:    StateQuery{OrgID: 1},
+			expected: []string{"uid-1 team=a", "uid-1 team=b", "uid-1 team=c", "uid-2 team=a"},
+		},
+		{
+			desc:     "by alert definition UID",
+			query:    StateQuery{OrgID: 1, UID: "uid-2"},
+			expected: []string{"uid-2 team=a"},
+		},
+		{
+			desc:     "by state",
+			query:    StateQuery{OrgID: 1, States: []eval.State{eval.Alerting, eval.NoData}},
+			expected: []string{"uid-1 team=a", "uid-1 team=c", "uid-2 team=a"},
+		},
+		{
+			desc:     "by label matchers",
+			query:    StateQuery{OrgID: 1, UID: "uid-1", Matchers: []Matcher{{Type: MatchRegexp, Name: "team", Value: "a|b"}}},
+			expected: []string{"uid-1 team=a", "uid-1 team=b"},
+		},
+		{
+			desc:     "unknown organisation",
+			query:    StateQuery{OrgID: 3},
+			expected: []string{},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			states, err := st.Query(tc.query)
+			require.NoError(t, err)
+			cacheIds := make([]string, 0, len(states))
+			for _, s := range states {
+				require.Equal(t, tc.query.OrgID, s.OrgID)
+				cacheIds = append(cacheIds, s.CacheId)
+			}
+			require.Equal(t, tc.expected, cacheIds)
+		})
+	}
+
+	_, err := st.Query(StateQuery{OrgID: 1, Matchers: []Matcher{{Type: MatchRegexp, Name: "team", Value: "("}}})
+	require.Error(t, err)
+}
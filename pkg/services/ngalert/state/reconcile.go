@@ -0,0 +1,157 @@
+package state
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/grafana/grafana/pkg/services/ngalert/eval"
+	ngModels "github.com/grafana/grafana/pkg/services/ngalert/models"
+)
+
+// InstanceStore is the store the alert states are persisted in, as alert instances.
+type InstanceStore interface {
+	GetAlertDefinitionByUID(*ngModels.GetAlertDefinitionByUIDQuery) error
+	ListAlertInstances(*ngModels.ListAlertInstancesQuery) error
+	SaveAlertInstance(*ngModels.SaveAlertInstanceCommand) error
+}
+
+// ReconcileReport lists the discrepancies found between the alert states and the stored alert instances, by cache ID.
+type ReconcileReport struct {
+	// Missing are the alert states without stored alert instance.
+	Missing []string
+	// Diverged are the alert states whose stored alert instance differs.
+	Diverged []string
+	// Deleted are the alert states of rules that no longer exist.
+	Deleted []string
+	// Unknown are the stored alert instances without alert state.
+	Unknown []string
+}
+
+// Empty returns true if no discrepancy was found.
+func (r ReconcileReport) Empty() bool {
+	return len(r.Missing) == 0 && len(r.Diverged) == 0 && len(r.Deleted) == 0 && len(r.Unknown) == 0
+}
+
+// ReconcileWithStore compares the alert states with the alert instances of their organisations in the store,
+// for instance to find the writes that were missed, and logs the discrepancies. If repair is set, the alert states
+// are taken as the reference: the missing and diverged ones are saved and the ones of deleted rules are evicted.
+// The stored alert instances without alert state are only reported, since they may belong to another instance.
+func (st *StateTracker) ReconcileWithStore(store InstanceStore, repair bool) (ReconcileReport, error) {
+	var report ReconcileReport
+	byOrg := make(map[int64][]AlertState)
+	for _, s := range st.GetAll() {
+		byOrg[s.OrgID] = append(byOrg[s.OrgID], s)
+	}
+
+	for orgID, states := range byOrg {
+		q := ngModels.ListAlertInstancesQuery{DefinitionOrgID: orgID}
+		if err := store.ListAlertInstances(&q); err != nil {
+			return report, err
+		}
+		stored := make(map[string]AlertState, len(q.Result))
+		for _, entry := range q.Result {
+			lbs := data.Labels(entry.Labels)
+			cacheId := CacheID(entry.DefinitionUID, lbs)
+			stored[cacheId] = AlertState{
+				UID:                entry.DefinitionUID,
+				OrgID:              entry.DefinitionOrgID,
+				CacheId:            cacheId,
+				Labels:             lbs,
+				State:              evalState(entry.CurrentState),
+				StartsAt:           entry.CurrentStateSince,
+				EndsAt:             entry.CurrentStateEnd,
+				LastEvaluationTime: entry.LastEvalTime,
+			}
+		}
+
+		deleted := make(map[string]bool)
+		for _, s := range states {
+			storedState, ok := stored[s.CacheId]
+			delete(stored, s.CacheId)
+
+			isDeleted, known := deleted[s.UID]
+			if !known {
+				err := store.GetAlertDefinitionByUID(&ngModels.GetAlertDefinitionByUIDQuery{OrgID: orgID, UID: s.UID})
+				if err != nil && !errors.Is(err, ngModels.ErrAlertDefinitionNotFound) {
+					return report, err
+				}
+				isDeleted = err != nil
+				deleted[s.UID] = isDeleted
+			}
+
+			switch {
+			case isDeleted:
+				st.Log.Warn("alert state of a deleted rule", "cacheId", s.CacheId)
+				report.Deleted = append(report.Deleted, s.CacheId)
+				if repair {
+					st.evict(orgID, s.CacheId)
+				}
+				continue
+			case !ok:
+				st.Log.Warn("alert state is not stored", "cacheId", s.CacheId)
+				report.Missing = append(report.Missing, s.CacheId)
+			case !s.EqualsWithin(storedState, time.Second):
+				st.Log.Warn("stored alert instance differs from the alert state", "cacheId", s.CacheId)
+				report.Diverged = append(report.Diverged, s.CacheId)
+			default:
+				continue
+			}
+			if repair {
+				if err := store.SaveAlertInstance(saveCommand(s)); err != nil {
+					return report, err
+				}
+			}
+		}
+
+		for cacheId := range stored {
+			st.Log.Warn("stored alert instance has no alert state", "cacheId", cacheId)
+			report.Unknown = append(report.Unknown, cacheId)
+		}
+	}
+	for _, ids := range [][]string{report.Missing, report.Diverged, report.Deleted, report.Unknown} {
+		sort.Strings(ids)
+	}
+	return report, nil
+}
+
+// evict removes the alert state from the cache.
+func (st *StateTracker) evict(orgID int64, cacheId string) {
+	st.stateCache.mu.Lock()
+	defer st.stateCache.mu.Unlock()
+	delete(st.stateCache.orgs[orgID], cacheId)
+}
+
+// evalState returns the evaluation state of the stored alert instance state.
+func evalState(state ngModels.InstanceStateType) eval.State {
+	switch state {
+	case ngModels.InstanceStateFiring:
+		return eval.Alerting
+	case ngModels.InstanceStateNormal:
+		return eval.Normal
+	case ngModels.InstanceStateNoData:
+		return eval.NoData
+	case ngModels.InstanceStatePending:
+		return eval.Pending
+	case ngModels.InstanceStateTimeout:
+		return eval.Timeout
+	default:
+		return eval.Error
+	}
+}
+
+// saveCommand returns the command saving the alert state as an alert instance.
+func saveCommand(s AlertState) *ngModels.SaveAlertInstanceCommand {
+	return &ngModels.SaveAlertInstanceCommand{
+		DefinitionOrgID:   s.OrgID,
+		DefinitionUID:     s.UID,
+		Labels:            ngModels.InstanceLabels(s.Labels),
+		State:             ngModels.InstanceStateType(s.State.String()),
+		LastEvalTime:      s.LastEvaluationTime,
+		CurrentStateSince: s.StartsAt,
+		CurrentStateEnd:   s.EndsAt,
+		ConditionStart:    s.ConditionStartsAt,
+		Origin:            s.Origin,
+	}
+}
@@ -0,0 +1,160 @@
+package state
+
+import (
+	"fmt"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// MatchType is the type of comparison a silence matcher applies to a label value.
+type MatchType string
+
+const (
+	// MatchEqual matches label values equal to the matcher value.
+	MatchEqual MatchType = "="
+	// MatchRegexp matches label values fully matching the matcher value as a regular expression.
+	MatchRegexp MatchType = "=~"
+)
+
+// Matcher matches the value of the label Name against Value.
+// A missing label matches as an empty value.
+type Matcher struct {
+	Type  MatchType
+	Name  string
+	Value string
+
+	re *regexp.Regexp
+}
+
+// NewMatcher returns the matcher of the label name against the value, with its regular expression compiled.
+func NewMatcher(t MatchType, name, value string) (Matcher, error) {
+	return Matcher{Type: t, Name: name, Value: value}.compile()
+}
+
+// compile validates the matcher and compiles its regular expression, if any and not compiled yet.
+func (m Matcher) compile() (Matcher, error) {
+	if m.re != nil {
+		return m, nil
+	}
+	switch m.Type {
+	case MatchEqual:
+		return m, nil
+	case MatchRegexp:
+		re, err := regexp.Compile("^(?:" + m.Value + ")$")
+		if err != nil {
+			return m, fmt.Errorf("invalid regular expression for label %q: %w", m.Name, err)
+		}
+		m.re = re
+		return m, nil
+	default:
+		return m, fmt.Errorf("invalid match type %q for label %q", m.Type, m.Name)
+	}
+}
+
+func (m Matcher) matches(labels data.Labels) bool {
+	value := labels[m.Name]
+	if m.re != nil {
+		return m.re.MatchString(value)
+	}
+	return value == m.Value
+}
+
+// silence suppresses the state transitions of the series whose labels match all of its matchers, until it ends.
+type silence struct {
+	matchers []Matcher
+	endsAt   time.Time
+}
+
+// expired returns true if the silence has ended at the given time.
+func (s silence) expired(now time.Time) bool {
+	return !now.Before(s.endsAt)
+}
+
+// silences is the registry of the silences, by organisation and ID.
+// A silence matches only the series of the alert rules of its organisation.
+type silences struct {
+	byOrg map[int64]map[string]silence
+	mu    sync.Mutex
+}
+
+func (s *silences) add(orgID int64, id string, endsAt time.Time, matchers []Matcher) error {
+	if len(matchers) == 0 {
+		return fmt.Errorf("silence %q has no matchers", id)
+	}
+	if endsAt.IsZero() {
+		return fmt.Errorf("silence %q has no end time", id)
+	}
+	compiled := make([]Matcher, 0, len(matchers))
+	for _, m := range matchers {
+		c, err := m.compile()
+		if err != nil {
+			return fmt.Errorf("silence %q: %w", id, err)
+		}
+		compiled = append(compiled, c)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	org, ok := s.byOrg[orgID]
+	if !ok {
+		org = make(map[string]silence)
+		s.byOrg[orgID] = org
+	}
+	org[id] = silence{matchers: compiled, endsAt: endsAt}
+	return nil
+}
+
+func (s *silences) remove(orgID int64, id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.byOrg[orgID], id)
+	if len(s.byOrg[orgID]) == 0 {
+		delete(s.byOrg, orgID)
+	}
+}
+
+// silenced returns true if any silence of the organisation active at the given time matches the labels.
+// The silences of the organisation that have ended are dropped.
+func (s *silences) silenced(orgID int64, labels data.Labels, now time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	org := s.byOrg[orgID]
+	matched := false
+	for id, entry := range org {
+		if entry.expired(now) {
+			delete(org, id)
+			continue
+		}
+		if matchAll(entry.matchers, labels) {
+			matched = true
+		}
+	}
+	if len(org) == 0 {
+		delete(s.byOrg, orgID)
+	}
+	return matched
+}
+
+func matchAll(matchers []Matcher, labels data.Labels) bool {
+	for _, m := range matchers {
+		if !m.matches(labels) {
+			return false
+		}
+	}
+	return true
+}
+
+// AddSilence adds, or replaces, the silence of the organisation with the given ID.
+// Until endsAt, the state transitions of the matching alert instances of the organisation are suppressed.
+// Silences without an end time and matchers with an invalid regular expression are rejected.
+func (st *StateTracker) AddSilence(orgID int64, id string, endsAt time.Time, matchers ...Matcher) error {
+	return st.silences.add(orgID, id, endsAt, matchers)
+}
+
+// RemoveSilence removes the silence of the organisation with the given ID.
+func (st *StateTracker) RemoveSilence(orgID int64, id string) {
+	st.silences.remove(orgID, id)
+}
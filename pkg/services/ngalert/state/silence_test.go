@@ -0,0 +1,110 @@
+package state
+
+import (
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/grafana/grafana/pkg/infra/log"
+	"github.com/grafana/grafana/pkg/services/ngalert/eval"
+	"github.com/grafana/grafana/pkg/services/ngalert/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSilences(t *testing.T) {
+	evaluationTime, err := time.Parse("2006-01-02", "2021-03-25")
+	require.NoError(t, err)
+	condition := models.Condition{Condition: "A", OrgID: 123}
+
+	staging := data.Labels{"env": "staging-eu", "team": "a"}
+	production := data.Labels{"env": "production", "team": "a"}
+	results := func(at time.Time, state eval.State) eval.Results {
+		return eval.Results{
+			{Instance: staging, State: state, EvaluatedAt: at},
+			{Instance: production, State: state, EvaluatedAt: at},
+		}
+	}
+
+	st := NewStateTracker(log.New("test_state_tracker"))
+	st.ProcessEvalResults("test_uid", results(evaluationTime, eval.Normal), condition)
+	endsAt := evaluationTime.Add(time.Hour)
+
+	t.Run("invalid regular expressions are rejected", func(t *testing.T) {
+		err := st.AddSilence(123, "invalid", endsAt, Matcher{Type: MatchRegexp, Name: "env", Value: "staging.*("})
+		require.Error(t, err)
+		err = st.AddSilence(123, "unknown", endsAt, Matcher{Type: "!~", Name: "env", Value: "staging.*"})
+		require.Error(t, err)
+		err = st.AddSilence(123, "empty", endsAt)
+		require.Error(t, err)
+		err = st.AddSilence(123, "endless", time.Time{}, Matcher{Type: MatchEqual, Name: "env", Value: "staging-eu"})
+		require.Error(t, err)
+	})
+
+	t.Run("regex silences suppress the transitions of the matching series", func(t *testing.T) {
+		require.NoError(t, st.AddSilence(123, "staging", endsAt, Matcher{Type: MatchRegexp, Name: "env", Value: "staging.*"}))
+		st.ProcessEvalResults("test_uid", results(evaluationTime.Add(time.Minute), eval.Alerting), condition)
+
+		silenced := st.Get(123, "test_uid env=staging-eu, team=a")
+		assert.Equal(t, eval.Normal, silenced.State)
+		assert.Equal(t, evaluationTime.Add(time.Minute), silenced.LastEvaluationTime)
+		assert.Len(t, silenced.Results, 2)
+		assert.Equal(t, eval.Alerting, st.Get(123, "test_uid env=production, team=a").State)
+	})
+
+	t.Run("regular expressions are fully anchored", func(t *testing.T) {
+		require.NoError(t, st.AddSilence(123, "prod", endsAt, Matcher{Type: MatchRegexp, Name: "env", Value: "prod"}))
+		st.ProcessEvalResults("test_uid", results(evaluationTime.Add(2*time.Minute), eval.Normal), condition)
+		assert.Equal(t, eval.Normal, st.Get(123, "test_uid env=production, team=a").State)
+	})
+
+	t.Run("all the matchers of a silence must match", func(t *testing.T) {
+		st.RemoveSilence(123, "staging")
+		st.RemoveSilence(123, "prod")
+		require.NoError(t, st.AddSilence(123, "team-b", endsAt,
+			Matcher{Type: MatchRegexp, Name: "env", Value: ".+"},
+			Matcher{Type: MatchEqual, Name: "team", Value: "b"},
+		))
+		st.ProcessEvalResults("test_uid", results(evaluationTime.Add(3*time.Minute), eval.Alerting), condition)
+		assert.Equal(t, eval.Alerting, st.Get(123, "test_uid env=staging-eu, team=a").State)
+		assert.Equal(t, eval.Alerting, st.Get(123, "test_uid env=production, team=a").State)
+	})
+
+	t.Run("silences stop suppressing the transitions when they end", func(t *testing.T) {
+		st.RemoveSilence(123, "team-b")
+		require.NoError(t, st.AddSilence(123, "staging", evaluationTime.Add(5*time.Minute), Matcher{Type: MatchEqual, Name: "env", Value: "staging-eu"}))
+		st.ProcessEvalResults("test_uid", results(evaluationTime.Add(4*time.Minute), eval.Normal), condition)
+		assert.Equal(t, eval.Alerting, st.Get(123, "test_uid env=staging-eu, team=a").State)
+
+		st.ProcessEvalResults("test_uid", results(evaluationTime.Add(5*time.Minute), eval.Normal), condition)
+		assert.Equal(t, eval.Normal, st.Get(123, "test_uid env=staging-eu, team=a").State)
+		// the ended silence is dropped from the registry
+		assert.NotContains(t, st.silences.byOrg, int64(123))
+	})
+}
+
+func TestSilencesByOrg(t *testing.T) {
+	evaluationTime, err := time.Parse("2006-01-02", "2021-03-25")
+	require.NoError(t, err)
+	labels := data.Labels{"env": "staging-eu"}
+	results := func(state eval.State) eval.Results {
+		return eval.Results{{Instance: labels, State: state, EvaluatedAt: evaluationTime}}
+	}
+
+	st := NewStateTracker(log.New("test_state_tracker"))
+	st.ProcessEvalResults("test_uid", results(eval.Normal), models.Condition{Condition: "A", OrgID: 1})
+	st.ProcessEvalResults("test_uid", results(eval.Normal), models.Condition{Condition: "A", OrgID: 2})
+
+	require.NoError(t, st.AddSilence(1, "staging", evaluationTime.Add(time.Hour), Matcher{Type: MatchEqual, Name: "env", Value: "staging-eu"}))
+	st.ProcessEvalResults("test_uid", results(eval.Alerting), models.Condition{Condition: "A", OrgID: 1})
+	st.ProcessEvalResults("test_uid", results(eval.Alerting), models.Condition{Condition: "A", OrgID: 2})
+
+	// the silence of the first organisation doesn't match the alert instances of the second one
+	assert.Equal(t, eval.Normal, st.Get(1, "test_uid env=staging-eu").State)
+	assert.Equal(t, eval.Alerting, st.Get(2, "test_uid env=staging-eu").State)
+
+	// removing a silence of another organisation with the same ID is a no-op
+	st.RemoveSilence(2, "staging")
+	st.ProcessEvalResults("test_uid", results(eval.Alerting), models.Condition{Condition: "A", OrgID: 1})
+	assert.Equal(t, eval.Normal, st.Get(1, "test_uid env=staging-eu").State)
+}
@@ -0,0 +1,166 @@
+package state
+
+import (
+	"sort"
+	"time"
+
+	"github.com/grafana/grafana/pkg/services/ngalert/eval"
+)
+
+// StaleSeries configures how the alert states of the series that stopped reporting go stale,
+// so that consumers can tell a condition that cleared from data that stopped.
+type StaleSeries struct {
+	// Threshold is how long after its last evaluation the alert state of a series goes stale.
+	// Zero disables the stale states.
+	Threshold time.Duration
+	// Resolve, if set, resolves the stale alert states, which go back to Normal;
+	// otherwise they keep their state. Either way they are flagged as Stale.
+	Resolve bool
+	// Retention is how long the stale alert states are kept before they are evicted;
+	// zero keeps them until their series reports again.
+	Retention time.Duration
+	// MissedEvaluations is the number of consecutive evaluations of its rule without its series
+	// after which an alert state is resolved and removed with ResolveMissedSeries. Zero disables it.
+	MissedEvaluations int
+}
+
+// ResolvedState is the alert state of a series that stopped reporting, resolved and removed from the cache.
+type ResolvedState struct {
+	AlertState
+	// From is the state of the alert state before it was resolved.
+	From eval.State
+}
+
+// SetStaleSeries configures the stale alert states. The alert states of a series that reports
+// again are no longer stale, and go through the state transitions of its evaluations.
+// The alert states are checked for staleness every Threshold.
+func (st *StateTracker) SetStaleSeries(cfg StaleSeries) {
+	st.stateCache.mu.Lock()
+	st.staleSeries = cfg
+	st.stateCache.mu.Unlock()
+	st.reconfigureCleanUp()
+}
+
+// markStale flags the alert states whose series stopped reporting as stale
+// and evicts the ones that have been stale for longer than the retention.
+func (st *StateTracker) markStale(now time.Time) {
+	st.stateCache.mu.Lock()
+	defer st.stateCache.mu.Unlock()
+	cfg := st.staleSeries
+	if cfg.Threshold <= 0 {
+		return
+	}
+	for _, orgStates := range st.stateCache.orgs {
+		for id, v := range orgStates {
+			// the dead man's switch isn't evaluated: it resolves with CheckDeadMansSwitch
+			if v.isDeadMansSwitch() {
+				continue
+			}
+			staleSince := v.LastEvaluationTime.Add(cfg.Threshold)
+			if !now.After(staleSince) {
+				continue
+			}
+			if cfg.Retention > 0 && now.Sub(staleSince) > cfg.Retention {
+				st.Log.Debug("evicting stale alert state", "cacheId", id, "staleSince", staleSince)
+				delete(orgStates, id)
+				continue
+			}
+			if v.Stale {
+				continue
+			}
+			st.Log.Debug("alert state is stale", "cacheId", id, "lastEvaluationTime", v.LastEvaluationTime)
+			v.Stale = true
+			if cfg.Resolve && v.State != eval.Normal {
+				v.State = eval.Normal
+				v.EndsAt = staleSince
+			}
+			orgStates[id] = v
+		}
+	}
+}
+
+// ResolveMissedSeries counts the evaluation of the rule with the results for the alert states of the rule
+// whose series the results don't report: those that miss MissedEvaluations consecutive evaluations are resolved,
+// going back to Normal at now with a state transition, and removed from the cache. They're returned so that
+// their resolution can be notified and their alert instances deleted. The evaluations without results
+// or with only Error or Timeout ones don't count, as they don't tell which series stopped reporting.
+func (st *StateTracker) ResolveMissedSeries(orgID int64, uid string, results eval.Results, now time.Time) []ResolvedState {
+	st.stateCache.mu.Lock()
+	if st.staleSeries.MissedEvaluations <= 0 || !reportsSeries(results) {
+		st.stateCache.mu.Unlock()
+		return nil
+	}
+	reported := make(map[string]struct{}, len(results))
+	for _, r := range results {
+		reported[CacheID(uid, r.Instance)] = struct{}{}
+	}
+	var resolved []ResolvedState
+	orgStates := st.stateCache.orgs[orgID]
+	for id, s := range orgStates {
+		if s.UID != uid || s.isDeadMansSwitch() {
+			continue
+		}
+		if _, ok := reported[id]; ok {
+			if s.MissedEvaluations > 0 {
+				s.MissedEvaluations = 0
+				orgStates[id] = s
+			}
+			continue
+		}
+		s.MissedEvaluations++
+		if s.MissedEvaluations < st.staleSeries.MissedEvaluations {
+			orgStates[id] = s
+			continue
+		}
+		st.Log.Debug("resolving alert state of a series that stopped reporting", "cacheId", id, "missedEvaluations", s.MissedEvaluations)
+		delete(orgStates, id)
+		key := seriesKey{orgID: orgID, cacheID: id}
+		delete(st.writes.pending, key)
+		delete(st.writes.written, key)
+
+		r := ResolvedState{AlertState: s, From: s.State}
+		if s.State != eval.Normal {
+			r.State = eval.Normal
+			r.EndsAt = now
+		}
+		r.Stale = true
+		r.ConditionStartsAt = time.Time{}
+		r.Origin = st.instanceID
+		resolved = append(resolved, r)
+	}
+	st.stateCache.mu.Unlock()
+
+	sort.Slice(resolved, func(i, j int) bool {
+		return resolved[i].CacheId < resolved[j].CacheId
+	})
+	var transitions []StateTransition
+	for _, r := range resolved {
+		if r.From == r.State {
+			continue
+		}
+		transitions = append(transitions, StateTransition{
+			OrgID:   r.OrgID,
+			UID:     r.UID,
+			CacheId: r.CacheId,
+			Labels:  r.Labels,
+			From:    r.From,
+			To:      r.State,
+			At:      now,
+			Actor:   eval.ServiceIdentityLogin,
+			Origin:  r.Origin,
+		})
+	}
+	st.countTransitions(transitions)
+	st.auditor.record(transitions)
+	return resolved
+}
+
+// reportsSeries returns true if the evaluation results tell which series reported.
+func reportsSeries(results eval.Results) bool {
+	for _, r := range results {
+		if r.State != eval.Error && r.State != eval.Timeout {
+			return true
+		}
+	}
+	return false
+}
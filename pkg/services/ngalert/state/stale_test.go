@@ -0,0 +1,159 @@
+package state
+
+import (
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/grafana/grafana/pkg/infra/log"
+	"github.com/grafana/grafana/pkg/services/ngalert/eval"
+	"github.com/grafana/grafana/pkg/services/ngalert/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStaleSeries(t *testing.T) {
+	evaluationTime, err := time.Parse("2006-01-02", "2021-03-25")
+	require.NoError(t, err)
+	condition := models.Condition{Condition: "A", OrgID: 123}
+	labels := data.Labels{"label1": "value1"}
+	cacheId := "test_uid label1=value1"
+
+	testCases := []struct {
+		desc          string
+		resolve       bool
+		expectedState eval.State
+	}{
+		{desc: "stale states keep their state", expectedState: eval.Alerting},
+		{desc: "stale states are resolved", resolve: true, expectedState: eval.Normal},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			st := NewStateTracker(log.New("test_state_tracker"))
+			st.SetStaleSeries(StaleSeries{Threshold: 5 * time.Minute, Resolve: tc.resolve, Retention: time.Hour})
+			st.ProcessEvalResults("test_uid", eval.Results{
+				{Instance: labels, State: eval.Normal, EvaluatedAt: evaluationTime},
+				{Instance: labels, State: eval.Alerting, EvaluatedAt: evaluationTime.Add(time.Minute)},
+			}, condition)
+			lastEvaluation := evaluationTime.Add(time.Minute)
+
+			st.markStale(lastEvaluation.Add(5 * time.Minute))
+			assert.False(t, st.Get(123, cacheId).Stale, "the series is not stale within the threshold")
+
+			st.markStale(lastEvaluation.Add(5*time.Minute + time.Second))
+			s := st.Get(123, cacheId)
+			assert.True(t, s.Stale)
+			assert.Equal(t, tc.expectedState, s.State)
+			if tc.resolve {
+				assert.Equal(t, lastEvaluation.Add(5*time.Minute), s.EndsAt)
+			}
+
+			st.markStale(lastEvaluation.Add(time.Hour + 5*time.Minute))
+			assert.Equal(t, cacheId, st.Get(123, cacheId).CacheId, "the stale state is kept during the retention")
+
+			st.markStale(lastEvaluation.Add(time.Hour + 5*time.Minute + time.Second))
+			assert.Empty(t, st.Get(123, cacheId).CacheId, "the stale state is evicted after the retention")
+		})
+	}
+
+	t.Run("series reporting again are no longer stale", func(t *testing.T) {
+		st := NewStateTracker(log.New("test_state_tracker"))
+		st.SetStaleSeries(StaleSeries{Threshold: 5 * time.Minute, Resolve: true})
+		st.ProcessEvalResults("test_uid", eval.Results{{Instance: labels, State: eval.Alerting, EvaluatedAt: evaluationTime}}, condition)
+		st.markStale(evaluationTime.Add(time.Hour))
+		require.True(t, st.Get(123, cacheId).Stale)
+
+		reportedAt := evaluationTime.Add(time.Hour)
+		st.ProcessEvalResults("test_uid", eval.Results{{Instance: labels, State: eval.Alerting, EvaluatedAt: reportedAt}}, condition)
+		s := st.Get(123, cacheId)
+		assert.False(t, s.Stale)
+		assert.Equal(t, eval.Alerting, s.State)
+		assert.Equal(t, reportedAt, s.StartsAt)
+	})
+}
+
+func TestStaleSeriesCleanUp(t *testing.T) {
+	mock := clock.NewMock()
+	start := mock.Now()
+	st := NewStateTrackerWithClock(log.New("test_state_tracker"), mock)
+	st.SetStaleSeries(StaleSeries{Threshold: time.Minute})
+	st.ProcessEvalResults("test_uid", eval.Results{
+		{Instance: data.Labels{"label1": "value1"}, State: eval.Alerting, EvaluatedAt: start},
+	}, models.Condition{Condition: "A", OrgID: 123})
+	assert.False(t, st.Get(123, "test_uid label1=value1").Stale)
+
+	// the alert states are checked for staleness on the clock of the state tracker, every threshold
+	require.Eventually(t, func() bool {
+		mock.Add(time.Minute)
+		return st.Get(123, "test_uid label1=value1").Stale
+	}, time.Second, 10*time.Millisecond)
+	assert.Less(t, int64(mock.Now().Sub(start)), int64(trimInterval))
+}
+
+func TestResolveMissedSeries(t *testing.T) {
+	evaluationTime, err := time.Parse("2006-01-02", "2021-03-25")
+	require.NoError(t, err)
+	at := func(i int) time.Time {
+		return evaluationTime.Add(time.Duration(i) * time.Minute)
+	}
+	condition := models.Condition{Condition: "A", OrgID: 123}
+	firing := data.Labels{"label1": "value1"}
+	normal := data.Labels{"label1": "value2"}
+	reporting := data.Labels{"label1": "value3"}
+
+	st := NewStateTracker(log.New("test_state_tracker"))
+	st.SetStaleSeries(StaleSeries{MissedEvaluations: 2})
+	sink := &recordingAuditSink{}
+	st.SetAuditSink(sink)
+	evaluate := func(i int, results eval.Results) []ResolvedState {
+		st.ProcessEvalResults("test_uid", results, condition)
+		return st.ResolveMissedSeries(123, "test_uid", results, at(i))
+	}
+
+	assert.Empty(t, evaluate(0, eval.Results{
+		{Instance: firing, State: eval.Alerting, EvaluatedAt: at(0)},
+		{Instance: normal, State: eval.Normal, EvaluatedAt: at(0)},
+		{Instance: reporting, State: eval.Normal, EvaluatedAt: at(0)},
+	}))
+	st.ProcessEvalResults("other_uid", eval.Results{{Instance: firing, State: eval.Alerting, EvaluatedAt: at(0)}}, condition)
+
+	assert.Empty(t, evaluate(1, eval.Results{{Instance: reporting, State: eval.Normal, EvaluatedAt: at(1)}}))
+	assert.Equal(t, 1, st.Get(123, "test_uid label1=value1").MissedEvaluations)
+	assert.Equal(t, 0, st.Get(123, "test_uid label1=value3").MissedEvaluations)
+
+	assert.Empty(t, evaluate(2, eval.Results{{Instance: reporting, State: eval.Error, EvaluatedAt: at(2)}}), "failed evaluations don't count")
+	assert.Empty(t, st.ResolveMissedSeries(123, "test_uid", nil, at(2)), "evaluations without results don't count")
+
+	resolved := evaluate(3, eval.Results{{Instance: reporting, State: eval.Normal, EvaluatedAt: at(3)}})
+	require.Len(t, resolved, 2)
+	assert.Equal(t, "test_uid label1=value1", resolved[0].CacheId)
+	assert.Equal(t, eval.Alerting, resolved[0].From)
+	assert.Equal(t, eval.Normal, resolved[0].State)
+	assert.Equal(t, at(3), resolved[0].EndsAt)
+	assert.True(t, resolved[0].Stale)
+	assert.Equal(t, "test_uid label1=value2", resolved[1].CacheId)
+	assert.Equal(t, eval.Normal, resolved[1].From)
+
+	assert.Empty(t, st.Get(123, "test_uid label1=value1").CacheId, "the resolved states are removed")
+	assert.Empty(t, st.Get(123, "test_uid label1=value2").CacheId, "the resolved states are removed")
+	assert.NotEmpty(t, st.Get(123, "test_uid label1=value3").CacheId)
+	assert.NotEmpty(t, st.Get(123, "other_uid label1=value1").CacheId, "the states of the other rules are kept")
+
+	require.Eventually(t, func() bool {
+		return len(sink.recorded()) == 3
+	}, time.Second, 10*time.Millisecond)
+	transition := sink.recorded()[2]
+	assert.Equal(t, "test_uid label1=value1", transition.CacheId)
+	assert.Equal(t, eval.Alerting, transition.From)
+	assert.Equal(t, eval.Normal, transition.To)
+	assert.Equal(t, at(3), transition.At)
+
+	t.Run("series reporting again start over", func(t *testing.T) {
+		assert.Empty(t, evaluate(4, eval.Results{{Instance: firing, State: eval.Alerting, EvaluatedAt: at(4)}}))
+		s := st.Get(123, "test_uid label1=value1")
+		assert.Equal(t, eval.Alerting, s.State)
+		assert.Equal(t, 0, s.MissedEvaluations)
+	})
+}
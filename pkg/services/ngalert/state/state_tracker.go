@@ -1,10 +1,12 @@
 package state
 
 import (
-	"fmt"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/benbjohnson/clock"
 	"github.com/grafana/grafana/pkg/infra/log"
 
 	"github.com/grafana/grafana-plugin-sdk-go/data"
@@ -22,43 +24,195 @@ type AlertState struct {
 	StartsAt           time.Time
 	EndsAt             time.Time
 	LastEvaluationTime time.Time
+	// Flapping is set while the state is frozen by the flap detection circuit breaker.
+	Flapping bool
+	// Stale is set while the series of the state has stopped reporting.
+	Stale bool
+	// MissedEvaluations is the number of consecutive evaluations of the rule that didn't report the series of the state.
+	MissedEvaluations int
+	// Origin is the ID of the Grafana instance that produced the state.
+	Origin string
+	// ConditionStartsAt is when the condition started to evaluate to true (Alerting),
+	// which the Pending state waits the pending period of the alert rule from.
+	// It's zero while the condition evaluates to false.
+	ConditionStartsAt time.Time
 }
 
 type StateEvaluation struct {
 	EvaluationTime  time.Time
 	EvaluationState eval.State
+	// LastEvaluationTime and Count are set on compacted entries that summarize
+	// a run of consecutive evaluations with the same state starting at EvaluationTime.
+	LastEvaluationTime time.Time
+	Count              int
+}
+
+// count returns the number of evaluations the entry stands for
+func (e StateEvaluation) count() int {
+	if e.Count > 0 {
+		return e.Count
+	}
+	return 1
+}
+
+// lastEvaluationTime returns the time of the latest evaluation the entry stands for
+func (e StateEvaluation) lastEvaluationTime() time.Time {
+	if e.LastEvaluationTime.IsZero() {
+		return e.EvaluationTime
+	}
+	return e.LastEvaluationTime
+}
+
+// compactResults collapses the runs of consecutive evaluations with the same state
+// into single entries, so that only the transitions between states remain.
+func compactResults(results []StateEvaluation) []StateEvaluation {
+	compacted := make([]StateEvaluation, 0, len(results))
+	for _, r := range results {
+		last := len(compacted) - 1
+		if last >= 0 && compacted[last].EvaluationState == r.EvaluationState {
+			compacted[last].LastEvaluationTime = r.lastEvaluationTime()
+			compacted[last].Count += r.count()
+			continue
+		}
+		r.LastEvaluationTime = r.lastEvaluationTime()
+		r.Count = r.count()
+		compacted = append(compacted, r)
+	}
+	return compacted
+}
+
+// CacheID returns the ID of the alert state of the alert rule with the given UID for the labels.
+// The labels are written sorted by name, so that the same labels always yield the same ID.
+func CacheID(uid string, labels data.Labels) string {
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var sb strings.Builder
+	sb.WriteString(uid)
+	sb.WriteString(" ")
+	for i, name := range names {
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		sb.WriteString(name)
+		sb.WriteString("=")
+		sb.WriteString(labels[name])
+	}
+	return sb.String()
 }
 
+// cache holds the alert states partitioned by organisation, so that the operations
+// on an organisation only go through its own states and cache IDs can't collide across them.
 type cache struct {
-	cacheMap map[string]AlertState
-	mu       sync.Mutex
+	orgs map[int64]orgCache
+	mu   sync.Mutex
+}
+
+// orgCache holds the alert states of an organisation, by cache ID.
+type orgCache map[string]AlertState
+
+// forOrg returns the alert states of the organisation, creating them if needed.
+// The caller must hold the lock.
+func (c *cache) forOrg(orgID int64) orgCache {
+	states, ok := c.orgs[orgID]
+	if !ok {
+		states = make(orgCache)
+		c.orgs[orgID] = states
+	}
+	return states
+}
+
+// FlapDetection configures the circuit breaker of an alert rule:
+// if an alert instance changes its evaluation state more than MaxTransitions times
+// within Window, its state is frozen until the transition rate subsides.
+type FlapDetection struct {
+	MaxTransitions int
+	Window         time.Duration
 }
 
 type StateTracker struct {
-	stateCache cache
-	quit       chan struct{}
-	Log        log.Logger
+	stateCache    cache
+	flapDetection map[ruleKey]FlapDetection
+	flapMu        sync.RWMutex
+	silences      silences
+	health        health
+	auditor       auditor
+	// suppression is guarded by the lock of the cache
+	suppression initialSuppression
+	// startsAtBound is the earliest StartsAt of the restored alert states; zero keeps them exact.
+	startsAtBound time.Time
+	// writes is guarded by the lock of the cache
+	writes writeCoalescing
+	// staleSeries is guarded by the lock of the cache
+	staleSeries StaleSeries
+	// instanceID is guarded by the lock of the cache
+	instanceID string
+	// deadMansSwitch is guarded by the lock of the cache
+	deadMansSwitch deadMansSwitch
+	// pendingPeriods is guarded by the lock of the cache
+	pendingPeriods map[ruleKey]time.Duration
+	// instanceLimit is guarded by the lock of the cache
+	instanceLimit instanceLimit
+	// preview is set for the state trackers of previews, whose state transitions aren't counted in the metrics
+	preview bool
+	// resolvedRetention is how long resolved alert states are kept
+	// before they are evicted from the cache; zero keeps them forever.
+	resolvedRetention time.Duration
+	// clock drives the cleanup process
+	clock clock.Clock
+	// reconfigure signals the cleanup process that its intervals changed
+	reconfigure chan struct{}
+	quit        chan struct{}
+	Log         log.Logger
 }
 
 func NewStateTracker(logger log.Logger) *StateTracker {
-	tracker := &StateTracker{
+	return NewStateTrackerWithClock(logger, clock.New())
+}
+
+// NewStateTrackerWithClock returns a state tracker whose cleanup process runs on the clock.
+func NewStateTrackerWithClock(logger log.Logger, c clock.Clock) *StateTracker {
+	tracker := newStateTracker(logger)
+	tracker.clock = c
+	go tracker.cleanUp()
+	return tracker
+}
+
+// newStateTracker returns a state tracker without its cleanup process.
+func newStateTracker(logger log.Logger) *StateTracker {
+	return &StateTracker{
 		stateCache: cache{
-			cacheMap: make(map[string]AlertState),
-			mu:       sync.Mutex{},
+			orgs: make(map[int64]orgCache),
+			mu:   sync.Mutex{},
 		},
-		quit: make(chan struct{}),
-		Log:  logger,
+		flapDetection: make(map[ruleKey]FlapDetection),
+		silences:      silences{byOrg: make(map[int64]map[string]silence)},
+		health:        health{samples: make(map[ruleKey][]healthSample)},
+		suppression:   initialSuppression{remaining: make(map[ruleKey]int)},
+		writes:        writeCoalescing{written: make(map[seriesKey]time.Time), pending: make(map[seriesKey]struct{})},
+		auditor:       auditor{logger: logger, blockTimeout: auditBlockTimeout},
+		clock:         clock.New(),
+		reconfigure:   make(chan struct{}, 1),
+		quit:          make(chan struct{}),
+		Log:           logger,
+		deadMansSwitch: deadMansSwitch{
+			expected:       make(map[ruleKey]expectedEvaluations),
+			lastEvaluation: make(map[ruleKey]time.Time),
+		},
+		pendingPeriods: make(map[ruleKey]time.Duration),
+		instanceLimit:  instanceLimit{limits: make(map[ruleKey]int), exceeded: make(map[ruleKey]struct{})},
 	}
-	go tracker.cleanUp()
-	return tracker
 }
 
+// getOrCreate returns the alert state of the evaluation result, creating it if needed.
+// The caller must hold the lock.
 func (st *StateTracker) getOrCreate(uid string, orgId int64, result eval.Result) AlertState {
-	st.stateCache.mu.Lock()
-	defer st.stateCache.mu.Unlock()
-
-	idString := fmt.Sprintf("%s %s", uid, result.Instance.String())
-	if state, ok := st.stateCache.cacheMap[idString]; ok {
+	states := st.stateCache.forOrg(orgId)
+	idString := CacheID(uid, result.Instance)
+	if state, ok := states[idString]; ok {
 		return state
 	}
 	st.Log.Debug("adding new alert state cache entry", "cacheId", idString, "state", result.State.String(), "evaluatedAt", result.EvaluatedAt.String())
@@ -70,49 +224,197 @@ func (st *StateTracker) getOrCreate(uid string, orgId int64, result eval.Result)
 		State:   result.State,
 		Results: []StateEvaluation{},
 	}
-	st.stateCache.cacheMap[idString] = newState
+	if result.State == eval.Alerting {
+		newState.ConditionStartsAt = result.EvaluatedAt
+		if st.pendingPeriods[ruleKey{orgID: orgId, uid: uid}] > 0 {
+			newState.State = eval.Pending
+			newState.StartsAt = result.EvaluatedAt
+		}
+	}
+	states[idString] = newState
 	return newState
 }
 
 func (st *StateTracker) set(stateEntry AlertState) {
 	st.stateCache.mu.Lock()
 	defer st.stateCache.mu.Unlock()
-	st.stateCache.cacheMap[stateEntry.CacheId] = stateEntry
+	st.stateCache.put(stateEntry)
+}
+
+// put stores the alert state. The caller must hold the lock.
+func (c *cache) put(stateEntry AlertState) {
+	c.forOrg(stateEntry.OrgID)[stateEntry.CacheId] = stateEntry
 }
 
-func (st *StateTracker) Get(stateId string) AlertState {
+func (st *StateTracker) Get(orgID int64, stateId string) AlertState {
 	st.stateCache.mu.Lock()
 	defer st.stateCache.mu.Unlock()
-	return st.stateCache.cacheMap[stateId]
+	return st.stateCache.orgs[orgID][stateId]
 }
 
 //Used to ensure a clean cache on startup
 func (st *StateTracker) ResetCache() {
 	st.stateCache.mu.Lock()
 	defer st.stateCache.mu.Unlock()
-	st.stateCache.cacheMap = make(map[string]AlertState)
+	st.stateCache.orgs = make(map[int64]orgCache)
+}
+
+// ResetOrg removes the alert states of the organisation.
+func (st *StateTracker) ResetOrg(orgID int64) {
+	st.stateCache.mu.Lock()
+	defer st.stateCache.mu.Unlock()
+	delete(st.stateCache.orgs, orgID)
+}
+
+// ResetRule removes the alert states of the rule with the given UID.
+func (st *StateTracker) ResetRule(orgID int64, uid string) {
+	st.stateCache.mu.Lock()
+	defer st.stateCache.mu.Unlock()
+	for cacheId, s := range st.stateCache.orgs[orgID] {
+		if s.UID == uid {
+			delete(st.stateCache.orgs[orgID], cacheId)
+		}
+	}
 }
 
 func (st *StateTracker) ProcessEvalResults(uid string, results eval.Results, condition ngModels.Condition) []AlertState {
 	st.Log.Info("state tracker processing evaluation results", "uid", uid, "resultCount", len(results))
+	st.health.record(condition.OrgID, uid, results)
 	var changedStates []AlertState
+	var transitions []StateTransition
+	// the results are applied as a batch, so that readers never see the rule partway through its evaluation
+	st.stateCache.mu.Lock()
+	suppressed := st.suppressed(condition.OrgID, uid)
+	st.recordEvaluation(condition.OrgID, uid, results)
+	results = st.applyInstanceLimit(condition.OrgID, uid, results)
 	for _, result := range results {
-		s, _ := st.setNextState(uid, condition.OrgID, result)
+		if suppressed {
+			changedStates = append(changedStates, st.stamp(st.setSuppressedState(uid, condition.OrgID, result)))
+			continue
+		}
+		previous := st.stateCache.orgs[condition.OrgID][CacheID(uid, result.Instance)]
+		s, changed := st.setNextState(uid, condition.OrgID, result)
+		s = st.stamp(s)
 		changedStates = append(changedStates, s)
+		if changed {
+			transitions = append(transitions, StateTransition{
+				OrgID:   s.OrgID,
+				UID:     s.UID,
+				CacheId: s.CacheId,
+				Labels:  s.Labels,
+				From:    previous.State,
+				To:      s.State,
+				At:      result.EvaluatedAt,
+				Actor:   eval.ServiceIdentityLogin,
+				Origin:  s.Origin,
+			})
+		}
 	}
+	st.stateCache.mu.Unlock()
+	st.countTransitions(transitions)
+	st.auditor.record(transitions)
 	st.Log.Debug("returning changed states to scheduler", "count", len(changedStates))
 	return changedStates
 }
 
+// SetInstanceID sets the ID of the Grafana instance the state tracker runs in, typically its instance name.
+// The alert states it produces and their transitions are stamped with it, so that in a highly available
+// setup one can tell which instance produced them; the states merged with Put keep their own origin.
+func (st *StateTracker) SetInstanceID(id string) {
+	st.stateCache.mu.Lock()
+	defer st.stateCache.mu.Unlock()
+	st.instanceID = id
+}
+
+// stamp sets the origin of the alert state to the instance ID. The caller must hold the lock of the cache.
+func (st *StateTracker) stamp(s AlertState) AlertState {
+	if s.Origin != st.instanceID {
+		s.Origin = st.instanceID
+		st.stateCache.put(s)
+	}
+	return s
+}
+
+// SetFlapDetection enables the flap detection circuit breaker for the alert rule of the organisation with the given UID.
+// A zero MaxTransitions disables it.
+func (st *StateTracker) SetFlapDetection(orgID int64, uid string, cfg FlapDetection) {
+	st.flapMu.Lock()
+	defer st.flapMu.Unlock()
+	key := ruleKey{orgID: orgID, uid: uid}
+	if cfg.MaxTransitions <= 0 {
+		delete(st.flapDetection, key)
+		return
+	}
+	st.flapDetection[key] = cfg
+}
+
+func (st *StateTracker) getFlapDetection(orgID int64, uid string) (FlapDetection, bool) {
+	st.flapMu.RLock()
+	defer st.flapMu.RUnlock()
+	cfg, ok := st.flapDetection[ruleKey{orgID: orgID, uid: uid}]
+	return cfg, ok
+}
+
+// isFlapping returns true if the evaluation results changed state
+// more than cfg.MaxTransitions times within the window that ends at now.
+func isFlapping(results []StateEvaluation, now time.Time, cfg FlapDetection) bool {
+	since := now.Add(-cfg.Window)
+	transitions := 0
+	for i := 1; i < len(results); i++ {
+		if results[i].EvaluationTime.Before(since) {
+			continue
+		}
+		if results[i].EvaluationState != results[i-1].EvaluationState {
+			transitions++
+		}
+	}
+	return transitions > cfg.MaxTransitions
+}
+
 //TODO: When calculating if an alert should not be firing anymore, we should take three things into account:
 // 1. The re-send the delay if any, we don't want to send every firing alert every time, we should have a fixed delay across all alerts to avoid saturating the notification system
 // 2. The evaluation interval defined for this particular alert - we don't support that yet but will eventually allow you to define how often do you want this alert to be evaluted
 // 3. The base interval defined by the scheduler - in the case where #2 is not yet an option we can use the base interval at which every alert runs.
 //Set the current state based on evaluation results
 //return the state and a bool indicating whether a state transition occurred
+//The caller must hold the lock of the cache.
 func (st *StateTracker) setNextState(uid string, orgId int64, result eval.Result) (AlertState, bool) {
 	currentState := st.getOrCreate(uid, orgId, result)
 	st.Log.Debug("setting alert state", "uid", uid)
+	if currentState.Stale {
+		st.Log.Debug("stale alert state is reporting again", "cacheId", currentState.CacheId)
+		currentState.Stale = false
+		st.stateCache.put(currentState)
+	}
+	if st.silences.silenced(orgId, result.Instance, result.EvaluatedAt) {
+		st.Log.Debug("alert state is silenced, suppressing state transition", "cacheId", currentState.CacheId, "state", currentState.State.String())
+		return st.keepState(currentState, result), false
+	}
+	if cfg, ok := st.getFlapDetection(orgId, uid); ok {
+		results := append(currentState.Results, StateEvaluation{
+			EvaluationTime:  result.EvaluatedAt,
+			EvaluationState: result.State,
+		})
+		if isFlapping(results, result.EvaluatedAt, cfg) {
+			if !currentState.Flapping {
+				st.Log.Debug("alert state is flapping, freezing state", "cacheId", currentState.CacheId, "state", currentState.State.String())
+			}
+			currentState.Flapping = true
+			currentState.LastEvaluationTime = result.EvaluatedAt
+			currentState.Results = results
+			st.stateCache.put(currentState)
+			return currentState, false
+		}
+		if currentState.Flapping {
+			st.Log.Debug("alert state stopped flapping", "cacheId", currentState.CacheId)
+		}
+		currentState.Flapping = false
+	}
+	if result.State == eval.Alerting && currentState.State != eval.Alerting {
+		if period := st.pendingPeriods[ruleKey{orgID: orgId, uid: uid}]; period > 0 {
+			return st.setPendingState(currentState, result, period)
+		}
+	}
 	switch {
 	case currentState.State == result.State:
 		st.Log.Debug("no state transition", "cacheId", currentState.CacheId, "state", currentState.State.String())
@@ -124,76 +426,256 @@ func (st *StateTracker) setNextState(uid string, orgId int64, result eval.Result
 		if currentState.State == eval.Alerting {
 			currentState.EndsAt = result.EvaluatedAt.Add(40 * time.Second)
 		}
-		st.set(currentState)
+		st.stateCache.put(currentState)
 		return currentState, false
 	case currentState.State == eval.Normal && result.State == eval.Alerting:
 		st.Log.Debug("state transition from normal to alerting", "cacheId", currentState.CacheId)
 		currentState.State = eval.Alerting
 		currentState.LastEvaluationTime = result.EvaluatedAt
 		currentState.StartsAt = result.EvaluatedAt
+		currentState.ConditionStartsAt = result.EvaluatedAt
 		currentState.EndsAt = result.EvaluatedAt.Add(40 * time.Second)
 		currentState.Results = append(currentState.Results, StateEvaluation{
 			EvaluationTime:  result.EvaluatedAt,
 			EvaluationState: result.State,
 		})
-		st.set(currentState)
+		st.stateCache.put(currentState)
 		return currentState, true
 	case currentState.State == eval.Alerting && result.State == eval.Normal:
 		st.Log.Debug("state transition from alerting to normal", "cacheId", currentState.CacheId)
 		currentState.State = eval.Normal
 		currentState.LastEvaluationTime = result.EvaluatedAt
 		currentState.EndsAt = result.EvaluatedAt
+		currentState.ConditionStartsAt = time.Time{}
 		currentState.Results = append(currentState.Results, StateEvaluation{
 			EvaluationTime:  result.EvaluatedAt,
 			EvaluationState: result.State,
 		})
-		st.set(currentState)
+		st.stateCache.put(currentState)
 		return currentState, true
 	default:
-		return currentState, false
+		st.Log.Debug("state transition", "cacheId", currentState.CacheId, "from", currentState.State.String(), "to", result.State.String())
+		// only the alert states leaving Alerting end: the Pending ones never fired
+		if currentState.State == eval.Alerting {
+			currentState.EndsAt = result.EvaluatedAt
+		}
+		if result.State != eval.Normal {
+			currentState.StartsAt = result.EvaluatedAt
+		}
+		if result.State == eval.Alerting {
+			currentState.EndsAt = result.EvaluatedAt.Add(40 * time.Second)
+			currentState.ConditionStartsAt = result.EvaluatedAt
+		} else {
+			currentState.ConditionStartsAt = time.Time{}
+		}
+		currentState.State = result.State
+		currentState.LastEvaluationTime = result.EvaluatedAt
+		currentState.Results = append(currentState.Results, StateEvaluation{
+			EvaluationTime:  result.EvaluatedAt,
+			EvaluationState: result.State,
+		})
+		st.stateCache.put(currentState)
+		return currentState, true
 	}
 }
 
+// keepState records the evaluation result in the alert state without changing its state.
+// The caller must hold the lock of the cache.
+func (st *StateTracker) keepState(currentState AlertState, result eval.Result) AlertState {
+	currentState.LastEvaluationTime = result.EvaluatedAt
+	currentState.Results = append(currentState.Results, StateEvaluation{
+		EvaluationTime:  result.EvaluatedAt,
+		EvaluationState: result.State,
+	})
+	st.stateCache.put(currentState)
+	return currentState
+}
+
+// SetResolvedRetention sets how long resolved alert states remain in the cache,
+// with their results history, before they are evicted. Zero disables the eviction.
+// The resolved alert states are checked for eviction every retention.
+func (st *StateTracker) SetResolvedRetention(retention time.Duration) {
+	st.stateCache.mu.Lock()
+	st.resolvedRetention = retention
+	st.stateCache.mu.Unlock()
+	st.reconfigureCleanUp()
+}
+
+// ReplayHistory recomputes the state of the alert instance of the rule identified by the labels
+// by discarding its current state and rerunning the state machine over the stored evaluations,
+// in order. Compacted entries are replayed as their first and last evaluations.
+func (st *StateTracker) ReplayHistory(uid string, orgID int64, labels data.Labels, history []StateEvaluation) AlertState {
+	cacheId := CacheID(uid, labels)
+	st.Log.Debug("replaying alert state history", "cacheId", cacheId, "count", len(history))
+
+	st.stateCache.mu.Lock()
+	defer st.stateCache.mu.Unlock()
+	delete(st.stateCache.orgs[orgID], cacheId)
+
+	for _, h := range history {
+		st.setNextState(uid, orgID, eval.Result{Instance: labels, State: h.EvaluationState, EvaluatedAt: h.EvaluationTime})
+		if h.count() > 1 {
+			st.setNextState(uid, orgID, eval.Result{Instance: labels, State: h.EvaluationState, EvaluatedAt: h.lastEvaluationTime()})
+		}
+	}
+	return st.stateCache.orgs[orgID][cacheId]
+}
+
 func (st *StateTracker) GetAll() []AlertState {
 	var states []AlertState
 	st.stateCache.mu.Lock()
 	defer st.stateCache.mu.Unlock()
-	for _, v := range st.stateCache.cacheMap {
+	for _, orgStates := range st.stateCache.orgs {
+		for _, v := range orgStates {
+			states = append(states, v)
+		}
+	}
+	return states
+}
+
+// GetAllForOrg returns the alert states of the organisation.
+func (st *StateTracker) GetAllForOrg(orgID int64) []AlertState {
+	st.stateCache.mu.Lock()
+	defer st.stateCache.mu.Unlock()
+	orgStates := st.stateCache.orgs[orgID]
+	states := make([]AlertState, 0, len(orgStates))
+	for _, v := range orgStates {
 		states = append(states, v)
 	}
 	return states
 }
 
+// LabelKeysForRule returns the distinct label keys of the current alert states of the rule, sorted.
+func (st *StateTracker) LabelKeysForRule(orgID int64, uid string) []string {
+	st.stateCache.mu.Lock()
+	seen := make(map[string]struct{})
+	for _, s := range st.stateCache.orgs[orgID] {
+		if s.UID != uid || s.isDeadMansSwitch() {
+			continue
+		}
+		for k := range s.Labels {
+			seen[k] = struct{}{}
+		}
+	}
+	st.stateCache.mu.Unlock()
+
+	keys := make([]string, 0, len(seen))
+	for k := range seen {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// trimInterval is the interval of the trimming of the results of the alert states.
+const trimInterval = time.Hour
+
+// cleanUp trims the results of the alert states every trimInterval, marks the alert states
+// of the series that stopped reporting as stale every stale threshold, and evicts the resolved
+// alert states every resolved retention, on the clock of the state tracker.
 func (st *StateTracker) cleanUp() {
-	ticker := time.NewTicker(time.Duration(60) * time.Minute)
-	st.Log.Debug("starting cleanup process", "intervalMinutes", 60)
+	ticker := st.clock.Ticker(trimInterval)
+	defer ticker.Stop()
+	st.Log.Debug("starting cleanup process", "trimInterval", trimInterval)
+
+	var staleTicker, evictTicker *clock.Ticker
+	var staleC, evictC <-chan time.Time
+	stopTickers := func() {
+		if staleTicker != nil {
+			staleTicker.Stop()
+			staleTicker, staleC = nil, nil
+		}
+		if evictTicker != nil {
+			evictTicker.Stop()
+			evictTicker, evictC = nil, nil
+		}
+	}
+	resetTickers := func() {
+		stopTickers()
+		st.stateCache.mu.Lock()
+		threshold, retention := st.staleSeries.Threshold, st.resolvedRetention
+		st.stateCache.mu.Unlock()
+		if threshold > 0 {
+			staleTicker = st.clock.Ticker(threshold)
+			staleC = staleTicker.C
+		}
+		if retention > 0 {
+			evictTicker = st.clock.Ticker(retention)
+			evictC = evictTicker.C
+		}
+	}
+	resetTickers()
+	defer stopTickers()
+
 	for {
 		select {
 		case <-ticker.C:
 			st.trim()
+		case now := <-staleC:
+			st.markStale(now)
+		case now := <-evictC:
+			st.evictResolved(now)
+		case <-st.reconfigure:
+			resetTickers()
 		case <-st.quit:
-			st.Log.Debug("stopping cleanup process", "now", time.Now())
-			ticker.Stop()
+			st.Log.Debug("stopping cleanup process", "now", st.clock.Now())
 			return
 		}
 	}
 }
 
+// reconfigureCleanUp signals the cleanup process that its intervals changed; it doesn't block.
+func (st *StateTracker) reconfigureCleanUp() {
+	select {
+	case st.reconfigure <- struct{}{}:
+	default:
+	}
+}
+
 func (st *StateTracker) trim() {
 	st.Log.Info("trimming alert state cache", "now", time.Now())
 	st.stateCache.mu.Lock()
 	defer st.stateCache.mu.Unlock()
-	for _, v := range st.stateCache.cacheMap {
-		if len(v.Results) > 100 {
-			st.Log.Debug("trimming result set", "cacheId", v.CacheId, "count", len(v.Results)-100)
-			newResults := make([]StateEvaluation, 100)
-			copy(newResults, v.Results[100:])
-			v.Results = newResults
-			st.set(v)
+	for _, orgStates := range st.stateCache.orgs {
+		for _, v := range orgStates {
+			if compacted := compactResults(v.Results); len(compacted) < len(v.Results) {
+				st.Log.Debug("compacting result set", "cacheId", v.CacheId, "count", len(v.Results)-len(compacted))
+				v.Results = compacted
+				orgStates[v.CacheId] = v
+			}
+			if len(v.Results) > 100 {
+				st.Log.Debug("trimming result set", "cacheId", v.CacheId, "count", len(v.Results)-100)
+				newResults := make([]StateEvaluation, 100)
+				copy(newResults, v.Results[100:])
+				v.Results = newResults
+				orgStates[v.CacheId] = v
+			}
 		}
 	}
 }
 
+// evictResolved removes the alert states that were resolved longer than the resolved retention ago.
+func (st *StateTracker) evictResolved(now time.Time) {
+	st.stateCache.mu.Lock()
+	defer st.stateCache.mu.Unlock()
+	if st.resolvedRetention <= 0 {
+		return
+	}
+	for _, orgStates := range st.stateCache.orgs {
+		for id, v := range orgStates {
+			if v.isResolved() && now.Sub(v.EndsAt) > st.resolvedRetention {
+				st.Log.Debug("evicting resolved alert state", "cacheId", id, "resolvedAt", v.EndsAt)
+				delete(orgStates, id)
+			}
+		}
+	}
+}
+
+// isResolved returns true if the alert state went back to normal after alerting.
+func (a AlertState) isResolved() bool {
+	return a.State == eval.Normal && !a.EndsAt.IsZero()
+}
+
 func (a AlertState) Equals(b AlertState) bool {
 	return a.UID == b.UID &&
 		a.OrgID == b.OrgID &&
@@ -205,8 +687,39 @@ func (a AlertState) Equals(b AlertState) bool {
 		a.LastEvaluationTime == b.LastEvaluationTime
 }
 
+// EqualsWithin is like Equals but compares the time fields truncated to the tolerance
+// so that states differing only by sub-unit precision, for example after a database
+// round-trip, compare equal. A zero tolerance compares the time instants exactly.
+func (a AlertState) EqualsWithin(b AlertState, tolerance time.Duration) bool {
+	equalTime := func(t1, t2 time.Time) bool {
+		return t1.Truncate(tolerance).Equal(t2.Truncate(tolerance))
+	}
+	return a.UID == b.UID &&
+		a.OrgID == b.OrgID &&
+		a.CacheId == b.CacheId &&
+		a.Labels.String() == b.Labels.String() &&
+		a.State.String() == b.State.String() &&
+		equalTime(a.StartsAt, b.StartsAt) &&
+		equalTime(a.EndsAt, b.EndsAt) &&
+		equalTime(a.LastEvaluationTime, b.LastEvaluationTime)
+}
+
+// SetStartsAtBound sets the earliest StartsAt of the alert states restored with Put:
+// the ones that started before the bound, typically the start of the process, report it instead.
+// The zero time keeps the restored StartsAt exact, which is the default.
+func (st *StateTracker) SetStartsAtBound(bound time.Time) {
+	st.stateCache.mu.Lock()
+	defer st.stateCache.mu.Unlock()
+	st.startsAtBound = bound
+}
+
 func (st *StateTracker) Put(states []AlertState) {
+	st.stateCache.mu.Lock()
+	defer st.stateCache.mu.Unlock()
 	for _, s := range states {
-		st.set(s)
+		if s.StartsAt.Before(st.startsAtBound) {
+			s.StartsAt = st.startsAtBound
+		}
+		st.stateCache.put(s)
 	}
 }
@@ -5,12 +5,14 @@ import (
 	"testing"
 	"time"
 
+	"github.com/benbjohnson/clock"
 	"github.com/grafana/grafana/pkg/infra/log"
 
 	"github.com/grafana/grafana-plugin-sdk-go/data"
 	"github.com/grafana/grafana/pkg/services/ngalert/eval"
 	"github.com/grafana/grafana/pkg/services/ngalert/models"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestProcessEvalResults(t *testing.T) {
@@ -208,7 +210,7 @@ func TestProcessEvalResults(t *testing.T) {
 					Labels:  data.Labels{"label1": "value1", "label2": "value2"},
 					State:   eval.Normal,
 					Results: []StateEvaluation{
-						{evaluationTime, eval.Normal},
+						{EvaluationTime: evaluationTime, EvaluationState: eval.Normal},
 						{EvaluationTime: evaluationTime.Add(1 * time.Minute), EvaluationState: eval.Normal},
 					},
 					StartsAt:           time.Time{},
@@ -224,18 +226,18 @@ func TestProcessEvalResults(t *testing.T) {
 			st := NewStateTracker(log.New("test_state_tracker"))
 			_ = st.ProcessEvalResults(tc.uid, tc.evalResults, tc.condition)
 			for _, entry := range tc.expectedCacheEntries {
-				if !entry.Equals(st.Get(entry.CacheId)) {
+				if !entry.Equals(st.Get(entry.OrgID, entry.CacheId)) {
 					t.Log(tc.desc)
-					printEntryDiff(entry, st.Get(entry.CacheId), t)
+					printEntryDiff(entry, st.Get(entry.OrgID, entry.CacheId), t)
 				}
-				assert.True(t, entry.Equals(st.Get(entry.CacheId)))
+				assert.True(t, entry.Equals(st.Get(entry.OrgID, entry.CacheId)))
 			}
 		})
 
 		t.Run("the expected number of entries are added to the cache", func(t *testing.T) {
 			st := NewStateTracker(log.New("test_state_tracker"))
 			st.ProcessEvalResults(tc.uid, tc.evalResults, tc.condition)
-			assert.Equal(t, len(tc.expectedCacheEntries), len(st.stateCache.cacheMap))
+			assert.Equal(t, len(tc.expectedCacheEntries), len(st.stateCache.orgs[tc.condition.OrgID]))
 		})
 
 		//This test, as configured, does not quite represent the behavior of the system.
@@ -249,6 +251,394 @@ func TestProcessEvalResults(t *testing.T) {
 	}
 }
 
+func TestFlapDetection(t *testing.T) {
+	evaluationTime, err := time.Parse("2006-01-02", "2021-03-25")
+	require.NoError(t, err)
+	condition := models.Condition{Condition: "A", OrgID: 123}
+	labels := data.Labels{"label1": "value1"}
+	cacheId := "test_uid label1=value1"
+
+	st := NewStateTracker(log.New("test_state_tracker"))
+	st.SetFlapDetection(123, "test_uid", FlapDetection{MaxTransitions: 2, Window: 10 * time.Minute})
+
+	evaluate := func(i int, state eval.State) AlertState {
+		st.ProcessEvalResults("test_uid", eval.Results{
+			{Instance: labels, State: state, EvaluatedAt: evaluationTime.Add(time.Duration(i) * time.Minute)},
+		}, condition)
+		return st.Get(123, cacheId)
+	}
+
+	// normal, alerting, normal: two transitions within the window
+	assert.False(t, evaluate(0, eval.Normal).Flapping)
+	assert.Equal(t, eval.Alerting, evaluate(1, eval.Alerting).State)
+	assert.Equal(t, eval.Normal, evaluate(2, eval.Normal).State)
+
+	t.Run("the breaker trips and freezes the state", func(t *testing.T) {
+		s := evaluate(3, eval.Alerting)
+		assert.True(t, s.Flapping)
+		assert.Equal(t, eval.Normal, s.State)
+
+		s = evaluate(4, eval.Normal)
+		assert.True(t, s.Flapping)
+		assert.Equal(t, eval.Normal, s.State)
+
+		s = evaluate(5, eval.Alerting)
+		assert.True(t, s.Flapping)
+		assert.Equal(t, eval.Normal, s.State)
+		assert.Equal(t, evaluationTime.Add(5*time.Minute), s.LastEvaluationTime)
+		assert.Len(t, s.Results, 6)
+	})
+
+	t.Run("the breaker resets once the transition rate subsides", func(t *testing.T) {
+		var s AlertState
+		for i := 6; i < 16; i++ {
+			s = evaluate(i, eval.Alerting)
+		}
+		assert.False(t, s.Flapping)
+		assert.Equal(t, eval.Alerting, s.State)
+	})
+
+	t.Run("other rules are not affected", func(t *testing.T) {
+		for i := 0; i < 6; i++ {
+			state := eval.Normal
+			if i%2 == 1 {
+				state = eval.Alerting
+			}
+			st.ProcessEvalResults("other_uid", eval.Results{
+				{Instance: labels, State: state, EvaluatedAt: evaluationTime.Add(time.Duration(i) * time.Minute)},
+			}, condition)
+		}
+		s := st.Get(123, "other_uid label1=value1")
+		assert.False(t, s.Flapping)
+		assert.Equal(t, eval.Alerting, s.State)
+	})
+
+	t.Run("the rules of other organisations with the same UID are not affected", func(t *testing.T) {
+		otherOrg := models.Condition{Condition: "A", OrgID: 456}
+		for i := 0; i < 6; i++ {
+			state := eval.Normal
+			if i%2 == 1 {
+				state = eval.Alerting
+			}
+			st.ProcessEvalResults("test_uid", eval.Results{
+				{Instance: labels, State: state, EvaluatedAt: evaluationTime.Add(time.Duration(i) * time.Minute)},
+			}, otherOrg)
+		}
+		s := st.Get(456, cacheId)
+		assert.False(t, s.Flapping)
+		assert.Equal(t, eval.Alerting, s.State)
+	})
+}
+
+func TestResolvedRetention(t *testing.T) {
+	evaluationTime, err := time.Parse("2006-01-02", "2021-03-25")
+	require.NoError(t, err)
+	condition := models.Condition{Condition: "A", OrgID: 123}
+	labels := data.Labels{"label1": "value1"}
+	cacheId := "test_uid label1=value1"
+
+	st := NewStateTracker(log.New("test_state_tracker"))
+	st.SetResolvedRetention(time.Hour)
+	st.ProcessEvalResults("test_uid", eval.Results{
+		{Instance: labels, State: eval.Alerting, EvaluatedAt: evaluationTime},
+		{Instance: labels, State: eval.Normal, EvaluatedAt: evaluationTime.Add(time.Minute)},
+	}, condition)
+	st.ProcessEvalResults("test_uid", eval.Results{
+		{Instance: data.Labels{"label2": "value2"}, State: eval.Normal, EvaluatedAt: evaluationTime},
+	}, condition)
+	resolvedAt := evaluationTime.Add(time.Minute)
+
+	t.Run("resolved state is queryable during the retention", func(t *testing.T) {
+		st.evictResolved(resolvedAt.Add(time.Hour))
+		s := st.Get(123, cacheId)
+		assert.Equal(t, cacheId, s.CacheId)
+		assert.Equal(t, eval.Normal, s.State)
+		assert.Len(t, s.Results, 2)
+	})
+
+	t.Run("resolved state is evicted after the retention", func(t *testing.T) {
+		st.evictResolved(resolvedAt.Add(time.Hour + time.Second))
+		assert.Empty(t, st.Get(123, cacheId).CacheId)
+	})
+
+	t.Run("states that never fired are kept", func(t *testing.T) {
+		assert.Equal(t, "test_uid label2=value2", st.Get(123, "test_uid label2=value2").CacheId)
+	})
+
+	t.Run("zero retention keeps resolved states forever", func(t *testing.T) {
+		st := NewStateTracker(log.New("test_state_tracker"))
+		st.ProcessEvalResults("test_uid", eval.Results{
+			{Instance: labels, State: eval.Alerting, EvaluatedAt: evaluationTime},
+			{Instance: labels, State: eval.Normal, EvaluatedAt: evaluationTime.Add(time.Minute)},
+		}, condition)
+		st.evictResolved(resolvedAt.Add(24 * time.Hour))
+		assert.Equal(t, cacheId, st.Get(123, cacheId).CacheId)
+	})
+
+	t.Run("resolved states are evicted on the clock of the state tracker, every retention", func(t *testing.T) {
+		mock := clock.NewMock()
+		start := mock.Now()
+		st := NewStateTrackerWithClock(log.New("test_state_tracker"), mock)
+		st.SetResolvedRetention(10 * time.Minute)
+		st.ProcessEvalResults("test_uid", eval.Results{
+			{Instance: labels, State: eval.Alerting, EvaluatedAt: start},
+			{Instance: labels, State: eval.Normal, EvaluatedAt: start.Add(time.Minute)},
+		}, condition)
+
+		require.Eventually(t, func() bool {
+			mock.Add(10 * time.Minute)
+			return st.Get(123, cacheId).CacheId == ""
+		}, time.Second, 10*time.Millisecond)
+		assert.Less(t, int64(mock.Now().Sub(start)), int64(trimInterval))
+	})
+}
+
+func TestCompactResults(t *testing.T) {
+	evaluationTime, err := time.Parse("2006-01-02", "2021-03-25")
+	require.NoError(t, err)
+	at := func(i int) time.Time {
+		return evaluationTime.Add(time.Duration(i) * time.Minute)
+	}
+	condition := models.Condition{Condition: "A", OrgID: 123}
+	labels := data.Labels{"label1": "value1"}
+	cacheId := "test_uid label1=value1"
+
+	st := NewStateTracker(log.New("test_state_tracker"))
+	var results eval.Results
+	for i := 0; i < 50; i++ {
+		results = append(results, eval.Result{Instance: labels, State: eval.Normal, EvaluatedAt: at(i)})
+	}
+	for i := 50; i < 80; i++ {
+		results = append(results, eval.Result{Instance: labels, State: eval.Alerting, EvaluatedAt: at(i)})
+	}
+	results = append(results, eval.Result{Instance: labels, State: eval.Normal, EvaluatedAt: at(80)})
+	st.ProcessEvalResults("test_uid", results, condition)
+	require.Len(t, st.Get(123, cacheId).Results, 81)
+
+	st.trim()
+
+	expected := []StateEvaluation{
+		{EvaluationTime: at(0), EvaluationState: eval.Normal, LastEvaluationTime: at(49), Count: 50},
+		{EvaluationTime: at(50), EvaluationState: eval.Alerting, LastEvaluationTime: at(79), Count: 30},
+		{EvaluationTime: at(80), EvaluationState: eval.Normal, LastEvaluationTime: at(80), Count: 1},
+	}
+	assert.Equal(t, expected, st.Get(123, cacheId).Results)
+
+	t.Run("compacting again does not change the timeline", func(t *testing.T) {
+		st.ProcessEvalResults("test_uid", eval.Results{{Instance: labels, State: eval.Normal, EvaluatedAt: at(81)}}, condition)
+		st.trim()
+		expected[2].LastEvaluationTime = at(81)
+		expected[2].Count = 2
+		assert.Equal(t, expected, st.Get(123, cacheId).Results)
+	})
+}
+
+func TestEqualsWithin(t *testing.T) {
+	evaluationTime, err := time.Parse("2006-01-02", "2021-03-25")
+	require.NoError(t, err)
+	a := AlertState{
+		UID:                "test_uid",
+		OrgID:              123,
+		CacheId:            "test_uid label1=value1",
+		Labels:             data.Labels{"label1": "value1"},
+		State:              eval.Alerting,
+		StartsAt:           evaluationTime,
+		EndsAt:             evaluationTime.Add(time.Minute),
+		LastEvaluationTime: evaluationTime,
+	}
+
+	b := a
+	b.StartsAt = a.StartsAt.Add(300 * time.Millisecond)
+	b.EndsAt = a.EndsAt.Add(999 * time.Millisecond)
+	b.LastEvaluationTime = a.LastEvaluationTime.Add(time.Microsecond).In(time.FixedZone("UTC+1", 3600))
+
+	t.Run("states differing by sub-unit precision are equal within the unit", func(t *testing.T) {
+		assert.False(t, a.Equals(b))
+		assert.True(t, a.EqualsWithin(b, time.Second))
+	})
+
+	t.Run("states differing by more than the unit are not equal", func(t *testing.T) {
+		c := b
+		c.EndsAt = a.EndsAt.Add(time.Second)
+		assert.False(t, a.EqualsWithin(c, time.Second))
+	})
+
+	t.Run("zero tolerance compares the time instants exactly", func(t *testing.T) {
+		assert.False(t, a.EqualsWithin(b, 0))
+		c := a
+		c.StartsAt = a.StartsAt.In(time.FixedZone("UTC+1", 3600))
+		assert.True(t, a.EqualsWithin(c, 0))
+	})
+
+	t.Run("states differing by other fields are not equal", func(t *testing.T) {
+		c := b
+		c.State = eval.Normal
+		assert.False(t, a.EqualsWithin(c, time.Second))
+	})
+}
+
+func TestReplayHistory(t *testing.T) {
+	evaluationTime, err := time.Parse("2006-01-02", "2021-03-25")
+	require.NoError(t, err)
+	at := func(i int) time.Time {
+		return evaluationTime.Add(time.Duration(i) * time.Minute)
+	}
+	labels := data.Labels{"label1": "value1"}
+	cacheId := "test_uid label1=value1"
+
+	history := []StateEvaluation{
+		{EvaluationTime: at(0), EvaluationState: eval.Normal},
+		{EvaluationTime: at(1), EvaluationState: eval.Alerting},
+		{EvaluationTime: at(2), EvaluationState: eval.Alerting},
+		{EvaluationTime: at(3), EvaluationState: eval.Normal},
+		{EvaluationTime: at(4), EvaluationState: eval.Alerting, LastEvaluationTime: at(9), Count: 6},
+	}
+
+	st := NewStateTracker(log.New("test_state_tracker"))
+	// a stale entry that must be discarded by the replay
+	st.Put([]AlertState{{UID: "test_uid", OrgID: 123, CacheId: cacheId, Labels: labels, State: eval.Normal, LastEvaluationTime: at(100)}})
+
+	t.Run("the derived state matches the history", func(t *testing.T) {
+		s := st.ReplayHistory("test_uid", 123, labels, history)
+		assert.Equal(t, cacheId, s.CacheId)
+		assert.Equal(t, int64(123), s.OrgID)
+		assert.Equal(t, eval.Alerting, s.State)
+		assert.Equal(t, at(4), s.StartsAt)
+		assert.Equal(t, at(9).Add(40*time.Second), s.EndsAt)
+		assert.Equal(t, at(9), s.LastEvaluationTime)
+		assert.Len(t, s.Results, 6)
+		assert.True(t, s.Equals(st.Get(123, cacheId)))
+	})
+
+	t.Run("replaying is deterministic", func(t *testing.T) {
+		first := st.ReplayHistory("test_uid", 123, labels, history)
+		second := st.ReplayHistory("test_uid", 123, labels, history)
+		assert.True(t, first.Equals(second))
+		assert.Equal(t, first.Results, second.Results)
+	})
+
+	t.Run("replaying an empty history removes the state", func(t *testing.T) {
+		s := st.ReplayHistory("test_uid", 123, labels, nil)
+		assert.Empty(t, s.CacheId)
+	})
+}
+
+func TestOrgIsolation(t *testing.T) {
+	evaluationTime, err := time.Parse("2006-01-02", "2021-03-25")
+	require.NoError(t, err)
+	labels := data.Labels{"label1": "value1"}
+	cacheId := "test_uid label1=value1"
+
+	st := NewStateTracker(log.New("test_state_tracker"))
+	// the same alert rule UID and labels in both organisations
+	st.ProcessEvalResults("test_uid", eval.Results{
+		{Instance: labels, State: eval.Alerting, EvaluatedAt: evaluationTime},
+	}, models.Condition{Condition: "A", OrgID: 1})
+	var results eval.Results
+	for i := 0; i < 10; i++ {
+		results = append(results, eval.Result{Instance: data.Labels{"label1": fmt.Sprintf("value%d", i)}, State: eval.Normal, EvaluatedAt: evaluationTime})
+	}
+	st.ProcessEvalResults("test_uid", results, models.Condition{Condition: "A", OrgID: 2})
+
+	t.Run("cache IDs don't collide across organisations", func(t *testing.T) {
+		assert.Equal(t, eval.Alerting, st.Get(1, cacheId).State)
+		assert.Equal(t, int64(1), st.Get(1, cacheId).OrgID)
+		assert.Equal(t, eval.Normal, st.Get(2, cacheId).State)
+		assert.Equal(t, int64(2), st.Get(2, cacheId).OrgID)
+	})
+
+	t.Run("queries only go through the states of the organisation", func(t *testing.T) {
+		assert.Len(t, st.GetAllForOrg(1), 1)
+		assert.Len(t, st.GetAllForOrg(2), 10)
+		assert.Empty(t, st.GetAllForOrg(3))
+		assert.Len(t, st.stateCache.orgs[1], 1)
+		assert.Len(t, st.AsAlertmanagerAlerts(1), 1)
+		assert.Empty(t, st.AsAlertmanagerAlerts(2))
+	})
+
+	t.Run("resetting an organisation doesn't touch the others", func(t *testing.T) {
+		st.ResetOrg(2)
+		assert.Empty(t, st.GetAllForOrg(2))
+		assert.Empty(t, st.Get(2, cacheId).CacheId)
+		assert.Equal(t, eval.Alerting, st.Get(1, cacheId).State)
+		assert.Len(t, st.GetAll(), 1)
+	})
+}
+
+func TestCacheID(t *testing.T) {
+	names := []string{"zone", "alpha", "env", "host", "beta"}
+
+	t.Run("the same labels in different insertion orders produce the same ID", func(t *testing.T) {
+		expected := "test_uid alpha=a, beta=b, env=e, host=h, zone=z"
+		for i := 0; i < len(names); i++ {
+			labels := data.Labels{}
+			for j := range names {
+				name := names[(i+j)%len(names)]
+				labels[name] = name[:1]
+			}
+			assert.Equal(t, expected, CacheID("test_uid", labels))
+		}
+	})
+
+	t.Run("labels without any entry produce the UID only", func(t *testing.T) {
+		assert.Equal(t, "test_uid ", CacheID("test_uid", data.Labels{}))
+		assert.Equal(t, "test_uid ", CacheID("test_uid", nil))
+	})
+
+	t.Run("the evaluation results are cached by their canonical IDs", func(t *testing.T) {
+		evaluationTime, err := time.Parse("2006-01-02", "2021-03-25")
+		require.NoError(t, err)
+		st := NewStateTracker(log.New("test_state_tracker"))
+		condition := models.Condition{Condition: "A", OrgID: 123}
+		st.ProcessEvalResults("test_uid", eval.Results{
+			{Instance: data.Labels{"b": "2", "a": "1"}, State: eval.Normal, EvaluatedAt: evaluationTime},
+		}, condition)
+		st.ProcessEvalResults("test_uid", eval.Results{
+			{Instance: data.Labels{"a": "1", "b": "2"}, State: eval.Alerting, EvaluatedAt: evaluationTime.Add(time.Minute)},
+		}, condition)
+
+		states := st.GetAllForOrg(123)
+		require.Len(t, states, 1)
+		assert.Equal(t, "test_uid a=1, b=2", states[0].CacheId)
+		assert.Equal(t, eval.Alerting, states[0].State)
+	})
+}
+
+func TestLabelKeysForRule(t *testing.T) {
+	evaluationTime, err := time.Parse("2006-01-02", "2021-03-25")
+	require.NoError(t, err)
+	condition := models.Condition{Condition: "A", OrgID: 123}
+
+	st := NewStateTracker(log.New("test_state_tracker"))
+	assert.Empty(t, st.LabelKeysForRule(123, "test_uid"))
+
+	st.ProcessEvalResults("test_uid", eval.Results{
+		{Instance: data.Labels{"job": "api", "instance": "a"}, State: eval.Normal, EvaluatedAt: evaluationTime},
+		{Instance: data.Labels{"job": "api", "instance": "b", "zone": "eu"}, State: eval.Normal, EvaluatedAt: evaluationTime},
+	}, condition)
+	st.ProcessEvalResults("other_uid", eval.Results{
+		{Instance: data.Labels{"other": "value"}, State: eval.Normal, EvaluatedAt: evaluationTime},
+	}, condition)
+
+	t.Run("the keys are distinct across the series of the rule and sorted", func(t *testing.T) {
+		assert.Equal(t, []string{"instance", "job", "zone"}, st.LabelKeysForRule(123, "test_uid"))
+		assert.Equal(t, []string{"other"}, st.LabelKeysForRule(123, "other_uid"))
+		assert.Empty(t, st.LabelKeysForRule(1, "test_uid"))
+	})
+
+	t.Run("the keys reflect the added series", func(t *testing.T) {
+		st.ProcessEvalResults("test_uid", eval.Results{
+			{Instance: data.Labels{"job": "api", "region": "west"}, State: eval.Normal, EvaluatedAt: evaluationTime.Add(time.Minute)},
+		}, condition)
+		assert.Equal(t, []string{"instance", "job", "region", "zone"}, st.LabelKeysForRule(123, "test_uid"))
+	})
+
+	t.Run("the keys reflect the removed series", func(t *testing.T) {
+		st.ReplayHistory("test_uid", 123, data.Labels{"job": "api", "instance": "b", "zone": "eu"}, nil)
+		assert.Equal(t, []string{"instance", "job", "region"}, st.LabelKeysForRule(123, "test_uid"))
+	})
+}
+
 func printEntryDiff(a, b AlertState, t *testing.T) {
 	if a.UID != b.UID {
 		t.Log(fmt.Sprintf("%v \t %v\n", a.UID, b.UID))
@@ -283,3 +673,94 @@ func printEntryDiff(a, b AlertState, t *testing.T) {
 		}
 	}
 }
+
+func TestProcessEvalResultsIsAtomic(t *testing.T) {
+	evaluationTime, err := time.Parse("2006-01-02", "2021-03-25")
+	require.NoError(t, err)
+	condition := models.Condition{Condition: "A", OrgID: 123}
+	const seriesCount = 50
+
+	st := NewStateTracker(log.New("test_state_tracker"))
+
+	done := make(chan struct{})
+	readerErr := make(chan error, 1)
+	go func() {
+		defer close(readerErr)
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			states := st.GetAllForOrg(123)
+			if len(states) != 0 && len(states) != seriesCount {
+				readerErr <- fmt.Errorf("observed %d of the %d alert states", len(states), seriesCount)
+				return
+			}
+			for _, s := range states {
+				if !s.LastEvaluationTime.Equal(states[0].LastEvaluationTime) {
+					readerErr <- fmt.Errorf("observed alert states evaluated at %v and %v", s.LastEvaluationTime, states[0].LastEvaluationTime)
+					return
+				}
+			}
+		}
+	}()
+
+	for i := 0; i < 100; i++ {
+		results := make(eval.Results, 0, seriesCount)
+		for j := 0; j < seriesCount; j++ {
+			state := eval.Normal
+			if (i+j)%2 == 0 {
+				state = eval.Alerting
+			}
+			results = append(results, eval.Result{
+				Instance:    data.Labels{"series": fmt.Sprintf("%d", j)},
+				State:       state,
+				EvaluatedAt: evaluationTime.Add(time.Duration(i) * time.Second),
+			})
+		}
+		st.ProcessEvalResults("test_uid", results, condition)
+	}
+	close(done)
+
+	require.NoError(t, <-readerErr)
+	assert.Len(t, st.GetAllForOrg(123), seriesCount)
+}
+
+func TestInstanceID(t *testing.T) {
+	evaluationTime, err := time.Parse("2006-01-02", "2021-03-25")
+	require.NoError(t, err)
+	condition := models.Condition{Condition: "A", OrgID: 123}
+	labels := data.Labels{"label1": "value1"}
+	cacheId := "test_uid label1=value1"
+
+	st := NewStateTracker(log.New("test_state_tracker"))
+	st.SetInstanceID("grafana-1")
+	sink := &recordingAuditSink{}
+	st.SetAuditSink(sink)
+
+	t.Run("states and transitions are stamped with the instance ID", func(t *testing.T) {
+		st.ProcessEvalResults("test_uid", eval.Results{
+			{Instance: labels, State: eval.Normal, EvaluatedAt: evaluationTime},
+			{Instance: labels, State: eval.Alerting, EvaluatedAt: evaluationTime.Add(time.Minute)},
+		}, condition)
+		assert.Equal(t, "grafana-1", st.Get(123, cacheId).Origin)
+
+		require.Eventually(t, func() bool {
+			return len(sink.recorded()) == 1
+		}, time.Second, 10*time.Millisecond)
+		assert.Equal(t, "grafana-1", sink.recorded()[0].Origin)
+	})
+
+	t.Run("merged states keep their origin", func(t *testing.T) {
+		st.Put([]AlertState{{UID: "other_uid", OrgID: 123, CacheId: "other_uid label1=value1", Labels: labels, State: eval.Alerting, Origin: "grafana-2"}})
+		assert.Equal(t, "grafana-2", st.Get(123, "other_uid label1=value1").Origin)
+	})
+
+	t.Run("states produced again are stamped with the instance ID", func(t *testing.T) {
+		st.ProcessEvalResults("other_uid", eval.Results{
+			{Instance: labels, State: eval.Alerting, EvaluatedAt: evaluationTime.Add(2 * time.Minute)},
+		}, condition)
+		assert.Equal(t, "grafana-1", st.Get(123, "other_uid label1=value1").Origin)
+	})
+}
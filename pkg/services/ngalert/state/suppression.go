@@ -0,0 +1,61 @@
+package state
+
+import (
+	"github.com/grafana/grafana/pkg/services/ngalert/eval"
+)
+
+// initialSuppression holds the number of evaluations the state transitions of new rules are suppressed for
+// and, by rule, the number of suppressed evaluations left. It's guarded by the lock of the cache.
+type initialSuppression struct {
+	evaluations int
+	remaining   map[ruleKey]int
+}
+
+// SetInitialSuppression suppresses the state transitions of the rules for their first evaluations
+// so that they establish a baseline before alerting; their alert instances start as Normal.
+// Only the rules without any alert state when first evaluated, rather than restored ones, are suppressed.
+// Zero disables the suppression.
+func (st *StateTracker) SetInitialSuppression(evaluations int) {
+	st.stateCache.mu.Lock()
+	defer st.stateCache.mu.Unlock()
+	st.suppression.evaluations = evaluations
+}
+
+// suppressed returns true if the state transitions of the rule are suppressed for its current evaluation.
+// The caller must hold the lock of the cache.
+func (st *StateTracker) suppressed(orgID int64, uid string) bool {
+	key := ruleKey{orgID: orgID, uid: uid}
+	remaining, seen := st.suppression.remaining[key]
+	if !seen {
+		remaining = 0
+		if st.suppression.evaluations > 0 && !st.hasStates(orgID, uid) {
+			remaining = st.suppression.evaluations
+		}
+	}
+	if remaining > 0 {
+		st.suppression.remaining[key] = remaining - 1
+		return true
+	}
+	st.suppression.remaining[key] = 0
+	return false
+}
+
+// hasStates returns true if the cache holds alert states of the series of the rule. The caller must hold the lock of the cache.
+func (st *StateTracker) hasStates(orgID int64, uid string) bool {
+	for _, s := range st.stateCache.orgs[orgID] {
+		if s.UID == uid && !s.isDeadMansSwitch() {
+			return true
+		}
+	}
+	return false
+}
+
+// setSuppressedState records the evaluation result without any state transition;
+// the alert instances created meanwhile start as Normal. The caller must hold the lock of the cache.
+func (st *StateTracker) setSuppressedState(uid string, orgId int64, result eval.Result) AlertState {
+	baseline := result
+	baseline.State = eval.Normal
+	currentState := st.getOrCreate(uid, orgId, baseline)
+	st.Log.Debug("rule is establishing its baseline, suppressing state transition", "cacheId", currentState.CacheId, "state", currentState.State.String())
+	return st.keepState(currentState, result)
+}
@@ -0,0 +1,57 @@
+package state
+
+import (
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/grafana/grafana/pkg/infra/log"
+	"github.com/grafana/grafana/pkg/services/ngalert/eval"
+	"github.com/grafana/grafana/pkg/services/ngalert/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInitialSuppression(t *testing.T) {
+	evaluationTime, err := time.Parse("2006-01-02", "2021-03-25")
+	require.NoError(t, err)
+	at := func(i int) time.Time {
+		return evaluationTime.Add(time.Duration(i) * time.Minute)
+	}
+	condition := models.Condition{Condition: "A", OrgID: 123}
+	labels := data.Labels{"label1": "value1"}
+	cacheId := "test_uid label1=value1"
+
+	st := NewStateTracker(log.New("test_state_tracker"))
+	st.SetInitialSuppression(2)
+	evaluate := func(uid string, i int, state eval.State) AlertState {
+		st.ProcessEvalResults(uid, eval.Results{{Instance: labels, State: state, EvaluatedAt: at(i)}}, condition)
+		return st.Get(123, uid+" label1=value1")
+	}
+
+	t.Run("a new rule on breaching data doesn't fire during the suppression", func(t *testing.T) {
+		for i := 0; i < 2; i++ {
+			s := evaluate("test_uid", i, eval.Alerting)
+			assert.Equal(t, eval.Normal, s.State)
+			assert.Equal(t, at(i), s.LastEvaluationTime)
+			assert.Len(t, s.Results, i+1)
+		}
+	})
+
+	t.Run("the rule fires after the suppression", func(t *testing.T) {
+		s := evaluate("test_uid", 2, eval.Alerting)
+		assert.Equal(t, eval.Alerting, s.State)
+		assert.Equal(t, at(2), s.StartsAt)
+	})
+
+	t.Run("rules with restored states are not suppressed", func(t *testing.T) {
+		st.Put([]AlertState{{UID: "restored_uid", OrgID: 123, CacheId: "restored_uid label1=value1", Labels: labels, State: eval.Normal}})
+		assert.Equal(t, eval.Alerting, evaluate("restored_uid", 0, eval.Alerting).State)
+	})
+
+	t.Run("disabling the suppression applies to the rules not evaluated yet", func(t *testing.T) {
+		st.SetInitialSuppression(0)
+		assert.Equal(t, eval.Alerting, evaluate("other_uid", 0, eval.Alerting).State)
+		assert.Equal(t, eval.Alerting, st.Get(123, cacheId).State)
+	})
+}
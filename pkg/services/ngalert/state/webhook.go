@@ -0,0 +1,215 @@
+package state
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"text/template"
+	"time"
+
+	"github.com/grafana/grafana/pkg/infra/log"
+)
+
+const (
+	// WebhookSignatureHeader is the header of the webhook requests that holds the HMAC-SHA256 signature
+	// of their payload with the secret of the webhook, as sha256=<hex>.
+	WebhookSignatureHeader = "X-Grafana-Alerting-Signature"
+	// defaultWebhookTemplate renders the state transitions as JSON.
+	defaultWebhookTemplate = `{{ json . }}`
+	// webhookBufferSize is the number of payloads buffered for delivery to the webhook.
+	webhookBufferSize = 100
+	// defaultWebhookBackoff is the wait before the second delivery attempt when the initial backoff is not set.
+	defaultWebhookBackoff = time.Second
+	// maxWebhookBackoff caps the exponential backoff between the delivery attempts.
+	maxWebhookBackoff = time.Minute
+)
+
+// WebhookConfig configures the webhook the state transitions are posted to.
+type WebhookConfig struct {
+	URL string
+	// Template is the Go template of the payload, executed with the WebhookData of the transitions;
+	// the json function encodes its argument as JSON. The transitions are encoded as JSON if it's empty.
+	Template string
+	// Secret, if set, signs the payloads with HMAC-SHA256 in the WebhookSignatureHeader.
+	Secret string
+	// MaxAttempts is the number of delivery attempts of a payload, one if it's not set.
+	MaxAttempts int
+	// InitialBackoff is the wait before the second attempt, a second if it's not set; it doubles after each failed attempt.
+	InitialBackoff time.Duration
+	Client         *http.Client
+}
+
+// WebhookData is the data the template of the webhook payload is executed with.
+type WebhookData struct {
+	Transitions []StateTransition `json:"transitions"`
+}
+
+// webhookPayload is a rendered payload awaiting delivery.
+type webhookPayload struct {
+	body        []byte
+	transitions []StateTransition
+}
+
+type webhookSink struct {
+	cfg    WebhookConfig
+	tmpl   *template.Template
+	ch     chan webhookPayload
+	logger log.Logger
+	sleep  func(time.Duration)
+	// deadLetter logs the payloads that couldn't be delivered
+	deadLetter log.Logger
+}
+
+// NewWebhookSink returns an audit sink posting the state transitions to the webhook, those of an evaluation
+// at once with the audit batching. The payloads are delivered from their own goroutine, with up to MaxAttempts
+// attempts and an exponential backoff. The payloads that aren't delivered, or that don't fit in the buffer
+// of the webhook, are logged to the ngalert.webhook.dead-letter logger.
+func NewWebhookSink(cfg WebhookConfig, logger log.Logger) (BatchAuditSink, error) {
+	s, err := newWebhookSink(cfg, logger)
+	if err != nil {
+		return nil, err
+	}
+	go s.run()
+	return s, nil
+}
+
+func newWebhookSink(cfg WebhookConfig, logger log.Logger) (*webhookSink, error) {
+	if cfg.URL == "" {
+		return nil, fmt.Errorf("the state webhook has no URL")
+	}
+	text := cfg.Template
+	if text == "" {
+		text = defaultWebhookTemplate
+	}
+	tmpl, err := template.New("webhook").Funcs(template.FuncMap{"json": webhookJSON}).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid template of the state webhook: %w", err)
+	}
+	if cfg.MaxAttempts < 1 {
+		cfg.MaxAttempts = 1
+	}
+	if cfg.InitialBackoff <= 0 {
+		cfg.InitialBackoff = defaultWebhookBackoff
+	}
+	if cfg.Client == nil {
+		cfg.Client = &http.Client{Timeout: 30 * time.Second}
+	}
+	return &webhookSink{
+		cfg:        cfg,
+		tmpl:       tmpl,
+		ch:         make(chan webhookPayload, webhookBufferSize),
+		logger:     logger,
+		sleep:      time.Sleep,
+		deadLetter: log.New("ngalert.webhook.dead-letter"),
+	}, nil
+}
+
+func webhookJSON(v interface{}) (string, error) {
+	b, err := json.Marshal(v)
+	return string(b), err
+}
+
+func (s *webhookSink) RecordTransition(t StateTransition) {
+	s.RecordTransitions([]StateTransition{t})
+}
+
+func (s *webhookSink) RecordTransitions(transitions []StateTransition) {
+	if len(transitions) == 0 {
+		return
+	}
+	var body bytes.Buffer
+	if err := s.tmpl.Execute(&body, WebhookData{Transitions: transitions}); err != nil {
+		s.dropped(webhookPayload{transitions: transitions}, 0, fmt.Errorf("failed to render the payload: %w", err))
+		return
+	}
+	payload := webhookPayload{body: body.Bytes(), transitions: transitions}
+	select {
+	case s.ch <- payload:
+	default:
+		s.dropped(payload, 0, fmt.Errorf("the webhook is not keeping up"))
+	}
+}
+
+// SpillTransitions queues the transitions that don't fit in the buffer of the auditor;
+// it doesn't block, as the payloads the webhook can't keep up with go to the dead-letter log.
+func (s *webhookSink) SpillTransitions(transitions []StateTransition) {
+	s.RecordTransitions(transitions)
+}
+
+func (s *webhookSink) run() {
+	for payload := range s.ch {
+		s.deliver(payload)
+	}
+}
+
+// deliver posts the payload to the webhook until it's accepted, it's rejected with a client error
+// or there are no attempts left.
+func (s *webhookSink) deliver(payload webhookPayload) {
+	backoff := s.cfg.InitialBackoff
+	var err error
+	for attempt := 1; attempt <= s.cfg.MaxAttempts; attempt++ {
+		if attempt > 1 {
+			s.sleep(backoff)
+			backoff *= 2
+			if backoff > maxWebhookBackoff {
+				backoff = maxWebhookBackoff
+			}
+		}
+		var retry bool
+		retry, err = s.post(payload.body)
+		if err == nil {
+			return
+		}
+		s.logger.Warn("failed to post state transitions to the webhook", "url", s.cfg.URL, "attempt", attempt, "err", err)
+		if !retry {
+			s.dropped(payload, attempt, err)
+			return
+		}
+	}
+	s.dropped(payload, s.cfg.MaxAttempts, err)
+}
+
+// post posts the payload to the webhook; it returns whether a failed post can be retried.
+func (s *webhookSink) post(body []byte) (bool, error) {
+	req, err := http.NewRequest(http.MethodPost, s.cfg.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.cfg.Secret != "" {
+		req.Header.Set(WebhookSignatureHeader, SignWebhookPayload(s.cfg.Secret, body))
+	}
+	resp, err := s.cfg.Client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer func() {
+		_, _ = io.Copy(ioutil.Discard, resp.Body)
+		_ = resp.Body.Close()
+	}()
+	if resp.StatusCode/100 == 2 {
+		return false, nil
+	}
+	retry := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode/100 == 5
+	return retry, fmt.Errorf("unexpected status from the webhook: %s", resp.Status)
+}
+
+// dropped logs the payload that couldn't be delivered to the dead-letter log.
+func (s *webhookSink) dropped(payload webhookPayload, attempts int, err error) {
+	s.deadLetter.Error("state transitions not delivered to the webhook", "url", s.cfg.URL,
+		"uid", payload.transitions[0].UID, "count", len(payload.transitions), "attempts", attempts,
+		"payload", string(payload.body), "err", err)
+}
+
+// SignWebhookPayload returns the value of the WebhookSignatureHeader of the payload signed with the secret.
+func SignWebhookPayload(secret string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	_, _ = mac.Write(payload)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,164 @@
+package state
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/grafana/grafana/pkg/infra/log"
+	"github.com/grafana/grafana/pkg/services/ngalert/eval"
+	"github.com/stretchr/testify/require"
+)
+
+// deadLetterLogger records the messages logged at the error level.
+type deadLetterLogger struct {
+	log.Logger
+	mu     sync.Mutex
+	errors []string
+}
+
+func (l *deadLetterLogger) Error(msg string, ctx ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.errors = append(l.errors, msg)
+}
+
+func (l *deadLetterLogger) count() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.errors)
+}
+
+type webhookRequest struct {
+	body      string
+	signature string
+}
+
+// webhookServer answers the requests with the statuses, in turn, then with 200.
+func webhookServer(t *testing.T, statuses ...int) (*httptest.Server, func() []webhookRequest) {
+	var mu sync.Mutex
+	var requests []webhookRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		require.NoError(t, err)
+		mu.Lock()
+		defer mu.Unlock()
+		requests = append(requests, webhookRequest{body: string(body), signature: r.Header.Get(WebhookSignatureHeader)})
+		if len(requests) <= len(statuses) {
+			w.WriteHeader(statuses[len(requests)-1])
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv, func() []webhookRequest {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]webhookRequest(nil), requests...)
+	}
+}
+
+func testWebhookSink(t *testing.T, cfg WebhookConfig) (*webhookSink, *[]time.Duration, *deadLetterLogger) {
+	s, err := newWebhookSink(cfg, log.New("test"))
+	require.NoError(t, err)
+	var backoffs []time.Duration
+	s.sleep = func(d time.Duration) {
+		backoffs = append(backoffs, d)
+	}
+	deadLetter := &deadLetterLogger{Logger: log.New("test")}
+	s.deadLetter = deadLetter
+	return s, &backoffs, deadLetter
+}
+
+func TestWebhookSink(t *testing.T) {
+	at := time.Unix(1617883200, 0).UTC()
+	transitions := []StateTransition{
+		{OrgID: 1, UID: "uid-1", Labels: data.Labels{"team": "a"}, From: eval.Normal, To: eval.Alerting, At: at},
+		{OrgID: 1, UID: "uid-1", Labels: data.Labels{"team": "b"}, From: eval.Alerting, To: eval.NoData, At: at},
+	}
+
+	t.Run("the transitions are posted as JSON by default", func(t *testing.T) {
+		srv, requests := webhookServer(t)
+		s, _, deadLetter := testWebhookSink(t, WebhookConfig{URL: srv.URL})
+		s.RecordTransitions(transitions)
+		s.deliver(<-s.ch)
+
+		require.Len(t, requests(), 1)
+		var payload struct {
+			Transitions []struct {
+				UID    string            `json:"uid"`
+				Labels map[string]string `json:"labels"`
+				From   string            `json:"from"`
+				To     string            `json:"to"`
+			} `json:"transitions"`
+		}
+		require.NoError(t, json.Unmarshal([]byte(requests()[0].body), &payload))
+		require.Len(t, payload.Transitions, 2)
+		require.Equal(t, "Normal", payload.Transitions[0].From)
+		require.Equal(t, "Alerting", payload.Transitions[0].To)
+		require.Equal(t, "NoData", payload.Transitions[1].To)
+		require.Equal(t, "b", payload.Transitions[1].Labels["team"])
+		require.Empty(t, requests()[0].signature)
+		require.Zero(t, deadLetter.count())
+	})
+
+	t.Run("the payloads are rendered with the template and signed with the secret", func(t *testing.T) {
+		srv, requests := webhookServer(t)
+		s, _, _ := testWebhookSink(t, WebhookConfig{
+			URL:      srv.URL,
+			Template: `{"text":"{{ range .Transitions }}{{ .Labels.team }}:{{ .From }}->{{ .To }} {{ end }}","at":{{ json (index .Transitions 0).At }}}`,
+			Secret:   "s3cr3t",
+		})
+		s.RecordTransitions(transitions)
+		s.deliver(<-s.ch)
+
+		require.Len(t, requests(), 1)
+		body := requests()[0].body
+		require.Equal(t, `{"text":"a:Normal->Alerting b:Alerting->NoData ","at":"2021-04-08T12:00:00Z"}`, body)
+		require.Equal(t, SignWebhookPayload("s3cr3t", []byte(body)), requests()[0].signature)
+	})
+
+	t.Run("the server errors are retried with an exponential backoff", func(t *testing.T) {
+		srv, requests := webhookServer(t, http.StatusServiceUnavailable, http.StatusTooManyRequests)
+		s, backoffs, deadLetter := testWebhookSink(t, WebhookConfig{URL: srv.URL, MaxAttempts: 3, InitialBackoff: time.Second})
+		s.RecordTransitions(transitions)
+		s.deliver(<-s.ch)
+
+		require.Len(t, requests(), 3)
+		require.Equal(t, []time.Duration{time.Second, 2 * time.Second}, *backoffs)
+		require.Zero(t, deadLetter.count())
+	})
+
+	t.Run("the undelivered payloads are dead-lettered", func(t *testing.T) {
+		srv, requests := webhookServer(t, http.StatusInternalServerError, http.StatusInternalServerError, http.StatusBadRequest)
+		s, _, deadLetter := testWebhookSink(t, WebhookConfig{URL: srv.URL, MaxAttempts: 2})
+		s.RecordTransitions(transitions)
+		s.deliver(<-s.ch)
+		require.Len(t, requests(), 2)
+		require.Equal(t, 1, deadLetter.count())
+
+		// the client errors are not retried
+		s.RecordTransitions(transitions)
+		s.deliver(<-s.ch)
+		require.Len(t, requests(), 3)
+		require.Equal(t, 2, deadLetter.count())
+	})
+
+	t.Run("the payloads are dead-lettered when the webhook is not keeping up", func(t *testing.T) {
+		s, _, deadLetter := testWebhookSink(t, WebhookConfig{URL: "http://localhost"})
+		for i := 0; i < webhookBufferSize+1; i++ {
+			s.RecordTransitions(transitions)
+		}
+		require.Equal(t, 1, deadLetter.count())
+	})
+}
+
+func TestWebhookSinkConfig(t *testing.T) {
+	_, err := NewWebhookSink(WebhookConfig{}, log.New("test"))
+	require.Error(t, err)
+	_, err = NewWebhookSink(WebhookConfig{URL: "http://localhost", Template: "{{ .Transitions "}, log.New("test"))
+	require.Error(t, err)
+}
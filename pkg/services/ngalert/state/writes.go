@@ -0,0 +1,91 @@
+package state
+
+import (
+	"sort"
+	"time"
+)
+
+type seriesKey struct {
+	orgID   int64
+	cacheID string
+}
+
+// writeCoalescing holds the minimum interval between the writes of the alert states of a series,
+// when each series was last written and the series with state changes not written yet.
+// It's guarded by the lock of the cache.
+type writeCoalescing struct {
+	minInterval time.Duration
+	written     map[seriesKey]time.Time
+	pending     map[seriesKey]struct{}
+}
+
+// SetMinWriteInterval sets the minimum interval between the writes of the alert states of a series:
+// the writes within the interval are coalesced, and the latest state is written once it has elapsed.
+// The states in the cache are always current. Zero writes every state, which is the default.
+func (st *StateTracker) SetMinWriteInterval(interval time.Duration) {
+	st.stateCache.mu.Lock()
+	defer st.stateCache.mu.Unlock()
+	st.writes.minInterval = interval
+}
+
+// StatesToWrite returns the alert states to write at now, given the alert states just processed:
+// the current states of the series not written within the minimum write interval
+// and with states processed since their last write. They are recorded as written at now.
+func (st *StateTracker) StatesToWrite(states []AlertState, now time.Time) []AlertState {
+	st.stateCache.mu.Lock()
+	defer st.stateCache.mu.Unlock()
+	if st.writes.minInterval <= 0 {
+		return states
+	}
+
+	for _, s := range states {
+		st.writes.pending[seriesKey{orgID: s.OrgID, cacheID: s.CacheId}] = struct{}{}
+	}
+	var toWrite []AlertState
+	for key := range st.writes.pending {
+		if written, ok := st.writes.written[key]; ok && now.Sub(written) < st.writes.minInterval {
+			continue
+		}
+		delete(st.writes.pending, key)
+		s, ok := st.stateCache.orgs[key.orgID][key.cacheID]
+		if !ok {
+			delete(st.writes.written, key)
+			continue
+		}
+		st.writes.written[key] = now
+		toWrite = append(toWrite, s)
+	}
+	sortStates(toWrite)
+	return toWrite
+}
+
+// StatesToFlush returns the current states of the series with states processed since their last write,
+// whose writes were coalesced within the minimum write interval, so that they can be written at once.
+// They are recorded as written at now.
+func (st *StateTracker) StatesToFlush(now time.Time) []AlertState {
+	st.stateCache.mu.Lock()
+	defer st.stateCache.mu.Unlock()
+	toFlush := make([]AlertState, 0, len(st.writes.pending))
+	for key := range st.writes.pending {
+		delete(st.writes.pending, key)
+		s, ok := st.stateCache.orgs[key.orgID][key.cacheID]
+		if !ok {
+			delete(st.writes.written, key)
+			continue
+		}
+		st.writes.written[key] = now
+		toFlush = append(toFlush, s)
+	}
+	sortStates(toFlush)
+	return toFlush
+}
+
+// sortStates sorts the alert states by organisation then cache ID.
+func sortStates(states []AlertState) {
+	sort.Slice(states, func(i, j int) bool {
+		if states[i].OrgID != states[j].OrgID {
+			return states[i].OrgID < states[j].OrgID
+		}
+		return states[i].CacheId < states[j].CacheId
+	})
+}
@@ -0,0 +1,102 @@
+package state
+
+import (
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/grafana/grafana/pkg/infra/log"
+	"github.com/grafana/grafana/pkg/services/ngalert/eval"
+	"github.com/grafana/grafana/pkg/services/ngalert/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMinWriteInterval(t *testing.T) {
+	evaluationTime, err := time.Parse("2006-01-02", "2021-03-25")
+	require.NoError(t, err)
+	at := func(i int) time.Time {
+		return evaluationTime.Add(time.Duration(i) * time.Second)
+	}
+	condition := models.Condition{Condition: "A", OrgID: 123}
+	labels := data.Labels{"label1": "value1"}
+
+	st := NewStateTracker(log.New("test_state_tracker"))
+	// evaluate processes the evaluation result at i seconds and returns the alert states to write.
+	evaluate := func(i int, state eval.State) []AlertState {
+		states := st.ProcessEvalResults("test_uid", eval.Results{{Instance: labels, State: state, EvaluatedAt: at(i)}}, condition)
+		return st.StatesToWrite(states, at(i))
+	}
+
+	t.Run("every state is written by default", func(t *testing.T) {
+		assert.Len(t, evaluate(0, eval.Normal), 1)
+		assert.Len(t, evaluate(1, eval.Alerting), 1)
+	})
+
+	st.SetMinWriteInterval(5 * time.Second)
+
+	t.Run("rapid transitions are coalesced to the minimum write interval", func(t *testing.T) {
+		written := evaluate(2, eval.Normal)
+		require.Len(t, written, 1)
+		assert.Equal(t, eval.Normal, written[0].State)
+
+		for i := 3; i < 7; i++ {
+			state := eval.Alerting
+			if i%2 == 0 {
+				state = eval.Normal
+			}
+			assert.Empty(t, evaluate(i, state))
+			assert.Equal(t, state, st.Get(123, "test_uid label1=value1").State, "the cache is current")
+		}
+
+		written = evaluate(7, eval.Alerting)
+		require.Len(t, written, 1)
+		assert.Equal(t, eval.Alerting, written[0].State)
+		assert.Equal(t, at(7), written[0].LastEvaluationTime)
+	})
+
+	t.Run("the latest state within the interval is written once it has elapsed", func(t *testing.T) {
+		assert.Empty(t, evaluate(8, eval.Normal))
+		written := st.StatesToWrite(nil, at(12))
+		require.Len(t, written, 1)
+		assert.Equal(t, eval.Normal, written[0].State)
+		assert.Equal(t, at(8), written[0].LastEvaluationTime)
+
+		assert.Empty(t, st.StatesToWrite(nil, at(20)), "series without new states are not written again")
+	})
+}
+
+func TestStatesToFlush(t *testing.T) {
+	evaluationTime, err := time.Parse("2006-01-02", "2021-03-25")
+	require.NoError(t, err)
+	at := func(i int) time.Time {
+		return evaluationTime.Add(time.Duration(i) * time.Second)
+	}
+	condition := models.Condition{Condition: "A", OrgID: 123}
+
+	st := NewStateTracker(log.New("test_state_tracker"))
+	st.SetMinWriteInterval(time.Minute)
+	evaluate := func(i int, labels data.Labels, state eval.State) []AlertState {
+		states := st.ProcessEvalResults("test_uid", eval.Results{{Instance: labels, State: state, EvaluatedAt: at(i)}}, condition)
+		return st.StatesToWrite(states, at(i))
+	}
+
+	require.Len(t, evaluate(0, data.Labels{"label1": "value1"}, eval.Normal), 1)
+	require.Len(t, evaluate(0, data.Labels{"label1": "value2"}, eval.Normal), 1)
+	assert.Empty(t, st.StatesToFlush(at(1)), "the written series are not flushed")
+
+	assert.Empty(t, evaluate(2, data.Labels{"label1": "value2"}, eval.Alerting))
+	assert.Empty(t, evaluate(3, data.Labels{"label1": "value1"}, eval.Alerting))
+	assert.Empty(t, evaluate(4, data.Labels{"label1": "value2"}, eval.Normal))
+
+	flushed := st.StatesToFlush(at(5))
+	require.Len(t, flushed, 2)
+	assert.Equal(t, "test_uid label1=value1", flushed[0].CacheId)
+	assert.Equal(t, eval.Alerting, flushed[0].State)
+	assert.Equal(t, "test_uid label1=value2", flushed[1].CacheId)
+	assert.Equal(t, eval.Normal, flushed[1].State)
+	assert.Equal(t, at(4), flushed[1].LastEvaluationTime)
+
+	assert.Empty(t, st.StatesToFlush(at(6)), "the flushed series are not flushed again")
+	assert.Empty(t, evaluate(7, data.Labels{"label1": "value1"}, eval.Normal), "the flush counts as a write")
+}
@@ -206,6 +206,10 @@ func (st DBstore) UpsertAlertRules(rules []UpsertRule) error {
 					r.New.IntervalSeconds = r.Existing.IntervalSeconds
 				}
 
+				if r.New.For == 0 {
+					r.New.For = r.Existing.For
+				}
+
 				r.New.ID = r.Existing.ID
 				r.New.OrgID = r.Existing.OrgID
 				r.New.NamespaceUID = r.Existing.NamespaceUID
@@ -242,6 +246,7 @@ func (st DBstore) UpsertAlertRules(rules []UpsertRule) error {
 				IntervalSeconds:  r.New.IntervalSeconds,
 				NoDataState:      r.New.NoDataState,
 				ExecErrState:     r.New.ExecErrState,
+				For:              r.New.For,
 			})
 		}
 
@@ -11,6 +11,7 @@ import (
 
 	"github.com/grafana/grafana/pkg/services/sqlstore"
 	"github.com/grafana/grafana/pkg/util"
+	prommodel "github.com/prometheus/common/model"
 )
 
 // TimeNow makes it possible to test usage of time
@@ -30,12 +31,28 @@ type Store interface {
 	GetOrgAlertDefinitions(*models.ListAlertDefinitionsQuery) error
 	SaveAlertDefinition(*models.SaveAlertDefinitionCommand) error
 	UpdateAlertDefinition(*models.UpdateAlertDefinitionCommand) error
+	ListAlertDefinitionVersions(*models.ListAlertDefinitionVersionsQuery) error
+	GetAlertDefinitionVersion(*models.GetAlertDefinitionVersionQuery) error
+	RestoreAlertDefinitionVersion(*models.RestoreAlertDefinitionVersionCommand) error
 	GetAlertInstance(*models.GetAlertInstanceQuery) error
 	ListAlertInstances(*models.ListAlertInstancesQuery) error
 	SaveAlertInstance(*models.SaveAlertInstanceCommand) error
+	SaveAlertInstances(*models.SaveAlertInstancesCommand) error
+	ListAlertFolderSettings(*models.ListAlertFolderSettingsQuery) error
+	GetAlertFolderSettings(*models.GetAlertFolderSettingsQuery) error
+	SaveAlertFolderSettings(*models.SaveAlertFolderSettingsCommand) error
+	DeleteAlertFolderSettings(*models.DeleteAlertFolderSettingsCommand) error
+	DeleteAlertInstances(*models.DeleteAlertInstancesCommand) error
 	ValidateAlertDefinition(*models.AlertDefinition, bool) error
 	UpdateAlertDefinitionPaused(*models.UpdateAlertDefinitionPausedCommand) error
+	DeleteAlertDefinitions(*models.DeleteAlertDefinitionsCommand) error
 	FetchOrgIds(cmd *models.FetchUniqueOrgIdsQuery) error
+	SaveAlertDefinitionLatency(*models.SaveAlertDefinitionLatencyCommand) error
+	GetAlertDefinitionLatency(*models.GetAlertDefinitionLatencyQuery) error
+	SaveAlertStateHistory(*models.SaveAlertStateHistoryCommand) error
+	ListAlertStateHistory(*models.ListAlertStateHistoryQuery) error
+	SaveSchedulerMember(*models.SaveSchedulerMemberCommand) error
+	ListSchedulerMembers(*models.ListSchedulerMembersQuery) error
 }
 
 // AlertingStore is the database interface used by the Alertmanager service.
@@ -43,6 +60,9 @@ type AlertingStore interface {
 	GetLatestAlertmanagerConfiguration(*models.GetLatestAlertmanagerConfigurationQuery) error
 	GetAlertmanagerConfiguration(*models.GetAlertmanagerConfigurationQuery) error
 	SaveAlertmanagerConfiguration(*models.SaveAlertmanagerConfigurationCmd) error
+	ListMuteTimings(*models.ListMuteTimingsQuery) error
+	SaveMuteTiming(*models.SaveMuteTimingCommand) error
+	DeleteMuteTiming(*models.DeleteMuteTimingCommand) error
 }
 
 // DBstore stores the alert definitions and instances in the database.
@@ -71,22 +91,42 @@ func getAlertDefinitionByUID(sess *sqlstore.DBSession, alertDefinitionUID string
 // It returns models.ErrAlertDefinitionNotFound if no alert definition is found for the provided ID.
 func (st DBstore) DeleteAlertDefinitionByUID(cmd *models.DeleteAlertDefinitionByUIDCommand) error {
 	return st.SQLStore.WithTransactionalDbSession(context.Background(), func(sess *sqlstore.DBSession) error {
-		_, err := sess.Exec("DELETE FROM alert_definition WHERE uid = ? AND org_id = ?", cmd.UID, cmd.OrgID)
-		if err != nil {
-			return err
-		}
+		_, err := deleteAlertDefinitionByUID(sess, cmd.UID, cmd.OrgID)
+		return err
+	})
+}
 
-		_, err = sess.Exec("DELETE FROM alert_definition_version WHERE alert_definition_uid = ?", cmd.UID)
-		if err != nil {
-			return err
-		}
+// deleteAlertDefinitionByUID deletes an alert definition along with its versions, instances and latency,
+// returning the number of alert definitions deleted.
+func deleteAlertDefinitionByUID(sess *sqlstore.DBSession, alertDefinitionUID string, orgID int64) (int64, error) {
+	res, err := sess.Exec("DELETE FROM alert_definition WHERE uid = ? AND org_id = ?", alertDefinitionUID, orgID)
+	if err != nil {
+		return 0, err
+	}
+	deleted, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	// the versions are not scoped by organisation: only the ones of an alert definition of the organisation are deleted
+	if deleted == 0 {
+		return 0, nil
+	}
 
-		_, err = sess.Exec("DELETE FROM alert_instance WHERE def_org_id = ? AND def_uid = ?", cmd.OrgID, cmd.UID)
-		if err != nil {
-			return err
-		}
-		return nil
-	})
+	_, err = sess.Exec("DELETE FROM alert_definition_version WHERE alert_definition_uid = ?", alertDefinitionUID)
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = sess.Exec("DELETE FROM alert_instance WHERE def_org_id = ? AND def_uid = ?", orgID, alertDefinitionUID)
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = sess.Exec("DELETE FROM alert_definition_latency WHERE def_org_id = ? AND def_uid = ?", orgID, alertDefinitionUID)
+	if err != nil {
+		return 0, err
+	}
+	return deleted, nil
 }
 
 // GetAlertDefinitionByUID is a handler for retrieving an alert definition from that database by its UID and organisation ID.
@@ -105,7 +145,11 @@ func (st DBstore) GetAlertDefinitionByUID(query *models.GetAlertDefinitionByUIDQ
 // SaveAlertDefinition is a handler for saving a new alert definition.
 func (st DBstore) SaveAlertDefinition(cmd *models.SaveAlertDefinitionCommand) error {
 	return st.SQLStore.WithTransactionalDbSession(context.Background(), func(sess *sqlstore.DBSession) error {
+		// the alert definitions of a folder inherit its interval unless they set theirs
 		intervalSeconds := st.DefaultIntervalSeconds
+		if cmd.NamespaceUID != "" {
+			intervalSeconds = 0
+		}
 		if cmd.IntervalSeconds != nil {
 			intervalSeconds = *cmd.IntervalSeconds
 		}
@@ -118,13 +162,22 @@ func (st DBstore) SaveAlertDefinition(cmd *models.SaveAlertDefinitionCommand) er
 		}
 
 		alertDefinition := &models.AlertDefinition{
-			OrgID:           cmd.OrgID,
-			Title:           cmd.Title,
-			Condition:       cmd.Condition,
-			Data:            cmd.Data,
-			IntervalSeconds: intervalSeconds,
-			Version:         initialVersion,
-			UID:             uid,
+			OrgID:             cmd.OrgID,
+			Title:             cmd.Title,
+			Condition:         cmd.Condition,
+			Data:              cmd.Data,
+			IntervalSeconds:   intervalSeconds,
+			Version:           initialVersion,
+			UID:               uid,
+			NoDataState:       cmd.NoDataState,
+			ExecErrState:      cmd.ExecErrState,
+			For:               cmd.For,
+			EvaluationTimeout: cmd.EvaluationTimeout,
+			MaxInstances:      cmd.MaxInstances,
+			NamespaceUID:      cmd.NamespaceUID,
+		}
+		if cmd.Record != nil {
+			alertDefinition.Record = *cmd.Record
 		}
 
 		if err := st.ValidateAlertDefinition(alertDefinition, false); err != nil {
@@ -151,6 +204,12 @@ func (st DBstore) SaveAlertDefinition(cmd *models.SaveAlertDefinitionCommand) er
 			Title:              alertDefinition.Title,
 			Data:               alertDefinition.Data,
 			IntervalSeconds:    alertDefinition.IntervalSeconds,
+			NoDataState:        alertDefinition.NoDataState,
+			ExecErrState:       alertDefinition.ExecErrState,
+			For:                alertDefinition.For,
+			EvaluationTimeout:  alertDefinition.EvaluationTimeout,
+			Record:             alertDefinition.Record,
+			MaxInstances:       alertDefinition.MaxInstances,
 		}
 		if _, err := sess.Insert(alertDefVersion); err != nil {
 			return err
@@ -189,16 +248,51 @@ func (st DBstore) UpdateAlertDefinition(cmd *models.UpdateAlertDefinitionCommand
 		if intervalSeconds == nil {
 			intervalSeconds = &existingAlertDefinition.IntervalSeconds
 		}
+		noDataState := cmd.NoDataState
+		if noDataState == "" {
+			noDataState = existingAlertDefinition.NoDataState
+		}
+		execErrState := cmd.ExecErrState
+		if execErrState == "" {
+			execErrState = existingAlertDefinition.ExecErrState
+		}
+		forDuration := cmd.For
+		if forDuration == nil {
+			forDuration = &existingAlertDefinition.For
+		}
+		evaluationTimeout := cmd.EvaluationTimeout
+		if evaluationTimeout == nil {
+			evaluationTimeout = &existingAlertDefinition.EvaluationTimeout
+		}
+		record := cmd.Record
+		if record == nil {
+			record = &existingAlertDefinition.Record
+		}
+		maxInstances := cmd.MaxInstances
+		if maxInstances == nil {
+			maxInstances = &existingAlertDefinition.MaxInstances
+		}
+		namespaceUID := cmd.NamespaceUID
+		if namespaceUID == nil {
+			namespaceUID = &existingAlertDefinition.NamespaceUID
+		}
 
 		// explicitly set all fields regardless of being provided or not
 		alertDefinition := &models.AlertDefinition{
-			ID:              existingAlertDefinition.ID,
-			Title:           title,
-			Condition:       condition,
-			Data:            data,
-			OrgID:           existingAlertDefinition.OrgID,
-			IntervalSeconds: *intervalSeconds,
-			UID:             existingAlertDefinition.UID,
+			ID:                existingAlertDefinition.ID,
+			Title:             title,
+			Condition:         condition,
+			Data:              data,
+			OrgID:             existingAlertDefinition.OrgID,
+			IntervalSeconds:   *intervalSeconds,
+			UID:               existingAlertDefinition.UID,
+			NoDataState:       noDataState,
+			ExecErrState:      execErrState,
+			For:               *forDuration,
+			EvaluationTimeout: *evaluationTimeout,
+			Record:            *record,
+			MaxInstances:      *maxInstances,
+			NamespaceUID:      *namespaceUID,
 		}
 
 		if err := st.ValidateAlertDefinition(alertDefinition, true); err != nil {
@@ -211,7 +305,9 @@ func (st DBstore) UpdateAlertDefinition(cmd *models.UpdateAlertDefinitionCommand
 
 		alertDefinition.Version = existingAlertDefinition.Version + 1
 
-		_, err = sess.ID(existingAlertDefinition.ID).Update(alertDefinition)
+		// the for duration, evaluation timeout, recording rule, instance limit and folder are updated even if they're zero,
+		// to be able to remove them; so is the interval, to be able to inherit the one of the folder
+		_, err = sess.ID(existingAlertDefinition.ID).MustCols("interval_seconds", "for", "evaluation_timeout", "record", "max_instances", "namespace_uid").Update(alertDefinition)
 		if err != nil {
 			if st.SQLStore.Dialect.IsUniqueConstraintViolation(err) && strings.Contains(err.Error(), "title") {
 				return fmt.Errorf("an alert definition with the title '%s' already exists: %w", cmd.Title, err)
@@ -229,6 +325,12 @@ func (st DBstore) UpdateAlertDefinition(cmd *models.UpdateAlertDefinitionCommand
 			Title:              alertDefinition.Title,
 			Data:               alertDefinition.Data,
 			IntervalSeconds:    alertDefinition.IntervalSeconds,
+			NoDataState:        alertDefinition.NoDataState,
+			ExecErrState:       alertDefinition.ExecErrState,
+			For:                alertDefinition.For,
+			EvaluationTimeout:  alertDefinition.EvaluationTimeout,
+			Record:             alertDefinition.Record,
+			MaxInstances:       alertDefinition.MaxInstances,
 		}
 		if _, err := sess.Insert(alertDefVersion); err != nil {
 			return err
@@ -258,7 +360,7 @@ func (st DBstore) GetOrgAlertDefinitions(query *models.ListAlertDefinitionsQuery
 func (st DBstore) GetAlertDefinitions(query *models.ListAlertDefinitionsQuery) error {
 	return st.SQLStore.WithDbSession(context.Background(), func(sess *sqlstore.DBSession) error {
 		alerts := make([]*models.AlertDefinition, 0)
-		q := "SELECT uid, org_id, interval_seconds, version, paused FROM alert_definition"
+		q := "SELECT uid, org_id, interval_seconds, version, paused, namespace_uid FROM alert_definition"
 		if err := sess.SQL(q).Find(&alerts); err != nil {
 			return err
 		}
@@ -270,16 +372,20 @@ func (st DBstore) GetAlertDefinitions(query *models.ListAlertDefinitionsQuery) e
 
 // UpdateAlertDefinitionPaused update the pause state of an alert definition.
 func (st DBstore) UpdateAlertDefinitionPaused(cmd *models.UpdateAlertDefinitionPausedCommand) error {
-	return st.SQLStore.WithDbSession(context.Background(), func(sess *sqlstore.DBSession) error {
-		if len(cmd.UIDs) == 0 {
+	return st.SQLStore.WithTransactionalDbSession(context.Background(), func(sess *sqlstore.DBSession) error {
+		uids, err := filterAlertDefinitionUIDs(sess, cmd.OrgID, cmd.UIDs, cmd.NamespaceUID, cmd.DatasourceUID)
+		if err != nil {
+			return err
+		}
+		if len(uids) == 0 {
 			return nil
 		}
 		placeHolders := strings.Builder{}
 		const separator = ", "
 		separatorVar := separator
 		params := []interface{}{cmd.Paused, cmd.OrgID}
-		for i, UID := range cmd.UIDs {
-			if i == len(cmd.UIDs)-1 {
+		for i, UID := range uids {
+			if i == len(uids)-1 {
 				separatorVar = ""
 			}
 			placeHolders.WriteString(fmt.Sprintf("?%s", separatorVar))
@@ -304,6 +410,78 @@ func (st DBstore) UpdateAlertDefinitionPaused(cmd *models.UpdateAlertDefinitionP
 	})
 }
 
+// DeleteAlertDefinitions is a handler for deleting alert definitions at once,
+// along with their versions, instances and latency: either all of them are deleted or none is.
+func (st DBstore) DeleteAlertDefinitions(cmd *models.DeleteAlertDefinitionsCommand) error {
+	return st.SQLStore.WithTransactionalDbSession(context.Background(), func(sess *sqlstore.DBSession) error {
+		uids, err := filterAlertDefinitionUIDs(sess, cmd.OrgID, cmd.UIDs, cmd.NamespaceUID, cmd.DatasourceUID)
+		if err != nil {
+			return err
+		}
+
+		var resultCount int64
+		for _, uid := range uids {
+			deleted, err := deleteAlertDefinitionByUID(sess, uid, cmd.OrgID)
+			if err != nil {
+				return err
+			}
+			resultCount += deleted
+		}
+		cmd.ResultCount = resultCount
+		return nil
+	})
+}
+
+// filterAlertDefinitionUIDs returns the UIDs of the alert definitions of the organisation among the ones of the UIDs,
+// if any, of the folder, if any, that query the datasource, if any. UIDs of other organisations or unknown are left out.
+// No alert definition is returned without UIDs, folder or datasource UID.
+func filterAlertDefinitionUIDs(sess *sqlstore.DBSession, orgID int64, uids []string, namespaceUID string, datasourceUID string) ([]string, error) {
+	if len(uids) == 0 && namespaceUID == "" && datasourceUID == "" {
+		return nil, nil
+	}
+
+	alertDefinitions := make([]*models.AlertDefinition, 0)
+	q := sess.Table("alert_definition").Cols("uid", "data").Where("org_id = ?", orgID)
+	if len(uids) > 0 {
+		q = q.In("uid", uids)
+	}
+	if namespaceUID != "" {
+		q = q.And("namespace_uid = ?", namespaceUID)
+	}
+	if err := q.Find(&alertDefinitions); err != nil {
+		return nil, err
+	}
+
+	filtered := make([]string, 0, len(alertDefinitions))
+	for _, alertDefinition := range alertDefinitions {
+		if datasourceUID != "" {
+			ok, err := queriesDatasource(alertDefinition, datasourceUID)
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				continue
+			}
+		}
+		filtered = append(filtered, alertDefinition.UID)
+	}
+	return filtered, nil
+}
+
+// queriesDatasource returns whether one of the queries of the alert definition queries the datasource.
+func queriesDatasource(alertDefinition *models.AlertDefinition, datasourceUID string) (bool, error) {
+	for _, query := range alertDefinition.Data {
+		uid, err := query.GetDatasource()
+		if err != nil {
+			return false, fmt.Errorf("failed to get the datasource of alert definition %s: %w", alertDefinition.UID, err)
+		}
+		if uid == datasourceUID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
 func generateNewAlertDefinitionUID(sess *sqlstore.DBSession, orgID int64) (string, error) {
 	for i := 0; i < 3; i++ {
 		uid := util.GenerateShortUID()
@@ -321,7 +499,7 @@ func generateNewAlertDefinitionUID(sess *sqlstore.DBSession, orgID int64) (strin
 	return "", models.ErrAlertDefinitionFailedGenerateUniqueUID
 }
 
-// ValidateAlertDefinition validates the alert definition interval and organisation.
+// ValidateAlertDefinition validates the alert definition interval, organisation, for duration and no data and execution error states.
 // If requireData is true checks that it contains at least one alert query
 func (st DBstore) ValidateAlertDefinition(alertDefinition *models.AlertDefinition, requireData bool) error {
 	if !requireData && len(alertDefinition.Data) == 0 {
@@ -345,5 +523,38 @@ func (st DBstore) ValidateAlertDefinition(alertDefinition *models.AlertDefinitio
 		return fmt.Errorf("no organisation is found")
 	}
 
+	if alertDefinition.For < 0 {
+		return fmt.Errorf("invalid for duration: %v: it should not be negative", alertDefinition.For)
+	}
+
+	if alertDefinition.EvaluationTimeout < 0 {
+		return fmt.Errorf("invalid evaluation timeout: %v: it should not be negative", alertDefinition.EvaluationTimeout)
+	}
+
+	if alertDefinition.MaxInstances < 0 {
+		return fmt.Errorf("invalid maximum number of alert instances: %d: it should not be negative", alertDefinition.MaxInstances)
+	}
+
+	switch alertDefinition.NoDataState {
+	case "", models.NoData, models.OK, models.Alerting, models.KeepLastState:
+	default:
+		return fmt.Errorf("invalid no data state: %q", alertDefinition.NoDataState)
+	}
+
+	switch alertDefinition.ExecErrState {
+	case "", models.AlertingErrState, models.OKErrState, models.ErrorErrState, models.KeepLastStateErrState:
+	default:
+		return fmt.Errorf("invalid execution error state: %q", alertDefinition.ExecErrState)
+	}
+
+	if record := alertDefinition.Record; alertDefinition.IsRecording() {
+		if !prommodel.IsValidMetricName(prommodel.LabelValue(record.Metric)) {
+			return fmt.Errorf("invalid recording rule metric: %q", record.Metric)
+		}
+		if record.TargetDatasourceUID == "" {
+			return fmt.Errorf("no target datasource is found for the recording rule")
+		}
+	}
+
 	return nil
 }
@@ -51,6 +51,28 @@ func AddAlertDefinitionMigrations(mg *migrator.Migrator, defaultIntervalSeconds
 	mg.AddMigration("Add column paused in alert_definition", migrator.NewAddColumnMigration(alertDefinition, &migrator.Column{
 		Name: "paused", Type: migrator.DB_Bool, Nullable: false, Default: "0",
 	}))
+
+	mg.AddMigration("add column no_data_state to alert_definition", migrator.NewAddColumnMigration(alertDefinition, &migrator.Column{
+		Name: "no_data_state", Type: migrator.DB_NVarchar, Length: 15, Nullable: false, Default: "''",
+	}))
+	mg.AddMigration("add column exec_err_state to alert_definition", migrator.NewAddColumnMigration(alertDefinition, &migrator.Column{
+		Name: "exec_err_state", Type: migrator.DB_NVarchar, Length: 15, Nullable: false, Default: "''",
+	}))
+	mg.AddMigration("add column for to alert_definition", migrator.NewAddColumnMigration(alertDefinition, &migrator.Column{
+		Name: "for", Type: migrator.DB_BigInt, Nullable: false, Default: "0",
+	}))
+	mg.AddMigration("add column evaluation_timeout to alert_definition", migrator.NewAddColumnMigration(alertDefinition, &migrator.Column{
+		Name: "evaluation_timeout", Type: migrator.DB_BigInt, Nullable: false, Default: "0",
+	}))
+	mg.AddMigration("add column record to alert_definition", migrator.NewAddColumnMigration(alertDefinition, &migrator.Column{
+		Name: "record", Type: migrator.DB_Text, Nullable: true,
+	}))
+	mg.AddMigration("add column namespace_uid to alert_definition", migrator.NewAddColumnMigration(alertDefinition, &migrator.Column{
+		Name: "namespace_uid", Type: migrator.DB_NVarchar, Length: 40, Nullable: false, Default: "''",
+	}))
+	mg.AddMigration("add column max_instances to alert_definition", migrator.NewAddColumnMigration(alertDefinition, &migrator.Column{
+		Name: "max_instances", Type: migrator.DB_BigInt, Nullable: false, Default: "0",
+	}))
 }
 
 func AddAlertDefinitionVersionMigrations(mg *migrator.Migrator) {
@@ -82,6 +104,25 @@ func AddAlertDefinitionVersionMigrations(mg *migrator.Migrator) {
 
 	mg.AddMigration("alter alert_definition_version table data column to mediumtext in mysql", migrator.NewRawSQLMigration("").
 		Mysql("ALTER TABLE alert_definition_version MODIFY data MEDIUMTEXT;"))
+
+	mg.AddMigration("add column no_data_state to alert_definition_version", migrator.NewAddColumnMigration(alertDefinitionVersion, &migrator.Column{
+		Name: "no_data_state", Type: migrator.DB_NVarchar, Length: 15, Nullable: false, Default: "''",
+	}))
+	mg.AddMigration("add column exec_err_state to alert_definition_version", migrator.NewAddColumnMigration(alertDefinitionVersion, &migrator.Column{
+		Name: "exec_err_state", Type: migrator.DB_NVarchar, Length: 15, Nullable: false, Default: "''",
+	}))
+	mg.AddMigration("add column for to alert_definition_version", migrator.NewAddColumnMigration(alertDefinitionVersion, &migrator.Column{
+		Name: "for", Type: migrator.DB_BigInt, Nullable: false, Default: "0",
+	}))
+	mg.AddMigration("add column evaluation_timeout to alert_definition_version", migrator.NewAddColumnMigration(alertDefinitionVersion, &migrator.Column{
+		Name: "evaluation_timeout", Type: migrator.DB_BigInt, Nullable: false, Default: "0",
+	}))
+	mg.AddMigration("add column record to alert_definition_version", migrator.NewAddColumnMigration(alertDefinitionVersion, &migrator.Column{
+		Name: "record", Type: migrator.DB_Text, Nullable: true,
+	}))
+	mg.AddMigration("add column max_instances to alert_definition_version", migrator.NewAddColumnMigration(alertDefinitionVersion, &migrator.Column{
+		Name: "max_instances", Type: migrator.DB_BigInt, Nullable: false, Default: "0",
+	}))
 }
 
 func AlertInstanceMigration(mg *migrator.Migrator) {
@@ -110,6 +151,94 @@ func AlertInstanceMigration(mg *migrator.Migrator) {
 	mg.AddMigration("add column current_state_end to alert_instance", migrator.NewAddColumnMigration(alertInstance, &migrator.Column{
 		Name: "current_state_end", Type: migrator.DB_BigInt, Nullable: false, Default: "0",
 	}))
+	mg.AddMigration("add column format_version to alert_instance", migrator.NewAddColumnMigration(alertInstance, &migrator.Column{
+		Name: "format_version", Type: migrator.DB_Int, Nullable: false, Default: "0",
+	}))
+	mg.AddMigration("add column origin to alert_instance", migrator.NewAddColumnMigration(alertInstance, &migrator.Column{
+		Name: "origin", Type: migrator.DB_NVarchar, Length: 190, Nullable: true,
+	}))
+	mg.AddMigration("add column condition_start to alert_instance", migrator.NewAddColumnMigration(alertInstance, &migrator.Column{
+		Name: "condition_start", Type: migrator.DB_BigInt, Nullable: false, Default: "0",
+	}))
+}
+
+func AlertFolderSettingsMigration(mg *migrator.Migrator) {
+	alertFolderSettings := migrator.Table{
+		Name: "alert_folder_settings",
+		Columns: []*migrator.Column{
+			{Name: "id", Type: migrator.DB_BigInt, IsPrimaryKey: true, IsAutoIncrement: true},
+			{Name: "org_id", Type: migrator.DB_BigInt, Nullable: false},
+			{Name: "namespace_uid", Type: migrator.DB_NVarchar, Length: 40, Nullable: false},
+			{Name: "interval_seconds", Type: migrator.DB_BigInt, Nullable: false, Default: "0"},
+			{Name: "for", Type: migrator.DB_BigInt, Nullable: false, Default: "0"},
+			{Name: "no_data_state", Type: migrator.DB_NVarchar, Length: 15, Nullable: false, Default: "''"},
+			{Name: "updated", Type: migrator.DB_DateTime, Nullable: false},
+		},
+		Indices: []*migrator.Index{
+			{Cols: []string{"org_id", "namespace_uid"}, Type: migrator.UniqueIndex},
+		},
+	}
+
+	mg.AddMigration("create alert_folder_settings table", migrator.NewAddTableMigration(alertFolderSettings))
+	mg.AddMigration("add unique index in alert_folder_settings on org_id and namespace_uid columns", migrator.NewAddIndexMigration(alertFolderSettings, alertFolderSettings.Indices[0]))
+}
+
+func AlertDefinitionLatencyMigration(mg *migrator.Migrator) {
+	alertDefinitionLatency := migrator.Table{
+		Name: "alert_definition_latency",
+		Columns: []*migrator.Column{
+			{Name: "def_org_id", Type: migrator.DB_BigInt, Nullable: false},
+			{Name: "def_uid", Type: migrator.DB_NVarchar, Length: 40, Nullable: false, Default: "0"},
+			{Name: "p50_ms", Type: migrator.DB_BigInt, Nullable: false},
+			{Name: "p95_ms", Type: migrator.DB_BigInt, Nullable: false},
+			{Name: "max_ms", Type: migrator.DB_BigInt, Nullable: false},
+			{Name: "samples", Type: migrator.DB_Int, Nullable: false},
+			{Name: "updated", Type: migrator.DB_BigInt, Nullable: false},
+		},
+		PrimaryKeys: []string{"def_org_id", "def_uid"},
+	}
+
+	// create table
+	mg.AddMigration("create alert_definition_latency table", migrator.NewAddTableMigration(alertDefinitionLatency))
+}
+
+func AlertStateHistoryMigration(mg *migrator.Migrator) {
+	alertStateHistory := migrator.Table{
+		Name: "alert_state_history",
+		Columns: []*migrator.Column{
+			{Name: "id", Type: migrator.DB_BigInt, IsPrimaryKey: true, IsAutoIncrement: true},
+			{Name: "def_org_id", Type: migrator.DB_BigInt, Nullable: false},
+			{Name: "def_uid", Type: migrator.DB_NVarchar, Length: 40, Nullable: false, Default: "0"},
+			{Name: "labels", Type: migrator.DB_Text, Nullable: false},
+			{Name: "labels_hash", Type: migrator.DB_NVarchar, Length: 190, Nullable: false},
+			{Name: "prev_state", Type: migrator.DB_NVarchar, Length: 190, Nullable: false},
+			{Name: "state", Type: migrator.DB_NVarchar, Length: 190, Nullable: false},
+			{Name: "at", Type: migrator.DB_BigInt, Nullable: false},
+			{Name: "origin", Type: migrator.DB_NVarchar, Length: 190, Nullable: true},
+		},
+		Indices: []*migrator.Index{
+			{Cols: []string{"def_org_id", "def_uid", "at"}, Type: migrator.IndexType},
+			{Cols: []string{"def_org_id", "at"}, Type: migrator.IndexType},
+		},
+	}
+
+	// create table
+	mg.AddMigration("create alert_state_history table", migrator.NewAddTableMigration(alertStateHistory))
+	mg.AddMigration("add index in alert_state_history table on def_org_id, def_uid and at columns", migrator.NewAddIndexMigration(alertStateHistory, alertStateHistory.Indices[0]))
+	mg.AddMigration("add index in alert_state_history table on def_org_id and at columns", migrator.NewAddIndexMigration(alertStateHistory, alertStateHistory.Indices[1]))
+}
+
+func AlertSchedulerMemberMigration(mg *migrator.Migrator) {
+	alertSchedulerMember := migrator.Table{
+		Name: "alert_scheduler_member",
+		Columns: []*migrator.Column{
+			{Name: "instance_id", Type: migrator.DB_NVarchar, Length: 190, IsPrimaryKey: true},
+			{Name: "last_heartbeat", Type: migrator.DB_BigInt, Nullable: false},
+		},
+	}
+
+	// create table
+	mg.AddMigration("create alert_scheduler_member table", migrator.NewAddTableMigration(alertSchedulerMember))
 }
 
 func AddAlertRuleMigrations(mg *migrator.Migrator, defaultIntervalSeconds int64) {
@@ -147,6 +276,10 @@ func AddAlertRuleMigrations(mg *migrator.Migrator, defaultIntervalSeconds int64)
 
 	mg.AddMigration("alter alert_rule table data column to mediumtext in mysql", migrator.NewRawSQLMigration("").
 		Mysql("ALTER TABLE alert_rule MODIFY data MEDIUMTEXT;"))
+
+	mg.AddMigration("add column for to alert_rule", migrator.NewAddColumnMigration(alertRule, &migrator.Column{
+		Name: "for", Type: migrator.DB_BigInt, Nullable: false, Default: "0",
+	}))
 }
 
 func AddAlertRuleVersionMigrations(mg *migrator.Migrator) {
@@ -181,4 +314,8 @@ func AddAlertRuleVersionMigrations(mg *migrator.Migrator) {
 
 	mg.AddMigration("alter alert_rule_version table data column to mediumtext in mysql", migrator.NewRawSQLMigration("").
 		Mysql("ALTER TABLE alert_rule_version MODIFY data MEDIUMTEXT;"))
+
+	mg.AddMigration("add column for to alert_rule_version", migrator.NewAddColumnMigration(alertRuleVersion, &migrator.Column{
+		Name: "for", Type: migrator.DB_BigInt, Nullable: false, Default: "0",
+	}))
 }
@@ -0,0 +1,91 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/grafana/grafana/pkg/services/ngalert/models"
+	"github.com/grafana/grafana/pkg/services/sqlstore"
+)
+
+// ListAlertFolderSettings is a handler for retrieving the alert settings of the folders.
+func (st DBstore) ListAlertFolderSettings(query *models.ListAlertFolderSettingsQuery) error {
+	return st.SQLStore.WithDbSession(context.Background(), func(sess *sqlstore.DBSession) error {
+		settings := make([]*models.AlertFolderSettings, 0)
+		q := sess.Asc("org_id", "namespace_uid")
+		if query.OrgID != 0 {
+			q = q.Where("org_id = ?", query.OrgID)
+		}
+		if err := q.Find(&settings); err != nil {
+			return err
+		}
+		query.Result = settings
+		return nil
+	})
+}
+
+// GetAlertFolderSettings is a handler for retrieving the alert settings of a folder.
+// It returns models.ErrAlertFolderSettingsNotFound if the folder has none.
+func (st DBstore) GetAlertFolderSettings(query *models.GetAlertFolderSettingsQuery) error {
+	return st.SQLStore.WithDbSession(context.Background(), func(sess *sqlstore.DBSession) error {
+		settings := models.AlertFolderSettings{OrgID: query.OrgID, NamespaceUID: query.NamespaceUID}
+		has, err := sess.Get(&settings)
+		if err != nil {
+			return err
+		}
+		if !has {
+			return models.ErrAlertFolderSettingsNotFound
+		}
+		query.Result = &settings
+		return nil
+	})
+}
+
+// SaveAlertFolderSettings is a handler for creating, or replacing, the alert settings of a folder.
+func (st DBstore) SaveAlertFolderSettings(cmd *models.SaveAlertFolderSettingsCommand) error {
+	if err := cmd.Validate(); err != nil {
+		return err
+	}
+	if cmd.IntervalSeconds%int64(st.BaseInterval.Seconds()) != 0 {
+		return fmt.Errorf("%w: interval %v should be divided exactly by scheduler interval: %v", models.ErrInvalidAlertFolderSettings,
+			time.Duration(cmd.IntervalSeconds)*time.Second, st.BaseInterval)
+	}
+
+	return st.SQLStore.WithTransactionalDbSession(context.Background(), func(sess *sqlstore.DBSession) error {
+		settings := models.AlertFolderSettings{OrgID: cmd.OrgID, NamespaceUID: cmd.NamespaceUID}
+		has, err := sess.Get(&settings)
+		if err != nil {
+			return err
+		}
+		settings.IntervalSeconds = cmd.IntervalSeconds
+		settings.For = cmd.For
+		settings.NoDataState = cmd.NoDataState
+		settings.Updated = TimeNow()
+		if has {
+			_, err = sess.ID(settings.ID).MustCols("interval_seconds", "for", "no_data_state").Update(&settings)
+		} else {
+			_, err = sess.Insert(&settings)
+		}
+		if err != nil {
+			return err
+		}
+		cmd.Result = &settings
+		return nil
+	})
+}
+
+// DeleteAlertFolderSettings is a handler for deleting the alert settings of a folder.
+// It returns models.ErrAlertFolderSettingsNotFound if the folder has none.
+func (st DBstore) DeleteAlertFolderSettings(cmd *models.DeleteAlertFolderSettingsCommand) error {
+	return st.SQLStore.WithDbSession(context.Background(), func(sess *sqlstore.DBSession) error {
+		res, err := sess.Exec("DELETE FROM alert_folder_settings WHERE org_id = ? AND namespace_uid = ?", cmd.OrgID, cmd.NamespaceUID)
+		if err != nil {
+			return err
+		}
+		if rows, err := res.RowsAffected(); err == nil && rows == 0 {
+			return models.ErrAlertFolderSettingsNotFound
+		}
+		return nil
+	})
+}
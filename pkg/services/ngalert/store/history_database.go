@@ -0,0 +1,72 @@
+package store
+
+import (
+	"context"
+	"strings"
+
+	"github.com/grafana/grafana/pkg/services/ngalert/models"
+	"github.com/grafana/grafana/pkg/services/sqlstore"
+)
+
+// SaveAlertStateHistory is a handler for appending state transitions to the alert state history.
+// The entries are appended at once: either all of them are saved or none is.
+func (st DBstore) SaveAlertStateHistory(cmd *models.SaveAlertStateHistoryCommand) error {
+	return st.SQLStore.WithTransactionalDbSession(context.Background(), func(sess *sqlstore.DBSession) error {
+		for _, e := range cmd.Entries {
+			labelTupleJSON, labelsHash, err := e.Labels.StringAndHash()
+			if err != nil {
+				return err
+			}
+
+			_, err = sess.Exec("INSERT INTO alert_state_history (def_org_id, def_uid, labels, labels_hash, prev_state, state, at, origin) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+				e.DefinitionOrgID, e.DefinitionUID, labelTupleJSON, labelsHash, e.PrevState, e.State, e.At.Unix(), e.Origin)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// ListAlertStateHistory is a handler for retrieving the alert state history within specific organisation
+// based on various filters, oldest first.
+func (st DBstore) ListAlertStateHistory(cmd *models.ListAlertStateHistoryQuery) error {
+	return st.SQLStore.WithDbSession(context.Background(), func(sess *sqlstore.DBSession) error {
+		entries := make([]*models.AlertStateHistoryEntry, 0)
+
+		s := strings.Builder{}
+		params := make([]interface{}, 0)
+
+		addToQuery := func(stmt string, p ...interface{}) {
+			s.WriteString(stmt)
+			params = append(params, p...)
+		}
+
+		addToQuery("SELECT * FROM alert_state_history WHERE def_org_id = ?", cmd.DefinitionOrgID)
+
+		if cmd.DefinitionUID != "" {
+			addToQuery(` AND def_uid = ?`, cmd.DefinitionUID)
+		}
+
+		if !cmd.From.IsZero() {
+			addToQuery(` AND at >= ?`, cmd.From.Unix())
+		}
+
+		if !cmd.To.IsZero() {
+			addToQuery(` AND at <= ?`, cmd.To.Unix())
+		}
+
+		if cmd.State != "" {
+			addToQuery(` AND state = ?`, cmd.State)
+		}
+
+		addToQuery(` ORDER BY at, id`)
+
+		if err := sess.SQL(s.String(), params...).Find(&entries); err != nil {
+			return err
+		}
+
+		cmd.Result = entries
+		return nil
+	})
+}
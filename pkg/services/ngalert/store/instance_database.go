@@ -67,6 +67,13 @@ func (st DBstore) ListAlertInstances(cmd *models.ListAlertInstancesQuery) error
 			addToQuery(` AND current_state = ?`, cmd.State)
 		}
 
+		if cmd.Limit > 0 {
+			if cmd.AfterDefinitionUID != "" {
+				addToQuery(` AND (def_uid > ? OR (def_uid = ? AND labels_hash > ?))`, cmd.AfterDefinitionUID, cmd.AfterDefinitionUID, cmd.AfterLabelsHash)
+			}
+			addToQuery(` ORDER BY def_uid, labels_hash` + st.SQLStore.Dialect.Limit(int64(cmd.Limit)))
+		}
+
 		if err := sess.SQL(s.String(), params...).Find(&alertInstances); err != nil {
 			return err
 		}
@@ -80,41 +87,78 @@ func (st DBstore) ListAlertInstances(cmd *models.ListAlertInstancesQuery) error
 // nolint:unused
 func (st DBstore) SaveAlertInstance(cmd *models.SaveAlertInstanceCommand) error {
 	return st.SQLStore.WithDbSession(context.Background(), func(sess *sqlstore.DBSession) error {
-		labelTupleJSON, labelsHash, err := cmd.Labels.StringAndHash()
-		if err != nil {
-			return err
-		}
-
-		alertInstance := &models.AlertInstance{
-			DefinitionOrgID:   cmd.DefinitionOrgID,
-			DefinitionUID:     cmd.DefinitionUID,
-			Labels:            cmd.Labels,
-			LabelsHash:        labelsHash,
-			CurrentState:      cmd.State,
-			CurrentStateSince: cmd.CurrentStateSince,
-			CurrentStateEnd:   cmd.CurrentStateEnd,
-			LastEvalTime:      cmd.LastEvalTime,
-		}
+		return st.saveAlertInstance(sess, cmd)
+	})
+}
 
-		if err := models.ValidateAlertInstance(alertInstance); err != nil {
-			return err
+// SaveAlertInstances is a handler for saving alert instances at once, in a single transaction:
+// none of them is saved if one fails to.
+func (st DBstore) SaveAlertInstances(cmd *models.SaveAlertInstancesCommand) error {
+	return st.SQLStore.WithTransactionalDbSession(context.Background(), func(sess *sqlstore.DBSession) error {
+		for i := range cmd.Instances {
+			if err := st.saveAlertInstance(sess, &cmd.Instances[i]); err != nil {
+				return err
+			}
 		}
+		return nil
+	})
+}
 
-		params := append(make([]interface{}, 0), alertInstance.DefinitionOrgID, alertInstance.DefinitionUID, labelTupleJSON, alertInstance.LabelsHash, alertInstance.CurrentState, alertInstance.CurrentStateSince.Unix(), alertInstance.CurrentStateEnd.Unix(), alertInstance.LastEvalTime.Unix())
-
-		upsertSQL := st.SQLStore.Dialect.UpsertSQL(
-			"alert_instance",
-			[]string{"def_org_id", "def_uid", "labels_hash"},
-			[]string{"def_org_id", "def_uid", "labels", "labels_hash", "current_state", "current_state_since", "current_state_end", "last_eval_time"})
-		_, err = sess.SQL(upsertSQL, params...).Query()
-		if err != nil {
-			return err
+// DeleteAlertInstances is a handler for deleting the alert instances of an alert definition by their labels,
+// in a single transaction. The labels without an alert instance are ignored.
+func (st DBstore) DeleteAlertInstances(cmd *models.DeleteAlertInstancesCommand) error {
+	return st.SQLStore.WithTransactionalDbSession(context.Background(), func(sess *sqlstore.DBSession) error {
+		for _, labels := range cmd.Labels {
+			_, labelsHash, err := labels.StringAndHash()
+			if err != nil {
+				return err
+			}
+			if _, err := sess.Exec("DELETE FROM alert_instance WHERE def_org_id = ? AND def_uid = ? AND labels_hash = ?",
+				cmd.DefinitionOrgID, cmd.DefinitionUID, labelsHash); err != nil {
+				return err
+			}
 		}
-
 		return nil
 	})
 }
 
+func (st DBstore) saveAlertInstance(sess *sqlstore.DBSession, cmd *models.SaveAlertInstanceCommand) error {
+	labelTupleJSON, labelsHash, err := cmd.Labels.StringAndHash()
+	if err != nil {
+		return err
+	}
+
+	alertInstance := &models.AlertInstance{
+		DefinitionOrgID:   cmd.DefinitionOrgID,
+		DefinitionUID:     cmd.DefinitionUID,
+		Labels:            cmd.Labels,
+		LabelsHash:        labelsHash,
+		CurrentState:      cmd.State,
+		CurrentStateSince: cmd.CurrentStateSince,
+		CurrentStateEnd:   cmd.CurrentStateEnd,
+		LastEvalTime:      cmd.LastEvalTime,
+		FormatVersion:     models.InstanceFormatVersionCurrent,
+		ConditionStart:    cmd.ConditionStart,
+		Origin:            cmd.Origin,
+	}
+
+	if err := models.ValidateAlertInstance(alertInstance); err != nil {
+		return err
+	}
+
+	params := append(make([]interface{}, 0), alertInstance.DefinitionOrgID, alertInstance.DefinitionUID, labelTupleJSON, alertInstance.LabelsHash, alertInstance.CurrentState, alertInstance.CurrentStateSince.Unix(), alertInstance.CurrentStateEnd.Unix(), alertInstance.LastEvalTime.Unix(), alertInstance.FormatVersion, alertInstance.ConditionStart.Unix(), alertInstance.Origin)
+
+	upsertSQL := st.SQLStore.Dialect.UpsertSQL(
+		"alert_instance",
+		[]string{"def_org_id", "def_uid", "labels_hash"},
+		[]string{"def_org_id", "def_uid", "labels", "labels_hash", "current_state", "current_state_since", "current_state_end", "last_eval_time", "format_version", "condition_start", "origin"})
+	_, err = sess.SQL(upsertSQL, params...).Query()
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
 func (st DBstore) FetchOrgIds(cmd *models.FetchUniqueOrgIdsQuery) error {
 	return st.SQLStore.WithDbSession(context.Background(), func(sess *sqlstore.DBSession) error {
 		orgIds := make([]*models.FetchUniqueOrgIdsQueryResult, 0)
@@ -0,0 +1,61 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"github.com/grafana/grafana/pkg/services/ngalert/models"
+	"github.com/grafana/grafana/pkg/services/sqlstore"
+)
+
+// alertDefinitionLatency is a row of the alert_definition_latency table.
+type alertDefinitionLatency struct {
+	DefOrgID int64  `xorm:"def_org_id"`
+	DefUID   string `xorm:"def_uid"`
+	P50      int64  `xorm:"p50_ms"`
+	P95      int64  `xorm:"p95_ms"`
+	Max      int64  `xorm:"max_ms"`
+	Samples  int    `xorm:"samples"`
+	Updated  int64  `xorm:"updated"`
+}
+
+// SaveAlertDefinitionLatency is a handler for saving the evaluation latency stats of an alert definition.
+func (st DBstore) SaveAlertDefinitionLatency(cmd *models.SaveAlertDefinitionLatencyCommand) error {
+	return st.SQLStore.WithDbSession(context.Background(), func(sess *sqlstore.DBSession) error {
+		l := cmd.Latency
+		params := []interface{}{l.DefinitionOrgID, l.DefinitionUID, l.P50.Milliseconds(), l.P95.Milliseconds(), l.Max.Milliseconds(), l.Samples, l.Updated.Unix()}
+
+		upsertSQL := st.SQLStore.Dialect.UpsertSQL(
+			"alert_definition_latency",
+			[]string{"def_org_id", "def_uid"},
+			[]string{"def_org_id", "def_uid", "p50_ms", "p95_ms", "max_ms", "samples", "updated"})
+		_, err := sess.SQL(upsertSQL, params...).Query()
+		return err
+	})
+}
+
+// GetAlertDefinitionLatency is a handler for retrieving the evaluation latency stats of an alert definition.
+// It returns models.ErrAlertDefinitionLatencyNotFound if none were saved for the alert definition.
+func (st DBstore) GetAlertDefinitionLatency(query *models.GetAlertDefinitionLatencyQuery) error {
+	return st.SQLStore.WithDbSession(context.Background(), func(sess *sqlstore.DBSession) error {
+		row := alertDefinitionLatency{}
+		has, err := sess.SQL("SELECT * FROM alert_definition_latency WHERE def_org_id = ? AND def_uid = ?", query.DefinitionOrgID, query.DefinitionUID).Get(&row)
+		if err != nil {
+			return err
+		}
+		if !has {
+			return models.ErrAlertDefinitionLatencyNotFound
+		}
+
+		query.Result = &models.AlertDefinitionLatency{
+			DefinitionOrgID: row.DefOrgID,
+			DefinitionUID:   row.DefUID,
+			P50:             time.Duration(row.P50) * time.Millisecond,
+			P95:             time.Duration(row.P95) * time.Millisecond,
+			Max:             time.Duration(row.Max) * time.Millisecond,
+			Samples:         row.Samples,
+			Updated:         time.Unix(row.Updated, 0),
+		}
+		return nil
+	})
+}
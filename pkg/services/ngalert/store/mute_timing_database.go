@@ -0,0 +1,63 @@
+package store
+
+import (
+	"context"
+
+	"github.com/grafana/grafana/pkg/services/ngalert/models"
+	"github.com/grafana/grafana/pkg/services/sqlstore"
+)
+
+// ListMuteTimings is a handler for retrieving the mute timings, by name.
+func (st DBstore) ListMuteTimings(query *models.ListMuteTimingsQuery) error {
+	return st.SQLStore.WithDbSession(context.Background(), func(sess *sqlstore.DBSession) error {
+		timings := make([]*models.MuteTiming, 0)
+		if err := sess.Asc("name").Find(&timings); err != nil {
+			return err
+		}
+		query.Result = timings
+		return nil
+	})
+}
+
+// SaveMuteTiming is a handler for creating, or replacing, the mute timing with the name.
+func (st DBstore) SaveMuteTiming(cmd *models.SaveMuteTimingCommand) error {
+	if err := cmd.Validate(); err != nil {
+		return err
+	}
+
+	return st.SQLStore.WithTransactionalDbSession(context.Background(), func(sess *sqlstore.DBSession) error {
+		timing := models.MuteTiming{Name: cmd.Name}
+		has, err := sess.Get(&timing)
+		if err != nil {
+			return err
+		}
+		timing.TimeIntervals = cmd.TimeIntervals
+		timing.Location = cmd.Location
+		timing.Updated = TimeNow()
+		if has {
+			_, err = sess.ID(timing.ID).MustCols("location").Update(&timing)
+		} else {
+			_, err = sess.Insert(&timing)
+		}
+		if err != nil {
+			return err
+		}
+		cmd.Result = &timing
+		return nil
+	})
+}
+
+// DeleteMuteTiming is a handler for deleting the mute timing with the name.
+// It returns models.ErrMuteTimingNotFound if there is no such mute timing.
+func (st DBstore) DeleteMuteTiming(cmd *models.DeleteMuteTimingCommand) error {
+	return st.SQLStore.WithDbSession(context.Background(), func(sess *sqlstore.DBSession) error {
+		res, err := sess.Exec("DELETE FROM alert_mute_timing WHERE name = ?", cmd.Name)
+		if err != nil {
+			return err
+		}
+		if rows, err := res.RowsAffected(); err == nil && rows == 0 {
+			return models.ErrMuteTimingNotFound
+		}
+		return nil
+	})
+}
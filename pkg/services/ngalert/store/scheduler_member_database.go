@@ -0,0 +1,51 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"github.com/grafana/grafana/pkg/services/ngalert/models"
+	"github.com/grafana/grafana/pkg/services/sqlstore"
+)
+
+// schedulerMember is a row of the alert_scheduler_member table.
+type schedulerMember struct {
+	InstanceID    string `xorm:"instance_id"`
+	LastHeartbeat int64  `xorm:"last_heartbeat"`
+}
+
+// SaveSchedulerMember is a handler for registering a scheduler member or renewing its membership.
+func (st DBstore) SaveSchedulerMember(cmd *models.SaveSchedulerMemberCommand) error {
+	return st.SQLStore.WithDbSession(context.Background(), func(sess *sqlstore.DBSession) error {
+		params := []interface{}{cmd.Member.InstanceID, cmd.Member.LastHeartbeat.Unix()}
+
+		upsertSQL := st.SQLStore.Dialect.UpsertSQL(
+			"alert_scheduler_member",
+			[]string{"instance_id"},
+			[]string{"instance_id", "last_heartbeat"})
+		_, err := sess.SQL(upsertSQL, params...).Query()
+		return err
+	})
+}
+
+// ListSchedulerMembers is a handler for retrieving the scheduler members, ordered by instance ID,
+// whose last heartbeat is not before the one of the query.
+func (st DBstore) ListSchedulerMembers(query *models.ListSchedulerMembersQuery) error {
+	return st.SQLStore.WithDbSession(context.Background(), func(sess *sqlstore.DBSession) error {
+		rows := make([]*schedulerMember, 0)
+		q := "SELECT * FROM alert_scheduler_member WHERE last_heartbeat >= ? ORDER BY instance_id"
+		if err := sess.SQL(q, query.Since.Unix()).Find(&rows); err != nil {
+			return err
+		}
+
+		members := make([]*models.SchedulerMember, 0, len(rows))
+		for _, row := range rows {
+			members = append(members, &models.SchedulerMember{
+				InstanceID:    row.InstanceID,
+				LastHeartbeat: time.Unix(row.LastHeartbeat, 0),
+			})
+		}
+		query.Result = members
+		return nil
+	})
+}
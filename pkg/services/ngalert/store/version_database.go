@@ -0,0 +1,140 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/grafana/grafana/pkg/services/ngalert/models"
+	"github.com/grafana/grafana/pkg/services/sqlstore"
+)
+
+func getAlertDefinitionVersion(sess *sqlstore.DBSession, alertDefinitionID int64, version int64) (*models.AlertDefinitionVersion, error) {
+	alertDefinitionVersion := models.AlertDefinitionVersion{AlertDefinitionID: alertDefinitionID, Version: version}
+	has, err := sess.Get(&alertDefinitionVersion)
+	if err != nil {
+		return nil, err
+	}
+	if !has {
+		return nil, models.ErrAlertDefinitionVersionNotFound
+	}
+	return &alertDefinitionVersion, nil
+}
+
+// ListAlertDefinitionVersions is a handler for retrieving the versions of an alert definition, latest first.
+// It returns models.ErrAlertDefinitionNotFound if no alert definition is found for the provided UID.
+func (st DBstore) ListAlertDefinitionVersions(query *models.ListAlertDefinitionVersionsQuery) error {
+	return st.SQLStore.WithDbSession(context.Background(), func(sess *sqlstore.DBSession) error {
+		alertDefinition, err := getAlertDefinitionByUID(sess, query.UID, query.OrgID)
+		if err != nil {
+			return err
+		}
+
+		versions := make([]*models.AlertDefinitionVersion, 0)
+		q := "SELECT * FROM alert_definition_version WHERE alert_definition_id = ? ORDER BY version DESC"
+		if err := sess.SQL(q, alertDefinition.ID).Find(&versions); err != nil {
+			return err
+		}
+
+		query.Result = versions
+		return nil
+	})
+}
+
+// GetAlertDefinitionVersion is a handler for retrieving a version of an alert definition.
+// It returns models.ErrAlertDefinitionNotFound if no alert definition is found for the provided UID
+// and models.ErrAlertDefinitionVersionNotFound if it has no such version.
+func (st DBstore) GetAlertDefinitionVersion(query *models.GetAlertDefinitionVersionQuery) error {
+	return st.SQLStore.WithDbSession(context.Background(), func(sess *sqlstore.DBSession) error {
+		alertDefinition, err := getAlertDefinitionByUID(sess, query.UID, query.OrgID)
+		if err != nil {
+			return err
+		}
+
+		version, err := getAlertDefinitionVersion(sess, alertDefinition.ID, query.Version)
+		if err != nil {
+			return err
+		}
+
+		query.Result = version
+		return nil
+	})
+}
+
+// RestoreAlertDefinitionVersion is a handler for restoring an alert definition to one of its versions.
+// The restored alert definition gets a new version, recording the version it is restored from.
+// It returns models.ErrAlertDefinitionNotFound if no alert definition is found for the provided UID
+// and models.ErrAlertDefinitionVersionNotFound if it has no such version.
+func (st DBstore) RestoreAlertDefinitionVersion(cmd *models.RestoreAlertDefinitionVersionCommand) error {
+	return st.SQLStore.WithTransactionalDbSession(context.Background(), func(sess *sqlstore.DBSession) error {
+		existingAlertDefinition, err := getAlertDefinitionByUID(sess, cmd.UID, cmd.OrgID)
+		if err != nil {
+			return err
+		}
+
+		version, err := getAlertDefinitionVersion(sess, existingAlertDefinition.ID, cmd.Version)
+		if err != nil {
+			return err
+		}
+
+		alertDefinition := &models.AlertDefinition{
+			ID:                existingAlertDefinition.ID,
+			Title:             version.Title,
+			Condition:         version.Condition,
+			Data:              version.Data,
+			OrgID:             existingAlertDefinition.OrgID,
+			IntervalSeconds:   version.IntervalSeconds,
+			UID:               existingAlertDefinition.UID,
+			NoDataState:       version.NoDataState,
+			ExecErrState:      version.ExecErrState,
+			For:               version.For,
+			EvaluationTimeout: version.EvaluationTimeout,
+			Record:            version.Record,
+			MaxInstances:      version.MaxInstances,
+		}
+
+		if err := st.ValidateAlertDefinition(alertDefinition, true); err != nil {
+			return err
+		}
+
+		if err := alertDefinition.PreSave(TimeNow); err != nil {
+			return err
+		}
+
+		alertDefinition.Version = existingAlertDefinition.Version + 1
+
+		// the fields empty in the version to restore are restored as well
+		_, err = sess.ID(existingAlertDefinition.ID).MustCols("for", "evaluation_timeout", "no_data_state", "exec_err_state", "record", "max_instances").Update(alertDefinition)
+		if err != nil {
+			if st.SQLStore.Dialect.IsUniqueConstraintViolation(err) && strings.Contains(err.Error(), "title") {
+				return fmt.Errorf("an alert definition with the title '%s' already exists: %w", alertDefinition.Title, err)
+			}
+			return err
+		}
+
+		alertDefVersion := models.AlertDefinitionVersion{
+			AlertDefinitionID:  alertDefinition.ID,
+			AlertDefinitionUID: alertDefinition.UID,
+			ParentVersion:      existingAlertDefinition.Version,
+			RestoredFrom:       version.Version,
+			Version:            alertDefinition.Version,
+			Condition:          alertDefinition.Condition,
+			Created:            alertDefinition.Updated,
+			Title:              alertDefinition.Title,
+			Data:               alertDefinition.Data,
+			IntervalSeconds:    alertDefinition.IntervalSeconds,
+			NoDataState:        alertDefinition.NoDataState,
+			ExecErrState:       alertDefinition.ExecErrState,
+			For:                alertDefinition.For,
+			EvaluationTimeout:  alertDefinition.EvaluationTimeout,
+			Record:             alertDefinition.Record,
+			MaxInstances:       alertDefinition.MaxInstances,
+		}
+		if _, err := sess.Insert(alertDefVersion); err != nil {
+			return err
+		}
+
+		cmd.Result = alertDefinition
+		return nil
+	})
+}
@@ -43,6 +43,8 @@ func TestCreatingAlertDefinition(t *testing.T) {
 		desc                 string
 		inputIntervalSeconds *int64
 		inputTitle           string
+		inputNoDataState     models.NoDataState
+		inputTimeout         time.Duration
 		expectedError        error
 		expectedInterval     int64
 
@@ -74,6 +76,20 @@ func TestCreatingAlertDefinition(t *testing.T) {
 			inputTitle:           "",
 			expectedError:        store.ErrEmptyTitleError,
 		},
+		{
+			desc:                 "should fail to create an alert definition with invalid no data state",
+			inputIntervalSeconds: &customIntervalSeconds,
+			inputTitle:           "a name with an invalid no data state",
+			inputNoDataState:     "Unknown",
+			expectedError:        errors.New(""),
+		},
+		{
+			desc:                 "should fail to create an alert definition with a negative evaluation timeout",
+			inputIntervalSeconds: &customIntervalSeconds,
+			inputTitle:           "a name with a negative evaluation timeout",
+			inputTimeout:         -time.Second,
+			expectedError:        errors.New(""),
+		},
 	}
 
 	for _, tc := range testCases {
@@ -101,6 +117,8 @@ func TestCreatingAlertDefinition(t *testing.T) {
 			if tc.inputIntervalSeconds != nil {
 				q.IntervalSeconds = tc.inputIntervalSeconds
 			}
+			q.NoDataState = tc.inputNoDataState
+			q.EvaluationTimeout = tc.inputTimeout
 			err := dbstore.SaveAlertDefinition(&q)
 			switch {
 			case tc.expectedError != nil:
@@ -411,6 +429,249 @@ func TestDeletingAlertDefinition(t *testing.T) {
 	})
 }
 
+func TestBulkAlertDefinitionOperations(t *testing.T) {
+	dbstore := setupTestEnv(t, baseIntervalSeconds)
+	t.Cleanup(registry.ClearOverrides)
+
+	exprDefinition1 := createTestAlertDefinition(t, dbstore, 60)
+	exprDefinition2 := createTestAlertDefinition(t, dbstore, 60)
+	otherOrgDefinition := createTestAlertDefinitionForOrg(t, dbstore, 60, 2)
+	cmd := models.SaveAlertDefinitionCommand{
+		OrgID:     1,
+		Title:     "an alert definition querying a datasource",
+		Condition: "A",
+		Data: []models.AlertQuery{
+			{
+				Model: json.RawMessage(`{
+						"datasource": "testdata",
+						"datasourceUid": "test-ds"
+					}`),
+				RelativeTimeRange: models.RelativeTimeRange{
+					From: models.Duration(5 * time.Hour),
+					To:   models.Duration(3 * time.Hour),
+				},
+				RefID: "A",
+			},
+		},
+	}
+	require.NoError(t, dbstore.SaveAlertDefinition(&cmd))
+	datasourceDefinition := cmd.Result
+
+	paused := func(alertDefinition *models.AlertDefinition) bool {
+		q := models.GetAlertDefinitionByUIDQuery{UID: alertDefinition.UID, OrgID: alertDefinition.OrgID}
+		require.NoError(t, dbstore.GetAlertDefinitionByUID(&q))
+		return q.Result.Paused
+	}
+
+	t.Run("can pause the alert definitions querying a datasource", func(t *testing.T) {
+		pauseCmd := models.UpdateAlertDefinitionPausedCommand{OrgID: 1, DatasourceUID: "test-ds", Paused: true}
+		require.NoError(t, dbstore.UpdateAlertDefinitionPaused(&pauseCmd))
+		assert.Equal(t, int64(1), pauseCmd.ResultCount)
+		assert.True(t, paused(datasourceDefinition))
+		assert.False(t, paused(exprDefinition1))
+	})
+
+	t.Run("the datasource filter applies to the UIDs", func(t *testing.T) {
+		pauseCmd := models.UpdateAlertDefinitionPausedCommand{
+			OrgID:         1,
+			UIDs:          []string{exprDefinition1.UID, datasourceDefinition.UID},
+			DatasourceUID: "-100",
+			Paused:        true,
+		}
+		require.NoError(t, dbstore.UpdateAlertDefinitionPaused(&pauseCmd))
+		assert.Equal(t, int64(1), pauseCmd.ResultCount)
+		assert.True(t, paused(exprDefinition1))
+		assert.False(t, paused(exprDefinition2))
+	})
+
+	t.Run("can delete the alert definitions querying a datasource", func(t *testing.T) {
+		deleteCmd := models.DeleteAlertDefinitionsCommand{OrgID: 1, DatasourceUID: "-100"}
+		require.NoError(t, dbstore.DeleteAlertDefinitions(&deleteCmd))
+		assert.Equal(t, int64(2), deleteCmd.ResultCount)
+
+		q := models.ListAlertDefinitionsQuery{OrgID: 1}
+		require.NoError(t, dbstore.GetOrgAlertDefinitions(&q))
+		require.Len(t, q.Result, 1)
+		assert.Equal(t, datasourceDefinition.UID, q.Result[0].UID)
+
+		versions := models.ListAlertDefinitionVersionsQuery{UID: exprDefinition1.UID, OrgID: 1}
+		require.ErrorIs(t, dbstore.ListAlertDefinitionVersions(&versions), models.ErrAlertDefinitionNotFound)
+
+		q = models.ListAlertDefinitionsQuery{OrgID: otherOrgDefinition.OrgID}
+		require.NoError(t, dbstore.GetOrgAlertDefinitions(&q))
+		require.Len(t, q.Result, 1, "the alert definitions of other organisations are not deleted")
+	})
+
+	t.Run("can delete alert definitions by UID", func(t *testing.T) {
+		deleteCmd := models.DeleteAlertDefinitionsCommand{OrgID: 1, UIDs: []string{datasourceDefinition.UID, "unknown"}}
+		require.NoError(t, dbstore.DeleteAlertDefinitions(&deleteCmd))
+		assert.Equal(t, int64(1), deleteCmd.ResultCount)
+	})
+
+	t.Run("the alert definitions of other organisations are not deleted by UID", func(t *testing.T) {
+		deleteCmd := models.DeleteAlertDefinitionsCommand{OrgID: 1, UIDs: []string{otherOrgDefinition.UID}}
+		require.NoError(t, dbstore.DeleteAlertDefinitions(&deleteCmd))
+		assert.Equal(t, int64(0), deleteCmd.ResultCount)
+
+		q := models.GetAlertDefinitionByUIDQuery{UID: otherOrgDefinition.UID, OrgID: otherOrgDefinition.OrgID}
+		require.NoError(t, dbstore.GetAlertDefinitionByUID(&q))
+		versions := models.ListAlertDefinitionVersionsQuery{UID: otherOrgDefinition.UID, OrgID: otherOrgDefinition.OrgID}
+		require.NoError(t, dbstore.ListAlertDefinitionVersions(&versions))
+		assert.Len(t, versions.Result, 1, "the versions of the alert definitions of other organisations are kept")
+
+		require.NoError(t, dbstore.DeleteAlertDefinitionByUID(&models.DeleteAlertDefinitionByUIDCommand{UID: otherOrgDefinition.UID, OrgID: 1}))
+		require.NoError(t, dbstore.ListAlertDefinitionVersions(&versions))
+		assert.Len(t, versions.Result, 1)
+	})
+
+	t.Run("nothing is deleted without UIDs or datasource", func(t *testing.T) {
+		deleteCmd := models.DeleteAlertDefinitionsCommand{OrgID: otherOrgDefinition.OrgID}
+		require.NoError(t, dbstore.DeleteAlertDefinitions(&deleteCmd))
+		assert.Equal(t, int64(0), deleteCmd.ResultCount)
+	})
+}
+
+func TestBulkAlertDefinitionOperationsByFolder(t *testing.T) {
+	dbstore := setupTestEnv(t, baseIntervalSeconds)
+	t.Cleanup(registry.ClearOverrides)
+
+	folderDefinition1 := createTestAlertDefinitionInFolder(t, dbstore, "folder-1")
+	folderDefinition2 := createTestAlertDefinitionInFolder(t, dbstore, "folder-1")
+	otherFolderDefinition := createTestAlertDefinitionInFolder(t, dbstore, "folder-2")
+	otherOrgCmd := testAlertDefinitionCommand(2)
+	otherOrgCmd.NamespaceUID = "folder-1"
+	require.NoError(t, dbstore.SaveAlertDefinition(&otherOrgCmd))
+	otherOrgDefinition := otherOrgCmd.Result
+
+	paused := func(alertDefinition *models.AlertDefinition) bool {
+		q := models.GetAlertDefinitionByUIDQuery{UID: alertDefinition.UID, OrgID: alertDefinition.OrgID}
+		require.NoError(t, dbstore.GetAlertDefinitionByUID(&q))
+		return q.Result.Paused
+	}
+
+	t.Run("can pause the alert definitions of a folder", func(t *testing.T) {
+		pauseCmd := models.UpdateAlertDefinitionPausedCommand{OrgID: 1, NamespaceUID: "folder-1", Paused: true}
+		require.NoError(t, dbstore.UpdateAlertDefinitionPaused(&pauseCmd))
+		assert.Equal(t, int64(2), pauseCmd.ResultCount)
+		assert.True(t, paused(folderDefinition1))
+		assert.True(t, paused(folderDefinition2))
+		assert.False(t, paused(otherFolderDefinition))
+		assert.False(t, paused(otherOrgDefinition), "the alert definitions of other organisations are not paused")
+	})
+
+	t.Run("can unpause the alert definitions of a folder among the UIDs", func(t *testing.T) {
+		pauseCmd := models.UpdateAlertDefinitionPausedCommand{
+			OrgID:        1,
+			UIDs:         []string{folderDefinition1.UID, otherFolderDefinition.UID},
+			NamespaceUID: "folder-1",
+			Paused:       false,
+		}
+		require.NoError(t, dbstore.UpdateAlertDefinitionPaused(&pauseCmd))
+		assert.Equal(t, int64(1), pauseCmd.ResultCount)
+		assert.False(t, paused(folderDefinition1))
+		assert.True(t, paused(folderDefinition2))
+	})
+
+	t.Run("the folder filter applies to the datasource", func(t *testing.T) {
+		deleteCmd := models.DeleteAlertDefinitionsCommand{OrgID: 1, NamespaceUID: "folder-2", DatasourceUID: "test-ds"}
+		require.NoError(t, dbstore.DeleteAlertDefinitions(&deleteCmd))
+		assert.Equal(t, int64(0), deleteCmd.ResultCount)
+	})
+
+	t.Run("can delete the alert definitions of a folder", func(t *testing.T) {
+		deleteCmd := models.DeleteAlertDefinitionsCommand{OrgID: 1, NamespaceUID: "folder-1"}
+		require.NoError(t, dbstore.DeleteAlertDefinitions(&deleteCmd))
+		assert.Equal(t, int64(2), deleteCmd.ResultCount)
+
+		q := models.ListAlertDefinitionsQuery{OrgID: 1}
+		require.NoError(t, dbstore.GetOrgAlertDefinitions(&q))
+		require.Len(t, q.Result, 1)
+		assert.Equal(t, otherFolderDefinition.UID, q.Result[0].UID)
+
+		q = models.ListAlertDefinitionsQuery{OrgID: otherOrgDefinition.OrgID}
+		require.NoError(t, dbstore.GetOrgAlertDefinitions(&q))
+		require.Len(t, q.Result, 1, "the alert definitions of other organisations are not deleted")
+	})
+}
+
+func TestRecordingRuleDefinitions(t *testing.T) {
+	dbstore := setupTestEnv(t, baseIntervalSeconds)
+	t.Cleanup(registry.ClearOverrides)
+
+	alertDefinition := createTestAlertDefinition(t, dbstore, 60)
+	record := &models.RecordingRule{Metric: "job:requests:rate5m", TargetDatasourceUID: "prometheus"}
+
+	t.Run("alert definitions are not recording rules by default", func(t *testing.T) {
+		q := models.GetAlertDefinitionByUIDQuery{UID: alertDefinition.UID, OrgID: alertDefinition.OrgID}
+		require.NoError(t, dbstore.GetAlertDefinitionByUID(&q))
+		assert.False(t, q.Result.IsRecording())
+	})
+
+	t.Run("can make an alert definition a recording rule", func(t *testing.T) {
+		update := models.UpdateAlertDefinitionCommand{UID: alertDefinition.UID, OrgID: alertDefinition.OrgID, Record: record}
+		require.NoError(t, dbstore.UpdateAlertDefinition(&update))
+
+		q := models.GetAlertDefinitionByUIDQuery{UID: alertDefinition.UID, OrgID: alertDefinition.OrgID}
+		require.NoError(t, dbstore.GetAlertDefinitionByUID(&q))
+		require.True(t, q.Result.IsRecording())
+		assert.Equal(t, *record, q.Result.Record)
+	})
+
+	t.Run("the recording rule is versioned and restored", func(t *testing.T) {
+		v := models.GetAlertDefinitionVersionQuery{UID: alertDefinition.UID, OrgID: alertDefinition.OrgID, Version: 2}
+		require.NoError(t, dbstore.GetAlertDefinitionVersion(&v))
+		assert.Equal(t, *record, v.Result.Record)
+
+		restore := models.RestoreAlertDefinitionVersionCommand{UID: alertDefinition.UID, OrgID: alertDefinition.OrgID, Version: 1}
+		require.NoError(t, dbstore.RestoreAlertDefinitionVersion(&restore))
+		q := models.GetAlertDefinitionByUIDQuery{UID: alertDefinition.UID, OrgID: alertDefinition.OrgID}
+		require.NoError(t, dbstore.GetAlertDefinitionByUID(&q))
+		assert.False(t, q.Result.IsRecording())
+	})
+
+	t.Run("recording rules are validated", func(t *testing.T) {
+		for _, invalid := range []*models.RecordingRule{
+			{Metric: "job requests", TargetDatasourceUID: "prometheus"},
+			{Metric: "job:requests:rate5m"},
+		} {
+			update := models.UpdateAlertDefinitionCommand{UID: alertDefinition.UID, OrgID: alertDefinition.OrgID, Record: invalid}
+			require.Error(t, dbstore.UpdateAlertDefinition(&update))
+		}
+	})
+}
+
+func TestAlertDefinitionMaxInstances(t *testing.T) {
+	dbstore := setupTestEnv(t, baseIntervalSeconds)
+	t.Cleanup(registry.ClearOverrides)
+
+	alertDefinition := createTestAlertDefinition(t, dbstore, 60)
+	assert.Equal(t, int64(0), alertDefinition.MaxInstances)
+
+	t.Run("can set the maximum number of alert instances", func(t *testing.T) {
+		var maxInstances int64 = 100
+		update := models.UpdateAlertDefinitionCommand{UID: alertDefinition.UID, OrgID: alertDefinition.OrgID, MaxInstances: &maxInstances}
+		require.NoError(t, dbstore.UpdateAlertDefinition(&update))
+
+		q := models.GetAlertDefinitionByUIDQuery{UID: alertDefinition.UID, OrgID: alertDefinition.OrgID}
+		require.NoError(t, dbstore.GetAlertDefinitionByUID(&q))
+		assert.Equal(t, maxInstances, q.Result.MaxInstances)
+	})
+
+	t.Run("the maximum number of alert instances is versioned and restored", func(t *testing.T) {
+		restore := models.RestoreAlertDefinitionVersionCommand{UID: alertDefinition.UID, OrgID: alertDefinition.OrgID, Version: 1}
+		require.NoError(t, dbstore.RestoreAlertDefinitionVersion(&restore))
+		q := models.GetAlertDefinitionByUIDQuery{UID: alertDefinition.UID, OrgID: alertDefinition.OrgID}
+		require.NoError(t, dbstore.GetAlertDefinitionByUID(&q))
+		assert.Equal(t, int64(0), q.Result.MaxInstances)
+	})
+
+	t.Run("a negative maximum number of alert instances is invalid", func(t *testing.T) {
+		var maxInstances int64 = -1
+		update := models.UpdateAlertDefinitionCommand{UID: alertDefinition.UID, OrgID: alertDefinition.OrgID, MaxInstances: &maxInstances}
+		require.Error(t, dbstore.UpdateAlertDefinition(&update))
+	})
+}
+
 func getLongString(n int) string {
 	b := make([]rune, n)
 	for i := range b {
@@ -0,0 +1,91 @@
+// +build integration
+
+package tests
+
+import (
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana/pkg/services/ngalert/models"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAlertFolderSettingsOperations(t *testing.T) {
+	dbstore := setupTestEnv(t, baseIntervalSeconds)
+
+	t.Run("can save and read the alert settings of a folder", func(t *testing.T) {
+		saveCmd := &models.SaveAlertFolderSettingsCommand{
+			OrgID:           1,
+			NamespaceUID:    "folder-1",
+			IntervalSeconds: 2 * baseIntervalSeconds,
+			For:             5 * time.Minute,
+			NoDataState:     models.OK,
+		}
+		err := dbstore.SaveAlertFolderSettings(saveCmd)
+		require.NoError(t, err)
+
+		getQuery := &models.GetAlertFolderSettingsQuery{OrgID: 1, NamespaceUID: "folder-1"}
+		err = dbstore.GetAlertFolderSettings(getQuery)
+		require.NoError(t, err)
+		require.Equal(t, models.AlertRuleSettings{
+			IntervalSeconds: 2 * baseIntervalSeconds,
+			For:             5 * time.Minute,
+			NoDataState:     models.OK,
+		}, getQuery.Result.Settings())
+	})
+
+	t.Run("saving the alert settings of a folder again replaces them", func(t *testing.T) {
+		saveCmd := &models.SaveAlertFolderSettingsCommand{
+			OrgID:        1,
+			NamespaceUID: "folder-1",
+			NoDataState:  models.Alerting,
+		}
+		err := dbstore.SaveAlertFolderSettings(saveCmd)
+		require.NoError(t, err)
+
+		getQuery := &models.GetAlertFolderSettingsQuery{OrgID: 1, NamespaceUID: "folder-1"}
+		err = dbstore.GetAlertFolderSettings(getQuery)
+		require.NoError(t, err)
+		require.Equal(t, models.AlertRuleSettings{NoDataState: models.Alerting}, getQuery.Result.Settings())
+	})
+
+	t.Run("can list the alert settings of the folders", func(t *testing.T) {
+		err := dbstore.SaveAlertFolderSettings(&models.SaveAlertFolderSettingsCommand{
+			OrgID:           2,
+			NamespaceUID:    "folder-1",
+			IntervalSeconds: baseIntervalSeconds,
+		})
+		require.NoError(t, err)
+
+		listQuery := &models.ListAlertFolderSettingsQuery{OrgID: 1}
+		err = dbstore.ListAlertFolderSettings(listQuery)
+		require.NoError(t, err)
+		require.Len(t, listQuery.Result, 1)
+
+		listQuery = &models.ListAlertFolderSettingsQuery{}
+		err = dbstore.ListAlertFolderSettings(listQuery)
+		require.NoError(t, err)
+		require.Len(t, listQuery.Result, 2)
+	})
+
+	t.Run("saving an interval not divided exactly by the scheduler interval should fail", func(t *testing.T) {
+		err := dbstore.SaveAlertFolderSettings(&models.SaveAlertFolderSettingsCommand{
+			OrgID:           1,
+			NamespaceUID:    "folder-2",
+			IntervalSeconds: baseIntervalSeconds + 1,
+		})
+		require.ErrorIs(t, err, models.ErrInvalidAlertFolderSettings)
+	})
+
+	t.Run("can delete the alert settings of a folder", func(t *testing.T) {
+		err := dbstore.DeleteAlertFolderSettings(&models.DeleteAlertFolderSettingsCommand{OrgID: 1, NamespaceUID: "folder-1"})
+		require.NoError(t, err)
+
+		err = dbstore.GetAlertFolderSettings(&models.GetAlertFolderSettingsQuery{OrgID: 1, NamespaceUID: "folder-1"})
+		require.ErrorIs(t, err, models.ErrAlertFolderSettingsNotFound)
+
+		err = dbstore.DeleteAlertFolderSettings(&models.DeleteAlertFolderSettingsCommand{OrgID: 1, NamespaceUID: "folder-1"})
+		require.ErrorIs(t, err, models.ErrAlertFolderSettingsNotFound)
+	})
+}
@@ -0,0 +1,90 @@
+// +build integration
+
+package tests
+
+import (
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana/pkg/services/ngalert/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAlertStateHistoryOperations(t *testing.T) {
+	dbstore := setupTestEnv(t, baseIntervalSeconds)
+
+	alertDefinition1 := createTestAlertDefinition(t, dbstore, 60)
+	orgID := alertDefinition1.OrgID
+	alertDefinition2 := createTestAlertDefinition(t, dbstore, 60)
+
+	at := time.Unix(1616630400, 0)
+	entry := func(uid string, state models.InstanceStateType, minutes int) models.AlertStateHistoryEntry {
+		prevState := models.InstanceStateNormal
+		if state == models.InstanceStateNormal {
+			prevState = models.InstanceStateFiring
+		}
+		return models.AlertStateHistoryEntry{
+			DefinitionOrgID: orgID,
+			DefinitionUID:   uid,
+			Labels:          models.InstanceLabels{"test": "testValue"},
+			PrevState:       prevState,
+			State:           state,
+			At:              at.Add(time.Duration(minutes) * time.Minute),
+			Origin:          "instance-a",
+		}
+	}
+	err := dbstore.SaveAlertStateHistory(&models.SaveAlertStateHistoryCommand{Entries: []models.AlertStateHistoryEntry{
+		entry(alertDefinition1.UID, models.InstanceStateFiring, 0),
+		entry(alertDefinition2.UID, models.InstanceStateFiring, 1),
+		entry(alertDefinition1.UID, models.InstanceStateNormal, 2),
+		entry(alertDefinition1.UID, models.InstanceStateFiring, 3),
+	}})
+	require.NoError(t, err)
+
+	list := func(q models.ListAlertStateHistoryQuery) []models.InstanceStateType {
+		q.DefinitionOrgID = orgID
+		require.NoError(t, dbstore.ListAlertStateHistory(&q))
+		states := make([]models.InstanceStateType, 0, len(q.Result))
+		for _, e := range q.Result {
+			states = append(states, e.State)
+		}
+		return states
+	}
+
+	t.Run("can list the history of an organisation, oldest first", func(t *testing.T) {
+		q := models.ListAlertStateHistoryQuery{DefinitionOrgID: orgID}
+		require.NoError(t, dbstore.ListAlertStateHistory(&q))
+		require.Len(t, q.Result, 4)
+		first := q.Result[0]
+		assert.Equal(t, alertDefinition1.UID, first.DefinitionUID)
+		assert.Equal(t, models.InstanceLabels{"test": "testValue"}, first.Labels)
+		assert.Equal(t, models.InstanceStateNormal, first.PrevState)
+		assert.Equal(t, models.InstanceStateFiring, first.State)
+		assert.Equal(t, at.Unix(), first.At.Unix())
+		assert.Equal(t, "instance-a", first.Origin)
+		assert.Equal(t, alertDefinition2.UID, q.Result[1].DefinitionUID)
+	})
+
+	t.Run("can filter the history by alert definition", func(t *testing.T) {
+		states := list(models.ListAlertStateHistoryQuery{DefinitionUID: alertDefinition1.UID})
+		assert.Equal(t, []models.InstanceStateType{models.InstanceStateFiring, models.InstanceStateNormal, models.InstanceStateFiring}, states)
+	})
+
+	t.Run("can filter the history by time range", func(t *testing.T) {
+		states := list(models.ListAlertStateHistoryQuery{DefinitionUID: alertDefinition1.UID, From: at.Add(time.Minute), To: at.Add(2 * time.Minute)})
+		assert.Equal(t, []models.InstanceStateType{models.InstanceStateNormal}, states)
+	})
+
+	t.Run("can filter the history by state", func(t *testing.T) {
+		states := list(models.ListAlertStateHistoryQuery{State: models.InstanceStateFiring})
+		assert.Equal(t, []models.InstanceStateType{models.InstanceStateFiring, models.InstanceStateFiring, models.InstanceStateFiring}, states)
+	})
+
+	t.Run("the history of other organisations is not listed", func(t *testing.T) {
+		q := models.ListAlertStateHistoryQuery{DefinitionOrgID: orgID + 1}
+		require.NoError(t, dbstore.ListAlertStateHistory(&q))
+		assert.Empty(t, q.Result)
+	})
+}
@@ -126,6 +126,30 @@ func TestAlertInstanceOperations(t *testing.T) {
 		require.Len(t, listQuery.Result, 1)
 	})
 
+	t.Run("can list all added instances in org by pages", func(t *testing.T) {
+		listQuery := &models.ListAlertInstancesQuery{
+			DefinitionOrgID: orgID,
+			Limit:           3,
+		}
+
+		err := dbstore.ListAlertInstances(listQuery)
+		require.NoError(t, err)
+		require.Len(t, listQuery.Result, 3)
+		listed := map[string]struct{}{}
+		for _, instance := range listQuery.Result {
+			listed[instance.DefinitionUID+instance.LabelsHash] = struct{}{}
+		}
+
+		last := listQuery.Result[len(listQuery.Result)-1]
+		listQuery.AfterDefinitionUID, listQuery.AfterLabelsHash = last.DefinitionUID, last.LabelsHash
+		err = dbstore.ListAlertInstances(listQuery)
+		require.NoError(t, err)
+		require.Len(t, listQuery.Result, 1)
+		listed[listQuery.Result[0].DefinitionUID+listQuery.Result[0].LabelsHash] = struct{}{}
+
+		require.Len(t, listed, 4)
+	})
+
 	t.Run("update instance with same org_id, uid and different labels", func(t *testing.T) {
 		saveCmdOne := &models.SaveAlertInstanceCommand{
 			DefinitionOrgID: alertDefinition4.OrgID,
@@ -163,4 +187,65 @@ func TestAlertInstanceOperations(t *testing.T) {
 		require.NotEmpty(t, listQuery.Result[0].DefinitionTitle)
 		require.Equal(t, alertDefinition4.Title, listQuery.Result[0].DefinitionTitle)
 	})
+
+	t.Run("can save a batch of new and existing instances", func(t *testing.T) {
+		batchCmd := &models.SaveAlertInstancesCommand{
+			Instances: []models.SaveAlertInstanceCommand{
+				{
+					DefinitionOrgID: alertDefinition3.OrgID,
+					DefinitionUID:   alertDefinition3.UID,
+					State:           models.InstanceStateNormal,
+					Labels:          models.InstanceLabels{"test": "meow"},
+				},
+				{
+					DefinitionOrgID: alertDefinition3.OrgID,
+					DefinitionUID:   alertDefinition3.UID,
+					State:           models.InstanceStateFiring,
+					Labels:          models.InstanceLabels{"test": "woof"},
+				},
+			},
+		}
+		err := dbstore.SaveAlertInstances(batchCmd)
+		require.NoError(t, err)
+
+		for _, saveCmd := range batchCmd.Instances {
+			getCmd := &models.GetAlertInstanceQuery{
+				DefinitionOrgID: saveCmd.DefinitionOrgID,
+				DefinitionUID:   saveCmd.DefinitionUID,
+				Labels:          saveCmd.Labels,
+			}
+			err = dbstore.GetAlertInstance(getCmd)
+			require.NoError(t, err)
+			require.Equal(t, saveCmd.State, getCmd.Result.CurrentState)
+		}
+
+		listQuery := &models.ListAlertInstancesQuery{
+			DefinitionOrgID: alertDefinition3.OrgID,
+			DefinitionUID:   alertDefinition3.UID,
+		}
+		err = dbstore.ListAlertInstances(listQuery)
+		require.NoError(t, err)
+		require.Len(t, listQuery.Result, 3)
+	})
+
+	t.Run("can delete instances by labels", func(t *testing.T) {
+		deleteCmd := &models.DeleteAlertInstancesCommand{
+			DefinitionOrgID: alertDefinition3.OrgID,
+			DefinitionUID:   alertDefinition3.UID,
+			Labels:          []models.InstanceLabels{{"test": "woof"}, {"test": "missing"}},
+		}
+		err := dbstore.DeleteAlertInstances(deleteCmd)
+		require.NoError(t, err)
+
+		listQuery := &models.ListAlertInstancesQuery{
+			DefinitionOrgID: alertDefinition3.OrgID,
+			DefinitionUID:   alertDefinition3.UID,
+		}
+		err = dbstore.ListAlertInstances(listQuery)
+		require.NoError(t, err)
+		require.Len(t, listQuery.Result, 2)
+		for _, instance := range listQuery.Result {
+			require.NotEqual(t, models.InstanceLabels{"test": "woof"}, instance.Labels)
+		}
+	})
 }
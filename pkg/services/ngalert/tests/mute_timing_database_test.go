@@ -0,0 +1,52 @@
+// +build integration
+
+package tests
+
+import (
+	"testing"
+
+	"github.com/prometheus/alertmanager/timeinterval"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/services/ngalert/models"
+)
+
+func TestMuteTimingOperations(t *testing.T) {
+	dbstore := setupTestEnv(t, baseIntervalSeconds)
+
+	weekends := []timeinterval.TimeInterval{{Weekdays: []timeinterval.WeekdayRange{{InclusiveRange: timeinterval.InclusiveRange{Begin: 6, End: 6}}}}}
+	holidays := []timeinterval.TimeInterval{{Months: []timeinterval.MonthRange{{InclusiveRange: timeinterval.InclusiveRange{Begin: 12, End: 12}}}}}
+
+	t.Run("invalid mute timings are not saved", func(t *testing.T) {
+		require.Error(t, dbstore.SaveMuteTiming(&models.SaveMuteTimingCommand{TimeIntervals: weekends}))
+		require.Error(t, dbstore.SaveMuteTiming(&models.SaveMuteTimingCommand{Name: "weekends"}))
+		require.Error(t, dbstore.SaveMuteTiming(&models.SaveMuteTimingCommand{Name: "weekends", TimeIntervals: weekends, Location: "Mars/Olympus_Mons"}))
+	})
+
+	t.Run("mute timings are created and replaced by name", func(t *testing.T) {
+		require.NoError(t, dbstore.SaveMuteTiming(&models.SaveMuteTimingCommand{Name: "weekends", TimeIntervals: weekends, Location: "Europe/Paris"}))
+		require.NoError(t, dbstore.SaveMuteTiming(&models.SaveMuteTimingCommand{Name: "holidays", TimeIntervals: weekends}))
+		cmd := &models.SaveMuteTimingCommand{Name: "holidays", TimeIntervals: holidays, Location: "America/New_York"}
+		require.NoError(t, dbstore.SaveMuteTiming(cmd))
+		require.Equal(t, "holidays", cmd.Result.Name)
+
+		query := &models.ListMuteTimingsQuery{}
+		require.NoError(t, dbstore.ListMuteTimings(query))
+		require.Len(t, query.Result, 2)
+		require.Equal(t, "holidays", query.Result[0].Name)
+		require.Equal(t, holidays, query.Result[0].TimeIntervals)
+		require.Equal(t, "America/New_York", query.Result[0].Location)
+		require.Equal(t, "weekends", query.Result[1].Name)
+		require.Equal(t, weekends, query.Result[1].TimeIntervals)
+	})
+
+	t.Run("mute timings are deleted by name", func(t *testing.T) {
+		require.NoError(t, dbstore.DeleteMuteTiming(&models.DeleteMuteTimingCommand{Name: "holidays"}))
+		require.ErrorIs(t, dbstore.DeleteMuteTiming(&models.DeleteMuteTimingCommand{Name: "holidays"}), models.ErrMuteTimingNotFound)
+
+		query := &models.ListMuteTimingsQuery{}
+		require.NoError(t, dbstore.ListMuteTimings(query))
+		require.Len(t, query.Result, 1)
+		require.Equal(t, "weekends", query.Result[0].Name)
+	})
+}
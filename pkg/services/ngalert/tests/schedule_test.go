@@ -2,23 +2,42 @@ package tests
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
 	"runtime"
+	"sort"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/golang/snappy"
 	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/grafana/grafana/pkg/infra/metrics"
+	apimodels "github.com/grafana/grafana/pkg/models"
 	"github.com/grafana/grafana/pkg/services/ngalert/eval"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/prometheus/prompb"
 
 	"github.com/grafana/grafana/pkg/services/ngalert/state"
 
 	"github.com/grafana/grafana/pkg/infra/log"
 
 	"github.com/grafana/grafana/pkg/services/ngalert/schedule"
+	"github.com/grafana/grafana/pkg/services/ngalert/schedule/schedtest"
 
 	"github.com/grafana/grafana/pkg/registry"
 	"github.com/grafana/grafana/pkg/services/ngalert/models"
+	"github.com/grafana/grafana/pkg/services/ngalert/notifier"
+	"github.com/grafana/grafana/pkg/services/sqlstore"
+	"github.com/grafana/grafana/pkg/tsdb"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
@@ -99,12 +118,327 @@ func TestWarmStateCache(t *testing.T) {
 
 	t.Run("instance cache has expected entries", func(t *testing.T) {
 		for _, entry := range expectedEntries {
-			cacheEntry := st.Get(entry.CacheId)
+			cacheEntry := st.Get(entry.OrgID, entry.CacheId)
 			assert.True(t, entry.Equals(cacheEntry))
 		}
 	})
 }
 
+func TestWarmStateCacheFormatVersions(t *testing.T) {
+	evaluationTime, _ := time.Parse("2006-01-02", "2021-03-25")
+
+	dbstore := setupTestEnv(t, 1)
+	t.Cleanup(registry.ClearOverrides)
+
+	// insertInstance persists an alert instance the way it was persisted with the format version
+	insertInstance := func(formatVersion int, labels models.InstanceLabels, state models.InstanceStateType, end int64) {
+		labelsJSON, labelsHash, err := labels.StringAndHash()
+		require.NoError(t, err)
+		err = dbstore.SQLStore.WithDbSession(context.Background(), func(sess *sqlstore.DBSession) error {
+			_, err := sess.Exec("INSERT INTO alert_instance (def_org_id, def_uid, labels, labels_hash, current_state, current_state_since, current_state_end, last_eval_time, format_version) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)",
+				123, "test_uid", labelsJSON, labelsHash, state, evaluationTime.Add(-time.Minute).Unix(), end, evaluationTime.Unix(), formatVersion)
+			return err
+		})
+		require.NoError(t, err)
+	}
+	insertInstance(models.InstanceFormatVersionUnversioned, models.InstanceLabels{"v0": "firing"}, models.InstanceStateFiring, 0)
+	insertInstance(models.InstanceFormatVersionUnversioned, models.InstanceLabels{"v0": "normal"}, models.InstanceStateNormal, 0)
+	insertInstance(models.InstanceFormatVersionStateEnd, models.InstanceLabels{"v1": "firing"}, models.InstanceStateFiring, evaluationTime.Add(time.Minute).Unix())
+	insertInstance(models.InstanceFormatVersionCurrent+1, models.InstanceLabels{"future": "firing"}, models.InstanceStateFiring, evaluationTime.Add(time.Minute).Unix())
+
+	schedCfg := schedule.SchedulerCfg{
+		C:            clock.NewMock(),
+		BaseInterval: time.Second,
+		Logger:       log.New("ngalert cache warming test"),
+		Store:        dbstore,
+	}
+	sched := schedule.NewScheduler(schedCfg, nil)
+	st := state.NewStateTracker(schedCfg.Logger)
+	sched.WarmStateCache(st)
+
+	t.Run("unversioned firing instances get the current state end set", func(t *testing.T) {
+		s := st.Get(123, "test_uid v0=firing")
+		assert.Equal(t, eval.Alerting, s.State)
+		assert.True(t, s.EndsAt.Equal(evaluationTime.Add(40*time.Second)))
+	})
+
+	t.Run("unversioned normal instances don't get a current state end", func(t *testing.T) {
+		s := st.Get(123, "test_uid v0=normal")
+		assert.Equal(t, eval.Normal, s.State)
+		assert.True(t, s.EndsAt.IsZero())
+	})
+
+	t.Run("current instances are kept as they are", func(t *testing.T) {
+		s := st.Get(123, "test_uid v1=firing")
+		assert.Equal(t, eval.Alerting, s.State)
+		assert.True(t, s.EndsAt.Equal(evaluationTime.Add(time.Minute)))
+	})
+
+	t.Run("instances with unknown format versions are skipped", func(t *testing.T) {
+		assert.Empty(t, st.Get(123, "test_uid future=firing").CacheId)
+		assert.Len(t, st.GetAllForOrg(123), 3)
+	})
+
+	t.Run("saved instances are stamped with the current format version", func(t *testing.T) {
+		err := dbstore.SaveAlertInstance(&models.SaveAlertInstanceCommand{
+			DefinitionOrgID:   123,
+			DefinitionUID:     "test_uid",
+			Labels:            models.InstanceLabels{"v0": "firing"},
+			State:             models.InstanceStateFiring,
+			LastEvalTime:      evaluationTime,
+			CurrentStateSince: evaluationTime.Add(-time.Minute),
+			CurrentStateEnd:   evaluationTime.Add(time.Minute),
+		})
+		require.NoError(t, err)
+		q := models.ListAlertInstancesQuery{DefinitionOrgID: 123, State: models.InstanceStateFiring}
+		require.NoError(t, dbstore.ListAlertInstances(&q))
+		for _, entry := range q.Result {
+			if entry.Labels["v0"] == "firing" {
+				assert.Equal(t, models.InstanceFormatVersionCurrent, entry.FormatVersion)
+			}
+		}
+	})
+}
+
+func TestWarmStateCacheOrigin(t *testing.T) {
+	evaluationTime, _ := time.Parse("2006-01-02", "2021-03-25")
+
+	dbstore := setupTestEnv(t, 1)
+	t.Cleanup(registry.ClearOverrides)
+
+	err := dbstore.SaveAlertInstance(&models.SaveAlertInstanceCommand{
+		DefinitionOrgID:   123,
+		DefinitionUID:     "test_uid",
+		Labels:            models.InstanceLabels{"test1": "testValue1"},
+		State:             models.InstanceStateFiring,
+		LastEvalTime:      evaluationTime,
+		CurrentStateSince: evaluationTime.Add(-time.Minute),
+		CurrentStateEnd:   evaluationTime.Add(time.Minute),
+		Origin:            "grafana-2",
+	})
+	require.NoError(t, err)
+
+	schedCfg := schedule.SchedulerCfg{
+		C:            clock.NewMock(),
+		BaseInterval: time.Second,
+		Logger:       log.New("ngalert cache warming test"),
+		Store:        dbstore,
+	}
+	sched := schedule.NewScheduler(schedCfg, nil)
+	st := state.NewStateTracker(schedCfg.Logger)
+	st.SetInstanceID("grafana-1")
+	sched.WarmStateCache(st)
+
+	assert.Equal(t, "grafana-2", st.Get(123, "test_uid test1=testValue1").Origin)
+}
+
+func TestWarmStateCacheStartsAtBound(t *testing.T) {
+	processStart, _ := time.Parse("2006-01-02", "2021-03-25")
+
+	dbstore := setupTestEnv(t, 1)
+	t.Cleanup(registry.ClearOverrides)
+
+	saveInstance := func(labels models.InstanceLabels, since time.Time) {
+		err := dbstore.SaveAlertInstance(&models.SaveAlertInstanceCommand{
+			DefinitionOrgID:   123,
+			DefinitionUID:     "test_uid",
+			Labels:            labels,
+			State:             models.InstanceStateFiring,
+			LastEvalTime:      processStart,
+			CurrentStateSince: since,
+			CurrentStateEnd:   processStart.Add(time.Minute),
+		})
+		require.NoError(t, err)
+	}
+	saveInstance(models.InstanceLabels{"instance": "old"}, processStart.Add(-24*time.Hour))
+	saveInstance(models.InstanceLabels{"instance": "recent"}, processStart.Add(time.Minute))
+
+	schedCfg := schedule.SchedulerCfg{
+		C:            clock.NewMock(),
+		BaseInterval: time.Second,
+		Logger:       log.New("ngalert cache warming test"),
+		Store:        dbstore,
+	}
+	sched := schedule.NewScheduler(schedCfg, nil)
+
+	t.Run("restored StartsAt is kept exact by default", func(t *testing.T) {
+		st := state.NewStateTracker(schedCfg.Logger)
+		sched.WarmStateCache(st)
+
+		s := st.Get(123, "test_uid instance=old")
+		assert.True(t, s.StartsAt.Equal(processStart.Add(-24*time.Hour)))
+	})
+
+	t.Run("restored StartsAt is clamped to the bound", func(t *testing.T) {
+		st := state.NewStateTracker(schedCfg.Logger)
+		st.SetStartsAtBound(processStart)
+		sched.WarmStateCache(st)
+
+		s := st.Get(123, "test_uid instance=old")
+		assert.True(t, s.StartsAt.Equal(processStart))
+		s = st.Get(123, "test_uid instance=recent")
+		assert.True(t, s.StartsAt.Equal(processStart.Add(time.Minute)))
+	})
+}
+
+func TestWarmStateCacheByPages(t *testing.T) {
+	evaluationTime, _ := time.Parse("2006-01-02", "2021-03-25")
+
+	dbstore := setupTestEnv(t, 1)
+	t.Cleanup(registry.ClearOverrides)
+
+	type cacheEntry struct {
+		orgID   int64
+		cacheID string
+	}
+	var expected []cacheEntry
+	for orgID := int64(1); orgID <= 3; orgID++ {
+		for i := 0; i < 5; i++ {
+			uid := fmt.Sprintf("test_uid_%d", i%2)
+			instance := fmt.Sprintf("instance%d", i)
+			err := dbstore.SaveAlertInstance(&models.SaveAlertInstanceCommand{
+				DefinitionOrgID:   orgID,
+				DefinitionUID:     uid,
+				Labels:            models.InstanceLabels{"instance": instance},
+				State:             models.InstanceStateFiring,
+				LastEvalTime:      evaluationTime,
+				CurrentStateSince: evaluationTime,
+				CurrentStateEnd:   evaluationTime.Add(time.Minute),
+			})
+			require.NoError(t, err)
+			expected = append(expected, cacheEntry{orgID: orgID, cacheID: state.CacheID(uid, data.Labels{"instance": instance})})
+		}
+	}
+
+	schedCfg := schedule.SchedulerCfg{
+		C:                 clock.NewMock(),
+		BaseInterval:      time.Second,
+		Logger:            log.New("ngalert cache warming test"),
+		Store:             dbstore,
+		StateCacheWarming: &schedule.StateCacheWarming{PageSize: 2, Parallelism: 2},
+	}
+	sched := schedule.NewScheduler(schedCfg, nil)
+	st := state.NewStateTracker(schedCfg.Logger)
+	sched.WarmStateCache(st)
+
+	t.Run("all the alert instances of all the organisations are loaded", func(t *testing.T) {
+		require.Equal(t, len(expected), st.CacheSize())
+		for _, entry := range expected {
+			assert.Equal(t, eval.Alerting, st.Get(entry.orgID, entry.cacheID).State, entry.cacheID)
+		}
+	})
+
+	t.Run("the warming is signalled once finished", func(t *testing.T) {
+		select {
+		case <-sched.StateCacheWarmed():
+		default:
+			t.Fatal("the state cache warming is not signalled")
+		}
+	})
+}
+
+func TestSchedulerWaitsForStateCache(t *testing.T) {
+	dbstore := setupTestEnv(t, 1)
+	t.Cleanup(registry.ClearOverrides)
+
+	alertDefinition := createTestAlertDefinition(t, dbstore, 1)
+	key := alertDefinition.GetKey()
+
+	evaluator := &fakeEvaluator{evalFunc: func(*models.Condition, time.Time) (eval.Results, error) {
+		return eval.Results{{Instance: data.Labels{}, State: eval.Normal}}, nil
+	}}
+	h := schedtest.New(t, schedule.SchedulerCfg{
+		MaxAttempts:       1,
+		Evaluator:         evaluator,
+		Store:             dbstore,
+		Notifier:          &fakeNotifier{},
+		Logger:            log.New("ngalert schedule test"),
+		WaitForStateCache: true,
+	})
+
+	tick := h.Advance()
+	h.ExpectEvaluated(tick)
+	select {
+	case <-h.Scheduler.StateCacheWarmed():
+		t.Fatal("the state cache warming is signalled before it's warmed")
+	default:
+	}
+
+	h.Scheduler.WarmStateCache(h.StateTracker)
+	h.ExpectEvaluated(tick, key)
+}
+
+func TestReconcileWithStore(t *testing.T) {
+	evaluationTime, _ := time.Parse("2006-01-02", "2021-03-25")
+
+	dbstore := setupTestEnv(t, 1)
+	t.Cleanup(registry.ClearOverrides)
+	alertDefinition := createTestAlertDefinition(t, dbstore, 1)
+
+	alertState := func(uid string, series string, s eval.State) state.AlertState {
+		lbs := data.Labels{"series": series}
+		return state.AlertState{
+			UID:                uid,
+			OrgID:              alertDefinition.OrgID,
+			CacheId:            state.CacheID(uid, lbs),
+			Labels:             lbs,
+			State:              s,
+			StartsAt:           evaluationTime.Add(-time.Minute),
+			EndsAt:             evaluationTime.Add(time.Minute),
+			LastEvaluationTime: evaluationTime,
+		}
+	}
+	save := func(s state.AlertState, instanceState models.InstanceStateType) {
+		err := dbstore.SaveAlertInstance(&models.SaveAlertInstanceCommand{
+			DefinitionOrgID:   s.OrgID,
+			DefinitionUID:     s.UID,
+			Labels:            models.InstanceLabels(s.Labels),
+			State:             instanceState,
+			LastEvalTime:      s.LastEvaluationTime,
+			CurrentStateSince: s.StartsAt,
+			CurrentStateEnd:   s.EndsAt,
+		})
+		require.NoError(t, err)
+	}
+
+	stored := alertState(alertDefinition.UID, "stored", eval.Alerting)
+	missing := alertState(alertDefinition.UID, "missing", eval.Alerting)
+	diverged := alertState(alertDefinition.UID, "diverged", eval.Alerting)
+	deleted := alertState("deleted_uid", "deleted", eval.Alerting)
+	unknown := alertState(alertDefinition.UID, "unknown", eval.Normal)
+	save(stored, models.InstanceStateFiring)
+	save(diverged, models.InstanceStateNormal)
+	save(unknown, models.InstanceStateNormal)
+
+	st := state.NewStateTracker(log.New("ngalert reconcile test"))
+	st.Put([]state.AlertState{stored, missing, diverged, deleted})
+
+	expected := state.ReconcileReport{
+		Missing:  []string{missing.CacheId},
+		Diverged: []string{diverged.CacheId},
+		Deleted:  []string{deleted.CacheId},
+		Unknown:  []string{unknown.CacheId},
+	}
+
+	t.Run("discrepancies are detected", func(t *testing.T) {
+		report, err := st.ReconcileWithStore(dbstore, false)
+		require.NoError(t, err)
+		assert.Equal(t, expected, report)
+		assert.Equal(t, deleted.CacheId, st.Get(deleted.OrgID, deleted.CacheId).CacheId, "nothing is repaired")
+	})
+
+	t.Run("discrepancies are repaired", func(t *testing.T) {
+		report, err := st.ReconcileWithStore(dbstore, true)
+		require.NoError(t, err)
+		assert.Equal(t, expected, report)
+		assert.Empty(t, st.Get(deleted.OrgID, deleted.CacheId).CacheId)
+
+		report, err = st.ReconcileWithStore(dbstore, true)
+		require.NoError(t, err)
+		assert.Equal(t, state.ReconcileReport{Unknown: []string{unknown.CacheId}}, report)
+	})
+}
+
 func TestAlertingTicker(t *testing.T) {
 	dbstore := setupTestEnv(t, 1)
 	t.Cleanup(registry.ClearOverrides)
@@ -236,71 +570,2291 @@ func TestAlertingTicker(t *testing.T) {
 	})
 }
 
-func assertEvalRun(t *testing.T, ch <-chan evalAppliedInfo, tick time.Time, keys ...models.AlertDefinitionKey) {
-	timeout := time.After(time.Second)
+func TestAlertingTickerWithHarness(t *testing.T) {
+	dbstore := setupTestEnv(t, 1)
+	t.Cleanup(registry.ClearOverrides)
 
-	expected := make(map[models.AlertDefinitionKey]struct{}, len(keys))
-	for _, k := range keys {
-		expected[k] = struct{}{}
-	}
+	// an alert definition with zero interval should never run
+	zeroInterval := createTestAlertDefinition(t, dbstore, 0)
+	oneSecInterval := createTestAlertDefinition(t, dbstore, 1)
 
-	for {
-		select {
-		case info := <-ch:
-			_, ok := expected[info.alertDefKey]
-			t.Logf("alert definition: %v evaluated at: %v", info.alertDefKey, info.now)
-			assert.True(t, ok)
-			assert.Equal(t, tick, info.now)
-			delete(expected, info.alertDefKey)
-			if len(expected) == 0 {
-				return
-			}
-		case <-timeout:
-			if len(expected) == 0 {
-				return
-			}
-			t.Fatal("cycle has expired")
-		}
-	}
+	h := schedtest.New(t, schedule.SchedulerCfg{
+		Store:  dbstore,
+		Logger: log.New("ngalert schedule test"),
+	})
+
+	h.AdvanceAndExpect(oneSecInterval.GetKey())
+
+	// change alert definition interval to three seconds
+	var threeSecInterval int64 = 3
+	err := dbstore.UpdateAlertDefinition(&models.UpdateAlertDefinitionCommand{
+		UID:             zeroInterval.UID,
+		IntervalSeconds: &threeSecInterval,
+		OrgID:           zeroInterval.OrgID,
+	})
+	require.NoError(t, err)
+
+	h.AdvanceAndExpect(oneSecInterval.GetKey())
+	h.AdvanceAndExpect(oneSecInterval.GetKey(), zeroInterval.GetKey())
+	h.AdvanceAndExpect(oneSecInterval.GetKey())
+
+	err = dbstore.DeleteAlertDefinitionByUID(&models.DeleteAlertDefinitionByUIDCommand{UID: oneSecInterval.UID, OrgID: oneSecInterval.OrgID})
+	require.NoError(t, err)
+
+	h.AdvanceAndExpect()
+	h.ExpectStopped(oneSecInterval.GetKey())
+	h.AdvanceAndExpect(zeroInterval.GetKey())
 }
 
-func assertStopRun(t *testing.T, ch <-chan models.AlertDefinitionKey, keys ...models.AlertDefinitionKey) {
-	timeout := time.After(time.Second)
+func TestSchedulerFolderDefaults(t *testing.T) {
+	dbstore := setupTestEnv(t, 1)
+	t.Cleanup(registry.ClearOverrides)
 
-	expected := make(map[models.AlertDefinitionKey]struct{}, len(keys))
-	for _, k := range keys {
-		expected[k] = struct{}{}
-	}
+	// an alert definition of a folder without alert settings has no interval and never runs
+	alertDefinition := createTestAlertDefinitionInFolder(t, dbstore, "folder-1")
+	require.Equal(t, int64(0), alertDefinition.IntervalSeconds)
+	key := alertDefinition.GetKey()
 
-	for {
-		select {
-		case alertDefKey := <-ch:
-			_, ok := expected[alertDefKey]
-			t.Logf("alert definition: %v stopped", alertDefKey)
-			assert.True(t, ok)
-			delete(expected, alertDefKey)
-			if len(expected) == 0 {
-				return
-			}
-		case <-timeout:
-			if len(expected) == 0 {
-				return
-			}
-			t.Fatal("cycle has expired")
+	evaluator := &fakeEvaluator{evalFunc: func(*models.Condition, time.Time) (eval.Results, error) {
+		return eval.Results{{Instance: data.Labels{}, State: eval.Alerting}}, nil
+	}}
+	h := schedtest.New(t, schedule.SchedulerCfg{
+		MaxAttempts: 1,
+		Evaluator:   evaluator,
+		Store:       dbstore,
+		Notifier:    &fakeNotifier{},
+		Logger:      log.New("ngalert schedule test"),
+	})
+	cacheID := state.CacheID(key.DefinitionUID, data.Labels{})
+
+	h.AdvanceAndExpect()
+
+	// the interval and the for duration of the folder apply to the alert definition
+	err := dbstore.SaveAlertFolderSettings(&models.SaveAlertFolderSettingsCommand{
+		OrgID:           key.OrgID,
+		NamespaceUID:    "folder-1",
+		IntervalSeconds: 2,
+		For:             time.Hour,
+	})
+	require.NoError(t, err)
+
+	h.AdvanceAndExpect(key)
+	assert.Equal(t, eval.Pending, h.StateTracker.Get(key.OrgID, cacheID).State)
+	h.AdvanceAndExpect()
+
+	// without a for duration the alert definition fires on its next evaluation
+	err = dbstore.SaveAlertFolderSettings(&models.SaveAlertFolderSettingsCommand{
+		OrgID:           key.OrgID,
+		NamespaceUID:    "folder-1",
+		IntervalSeconds: 2,
+	})
+	require.NoError(t, err)
+
+	h.AdvanceAndExpect(key)
+	assert.Equal(t, eval.Alerting, h.StateTracker.Get(key.OrgID, cacheID).State)
+}
+
+func TestSchedulerLastDecision(t *testing.T) {
+	dbstore := setupTestEnv(t, 1)
+	t.Cleanup(registry.ClearOverrides)
+
+	pausedDefinition := createTestAlertDefinition(t, dbstore, 1)
+	err := dbstore.UpdateAlertDefinitionPaused(&models.UpdateAlertDefinitionPausedCommand{UIDs: []string{pausedDefinition.UID}, OrgID: pausedDefinition.OrgID, Paused: true})
+	require.NoError(t, err)
+	slowDefinition := createTestAlertDefinition(t, dbstore, 1)
+	notDueDefinition := createTestAlertDefinition(t, dbstore, 3)
+	maintenanceDefinition := createTestAlertDefinition(t, dbstore, 1)
+
+	evalStarted := make(chan struct{}, 1)
+	releaseEval := make(chan struct{})
+	evaluator := &fakeEvaluator{evalFunc: func(c *models.Condition, now time.Time) (eval.Results, error) {
+		if now.Unix() == 1 {
+			evalStarted <- struct{}{}
+			<-releaseEval
 		}
+		return eval.Results{}, nil
+	}}
+
+	evalAppliedCh := make(chan evalAppliedInfo, 3)
+	mockedClock := clock.NewMock()
+	schedCfg := schedule.SchedulerCfg{
+		C:            mockedClock,
+		BaseInterval: time.Second,
+		EvalAppliedFunc: func(alertDefKey models.AlertDefinitionKey, now time.Time) {
+			evalAppliedCh <- evalAppliedInfo{alertDefKey: alertDefKey, now: now}
+		},
+		MaxAttempts: 1,
+		Evaluator:   evaluator,
+		Store:       dbstore,
+		Notifier:    &fakeNotifier{},
+		Logger:      log.New("ngalert schedule test"),
+		// a calendar without time intervals is a maintenance window that never ends
+		Calendars: map[string]schedule.Calendar{"maintenance": {}},
 	}
-}
+	sched := schedule.NewScheduler(schedCfg, nil)
+	require.NoError(t, sched.AttachCalendar(maintenanceDefinition.GetKey(), "maintenance"))
 
-func advanceClock(t *testing.T, mockedClock *clock.Mock) time.Time {
-	mockedClock.Add(time.Second)
-	return mockedClock.Now()
-	// t.Logf("Tick: %v", mockedClock.Now())
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	st := state.NewStateTracker(schedCfg.Logger)
+	go func() {
+		_ = sched.Ticker(ctx, st)
+	}()
+	runtime.Gosched()
+
+	t.Run("an alert definition without decision is not found", func(t *testing.T) {
+		_, ok := sched.LastDecision(models.AlertDefinitionKey{OrgID: 1, DefinitionUID: "unknown"})
+		assert.False(t, ok)
+	})
+
+	tick := advanceClock(t, mockedClock)
+	<-evalStarted
+	t.Run("on 1st tick the decisions report the skip reasons", func(t *testing.T) {
+		assertDecision(t, sched, pausedDefinition.GetKey(), tick, schedule.SkipReasonPaused)
+		assertDecision(t, sched, notDueDefinition.GetKey(), tick, schedule.SkipReasonNotDue)
+		assertDecision(t, sched, maintenanceDefinition.GetKey(), tick, schedule.SkipReasonMaintenance)
+		assertDecision(t, sched, slowDefinition.GetKey(), tick, "")
+	})
+
+	tick = advanceClock(t, mockedClock)
+	t.Run("on 2nd tick the running alert definition is skipped", func(t *testing.T) {
+		assertDecision(t, sched, slowDefinition.GetKey(), tick, schedule.SkipReasonOverlap)
+	})
+
+	close(releaseEval)
+	assertEvalRun(t, evalAppliedCh, tick.Add(-time.Second), slowDefinition.GetKey())
+
+	tick = advanceClock(t, mockedClock)
+	t.Run("on 3rd tick the alert definitions are evaluated", func(t *testing.T) {
+		assertDecision(t, sched, slowDefinition.GetKey(), tick, "")
+		assertDecision(t, sched, notDueDefinition.GetKey(), tick, "")
+		assertDecision(t, sched, pausedDefinition.GetKey(), tick, schedule.SkipReasonPaused)
+		assertDecision(t, sched, maintenanceDefinition.GetKey(), tick, schedule.SkipReasonMaintenance)
+	})
 }
 
-func concatenate(keys []models.AlertDefinitionKey) string {
-	s := make([]string, len(keys))
-	for _, k := range keys {
-		s = append(s, k.String())
-	}
-	return fmt.Sprintf("[%s]", strings.Join(s, ","))
+func TestSchedulerAdaptiveCadence(t *testing.T) {
+	dbstore := setupTestEnv(t, 1)
+	t.Cleanup(registry.ClearOverrides)
+
+	alertDefinition := createTestAlertDefinition(t, dbstore, 1)
+
+	var mtx sync.Mutex
+	resultState := eval.Normal
+	evaluator := &fakeEvaluator{evalFunc: func(c *models.Condition, now time.Time) (eval.Results, error) {
+		mtx.Lock()
+		defer mtx.Unlock()
+		return eval.Results{{Instance: data.Labels{"label": "value"}, State: resultState, EvaluatedAt: now}}, nil
+	}}
+
+	evalAppliedCh := make(chan evalAppliedInfo, 1)
+	mockedClock := clock.NewMock()
+	schedCfg := schedule.SchedulerCfg{
+		C:            mockedClock,
+		BaseInterval: time.Second,
+		EvalAppliedFunc: func(alertDefKey models.AlertDefinitionKey, now time.Time) {
+			evalAppliedCh <- evalAppliedInfo{alertDefKey: alertDefKey, now: now}
+		},
+		MaxAttempts:     1,
+		Evaluator:       evaluator,
+		Store:           dbstore,
+		Notifier:        &fakeNotifier{},
+		Logger:          log.New("ngalert schedule test"),
+		AdaptiveCadence: &schedule.AdaptiveCadence{StableEvaluations: 2, MaxIntervalMultiplier: 4},
+	}
+	sched := schedule.NewScheduler(schedCfg, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	st := state.NewStateTracker(schedCfg.Logger)
+	go func() {
+		_ = sched.Ticker(ctx, st)
+	}()
+	runtime.Gosched()
+
+	assertTick := func(t *testing.T, evaluated bool) {
+		t.Helper()
+		tick := advanceClock(t, mockedClock)
+		if evaluated {
+			assertDecision(t, sched, alertDefinition.GetKey(), tick, "")
+			assertEvalRun(t, evalAppliedCh, tick, alertDefinition.GetKey())
+			return
+		}
+		assertDecision(t, sched, alertDefinition.GetKey(), tick, schedule.SkipReasonNotDue)
+	}
+
+	t.Run("the interval widens while the results are stable", func(t *testing.T) {
+		// ticks 1-3 are evaluated; after the 3rd the interval is doubled
+		assertTick(t, true)
+		assertTick(t, true)
+		assertTick(t, true)
+		// ticks 4 and 6 are evaluated; after the 6th the interval is doubled again
+		assertTick(t, true)
+		assertTick(t, false)
+		assertTick(t, true)
+		// the interval is bounded to four times the alert definition interval
+		assertTick(t, false)
+	})
+
+	mtx.Lock()
+	resultState = eval.Alerting
+	mtx.Unlock()
+
+	t.Run("the interval tightens when the results change", func(t *testing.T) {
+		// tick 8 is due and evaluates the new results
+		assertTick(t, true)
+		assertTick(t, true)
+		assertTick(t, true)
+	})
+}
+
+func TestSchedulerSubscribersRequired(t *testing.T) {
+	dbstore := setupTestEnv(t, 1)
+	t.Cleanup(registry.ClearOverrides)
+
+	watchedDefinition := createTestAlertDefinition(t, dbstore, 1)
+	otherDefinition := createTestAlertDefinition(t, dbstore, 1)
+
+	presence := &fakeSubscriberPresence{}
+	mockedClock := clock.NewMock()
+	schedCfg := schedule.SchedulerCfg{
+		C:                  mockedClock,
+		BaseInterval:       time.Second,
+		Store:              dbstore,
+		Logger:             log.New("ngalert schedule test"),
+		SubscriberPresence: presence,
+	}
+	sched := schedule.NewScheduler(schedCfg, nil)
+	sched.SetSubscribersRequired(watchedDefinition.GetKey(), true)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go func() {
+		_ = sched.Ticker(ctx, state.NewStateTracker(schedCfg.Logger))
+	}()
+	runtime.Gosched()
+
+	t.Run("alert definition without subscribers is skipped", func(t *testing.T) {
+		tick := advanceClock(t, mockedClock)
+		assertDecision(t, sched, watchedDefinition.GetKey(), tick, schedule.SkipReasonNoSubscribers)
+		assertDecision(t, sched, otherDefinition.GetKey(), tick, "")
+	})
+
+	presence.set(true)
+	t.Run("alert definition is evaluated once a subscriber appears", func(t *testing.T) {
+		tick := advanceClock(t, mockedClock)
+		assertDecision(t, sched, watchedDefinition.GetKey(), tick, "")
+	})
+
+	presence.set(false)
+	t.Run("alert definition is skipped again once the subscribers are gone", func(t *testing.T) {
+		tick := advanceClock(t, mockedClock)
+		assertDecision(t, sched, watchedDefinition.GetKey(), tick, schedule.SkipReasonNoSubscribers)
+	})
+
+	sched.SetSubscribersRequired(watchedDefinition.GetKey(), false)
+	t.Run("alert definition that no longer requires subscribers is evaluated", func(t *testing.T) {
+		tick := advanceClock(t, mockedClock)
+		assertDecision(t, sched, watchedDefinition.GetKey(), tick, "")
+	})
+}
+
+func TestSchedulerOrgEnabled(t *testing.T) {
+	dbstore := setupTestEnv(t, 1)
+	t.Cleanup(registry.ClearOverrides)
+
+	enabledOrgDefinition := createTestAlertDefinitionForOrg(t, dbstore, 1, 1)
+	disabledOrgDefinition := createTestAlertDefinitionForOrg(t, dbstore, 1, 2)
+
+	evalAppliedCh := make(chan evalAppliedInfo, 2)
+	mockedClock := clock.NewMock()
+	schedCfg := schedule.SchedulerCfg{
+		C:            mockedClock,
+		BaseInterval: time.Second,
+		EvalAppliedFunc: func(alertDefKey models.AlertDefinitionKey, now time.Time) {
+			evalAppliedCh <- evalAppliedInfo{alertDefKey: alertDefKey, now: now}
+		},
+		Store:  dbstore,
+		Logger: log.New("ngalert schedule test"),
+		IsOrgEnabled: func(orgID int64) bool {
+			return orgID == 1
+		},
+	}
+	sched := schedule.NewScheduler(schedCfg, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go func() {
+		_ = sched.Ticker(ctx, state.NewStateTracker(schedCfg.Logger))
+	}()
+	runtime.Gosched()
+
+	for i := 1; i <= 2; i++ {
+		t.Run(fmt.Sprintf("on tick %d only the alert definitions of the enabled organisation are evaluated", i), func(t *testing.T) {
+			tick := advanceClock(t, mockedClock)
+			assertEvalRun(t, evalAppliedCh, tick, enabledOrgDefinition.GetKey())
+			assertDecision(t, sched, disabledOrgDefinition.GetKey(), tick, schedule.SkipReasonOrgDisabled)
+			select {
+			case info := <-evalAppliedCh:
+				t.Fatalf("alert definition %v of a disabled organisation was evaluated", info.alertDefKey)
+			default:
+			}
+		})
+	}
+}
+
+func TestSchedulerSetPaused(t *testing.T) {
+	dbstore := setupTestEnv(t, 1)
+	t.Cleanup(registry.ClearOverrides)
+
+	alertDefinition := createTestAlertDefinition(t, dbstore, 1)
+
+	evalAppliedCh := make(chan evalAppliedInfo, 1)
+	stopAppliedCh := make(chan models.AlertDefinitionKey, 1)
+	mockedClock := clock.NewMock()
+	schedCfg := schedule.SchedulerCfg{
+		C:            mockedClock,
+		BaseInterval: time.Second,
+		EvalAppliedFunc: func(alertDefKey models.AlertDefinitionKey, now time.Time) {
+			evalAppliedCh <- evalAppliedInfo{alertDefKey: alertDefKey, now: now}
+		},
+		StopAppliedFunc: func(alertDefKey models.AlertDefinitionKey) {
+			stopAppliedCh <- alertDefKey
+		},
+		Store:  dbstore,
+		Logger: log.New("ngalert schedule test"),
+	}
+	sched := schedule.NewScheduler(schedCfg, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go func() {
+		_ = sched.Ticker(ctx, state.NewStateTracker(schedCfg.Logger))
+	}()
+	runtime.Gosched()
+
+	t.Run("alert definitions are evaluated before pausing", func(t *testing.T) {
+		tick := advanceClock(t, mockedClock)
+		assertEvalRun(t, evalAppliedCh, tick, alertDefinition.GetKey())
+	})
+
+	sched.SetPaused(true)
+	t.Run("no alert definition is evaluated while paused", func(t *testing.T) {
+		assert.Equal(t, float64(1), testutil.ToFloat64(metrics.MNGAlertSchedulerPaused))
+		tick := advanceClock(t, mockedClock)
+		assertDecision(t, sched, alertDefinition.GetKey(), tick, schedule.SkipReasonSchedulerPaused)
+		assertNoEvalRun(t, evalAppliedCh)
+	})
+
+	// alert definitions are still reconciled while paused
+	err := dbstore.DeleteAlertDefinitionByUID(&models.DeleteAlertDefinitionByUIDCommand{UID: alertDefinition.UID, OrgID: alertDefinition.OrgID})
+	require.NoError(t, err)
+	newDefinition := createTestAlertDefinition(t, dbstore, 1)
+	t.Run("alert definitions are reconciled while paused", func(t *testing.T) {
+		tick := advanceClock(t, mockedClock)
+		assertStopRun(t, stopAppliedCh, alertDefinition.GetKey())
+		assertDecision(t, sched, newDefinition.GetKey(), tick, schedule.SkipReasonSchedulerPaused)
+	})
+
+	sched.SetPaused(false)
+	t.Run("normal ticking is restored after resuming", func(t *testing.T) {
+		assert.Equal(t, float64(0), testutil.ToFloat64(metrics.MNGAlertSchedulerPaused))
+		tick := advanceClock(t, mockedClock)
+		assertEvalRun(t, evalAppliedCh, tick, newDefinition.GetKey())
+	})
+}
+
+func TestSchedulerEvaluationHardTimeout(t *testing.T) {
+	dbstore := setupTestEnv(t, 1)
+	t.Cleanup(registry.ClearOverrides)
+
+	alertDefinition := createTestAlertDefinition(t, dbstore, 1)
+
+	evalStarted := make(chan struct{}, 1)
+	releaseEval := make(chan struct{})
+	t.Cleanup(func() { close(releaseEval) })
+	evaluator := &fakeEvaluator{evalFunc: func(c *models.Condition, now time.Time) (eval.Results, error) {
+		// the 1st evaluation is stuck and can't be cancelled
+		if now.Unix() == 1 {
+			evalStarted <- struct{}{}
+			<-releaseEval
+		}
+		return eval.Results{}, nil
+	}}
+
+	evalAppliedCh := make(chan evalAppliedInfo, 1)
+	mockedClock := clock.NewMock()
+	schedCfg := schedule.SchedulerCfg{
+		C:            mockedClock,
+		BaseInterval: time.Second,
+		EvalAppliedFunc: func(alertDefKey models.AlertDefinitionKey, now time.Time) {
+			evalAppliedCh <- evalAppliedInfo{alertDefKey: alertDefKey, now: now}
+		},
+		MaxAttempts:           1,
+		Evaluator:             evaluator,
+		Store:                 dbstore,
+		Notifier:              &fakeNotifier{},
+		Logger:                log.New("ngalert schedule test"),
+		EvaluationHardTimeout: 2500 * time.Millisecond,
+	}
+	sched := schedule.NewScheduler(schedCfg, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go func() {
+		_ = sched.Ticker(ctx, state.NewStateTracker(schedCfg.Logger))
+	}()
+	runtime.Gosched()
+
+	stuckTick := advanceClock(t, mockedClock)
+	<-evalStarted
+	t.Run("the alert definition is skipped while its evaluation is stuck", func(t *testing.T) {
+		for i := 0; i < 2; i++ {
+			tick := advanceClock(t, mockedClock)
+			assertDecision(t, sched, alertDefinition.GetKey(), tick, schedule.SkipReasonOverlap)
+		}
+		assertNoEvalRun(t, evalAppliedCh)
+	})
+
+	t.Run("the stuck evaluation is abandoned after the hard timeout", func(t *testing.T) {
+		mockedClock.Add(500 * time.Millisecond)
+		// the abandonment is signalled by the evaluation applied for the stuck tick,
+		// which is waited for before the clock is advanced again
+		assertEvalRun(t, evalAppliedCh, stuckTick, alertDefinition.GetKey())
+	})
+
+	t.Run("the alert definition is evaluated again", func(t *testing.T) {
+		mockedClock.Add(500 * time.Millisecond)
+		tick := mockedClock.Now()
+		assertDecision(t, sched, alertDefinition.GetKey(), tick, "")
+		assertEvalRun(t, evalAppliedCh, tick, alertDefinition.GetKey())
+	})
+}
+
+func TestSchedulerReconcileInterval(t *testing.T) {
+	dbstore := setupTestEnv(t, 1)
+	t.Cleanup(registry.ClearOverrides)
+
+	alertDefinition := createTestAlertDefinition(t, dbstore, 1)
+
+	evalAppliedCh := make(chan evalAppliedInfo, 2)
+	mockedClock := clock.NewMock()
+	schedCfg := schedule.SchedulerCfg{
+		C:            mockedClock,
+		BaseInterval: time.Second,
+		EvalAppliedFunc: func(alertDefKey models.AlertDefinitionKey, now time.Time) {
+			evalAppliedCh <- evalAppliedInfo{alertDefKey: alertDefKey, now: now}
+		},
+		Store:             dbstore,
+		Logger:            log.New("ngalert schedule test"),
+		ReconcileInterval: 3 * time.Second,
+	}
+	sched := schedule.NewScheduler(schedCfg, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go func() {
+		_ = sched.Ticker(ctx, state.NewStateTracker(schedCfg.Logger))
+	}()
+	runtime.Gosched()
+
+	t.Run("on 1st tick the alert definitions are reconciled", func(t *testing.T) {
+		tick := advanceClock(t, mockedClock)
+		assertEvalRun(t, evalAppliedCh, tick, alertDefinition.GetKey())
+	})
+
+	newDefinition := createTestAlertDefinition(t, dbstore, 1)
+	t.Run("the ticks before the reconcile interval don't fetch the new alert definition", func(t *testing.T) {
+		for i := 0; i < 2; i++ {
+			tick := advanceClock(t, mockedClock)
+			assertEvalRun(t, evalAppliedCh, tick, alertDefinition.GetKey())
+			_, ok := sched.LastDecision(newDefinition.GetKey())
+			assert.False(t, ok)
+		}
+	})
+
+	t.Run("after the reconcile interval the new alert definition is scheduled", func(t *testing.T) {
+		tick := advanceClock(t, mockedClock)
+		assertEvalRun(t, evalAppliedCh, tick, alertDefinition.GetKey(), newDefinition.GetKey())
+	})
+}
+
+func TestSchedulerOverrideInterval(t *testing.T) {
+	dbstore := setupTestEnv(t, 1)
+	t.Cleanup(registry.ClearOverrides)
+
+	alertDefinition := createTestAlertDefinition(t, dbstore, 3)
+
+	evalAppliedCh := make(chan evalAppliedInfo, 1)
+	mockedClock := clock.NewMock()
+	schedCfg := schedule.SchedulerCfg{
+		C:            mockedClock,
+		BaseInterval: time.Second,
+		EvalAppliedFunc: func(alertDefKey models.AlertDefinitionKey, now time.Time) {
+			evalAppliedCh <- evalAppliedInfo{alertDefKey: alertDefKey, now: now}
+		},
+		Store:  dbstore,
+		Logger: log.New("ngalert schedule test"),
+	}
+	sched := schedule.NewScheduler(schedCfg, nil)
+
+	t.Run("invalid intervals are rejected", func(t *testing.T) {
+		require.Error(t, sched.OverrideInterval(alertDefinition.GetKey(), 1500*time.Millisecond, time.Unix(10, 0)))
+		require.Error(t, sched.OverrideInterval(alertDefinition.GetKey(), -time.Second, time.Unix(10, 0)))
+	})
+
+	require.NoError(t, sched.OverrideInterval(alertDefinition.GetKey(), time.Second, time.Unix(2, int64(500*time.Millisecond))))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go func() {
+		_ = sched.Ticker(ctx, state.NewStateTracker(schedCfg.Logger))
+	}()
+	runtime.Gosched()
+
+	t.Run("the alert definition is evaluated on the overridden interval", func(t *testing.T) {
+		for i := 0; i < 2; i++ {
+			tick := advanceClock(t, mockedClock)
+			assertEvalRun(t, evalAppliedCh, tick, alertDefinition.GetKey())
+		}
+	})
+
+	t.Run("the alert definition interval reverts after the override expiry", func(t *testing.T) {
+		tick := advanceClock(t, mockedClock)
+		assertEvalRun(t, evalAppliedCh, tick, alertDefinition.GetKey())
+		for i := 0; i < 2; i++ {
+			tick := advanceClock(t, mockedClock)
+			assertDecision(t, sched, alertDefinition.GetKey(), tick, schedule.SkipReasonNotDue)
+		}
+		tick = advanceClock(t, mockedClock)
+		assertEvalRun(t, evalAppliedCh, tick, alertDefinition.GetKey())
+	})
+}
+
+func TestSchedulerIntervalPolicy(t *testing.T) {
+	dbstore := setupTestEnv(t, 1)
+	t.Cleanup(registry.ClearOverrides)
+
+	zeroIntervalDefinition := createTestAlertDefinition(t, dbstore, 0)
+
+	runScheduler := func(t *testing.T, policy schedule.IntervalPolicy) (schedule.ScheduleService, *clock.Mock, <-chan evalAppliedInfo) {
+		evalAppliedCh := make(chan evalAppliedInfo, 1)
+		mockedClock := clock.NewMock()
+		schedCfg := schedule.SchedulerCfg{
+			C:            mockedClock,
+			BaseInterval: time.Second,
+			EvalAppliedFunc: func(alertDefKey models.AlertDefinitionKey, now time.Time) {
+				evalAppliedCh <- evalAppliedInfo{alertDefKey: alertDefKey, now: now}
+			},
+			Store:          dbstore,
+			Logger:         log.New("ngalert schedule test"),
+			IntervalPolicy: policy,
+		}
+		sched := schedule.NewScheduler(schedCfg, nil)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		t.Cleanup(cancel)
+		go func() {
+			_ = sched.Ticker(ctx, state.NewStateTracker(schedCfg.Logger))
+		}()
+		runtime.Gosched()
+		return sched, mockedClock, evalAppliedCh
+	}
+
+	t.Run("with the ignore policy the alert definition is never due", func(t *testing.T) {
+		sched, mockedClock, evalAppliedCh := runScheduler(t, schedule.IntervalPolicyIgnore)
+		for i := 0; i < 3; i++ {
+			tick := advanceClock(t, mockedClock)
+			assertDecision(t, sched, zeroIntervalDefinition.GetKey(), tick, schedule.SkipReasonNotDue)
+		}
+		assertNoEvalRun(t, evalAppliedCh)
+	})
+
+	t.Run("with the reject policy the alert definition is rejected", func(t *testing.T) {
+		sched, mockedClock, evalAppliedCh := runScheduler(t, schedule.IntervalPolicyReject)
+		for i := 0; i < 3; i++ {
+			tick := advanceClock(t, mockedClock)
+			assertDecision(t, sched, zeroIntervalDefinition.GetKey(), tick, schedule.SkipReasonInvalidInterval)
+		}
+		assertNoEvalRun(t, evalAppliedCh)
+	})
+
+	t.Run("with the default policy the alert definition is evaluated every base interval", func(t *testing.T) {
+		sched, mockedClock, evalAppliedCh := runScheduler(t, schedule.IntervalPolicyDefault)
+		for i := 0; i < 3; i++ {
+			tick := advanceClock(t, mockedClock)
+			assertDecision(t, sched, zeroIntervalDefinition.GetKey(), tick, "")
+			assertEvalRun(t, evalAppliedCh, tick, zeroIntervalDefinition.GetKey())
+		}
+	})
+}
+
+func TestSchedulerSynchronousEval(t *testing.T) {
+	dbstore := setupTestEnv(t, 1)
+	t.Cleanup(registry.ClearOverrides)
+
+	var keys []models.AlertDefinitionKey
+	for i := 0; i < 5; i++ {
+		keys = append(keys, createTestAlertDefinitionForOrg(t, dbstore, 1, int64(2-i%2)).GetKey())
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].OrgID != keys[j].OrgID {
+			return keys[i].OrgID < keys[j].OrgID
+		}
+		return keys[i].DefinitionUID < keys[j].DefinitionUID
+	})
+
+	evalAppliedCh := make(chan evalAppliedInfo, len(keys))
+	stopAppliedCh := make(chan models.AlertDefinitionKey, 1)
+	mockedClock := clock.NewMock()
+	schedCfg := schedule.SchedulerCfg{
+		C:            mockedClock,
+		BaseInterval: time.Second,
+		EvalAppliedFunc: func(alertDefKey models.AlertDefinitionKey, now time.Time) {
+			evalAppliedCh <- evalAppliedInfo{alertDefKey: alertDefKey, now: now}
+		},
+		StopAppliedFunc: func(alertDefKey models.AlertDefinitionKey) {
+			stopAppliedCh <- alertDefKey
+		},
+		MaxAttempts:     1,
+		Evaluator:       &fakeEvaluator{evalFunc: func(*models.Condition, time.Time) (eval.Results, error) { return eval.Results{}, nil }},
+		Store:           dbstore,
+		Notifier:        &fakeNotifier{},
+		Logger:          log.New("ngalert schedule test"),
+		SynchronousEval: true,
+	}
+	sched := schedule.NewScheduler(schedCfg, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go func() {
+		_ = sched.Ticker(ctx, state.NewStateTracker(schedCfg.Logger))
+	}()
+	runtime.Gosched()
+
+	assertEvalOrder := func(t *testing.T, tick time.Time, expected []models.AlertDefinitionKey) {
+		t.Helper()
+		for _, key := range expected {
+			select {
+			case info := <-evalAppliedCh:
+				assert.Equal(t, key, info.alertDefKey)
+				assert.Equal(t, tick, info.now)
+			case <-time.After(time.Second):
+				t.Fatalf("alert definition %v was not evaluated", key)
+			}
+		}
+	}
+
+	t.Run("alert definitions are evaluated in key order on every tick", func(t *testing.T) {
+		for i := 0; i < 3; i++ {
+			tick := advanceClock(t, mockedClock)
+			assertEvalOrder(t, tick, keys)
+		}
+	})
+
+	t.Run("deleted alert definitions are unregistered", func(t *testing.T) {
+		err := dbstore.DeleteAlertDefinitionByUID(&models.DeleteAlertDefinitionByUIDCommand{UID: keys[0].DefinitionUID, OrgID: keys[0].OrgID})
+		require.NoError(t, err)
+		tick := advanceClock(t, mockedClock)
+		assertEvalOrder(t, tick, keys[1:])
+		assertStopRun(t, stopAppliedCh, keys[0])
+	})
+}
+
+func TestSchedulerErrorBackoff(t *testing.T) {
+	dbstore := setupTestEnv(t, 1)
+	t.Cleanup(registry.ClearOverrides)
+
+	alertDefinition := createTestAlertDefinition(t, dbstore, 1)
+	key := alertDefinition.GetKey()
+
+	var failing int32 = 1
+	evaluator := &fakeEvaluator{evalFunc: func(*models.Condition, time.Time) (eval.Results, error) {
+		if atomic.LoadInt32(&failing) == 1 {
+			return nil, errors.New("evaluation failed")
+		}
+		return eval.Results{}, nil
+	}}
+
+	type backoffInfo struct {
+		key   models.AlertDefinitionKey
+		until time.Time
+	}
+	evalAppliedCh := make(chan evalAppliedInfo, 1)
+	backoffAppliedCh := make(chan backoffInfo, 1)
+	backoffClearedCh := make(chan models.AlertDefinitionKey, 1)
+	mockedClock := clock.NewMock()
+	schedCfg := schedule.SchedulerCfg{
+		C:            mockedClock,
+		BaseInterval: time.Second,
+		EvalAppliedFunc: func(alertDefKey models.AlertDefinitionKey, now time.Time) {
+			evalAppliedCh <- evalAppliedInfo{alertDefKey: alertDefKey, now: now}
+		},
+		MaxAttempts:  1,
+		Evaluator:    evaluator,
+		Store:        dbstore,
+		Notifier:     &fakeNotifier{},
+		Logger:       log.New("ngalert schedule test"),
+		ErrorBackoff: &schedule.ErrorBackoff{Initial: 2 * time.Second, Max: 3 * time.Second},
+		BackoffAppliedFunc: func(alertDefKey models.AlertDefinitionKey, until time.Time) {
+			backoffAppliedCh <- backoffInfo{key: alertDefKey, until: until}
+		},
+		BackoffClearedFunc: func(alertDefKey models.AlertDefinitionKey) {
+			backoffClearedCh <- alertDefKey
+		},
+	}
+	sched := schedule.NewScheduler(schedCfg, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go func() {
+		_ = sched.Ticker(ctx, state.NewStateTracker(schedCfg.Logger))
+	}()
+	runtime.Gosched()
+
+	assertBackoffApplied := func(t *testing.T, until time.Time) {
+		t.Helper()
+		select {
+		case info := <-backoffAppliedCh:
+			assert.Equal(t, key, info.key)
+			assert.Equal(t, until, info.until)
+		case <-time.After(time.Second):
+			t.Fatal("backoff was not applied")
+		}
+	}
+
+	t.Run("a failing alert definition enters the backoff", func(t *testing.T) {
+		tick := advanceClock(t, mockedClock)
+		assertEvalRun(t, evalAppliedCh, tick, key)
+		assertBackoffApplied(t, tick.Add(2*time.Second))
+
+		tick = advanceClock(t, mockedClock)
+		assertDecision(t, sched, key, tick, schedule.SkipReasonBackoff)
+	})
+
+	t.Run("the backoff grows on consecutive failures up to the max", func(t *testing.T) {
+		tick := advanceClock(t, mockedClock)
+		assertEvalRun(t, evalAppliedCh, tick, key)
+		assertBackoffApplied(t, tick.Add(3*time.Second))
+		for i := 0; i < 2; i++ {
+			tick := advanceClock(t, mockedClock)
+			assertDecision(t, sched, key, tick, schedule.SkipReasonBackoff)
+		}
+	})
+
+	t.Run("the backoff is cleared on recovery", func(t *testing.T) {
+		atomic.StoreInt32(&failing, 0)
+		tick := advanceClock(t, mockedClock)
+		assertEvalRun(t, evalAppliedCh, tick, key)
+		select {
+		case clearedKey := <-backoffClearedCh:
+			assert.Equal(t, key, clearedKey)
+		case <-time.After(time.Second):
+			t.Fatal("backoff was not cleared")
+		}
+
+		tick = advanceClock(t, mockedClock)
+		assertDecision(t, sched, key, tick, "")
+		assertEvalRun(t, evalAppliedCh, tick, key)
+	})
+}
+
+// assertNoEvalRun asserts that no alert definition evaluation is reported for a while.
+func assertNoEvalRun(t *testing.T, ch <-chan evalAppliedInfo) {
+	t.Helper()
+	select {
+	case info := <-ch:
+		t.Fatalf("alert definition %v was unexpectedly evaluated at %v", info.alertDefKey, info.now)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+type fakeSubscriberPresence struct {
+	mtx     sync.Mutex
+	present bool
+}
+
+func (p *fakeSubscriberPresence) set(present bool) {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	p.present = present
+}
+
+func (p *fakeSubscriberPresence) HasSubscribers(_ models.AlertDefinitionKey) bool {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	return p.present
+}
+
+func assertDecision(t *testing.T, sched schedule.ScheduleService, key models.AlertDefinitionKey, tick time.Time, reason schedule.SkipReason) {
+	t.Helper()
+	require.Eventually(t, func() bool {
+		decision, ok := sched.LastDecision(key)
+		return ok && decision.Tick.Equal(tick)
+	}, time.Second, 10*time.Millisecond)
+
+	decision, _ := sched.LastDecision(key)
+	assert.Equal(t, reason == "", decision.Evaluated)
+	assert.Equal(t, reason, decision.Reason)
+}
+
+func TestSchedulerIsolateEvaluations(t *testing.T) {
+	dbstore := setupTestEnv(t, 1)
+	t.Cleanup(registry.ClearOverrides)
+
+	alertDefinition := createTestAlertDefinition(t, dbstore, 1)
+	key := alertDefinition.GetKey()
+
+	var panicking int32 = 1
+	evaluator := &fakeEvaluator{evalFunc: func(*models.Condition, time.Time) (eval.Results, error) {
+		if atomic.LoadInt32(&panicking) == 1 {
+			panic("bad plugin")
+		}
+		return eval.Results{{Instance: data.Labels{}, State: eval.Normal}}, nil
+	}}
+
+	panics := testutil.ToFloat64(metrics.MNGAlertEvaluationPanics)
+	h := schedtest.New(t, schedule.SchedulerCfg{
+		MaxAttempts:        1,
+		Evaluator:          evaluator,
+		Store:              dbstore,
+		Notifier:           &fakeNotifier{},
+		Logger:             log.New("ngalert schedule test"),
+		IsolateEvaluations: true,
+	})
+	cacheID := state.CacheID(key.DefinitionUID, data.Labels{})
+
+	h.AdvanceAndExpect(key)
+	assert.Equal(t, eval.Error, h.StateTracker.Get(key.OrgID, cacheID).State)
+	assert.Equal(t, panics+1, testutil.ToFloat64(metrics.MNGAlertEvaluationPanics))
+
+	// the scheduler survives the panic and keeps evaluating the alert definition
+	h.AdvanceAndExpect(key)
+	assert.Equal(t, panics+2, testutil.ToFloat64(metrics.MNGAlertEvaluationPanics))
+	assert.Equal(t, 0.0, h.StateTracker.HealthRatio(key.OrgID, key.DefinitionUID))
+
+	atomic.StoreInt32(&panicking, 0)
+	h.AdvanceAndExpect(key)
+	assert.Equal(t, panics+2, testutil.ToFloat64(metrics.MNGAlertEvaluationPanics))
+}
+
+func TestSchedulerMissingDatasourcePolicy(t *testing.T) {
+	testCases := []struct {
+		desc   string
+		policy schedule.MissingDatasourcePolicy
+		// degraded is whether an error result is recorded while the datasource is missing
+		degraded bool
+	}{
+		{desc: "pause", policy: schedule.MissingDatasourcePause},
+		{desc: "degrade", policy: schedule.MissingDatasourceDegrade, degraded: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			dbstore := setupTestEnv(t, 1)
+			t.Cleanup(registry.ClearOverrides)
+
+			var intervalSeconds int64 = 1
+			cmd := models.SaveAlertDefinitionCommand{
+				OrgID:     1,
+				Title:     "an alert definition with a datasource",
+				Condition: "A",
+				Data: []models.AlertQuery{
+					{
+						Model:             json.RawMessage(`{"datasource": "test datasource", "datasourceUid": "ds_uid"}`),
+						RelativeTimeRange: models.RelativeTimeRange{From: models.Duration(5 * time.Hour), To: models.Duration(3 * time.Hour)},
+						RefID:             "A",
+					},
+				},
+				IntervalSeconds: &intervalSeconds,
+			}
+			require.NoError(t, dbstore.SaveAlertDefinition(&cmd))
+			key := cmd.Result.GetKey()
+
+			datasourceCache := &fakeDatasourceCache{uids: map[string]struct{}{"ds_uid": {}}}
+			evaluator := &fakeEvaluator{evalFunc: func(*models.Condition, time.Time) (eval.Results, error) {
+				return eval.Results{{Instance: data.Labels{}, State: eval.Normal}}, nil
+			}}
+			h := schedtest.New(t, schedule.SchedulerCfg{
+				MaxAttempts:             1,
+				Evaluator:               evaluator,
+				Store:                   dbstore,
+				Notifier:                &fakeNotifier{},
+				Logger:                  log.New("ngalert schedule test"),
+				DatasourceCache:         datasourceCache,
+				MissingDatasourcePolicy: tc.policy,
+			})
+			cacheID := state.CacheID(key.DefinitionUID, data.Labels{})
+
+			h.AdvanceAndExpect(key)
+			assert.Equal(t, eval.Normal, h.StateTracker.Get(key.OrgID, cacheID).State)
+
+			// the routine of the alert definition skips the evaluation
+			datasourceCache.set("ds_uid", false)
+			tick := h.AdvanceAndExpect(key)
+			assertDecision(t, h.Scheduler, key, tick, schedule.SkipReasonDatasourceMissing)
+			if tc.degraded {
+				// the error result counts against the health of the alert definition
+				assert.Equal(t, 0.5, h.StateTracker.HealthRatio(key.OrgID, key.DefinitionUID))
+			} else {
+				assert.Equal(t, 1.0, h.StateTracker.HealthRatio(key.OrgID, key.DefinitionUID))
+			}
+
+			// re-adding the datasource re-enables the alert definition
+			datasourceCache.set("ds_uid", true)
+			h.AdvanceAndExpect(key)
+		})
+	}
+}
+
+func TestSchedulerEvaluateAsOf(t *testing.T) {
+	dbstore := setupTestEnv(t, 1)
+	t.Cleanup(registry.ClearOverrides)
+
+	alertDefinition := createTestAlertDefinition(t, dbstore, 1)
+	key := alertDefinition.GetKey()
+
+	incidentStart, _ := time.Parse("2006-01-02", "2021-03-25")
+	// the condition breaches from the start of the incident
+	evaluator := &fakeEvaluator{evalFunc: func(_ *models.Condition, now time.Time) (eval.Results, error) {
+		state := eval.Normal
+		if !now.Before(incidentStart) {
+			state = eval.Alerting
+		}
+		return eval.Results{{Instance: data.Labels{"label": "value"}, State: state, EvaluatedAt: now}}, nil
+	}}
+	schedCfg := schedule.SchedulerCfg{
+		C:            clock.NewMock(),
+		BaseInterval: time.Second,
+		MaxAttempts:  1,
+		Evaluator:    evaluator,
+		Store:        dbstore,
+		Notifier:     &fakeNotifier{},
+		Logger:       log.New("ngalert schedule test"),
+	}
+	sched := schedule.NewScheduler(schedCfg, nil)
+	st := state.NewStateTracker(schedCfg.Logger)
+
+	t.Run("the alert definition is normal before the incident", func(t *testing.T) {
+		at := incidentStart.Add(-time.Minute)
+		results, states, err := sched.EvaluateAsOf(key, at)
+		require.NoError(t, err)
+		require.Len(t, results, 1)
+		assert.Equal(t, eval.Normal, results[0].State)
+		assert.Equal(t, at, results[0].EvaluatedAt)
+		require.Len(t, states, 1)
+		assert.Equal(t, eval.Normal, states[0].State)
+	})
+
+	t.Run("the alert definition fires from the start of the incident", func(t *testing.T) {
+		at := incidentStart.Add(time.Minute)
+		results, states, err := sched.EvaluateAsOf(key, at)
+		require.NoError(t, err)
+		require.Len(t, results, 1)
+		assert.Equal(t, eval.Alerting, results[0].State)
+		require.Len(t, states, 1)
+		assert.Equal(t, eval.Alerting, states[0].State)
+		assert.Equal(t, at, states[0].LastEvaluationTime)
+	})
+
+	t.Run("the live states are untouched", func(t *testing.T) {
+		assert.Empty(t, st.GetAll())
+	})
+
+	t.Run("evaluating an unknown alert definition fails", func(t *testing.T) {
+		_, _, err := sched.EvaluateAsOf(models.AlertDefinitionKey{OrgID: 1, DefinitionUID: "unknown"}, incidentStart)
+		require.Error(t, err)
+	})
+}
+
+func TestSchedulerTickSkew(t *testing.T) {
+	dbstore := setupTestEnv(t, 1)
+	t.Cleanup(registry.ClearOverrides)
+
+	type skewInfo struct {
+		tick time.Time
+		skew time.Duration
+	}
+	skewCh := make(chan skewInfo)
+	// the ticks are handled one at a time, when the test lets them go
+	proceed := make(chan struct{})
+	mockedClock := clock.NewMock()
+	schedCfg := schedule.SchedulerCfg{
+		C:            mockedClock,
+		BaseInterval: time.Second,
+		Store:        dbstore,
+		Logger:       log.New("ngalert schedule test"),
+		TickSkewFunc: func(tick time.Time, skew time.Duration) {
+			skewCh <- skewInfo{tick: tick, skew: skew}
+			<-proceed
+		},
+	}
+	sched := schedule.NewScheduler(schedCfg, nil)
+
+	sampleCount := func() uint64 {
+		m := &dto.Metric{}
+		require.NoError(t, metrics.MNGAlertTickSkew.Write(m))
+		return m.GetHistogram().GetSampleCount()
+	}
+	samples := sampleCount()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go func() {
+		_ = sched.Ticker(ctx, state.NewStateTracker(schedCfg.Logger))
+	}()
+	runtime.Gosched()
+
+	assertSkew := func(tick time.Time, skew time.Duration) {
+		t.Helper()
+		select {
+		case info := <-skewCh:
+			assert.Equal(t, tick, info.tick)
+			assert.Equal(t, skew, info.skew)
+		case <-time.After(time.Second):
+			t.Fatal("tick skew was not reported")
+		}
+	}
+
+	start := mockedClock.Now()
+	mockedClock.Add(time.Second)
+	assertSkew(start.Add(time.Second), 0)
+
+	// the scheduler lags: the clock moves two more ticks while it's still handling the first one
+	mockedClock.Add(2 * time.Second)
+	proceed <- struct{}{}
+	assertSkew(start.Add(2*time.Second), time.Second)
+	proceed <- struct{}{}
+	assertSkew(start.Add(3*time.Second), 0)
+	proceed <- struct{}{}
+
+	assert.Equal(t, samples+3, sampleCount())
+}
+
+func TestSchedulerCalendars(t *testing.T) {
+	dbstore := setupTestEnv(t, 1)
+	t.Cleanup(registry.ClearOverrides)
+
+	alertDefinition := createTestAlertDefinition(t, dbstore, 1)
+	key := alertDefinition.GetKey()
+
+	businessHours := schedule.Calendar{
+		Intervals: []schedule.TimeInterval{{
+			Weekdays: []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday},
+			Start:    9 * time.Hour,
+			End:      17 * time.Hour,
+		}},
+	}
+
+	t.Run("calendars contain their time intervals", func(t *testing.T) {
+		thursday := time.Date(2021, time.March, 25, 0, 0, 0, 0, time.UTC)
+		assert.False(t, businessHours.Contains(thursday.Add(9*time.Hour-time.Second)))
+		assert.True(t, businessHours.Contains(thursday.Add(9*time.Hour)))
+		assert.True(t, businessHours.Contains(thursday.Add(17*time.Hour-time.Second)))
+		assert.False(t, businessHours.Contains(thursday.Add(17*time.Hour)))
+		assert.False(t, businessHours.Contains(thursday.AddDate(0, 0, 2).Add(10*time.Hour)), "saturdays are excluded")
+
+		cet := time.FixedZone("CET", 60*60)
+		assert.True(t, schedule.Calendar{Intervals: businessHours.Intervals, Location: cet}.Contains(thursday.Add(16*time.Hour-time.Second)))
+		assert.False(t, schedule.Calendar{Intervals: businessHours.Intervals, Location: cet}.Contains(thursday.Add(16*time.Hour)))
+	})
+
+	evalAppliedCh := make(chan evalAppliedInfo, 1)
+	mockedClock := clock.NewMock()
+	// a thursday, just before the end of the business hours
+	mockedClock.Set(time.Date(2021, time.March, 25, 16, 59, 58, 0, time.UTC))
+	schedCfg := schedule.SchedulerCfg{
+		C:            mockedClock,
+		BaseInterval: time.Second,
+		EvalAppliedFunc: func(alertDefKey models.AlertDefinitionKey, now time.Time) {
+			evalAppliedCh <- evalAppliedInfo{alertDefKey: alertDefKey, now: now}
+		},
+		Store:     dbstore,
+		Logger:    log.New("ngalert schedule test"),
+		Calendars: map[string]schedule.Calendar{"business-hours": businessHours},
+	}
+	sched := schedule.NewScheduler(schedCfg, nil)
+	require.Error(t, sched.AttachCalendar(key, "unknown"))
+	require.NoError(t, sched.AttachCalendar(key, "business-hours"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go func() {
+		_ = sched.Ticker(ctx, state.NewStateTracker(schedCfg.Logger))
+	}()
+	runtime.Gosched()
+
+	t.Run("alert definitions are evaluated within their calendar", func(t *testing.T) {
+		tick := advanceClock(t, mockedClock)
+		assertEvalRun(t, evalAppliedCh, tick, key)
+	})
+
+	t.Run("alert definitions are not evaluated outside of their calendar", func(t *testing.T) {
+		tick := advanceClock(t, mockedClock)
+		assertEvalRun(t, evalAppliedCh, tick)
+		assertDecision(t, sched, key, tick, schedule.SkipReasonMaintenance)
+	})
+
+	t.Run("detached alert definitions are evaluated again", func(t *testing.T) {
+		require.NoError(t, sched.AttachCalendar(key, ""))
+		tick := advanceClock(t, mockedClock)
+		assertEvalRun(t, evalAppliedCh, tick, key)
+	})
+}
+
+type fakeEvaluator struct {
+	evalFunc func(*models.Condition, time.Time) (eval.Results, error)
+}
+
+func (e *fakeEvaluator) ConditionEval(_ context.Context, condition *models.Condition, now time.Time, _ *tsdb.Service) (eval.Results, error) {
+	return e.evalFunc(condition, now)
+}
+
+// contextEvaluator is an evaluator whose evaluations get their context.
+type contextEvaluator struct {
+	evalFunc func(context.Context, *models.Condition, time.Time) (eval.Results, error)
+}
+
+func (e *contextEvaluator) ConditionEval(ctx context.Context, condition *models.Condition, now time.Time, _ *tsdb.Service) (eval.Results, error) {
+	return e.evalFunc(ctx, condition, now)
+}
+
+type fakeDatasourceCache struct {
+	mu   sync.Mutex
+	uids map[string]struct{}
+}
+
+func (c *fakeDatasourceCache) set(uid string, exists bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if exists {
+		c.uids[uid] = struct{}{}
+	} else {
+		delete(c.uids, uid)
+	}
+}
+
+func (c *fakeDatasourceCache) GetDatasource(_ int64, _ *apimodels.SignedInUser, _ bool) (*apimodels.DataSource, error) {
+	return nil, apimodels.ErrDataSourceNotFound
+}
+
+func (c *fakeDatasourceCache) GetDatasourceByUID(uid string, user *apimodels.SignedInUser, _ bool) (*apimodels.DataSource, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.uids[uid]; !ok {
+		return nil, apimodels.ErrDataSourceNotFound
+	}
+	return &apimodels.DataSource{Uid: uid, OrgId: user.OrgId}, nil
+}
+
+type fakeLivePublisher struct {
+	mu        sync.Mutex
+	published map[string][]json.RawMessage
+}
+
+func (p *fakeLivePublisher) Publish(channel string, data []byte) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.published[channel] = append(p.published[channel], data)
+	return nil
+}
+
+func (p *fakeLivePublisher) get(channel string) []json.RawMessage {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.published[channel]
+}
+
+type fakeSeriesWriter struct {
+	mu      sync.Mutex
+	err     error
+	written map[string][]schedule.Sample
+}
+
+func (w *fakeSeriesWriter) WriteSeries(_ context.Context, orgID int64, datasourceUID string, samples []schedule.Sample) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.err != nil {
+		return w.err
+	}
+	key := fmt.Sprintf("%d/%s", orgID, datasourceUID)
+	w.written[key] = append(w.written[key], samples...)
+	return nil
+}
+
+func (w *fakeSeriesWriter) setErr(err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.err = err
+}
+
+func (w *fakeSeriesWriter) get(orgID int64, datasourceUID string) []schedule.Sample {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.written[fmt.Sprintf("%d/%s", orgID, datasourceUID)]
+}
+
+func TestFromAlertStateToPostableAlerts(t *testing.T) {
+	labels := data.Labels{"team": "a"}
+	alerts := schedule.FromAlertStateToPostableAlerts([]state.AlertState{
+		{UID: "uid-1", Labels: labels, State: eval.Alerting},
+		{UID: "uid-1", Labels: data.Labels{"team": "b"}, State: eval.Normal},
+	})
+
+	require.Len(t, alerts, 1)
+	require.Equal(t, "a", alerts[0].Labels["team"])
+	require.Equal(t, "uid-1", alerts[0].Labels[schedule.AlertRuleUIDLabel])
+	// the labels of the alert states are left as they are
+	require.Equal(t, data.Labels{"team": "a"}, labels)
+}
+
+type fakeNotifier struct{}
+
+func (n *fakeNotifier) PutAlerts(_ ...*notifier.PostableAlert) error {
+	return nil
+}
+
+// recordingNotifier records the alerts put in the notifier.
+type recordingNotifier struct {
+	mu     sync.Mutex
+	alerts []*notifier.PostableAlert
+}
+
+func (n *recordingNotifier) PutAlerts(alerts ...*notifier.PostableAlert) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.alerts = append(n.alerts, alerts...)
+	return nil
+}
+
+func (n *recordingNotifier) recorded() []*notifier.PostableAlert {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return append([]*notifier.PostableAlert{}, n.alerts...)
+}
+
+func assertEvalRun(t *testing.T, ch <-chan evalAppliedInfo, tick time.Time, keys ...models.AlertDefinitionKey) {
+	timeout := time.After(time.Second)
+
+	expected := make(map[models.AlertDefinitionKey]struct{}, len(keys))
+	for _, k := range keys {
+		expected[k] = struct{}{}
+	}
+
+	for {
+		select {
+		case info := <-ch:
+			_, ok := expected[info.alertDefKey]
+			t.Logf("alert definition: %v evaluated at: %v", info.alertDefKey, info.now)
+			assert.True(t, ok)
+			assert.Equal(t, tick, info.now)
+			delete(expected, info.alertDefKey)
+			if len(expected) == 0 {
+				return
+			}
+		case <-timeout:
+			if len(expected) == 0 {
+				return
+			}
+			t.Fatal("cycle has expired")
+		}
+	}
+}
+
+func assertStopRun(t *testing.T, ch <-chan models.AlertDefinitionKey, keys ...models.AlertDefinitionKey) {
+	timeout := time.After(time.Second)
+
+	expected := make(map[models.AlertDefinitionKey]struct{}, len(keys))
+	for _, k := range keys {
+		expected[k] = struct{}{}
+	}
+
+	for {
+		select {
+		case alertDefKey := <-ch:
+			_, ok := expected[alertDefKey]
+			t.Logf("alert definition: %v stopped", alertDefKey)
+			assert.True(t, ok)
+			delete(expected, alertDefKey)
+			if len(expected) == 0 {
+				return
+			}
+		case <-timeout:
+			if len(expected) == 0 {
+				return
+			}
+			t.Fatal("cycle has expired")
+		}
+	}
+}
+
+func advanceClock(t *testing.T, mockedClock *clock.Mock) time.Time {
+	mockedClock.Add(time.Second)
+	return mockedClock.Now()
+	// t.Logf("Tick: %v", mockedClock.Now())
+}
+
+func concatenate(keys []models.AlertDefinitionKey) string {
+	s := make([]string, len(keys))
+	for _, k := range keys {
+		s = append(s, k.String())
+	}
+	return fmt.Sprintf("[%s]", strings.Join(s, ","))
+}
+
+func TestSchedulerLatencyStats(t *testing.T) {
+	dbstore := setupTestEnv(t, 1)
+	t.Cleanup(registry.ClearOverrides)
+
+	alertDefinition := createTestAlertDefinition(t, dbstore, 1)
+	key := alertDefinition.GetKey()
+
+	// the evaluations of the first ticks take varied durations, the next ones are immediate
+	durations := map[int64]time.Duration{1: 10 * time.Millisecond, 2: 100 * time.Millisecond, 3: 50 * time.Millisecond}
+	evaluator := &fakeEvaluator{evalFunc: func(c *models.Condition, now time.Time) (eval.Results, error) {
+		time.Sleep(durations[now.Unix()])
+		return eval.Results{{Instance: data.Labels{}, State: eval.Normal, EvaluatedAt: now}}, nil
+	}}
+
+	h := schedtest.New(t, schedule.SchedulerCfg{
+		MaxAttempts:  1,
+		Evaluator:    evaluator,
+		Store:        dbstore,
+		Notifier:     &fakeNotifier{},
+		Logger:       log.New("ngalert schedule test"),
+		LatencyStats: &schedule.LatencyStats{Window: 3, FlushInterval: 3 * time.Second},
+	})
+	getLatency := func() *models.AlertDefinitionLatency {
+		q := models.GetAlertDefinitionLatencyQuery{DefinitionOrgID: key.OrgID, DefinitionUID: key.DefinitionUID}
+		require.NoError(t, dbstore.GetAlertDefinitionLatency(&q))
+		return q.Result
+	}
+
+	// the stats are flushed on the 1st tick, before any evaluation, then every 3 ticks
+	for i := 0; i < 3; i++ {
+		h.AdvanceAndExpect(key)
+	}
+	err := dbstore.GetAlertDefinitionLatency(&models.GetAlertDefinitionLatencyQuery{DefinitionOrgID: key.OrgID, DefinitionUID: key.DefinitionUID})
+	require.ErrorIs(t, err, models.ErrAlertDefinitionLatencyNotFound)
+
+	t.Run("the persisted stats reflect the evaluation durations", func(t *testing.T) {
+		tick := h.AdvanceAndExpect(key)
+		latency := getLatency()
+		assert.Equal(t, 3, latency.Samples)
+		assert.Equal(t, tick.Unix(), latency.Updated.Unix())
+		assert.GreaterOrEqual(t, int64(latency.P50), int64(50*time.Millisecond))
+		assert.Less(t, int64(latency.P50), int64(100*time.Millisecond))
+		assert.GreaterOrEqual(t, int64(latency.Max), int64(100*time.Millisecond))
+		assert.Equal(t, latency.Max, latency.P95)
+	})
+
+	t.Run("the stats are computed over the recent evaluations", func(t *testing.T) {
+		h.AdvanceAndExpect(key)
+		h.AdvanceAndExpect(key)
+		h.AdvanceAndExpect(key)
+		latency := getLatency()
+		assert.Equal(t, 3, latency.Samples)
+		assert.Less(t, int64(latency.Max), int64(10*time.Millisecond))
+	})
+}
+
+func TestSchedulerDeadMansSwitch(t *testing.T) {
+	dbstore := setupTestEnv(t, 1)
+	t.Cleanup(registry.ClearOverrides)
+
+	alertDefinition := createTestAlertDefinition(t, dbstore, 1)
+	key := alertDefinition.GetKey()
+
+	// the evaluations get stuck from the 3rd tick
+	releaseEval := make(chan struct{})
+	t.Cleanup(func() { close(releaseEval) })
+	evaluator := &fakeEvaluator{evalFunc: func(c *models.Condition, now time.Time) (eval.Results, error) {
+		if now.Unix() >= 3 {
+			<-releaseEval
+		}
+		return eval.Results{{Instance: data.Labels{}, State: eval.Normal, EvaluatedAt: now}}, nil
+	}}
+
+	h := schedtest.New(t, schedule.SchedulerCfg{
+		MaxAttempts: 1,
+		Evaluator:   evaluator,
+		Store:       dbstore,
+		Notifier:    &fakeNotifier{},
+		Logger:      log.New("ngalert schedule test"),
+	})
+	h.StateTracker.SetDeadMansSwitch(state.DeadMansSwitch{IntervalMultiple: 2})
+	switchId := state.CacheID(key.DefinitionUID, data.Labels{state.DeadMansSwitchLabel: key.DefinitionUID})
+
+	h.AdvanceAndExpect(key)
+	h.AdvanceAndExpect(key)
+	h.Advance()
+	h.Advance()
+	// the evaluations stopped after the 2nd tick: the switch is within the threshold on the 4th one
+	assert.Never(t, func() bool {
+		return h.StateTracker.Get(key.OrgID, switchId).State == eval.Alerting
+	}, 100*time.Millisecond, 10*time.Millisecond)
+
+	h.Advance()
+	require.Eventually(t, func() bool {
+		return h.StateTracker.Get(key.OrgID, switchId).State == eval.Alerting
+	}, time.Second, 10*time.Millisecond)
+
+	t.Run("the switch is persisted and restored", func(t *testing.T) {
+		q := models.ListAlertInstancesQuery{DefinitionOrgID: key.OrgID, DefinitionUID: key.DefinitionUID, State: models.InstanceStateFiring}
+		require.NoError(t, dbstore.ListAlertInstances(&q))
+		require.Len(t, q.Result, 1)
+		assert.Equal(t, key.DefinitionUID, q.Result[0].Labels[state.DeadMansSwitchLabel])
+
+		restored := state.NewStateTracker(log.New("ngalert schedule test"))
+		h.Scheduler.WarmStateCache(restored)
+		assert.Equal(t, eval.Alerting, restored.Get(key.OrgID, switchId).State)
+	})
+}
+
+func TestSchedulerLivePublishing(t *testing.T) {
+	dbstore := setupTestEnv(t, 1)
+	t.Cleanup(registry.ClearOverrides)
+
+	published := createTestAlertDefinition(t, dbstore, 1)
+	unpublished := createTestAlertDefinition(t, dbstore, 1)
+
+	// the alert definitions fire on the 2nd tick and resolve on the 3rd one
+	evaluator := &fakeEvaluator{evalFunc: func(c *models.Condition, now time.Time) (eval.Results, error) {
+		resultState := eval.Normal
+		if now.Unix() == 2 {
+			resultState = eval.Alerting
+		}
+		return eval.Results{{Instance: data.Labels{"label": "value"}, State: resultState, EvaluatedAt: now}}, nil
+	}}
+
+	publisher := &fakeLivePublisher{published: make(map[string][]json.RawMessage)}
+	h := schedtest.New(t, schedule.SchedulerCfg{
+		MaxAttempts:   1,
+		Evaluator:     evaluator,
+		Store:         dbstore,
+		Notifier:      &fakeNotifier{},
+		Logger:        log.New("ngalert schedule test"),
+		LivePublisher: publisher,
+	})
+	h.Scheduler.SetLivePublishing(published.GetKey(), true)
+
+	h.AdvanceAndExpect(published.GetKey(), unpublished.GetKey())
+	assert.Empty(t, publisher.get(schedule.LiveChannel(published.GetKey())), "the new Normal alert instance doesn't transition")
+
+	firing := h.AdvanceAndExpect(published.GetKey(), unpublished.GetKey())
+	resolved := h.AdvanceAndExpect(published.GetKey(), unpublished.GetKey())
+
+	type frame struct {
+		UID     string      `json:"uid"`
+		CacheId string      `json:"cacheId"`
+		Labels  data.Labels `json:"labels"`
+		From    string      `json:"from"`
+		To      string      `json:"to"`
+		At      time.Time   `json:"at"`
+	}
+	cacheId := state.CacheID(published.UID, data.Labels{"label": "value"})
+	expected := []frame{
+		{UID: published.UID, CacheId: cacheId, Labels: data.Labels{"label": "value"}, From: "Normal", To: "Alerting", At: time.Unix(firing.Unix(), 0)},
+		{UID: published.UID, CacheId: cacheId, Labels: data.Labels{"label": "value"}, From: "Alerting", To: "Normal", At: time.Unix(resolved.Unix(), 0)},
+	}
+	var frames []frame
+	for _, raw := range publisher.get(schedule.LiveChannel(published.GetKey())) {
+		var f frame
+		require.NoError(t, json.Unmarshal(raw, &f))
+		f.At = time.Unix(f.At.Unix(), 0)
+		frames = append(frames, f)
+	}
+	assert.Equal(t, expected, frames)
+	assert.Equal(t, fmt.Sprintf("grafana/alerting/%d/%s", published.OrgID, published.UID), schedule.LiveChannel(published.GetKey()))
+
+	publisher.mu.Lock()
+	assert.Len(t, publisher.published, 1, "only the alert definitions set to publish do")
+	publisher.mu.Unlock()
+
+	t.Run("the alert definitions stop publishing when unset", func(t *testing.T) {
+		h.Scheduler.SetLivePublishing(published.GetKey(), false)
+		h.AdvanceAndExpect(published.GetKey(), unpublished.GetKey())
+		assert.Len(t, publisher.get(schedule.LiveChannel(published.GetKey())), 2)
+	})
+}
+
+func TestSchedulerEvaluationJitter(t *testing.T) {
+	testCases := []struct {
+		desc   string
+		jitter bool
+	}{
+		{desc: "jitter spreads the evaluations across the interval", jitter: true},
+		{desc: "without jitter the evaluations are on the first tick of the interval"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			dbstore := setupTestEnv(t, 1)
+			t.Cleanup(registry.ClearOverrides)
+
+			var keys []models.AlertDefinitionKey
+			for i := 0; i < 4; i++ {
+				keys = append(keys, createTestAlertDefinition(t, dbstore, 4).GetKey())
+			}
+
+			h := schedtest.New(t, schedule.SchedulerCfg{
+				MaxAttempts:      1,
+				Evaluator:        &fakeEvaluator{evalFunc: func(*models.Condition, time.Time) (eval.Results, error) { return eval.Results{}, nil }},
+				Store:            dbstore,
+				Notifier:         &fakeNotifier{},
+				Logger:           log.New("ngalert schedule test"),
+				EvaluationJitter: tc.jitter,
+			})
+
+			evaluations := make(map[models.AlertDefinitionKey]int)
+			for tickNum := int64(1); tickNum <= 8; tickNum++ {
+				var expected []models.AlertDefinitionKey
+				for _, key := range keys {
+					offset := int64(0)
+					if tc.jitter {
+						offset = schedule.EvaluationOffset(key, 4)
+						require.Equal(t, offset, schedule.EvaluationOffset(key, 4), "the offset is stable")
+						require.True(t, offset >= 0 && offset < 4)
+					}
+					if tickNum%4 == offset {
+						expected = append(expected, key)
+						evaluations[key]++
+					}
+				}
+				tick := h.Advance()
+				if len(expected) > 0 {
+					h.ExpectEvaluated(tick, expected...)
+				}
+			}
+			for _, key := range keys {
+				assert.Equal(t, 2, evaluations[key], "every alert definition is evaluated once per interval")
+			}
+		})
+	}
+}
+
+func TestSchedulerMaxConcurrentEvaluations(t *testing.T) {
+	dbstore := setupTestEnv(t, 1)
+	t.Cleanup(registry.ClearOverrides)
+
+	var keys []models.AlertDefinitionKey
+	for i := 0; i < 3; i++ {
+		keys = append(keys, createTestAlertDefinition(t, dbstore, 1).GetKey())
+	}
+
+	var running, maxRunning int32
+	releaseEval := make(chan struct{})
+	evaluator := &fakeEvaluator{evalFunc: func(*models.Condition, time.Time) (eval.Results, error) {
+		n := atomic.AddInt32(&running, 1)
+		defer atomic.AddInt32(&running, -1)
+		for {
+			m := atomic.LoadInt32(&maxRunning)
+			if n <= m || atomic.CompareAndSwapInt32(&maxRunning, m, n) {
+				break
+			}
+		}
+		<-releaseEval
+		return eval.Results{}, nil
+	}}
+
+	waits := func() uint64 {
+		var m dto.Metric
+		require.NoError(t, metrics.MNGAlertEvaluationQueueWait.Write(&m))
+		return m.GetHistogram().GetSampleCount()
+	}
+	initialWaits := waits()
+	h := schedtest.New(t, schedule.SchedulerCfg{
+		MaxAttempts:              1,
+		Evaluator:                evaluator,
+		Store:                    dbstore,
+		Notifier:                 &fakeNotifier{},
+		Logger:                   log.New("ngalert schedule test"),
+		MaxConcurrentEvaluations: 1,
+	})
+
+	tick := h.Advance()
+	// one alert definition is evaluated, the other ones wait for its slot
+	require.Eventually(t, func() bool {
+		return atomic.LoadInt32(&running) == 1 && testutil.ToFloat64(metrics.MNGAlertEvaluationQueueDepth) == 2
+	}, time.Second, 10*time.Millisecond)
+
+	close(releaseEval)
+	h.ExpectEvaluated(tick, keys...)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&maxRunning))
+	assert.Equal(t, 0.0, testutil.ToFloat64(metrics.MNGAlertEvaluationQueueDepth))
+	assert.Equal(t, initialWaits+3, waits())
+}
+
+func TestSchedulerNoDataAndExecErrStates(t *testing.T) {
+	testCases := []struct {
+		desc           string
+		noDataState    models.NoDataState
+		execErrState   models.ExecutionErrorState
+		expectedNoData eval.State
+		expectedErr    eval.State
+	}{
+		{desc: "unset", expectedNoData: eval.Alerting, expectedErr: eval.Alerting},
+		{desc: "no data and error", noDataState: models.NoData, execErrState: models.ErrorErrState, expectedNoData: eval.NoData, expectedErr: eval.Error},
+		{desc: "ok", noDataState: models.OK, execErrState: models.OKErrState, expectedNoData: eval.Normal, expectedErr: eval.Normal},
+		{desc: "alerting", noDataState: models.Alerting, execErrState: models.AlertingErrState, expectedNoData: eval.Alerting, expectedErr: eval.Alerting},
+		{desc: "keep last state", noDataState: models.KeepLastState, execErrState: models.KeepLastStateErrState, expectedNoData: eval.Alerting, expectedErr: eval.Alerting},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			dbstore := setupTestEnv(t, 1)
+			t.Cleanup(registry.ClearOverrides)
+
+			alertDefinition := createTestAlertDefinition(t, dbstore, 1)
+			key := alertDefinition.GetKey()
+			require.NoError(t, dbstore.UpdateAlertDefinition(&models.UpdateAlertDefinitionCommand{
+				OrgID:        key.OrgID,
+				UID:          key.DefinitionUID,
+				NoDataState:  tc.noDataState,
+				ExecErrState: tc.execErrState,
+			}))
+
+			var mu sync.Mutex
+			var evalErr error
+			setEvalErr := func(err error) {
+				mu.Lock()
+				defer mu.Unlock()
+				evalErr = err
+			}
+			evaluator := &fakeEvaluator{evalFunc: func(*models.Condition, time.Time) (eval.Results, error) {
+				mu.Lock()
+				defer mu.Unlock()
+				if evalErr != nil {
+					return nil, evalErr
+				}
+				return eval.Results{{Instance: data.Labels{}, State: eval.Alerting}}, nil
+			}}
+			h := schedtest.New(t, schedule.SchedulerCfg{
+				MaxAttempts: 1,
+				Evaluator:   evaluator,
+				Store:       dbstore,
+				Notifier:    &fakeNotifier{},
+				Logger:      log.New("ngalert schedule test"),
+			})
+			cacheID := state.CacheID(key.DefinitionUID, data.Labels{})
+			assertState := func(expected eval.State) {
+				t.Helper()
+				assert.Equal(t, expected, h.StateTracker.Get(key.OrgID, cacheID).State)
+				q := models.ListAlertInstancesQuery{DefinitionOrgID: key.OrgID, DefinitionUID: key.DefinitionUID}
+				require.NoError(t, dbstore.ListAlertInstances(&q))
+				require.Len(t, q.Result, 1)
+				assert.Equal(t, models.InstanceStateType(expected.String()), q.Result[0].CurrentState)
+			}
+
+			h.AdvanceAndExpect(key)
+			assertState(eval.Alerting)
+
+			setEvalErr(fmt.Errorf("failed to execute conditions: %w", eval.ErrNoData))
+			h.AdvanceAndExpect(key)
+			assertState(tc.expectedNoData)
+
+			setEvalErr(errors.New("datasource is down"))
+			h.AdvanceAndExpect(key)
+			assertState(tc.expectedErr)
+		})
+	}
+}
+
+func TestSchedulerPendingPeriod(t *testing.T) {
+	dbstore := setupTestEnv(t, 1)
+	t.Cleanup(registry.ClearOverrides)
+
+	alertDefinition := createTestAlertDefinition(t, dbstore, 1)
+	key := alertDefinition.GetKey()
+	forDuration := 2 * time.Second
+	require.NoError(t, dbstore.UpdateAlertDefinition(&models.UpdateAlertDefinitionCommand{
+		OrgID: key.OrgID,
+		UID:   key.DefinitionUID,
+		For:   &forDuration,
+	}))
+
+	evaluator := &fakeEvaluator{evalFunc: func(c *models.Condition, now time.Time) (eval.Results, error) {
+		return eval.Results{{Instance: data.Labels{}, State: eval.Alerting, EvaluatedAt: now}}, nil
+	}}
+	h := schedtest.New(t, schedule.SchedulerCfg{
+		MaxAttempts: 1,
+		Evaluator:   evaluator,
+		Store:       dbstore,
+		Notifier:    &fakeNotifier{},
+		Logger:      log.New("ngalert schedule test"),
+	})
+	cacheID := state.CacheID(key.DefinitionUID, data.Labels{})
+
+	conditionStart := h.AdvanceAndExpect(key)
+	h.AdvanceAndExpect(key)
+	assert.Equal(t, eval.Pending, h.StateTracker.Get(key.OrgID, cacheID).State)
+
+	t.Run("the pending state is persisted and restored with its condition start", func(t *testing.T) {
+		q := models.ListAlertInstancesQuery{DefinitionOrgID: key.OrgID, DefinitionUID: key.DefinitionUID}
+		require.NoError(t, dbstore.ListAlertInstances(&q))
+		require.Len(t, q.Result, 1)
+		assert.Equal(t, models.InstanceStatePending, q.Result[0].CurrentState)
+
+		restored := state.NewStateTracker(log.New("ngalert schedule test"))
+		h.Scheduler.WarmStateCache(restored)
+		s := restored.Get(key.OrgID, cacheID)
+		assert.Equal(t, eval.Pending, s.State)
+		assert.Equal(t, conditionStart.Unix(), s.ConditionStartsAt.Unix())
+	})
+
+	// the alert state is alerting once the condition has been true for the for duration
+	h.AdvanceAndExpect(key)
+	s := h.StateTracker.Get(key.OrgID, cacheID)
+	assert.Equal(t, eval.Alerting, s.State)
+	assert.Equal(t, conditionStart.Unix(), s.ConditionStartsAt.Unix())
+}
+
+func TestSchedulerSharding(t *testing.T) {
+	dbstore := setupTestEnv(t, 1)
+	t.Cleanup(registry.ClearOverrides)
+
+	// the alert definitions are created until both members own some
+	members := []string{"instance-a", "instance-b"}
+	owned := make(map[string][]models.AlertDefinitionKey)
+	var keys []models.AlertDefinitionKey
+	for i := 0; i < 20 && (len(owned[members[0]]) == 0 || len(owned[members[1]]) == 0); i++ {
+		key := createTestAlertDefinition(t, dbstore, 1).GetKey()
+		keys = append(keys, key)
+		owner := schedule.ShardOwner(members, key)
+		owned[owner] = append(owned[owner], key)
+	}
+	require.NotEmpty(t, owned[members[0]])
+	require.NotEmpty(t, owned[members[1]])
+
+	require.NoError(t, dbstore.SaveSchedulerMember(&models.SaveSchedulerMemberCommand{
+		Member: models.SchedulerMember{InstanceID: members[1], LastHeartbeat: time.Unix(1, 0)},
+	}))
+
+	evaluator := &fakeEvaluator{evalFunc: func(*models.Condition, time.Time) (eval.Results, error) {
+		return eval.Results{{Instance: data.Labels{}, State: eval.Normal}}, nil
+	}}
+	h := schedtest.New(t, schedule.SchedulerCfg{
+		MaxAttempts: 1,
+		Evaluator:   evaluator,
+		Store:       dbstore,
+		Notifier:    &fakeNotifier{},
+		Logger:      log.New("ngalert schedule test"),
+		Sharding: &schedule.Sharding{
+			InstanceID:        members[0],
+			HeartbeatInterval: time.Second,
+			MemberTTL:         3 * time.Second,
+		},
+	})
+
+	// only the alert definitions owned by the instance are evaluated while the other member heartbeats
+	tick := h.AdvanceAndExpect(owned[members[0]]...)
+	assert.Equal(t, members, h.Scheduler.ShardMembers())
+	for _, key := range owned[members[1]] {
+		assertDecision(t, h.Scheduler, key, tick, schedule.SkipReasonNotOwned)
+	}
+
+	q := models.ListSchedulerMembersQuery{Since: time.Unix(0, 0)}
+	require.NoError(t, dbstore.ListSchedulerMembers(&q))
+	require.Len(t, q.Result, 2)
+	assert.Equal(t, members[0], q.Result[0].InstanceID)
+	assert.Equal(t, tick.Unix(), q.Result[0].LastHeartbeat.Unix())
+
+	for i := 0; i < 3; i++ {
+		h.AdvanceAndExpect(owned[members[0]]...)
+	}
+
+	// the other member stopped heartbeating: its alert definitions move to the instance
+	h.AdvanceAndExpect(keys...)
+	assert.Equal(t, members[:1], h.Scheduler.ShardMembers())
+}
+
+func TestSchedulerEvaluationTimeout(t *testing.T) {
+	dbstore := setupTestEnv(t, 1)
+	t.Cleanup(registry.ClearOverrides)
+
+	ownTimeoutDefinition := createTestAlertDefinition(t, dbstore, 1)
+	globalTimeoutDefinition := createTestAlertDefinition(t, dbstore, 1)
+	ownTimeout := 20 * time.Millisecond
+	globalTimeout := 200 * time.Millisecond
+	require.NoError(t, dbstore.UpdateAlertDefinition(&models.UpdateAlertDefinitionCommand{
+		OrgID:             ownTimeoutDefinition.OrgID,
+		UID:               ownTimeoutDefinition.UID,
+		EvaluationTimeout: &ownTimeout,
+	}))
+
+	var mu sync.Mutex
+	var remaining []time.Duration
+	evaluator := &contextEvaluator{evalFunc: func(ctx context.Context, c *models.Condition, now time.Time) (eval.Results, error) {
+		// the evaluations block until they time out
+		if deadline, ok := ctx.Deadline(); ok {
+			mu.Lock()
+			remaining = append(remaining, time.Until(deadline))
+			mu.Unlock()
+		}
+		<-ctx.Done()
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return nil, fmt.Errorf("failed to execute conditions: %w: %s", eval.ErrTimeout, ctx.Err())
+		}
+		return nil, ctx.Err()
+	}}
+	h := schedtest.New(t, schedule.SchedulerCfg{
+		MaxAttempts:       1,
+		Evaluator:         evaluator,
+		Store:             dbstore,
+		Notifier:          &fakeNotifier{},
+		Logger:            log.New("ngalert schedule test"),
+		EvaluationTimeout: globalTimeout,
+	})
+	h.AdvanceAndExpect(ownTimeoutDefinition.GetKey(), globalTimeoutDefinition.GetKey())
+
+	t.Run("the alert definitions with a timeout of their own don't use the global one", func(t *testing.T) {
+		mu.Lock()
+		defer mu.Unlock()
+		require.Len(t, remaining, 2)
+		sort.Slice(remaining, func(i, j int) bool { return remaining[i] < remaining[j] })
+		assert.LessOrEqual(t, int64(remaining[0]), int64(ownTimeout))
+		assert.Greater(t, int64(remaining[1]), int64(ownTimeout))
+		assert.LessOrEqual(t, int64(remaining[1]), int64(globalTimeout))
+	})
+
+	t.Run("the alert definitions whose evaluation timed out get the Timeout state", func(t *testing.T) {
+		for _, alertDefinition := range []*models.AlertDefinition{ownTimeoutDefinition, globalTimeoutDefinition} {
+			cacheID := state.CacheID(alertDefinition.UID, data.Labels{})
+			assert.Equal(t, eval.Timeout, h.StateTracker.Get(alertDefinition.OrgID, cacheID).State)
+
+			q := models.ListAlertInstancesQuery{DefinitionOrgID: alertDefinition.OrgID, DefinitionUID: alertDefinition.UID}
+			require.NoError(t, dbstore.ListAlertInstances(&q))
+			require.Len(t, q.Result, 1)
+			assert.Equal(t, models.InstanceStateTimeout, q.Result[0].CurrentState)
+		}
+	})
+}
+
+func TestSchedulerEvaluationTimeoutBeyondDefault(t *testing.T) {
+	dbstore := setupTestEnv(t, 1)
+	t.Cleanup(registry.ClearOverrides)
+
+	alertDefinition := createTestAlertDefinition(t, dbstore, 1)
+	timeout := 45 * time.Second
+	require.NoError(t, dbstore.UpdateAlertDefinition(&models.UpdateAlertDefinitionCommand{
+		OrgID:             alertDefinition.OrgID,
+		UID:               alertDefinition.UID,
+		EvaluationTimeout: &timeout,
+	}))
+
+	remaining := make(chan time.Duration, 1)
+	evaluator := &contextEvaluator{evalFunc: func(ctx context.Context, c *models.Condition, now time.Time) (eval.Results, error) {
+		deadline, ok := ctx.Deadline()
+		require.True(t, ok)
+		remaining <- time.Until(deadline)
+		return eval.Results{{Instance: data.Labels{}, State: eval.Normal, EvaluatedAt: now}}, nil
+	}}
+	h := schedtest.New(t, schedule.SchedulerCfg{
+		MaxAttempts:       1,
+		Evaluator:         evaluator,
+		Store:             dbstore,
+		Notifier:          &fakeNotifier{},
+		Logger:            log.New("ngalert schedule test"),
+		EvaluationTimeout: 10 * time.Second,
+	})
+	h.AdvanceAndExpect(alertDefinition.GetKey())
+
+	// the timeout of the alert definition is not capped by the default timeout of the evaluations
+	r := <-remaining
+	assert.Greater(t, int64(r), int64(30*time.Second))
+	assert.LessOrEqual(t, int64(r), int64(timeout))
+}
+
+func TestSchedulerCancelsEvaluationOnStop(t *testing.T) {
+	dbstore := setupTestEnv(t, 1)
+	t.Cleanup(registry.ClearOverrides)
+
+	alertDefinition := createTestAlertDefinition(t, dbstore, 1)
+	key := alertDefinition.GetKey()
+	require.NoError(t, dbstore.UpdateAlertDefinition(&models.UpdateAlertDefinitionCommand{
+		OrgID:        key.OrgID,
+		UID:          key.DefinitionUID,
+		ExecErrState: models.ErrorErrState,
+	}))
+
+	evalStarted := make(chan struct{}, 1)
+	evalErr := make(chan error, 1)
+	evaluator := &contextEvaluator{evalFunc: func(ctx context.Context, c *models.Condition, now time.Time) (eval.Results, error) {
+		evalStarted <- struct{}{}
+		<-ctx.Done()
+		evalErr <- ctx.Err()
+		return nil, ctx.Err()
+	}}
+	h := schedtest.New(t, schedule.SchedulerCfg{
+		MaxAttempts: 3,
+		Evaluator:   evaluator,
+		Store:       dbstore,
+		Notifier:    &fakeNotifier{},
+		Logger:      log.New("ngalert schedule test"),
+	})
+
+	tick := h.Advance()
+	<-evalStarted
+
+	require.NoError(t, dbstore.DeleteAlertDefinitionByUID(&models.DeleteAlertDefinitionByUIDCommand{OrgID: key.OrgID, UID: key.DefinitionUID}))
+	h.Advance()
+	h.ExpectEvaluated(tick, key)
+	h.ExpectStopped(key)
+	assert.ErrorIs(t, <-evalErr, context.Canceled)
+	assert.Empty(t, evalStarted, "the cancelled evaluation is not attempted again")
+	assert.Empty(t, h.StateTracker.GetAll(), "the alert states are left as they are")
+}
+
+func TestSchedulerMetrics(t *testing.T) {
+	dbstore := setupTestEnv(t, 1)
+	t.Cleanup(registry.ClearOverrides)
+
+	alertingDefinition := createTestAlertDefinitionForOrg(t, dbstore, 1, 1)
+	failingDefinition := createTestAlertDefinitionForOrg(t, dbstore, 1, 2)
+
+	var resolved int32
+	evaluator := &fakeEvaluator{evalFunc: func(c *models.Condition, now time.Time) (eval.Results, error) {
+		if c.OrgID == failingDefinition.OrgID {
+			return nil, errors.New("failed to execute conditions")
+		}
+		if atomic.LoadInt32(&resolved) == 1 {
+			return eval.Results{{Instance: data.Labels{}, State: eval.Normal, EvaluatedAt: now}}, nil
+		}
+		return eval.Results{{Instance: data.Labels{}, State: eval.Alerting, EvaluatedAt: now}}, nil
+	}}
+
+	evaluations := func(orgID string) float64 {
+		return testutil.ToFloat64(metrics.MNGAlertEvaluations.WithLabelValues(orgID))
+	}
+	failures := func(orgID string) float64 {
+		return testutil.ToFloat64(metrics.MNGAlertEvaluationFailures.WithLabelValues(orgID))
+	}
+	durations := func(orgID string) uint64 {
+		m := &dto.Metric{}
+		require.NoError(t, metrics.MNGAlertEvaluationDuration.WithLabelValues(orgID).(prometheus.Histogram).Write(m))
+		return m.GetHistogram().GetSampleCount()
+	}
+	transitions := func() float64 {
+		return testutil.ToFloat64(metrics.MNGAlertStateTransitions.WithLabelValues(eval.Alerting.String(), eval.Normal.String()))
+	}
+	evaluations1, evaluations2 := evaluations("1"), evaluations("2")
+	failures1, failures2 := failures("1"), failures("2")
+	durations1, durations2 := durations("1"), durations("2")
+	transitionsToNormal := transitions()
+
+	h := schedtest.New(t, schedule.SchedulerCfg{
+		MaxAttempts: 2,
+		Evaluator:   evaluator,
+		Store:       dbstore,
+		Notifier:    &fakeNotifier{},
+		Logger:      log.New("ngalert schedule test"),
+	})
+	h.AdvanceAndExpect(alertingDefinition.GetKey(), failingDefinition.GetKey())
+
+	t.Run("the evaluations are counted by organisation, once whatever their attempts", func(t *testing.T) {
+		assert.Equal(t, evaluations1+1, evaluations("1"))
+		assert.Equal(t, evaluations2+1, evaluations("2"))
+		assert.Equal(t, failures1, failures("1"))
+		assert.Equal(t, failures2+1, failures("2"))
+	})
+
+	t.Run("the duration of every attempt is observed by organisation", func(t *testing.T) {
+		assert.Equal(t, durations1+1, durations("1"))
+		assert.Equal(t, durations2+2, durations("2"))
+	})
+
+	// the routines of the schedulers of the previous tests may still be stopping
+	routines := testutil.ToFloat64(metrics.MNGAlertActiveRoutines)
+	assert.GreaterOrEqual(t, routines, 2.0)
+
+	atomic.StoreInt32(&resolved, 1)
+	h.AdvanceAndExpect(alertingDefinition.GetKey(), failingDefinition.GetKey())
+
+	t.Run("the state transitions are counted by states", func(t *testing.T) {
+		assert.Equal(t, transitionsToNormal+1, transitions())
+	})
+
+	t.Run("the size of the state cache is reported every tick", func(t *testing.T) {
+		assert.Equal(t, 1, h.StateTracker.CacheSize())
+		assert.Equal(t, 1.0, testutil.ToFloat64(metrics.MNGAlertStateCacheSize))
+	})
+
+	t.Run("the routines of the deleted alert definitions are no longer counted", func(t *testing.T) {
+		err := dbstore.DeleteAlertDefinitionByUID(&models.DeleteAlertDefinitionByUIDCommand{UID: failingDefinition.UID, OrgID: failingDefinition.OrgID})
+		require.NoError(t, err)
+		h.Advance()
+		h.ExpectStopped(failingDefinition.GetKey())
+		require.Eventually(t, func() bool {
+			return testutil.ToFloat64(metrics.MNGAlertActiveRoutines) < routines
+		}, time.Second, 10*time.Millisecond)
+	})
+}
+
+func TestSchedulerRecordingRules(t *testing.T) {
+	dbstore := setupTestEnv(t, 1)
+	t.Cleanup(registry.ClearOverrides)
+
+	alertDefinition := createTestAlertDefinition(t, dbstore, 1)
+	record := models.RecordingRule{Metric: "job:requests:rate1m", TargetDatasourceUID: "prometheus"}
+	update := models.UpdateAlertDefinitionCommand{UID: alertDefinition.UID, OrgID: alertDefinition.OrgID, Record: &record}
+	require.NoError(t, dbstore.UpdateAlertDefinition(&update))
+
+	var evaluations int32
+	value := 4.2
+	evaluator := &fakeEvaluator{evalFunc: func(c *models.Condition, now time.Time) (eval.Results, error) {
+		atomic.AddInt32(&evaluations, 1)
+		return eval.Results{
+			{Instance: data.Labels{"job": "api"}, State: eval.Alerting, EvaluatedAt: now, Value: &value},
+			{Instance: data.Labels{"job": "web"}, State: eval.NoData, EvaluatedAt: now},
+		}, nil
+	}}
+	writer := &fakeSeriesWriter{written: make(map[string][]schedule.Sample)}
+
+	h := schedtest.New(t, schedule.SchedulerCfg{
+		MaxAttempts:  2,
+		Evaluator:    evaluator,
+		Store:        dbstore,
+		Notifier:     &fakeNotifier{},
+		Logger:       log.New("ngalert schedule test"),
+		SeriesWriter: writer,
+	})
+	tick := h.AdvanceAndExpect(alertDefinition.GetKey())
+
+	t.Run("the values of the recording rule are written as series of its metric", func(t *testing.T) {
+		samples := writer.get(alertDefinition.OrgID, record.TargetDatasourceUID)
+		require.Len(t, samples, 1)
+		assert.Equal(t, data.Labels{schedule.MetricNameLabel: record.Metric, "job": "api"}, samples[0].Labels)
+		assert.Equal(t, value, samples[0].Value)
+		assert.Equal(t, tick, samples[0].Timestamp)
+	})
+
+	t.Run("the recording rule has no alert instances", func(t *testing.T) {
+		assert.Empty(t, h.StateTracker.GetAll())
+		q := models.ListAlertInstancesQuery{DefinitionOrgID: alertDefinition.OrgID, DefinitionUID: alertDefinition.UID}
+		require.NoError(t, dbstore.ListAlertInstances(&q))
+		assert.Empty(t, q.Result)
+	})
+
+	t.Run("failing writes are retried without alert instances", func(t *testing.T) {
+		writer.setErr(errors.New("remote write failed"))
+		before := atomic.LoadInt32(&evaluations)
+		h.AdvanceAndExpect(alertDefinition.GetKey())
+		assert.Equal(t, before+2, atomic.LoadInt32(&evaluations))
+		assert.Empty(t, h.StateTracker.GetAll())
+	})
+}
+
+// remoteWriteDatasourceCache is a datasource cache whose datasources are at the URL.
+type remoteWriteDatasourceCache struct {
+	url string
+}
+
+func (c *remoteWriteDatasourceCache) GetDatasource(_ int64, _ *apimodels.SignedInUser, _ bool) (*apimodels.DataSource, error) {
+	return nil, apimodels.ErrDataSourceNotFound
+}
+
+func (c *remoteWriteDatasourceCache) GetDatasourceByUID(uid string, user *apimodels.SignedInUser, _ bool) (*apimodels.DataSource, error) {
+	return &apimodels.DataSource{Id: -1, Uid: uid, OrgId: user.OrgId, Url: c.url, Updated: time.Now()}, nil
+}
+
+func TestRemoteWriter(t *testing.T) {
+	var received []prompb.WriteRequest
+	var status int32 = http.StatusNoContent
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/v1/write", r.URL.Path)
+		assert.Equal(t, "snappy", r.Header.Get("Content-Encoding"))
+		compressed, err := ioutil.ReadAll(r.Body)
+		require.NoError(t, err)
+		body, err := snappy.Decode(nil, compressed)
+		require.NoError(t, err)
+		var req prompb.WriteRequest
+		require.NoError(t, req.Unmarshal(body))
+		received = append(received, req)
+		w.WriteHeader(int(atomic.LoadInt32(&status)))
+	}))
+	t.Cleanup(srv.Close)
+
+	writer := schedule.NewRemoteWriter(&remoteWriteDatasourceCache{url: srv.URL + "/"})
+	ts := time.Unix(1616161616, 0)
+	samples := []schedule.Sample{
+		{Labels: data.Labels{schedule.MetricNameLabel: "job:requests:rate1m", "job": "api"}, Value: 4.2, Timestamp: ts},
+	}
+
+	t.Run("the samples are written with the remote write protocol", func(t *testing.T) {
+		require.NoError(t, writer.WriteSeries(context.Background(), 1, "prometheus", samples))
+		require.Len(t, received, 1)
+		require.Len(t, received[0].Timeseries, 1)
+		series := received[0].Timeseries[0]
+		assert.Equal(t, []prompb.Label{{Name: "__name__", Value: "job:requests:rate1m"}, {Name: "job", Value: "api"}}, series.Labels)
+		assert.Equal(t, []prompb.Sample{{Value: 4.2, Timestamp: ts.UnixNano() / 1e6}}, series.Samples)
+	})
+
+	t.Run("the rejected writes fail", func(t *testing.T) {
+		atomic.StoreInt32(&status, http.StatusBadRequest)
+		require.Error(t, writer.WriteSeries(context.Background(), 1, "prometheus", samples))
+	})
+}
+
+func TestSchedulerPreviewAlertDefinition(t *testing.T) {
+	dbstore := setupTestEnv(t, 1)
+	t.Cleanup(registry.ClearOverrides)
+
+	start := time.Date(2021, 4, 1, 12, 0, 0, 0, time.UTC)
+	times := []time.Time{start, start.Add(time.Minute), start.Add(2 * time.Minute), start.Add(3 * time.Minute)}
+	evaluator := &fakeEvaluator{evalFunc: func(c *models.Condition, now time.Time) (eval.Results, error) {
+		switch now {
+		case times[0], times[1]:
+			return eval.Results{{Instance: data.Labels{"job": "api"}, State: eval.Alerting, EvaluatedAt: now}}, nil
+		case times[2]:
+			return eval.Results{{Instance: data.Labels{"job": "api"}, State: eval.Normal, EvaluatedAt: now}}, nil
+		}
+		return nil, errors.New("failed to execute conditions")
+	}}
+	h := schedtest.New(t, schedule.SchedulerCfg{
+		Evaluator: evaluator,
+		Store:     dbstore,
+		Notifier:  &fakeNotifier{},
+		Logger:    log.New("ngalert schedule test"),
+	})
+
+	alertDefinition := &models.AlertDefinition{
+		UID:          "preview",
+		OrgID:        1,
+		For:          time.Minute,
+		ExecErrState: models.AlertingErrState,
+	}
+	evaluations := h.Scheduler.PreviewAlertDefinition(context.Background(), alertDefinition, times)
+	require.Len(t, evaluations, len(times))
+
+	t.Run("every evaluation has its results", func(t *testing.T) {
+		for i, e := range evaluations[:3] {
+			assert.Equal(t, times[i], e.At)
+			require.NoError(t, e.Err)
+			require.Len(t, e.Results, 1)
+		}
+		assert.Error(t, evaluations[3].Err)
+	})
+
+	t.Run("the state transitions follow the pending period and the execution error policy", func(t *testing.T) {
+		var got [][]string
+		for _, e := range evaluations {
+			var transitions []string
+			for _, tr := range e.Transitions {
+				transitions = append(transitions, fmt.Sprintf("%s %s->%s", tr.CacheId, tr.From, tr.To))
+			}
+			got = append(got, transitions)
+		}
+		assert.Equal(t, [][]string{
+			{"preview job=api Normal->Pending"},
+			{"preview job=api Pending->Alerting"},
+			{"preview job=api Alerting->Normal"},
+			{"preview  Normal->Pending"},
+		}, got)
+	})
+
+	t.Run("the preview touches no alert state", func(t *testing.T) {
+		assert.Empty(t, h.StateTracker.GetAll())
+		q := models.ListAlertInstancesQuery{DefinitionOrgID: 1}
+		require.NoError(t, dbstore.ListAlertInstances(&q))
+		assert.Empty(t, q.Result)
+	})
+}
+
+func TestSchedulerFlushesStates(t *testing.T) {
+	dbstore := setupTestEnv(t, 1)
+	t.Cleanup(registry.ClearOverrides)
+
+	alertDefinition := createTestAlertDefinition(t, dbstore, 10)
+	key := alertDefinition.GetKey()
+
+	evaluator := &fakeEvaluator{evalFunc: func(_ *models.Condition, now time.Time) (eval.Results, error) {
+		return eval.Results{{Instance: data.Labels{}, State: eval.Normal, EvaluatedAt: now}}, nil
+	}}
+	h := schedtest.New(t, schedule.SchedulerCfg{
+		MaxAttempts:        1,
+		Evaluator:          evaluator,
+		Store:              dbstore,
+		Notifier:           &fakeNotifier{},
+		Logger:             log.New("ngalert schedule test"),
+		StateFlushInterval: 3 * time.Second,
+	})
+	h.StateTracker.SetMinWriteInterval(time.Hour)
+
+	lastEvalTime := func() time.Time {
+		q := models.GetAlertInstanceQuery{DefinitionOrgID: key.OrgID, DefinitionUID: key.DefinitionUID, Labels: models.InstanceLabels{}}
+		require.NoError(t, dbstore.GetAlertInstance(&q))
+		return q.Result.LastEvalTime
+	}
+
+	for i := 0; i < 9; i++ {
+		h.Advance()
+	}
+	first := h.AdvanceAndExpect(key)
+	assert.Equal(t, first.Unix(), lastEvalTime().Unix(), "the first state of the series is written")
+
+	for i := 0; i < 9; i++ {
+		h.Advance()
+	}
+	// the state is coalesced with the minimum write interval and saved by the next flush
+	second := h.AdvanceAndExpect(key)
+	for i := 0; i < 3; i++ {
+		h.Advance()
+	}
+	require.Eventually(t, func() bool {
+		return lastEvalTime().Unix() == second.Unix()
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestSchedulerResolvesMissedSeries(t *testing.T) {
+	dbstore := setupTestEnv(t, 1)
+	t.Cleanup(registry.ClearOverrides)
+
+	alertDefinition := createTestAlertDefinition(t, dbstore, 1)
+	key := alertDefinition.GetKey()
+
+	var missing int32
+	evaluator := &fakeEvaluator{evalFunc: func(_ *models.Condition, now time.Time) (eval.Results, error) {
+		results := eval.Results{{Instance: data.Labels{"series": "b"}, State: eval.Normal, EvaluatedAt: now}}
+		if atomic.LoadInt32(&missing) == 0 {
+			results = append(results, eval.Result{Instance: data.Labels{"series": "a"}, State: eval.Alerting, EvaluatedAt: now})
+		}
+		return results, nil
+	}}
+	n := &recordingNotifier{}
+	h := schedtest.New(t, schedule.SchedulerCfg{
+		MaxAttempts: 1,
+		Evaluator:   evaluator,
+		Store:       dbstore,
+		Notifier:    n,
+		Logger:      log.New("ngalert schedule test"),
+	})
+	h.StateTracker.SetStaleSeries(state.StaleSeries{MissedEvaluations: 2})
+	cacheID := state.CacheID(key.DefinitionUID, data.Labels{"series": "a"})
+	listInstances := func() []*models.ListAlertInstancesQueryResult {
+		q := models.ListAlertInstancesQuery{DefinitionOrgID: key.OrgID, DefinitionUID: key.DefinitionUID}
+		require.NoError(t, dbstore.ListAlertInstances(&q))
+		return q.Result
+	}
+
+	h.AdvanceAndExpect(key)
+	require.Len(t, n.recorded(), 1, "the firing alert is notified")
+	require.Len(t, listInstances(), 2)
+
+	atomic.StoreInt32(&missing, 1)
+	h.AdvanceAndExpect(key)
+	assert.Equal(t, eval.Alerting, h.StateTracker.Get(key.OrgID, cacheID).State, "the series is kept until it misses enough evaluations")
+	require.Len(t, listInstances(), 2)
+
+	resolvedAt := h.AdvanceAndExpect(key)
+	assert.Empty(t, h.StateTracker.Get(key.OrgID, cacheID).CacheId, "the resolved state is removed from the cache")
+	instances := listInstances()
+	require.Len(t, instances, 1, "the resolved alert instance is deleted")
+	assert.Equal(t, models.InstanceLabels{"series": "b"}, instances[0].Labels)
+
+	alerts := n.recorded()
+	require.Len(t, alerts, 2)
+	resolved := alerts[1]
+	assert.Equal(t, "a", resolved.Labels["series"])
+	assert.Equal(t, alertDefinition.Title, resolved.Labels[schedule.AlertNameLabel])
+	assert.Equal(t, resolvedAt.Unix(), time.Time(resolved.EndsAt).Unix(), "the resolution is notified")
 }
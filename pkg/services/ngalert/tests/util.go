@@ -3,7 +3,6 @@ package tests
 import (
 	"encoding/json"
 	"fmt"
-	"math/rand"
 	"testing"
 	"time"
 
@@ -18,6 +17,7 @@ import (
 	"github.com/grafana/grafana/pkg/registry"
 	"github.com/grafana/grafana/pkg/services/sqlstore"
 	"github.com/grafana/grafana/pkg/setting"
+	"github.com/grafana/grafana/pkg/util"
 	"github.com/stretchr/testify/require"
 )
 
@@ -63,9 +63,35 @@ func overrideAlertNGInRegistry(t *testing.T, cfg *setting.Cfg) ngalert.AlertNG {
 
 // createTestAlertDefinition creates a dummy alert definition to be used by the tests.
 func createTestAlertDefinition(t *testing.T, store *store.DBstore, intervalSeconds int64) *models.AlertDefinition {
-	cmd := models.SaveAlertDefinitionCommand{
-		OrgID:     1,
-		Title:     fmt.Sprintf("an alert definition %d", rand.Intn(1000)),
+	return createTestAlertDefinitionForOrg(t, store, intervalSeconds, 1)
+}
+
+// createTestAlertDefinitionForOrg creates a dummy alert definition of the organisation to be used by the tests.
+func createTestAlertDefinitionForOrg(t *testing.T, store *store.DBstore, intervalSeconds int64, orgID int64) *models.AlertDefinition {
+	cmd := testAlertDefinitionCommand(orgID)
+	cmd.IntervalSeconds = &intervalSeconds
+	err := store.SaveAlertDefinition(&cmd)
+	require.NoError(t, err)
+	t.Logf("alert definition: %v with interval: %d created", cmd.Result.GetKey(), intervalSeconds)
+	return cmd.Result
+}
+
+// createTestAlertDefinitionInFolder creates a dummy alert definition of the folder
+// that inherits its interval from the alert settings of the folder.
+func createTestAlertDefinitionInFolder(t *testing.T, store *store.DBstore, namespaceUID string) *models.AlertDefinition {
+	cmd := testAlertDefinitionCommand(1)
+	cmd.NamespaceUID = namespaceUID
+	err := store.SaveAlertDefinition(&cmd)
+	require.NoError(t, err)
+	t.Logf("alert definition: %v of folder: %s created", cmd.Result.GetKey(), namespaceUID)
+	return cmd.Result
+}
+
+// testAlertDefinitionCommand returns the command for saving a dummy alert definition of the organisation.
+func testAlertDefinitionCommand(orgID int64) models.SaveAlertDefinitionCommand {
+	return models.SaveAlertDefinitionCommand{
+		OrgID:     orgID,
+		Title:     fmt.Sprintf("an alert definition %s", util.GenerateShortUID()),
 		Condition: "A",
 		Data: []models.AlertQuery{
 			{
@@ -81,10 +107,5 @@ func createTestAlertDefinition(t *testing.T, store *store.DBstore, intervalSecon
 				RefID: "A",
 			},
 		},
-		IntervalSeconds: &intervalSeconds,
 	}
-	err := store.SaveAlertDefinition(&cmd)
-	require.NoError(t, err)
-	t.Logf("alert definition: %v with interval: %d created", cmd.Result.GetKey(), intervalSeconds)
-	return cmd.Result
 }
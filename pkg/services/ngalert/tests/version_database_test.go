@@ -0,0 +1,88 @@
+// +build integration
+
+package tests
+
+import (
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana/pkg/services/ngalert/models"
+
+	"github.com/grafana/grafana/pkg/registry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAlertDefinitionVersions(t *testing.T) {
+	dbstore := setupTestEnv(t, baseIntervalSeconds)
+	t.Cleanup(registry.ClearOverrides)
+
+	alertDefinition := createTestAlertDefinition(t, dbstore, 60)
+	originalTitle := alertDefinition.Title
+
+	var interval int64 = 120
+	forDuration := time.Minute
+	update := models.UpdateAlertDefinitionCommand{
+		UID:             alertDefinition.UID,
+		OrgID:           alertDefinition.OrgID,
+		Title:           "an updated alert definition",
+		IntervalSeconds: &interval,
+		NoDataState:     models.Alerting,
+		For:             &forDuration,
+	}
+	require.NoError(t, dbstore.UpdateAlertDefinition(&update))
+
+	t.Run("can list the versions, latest first", func(t *testing.T) {
+		q := models.ListAlertDefinitionVersionsQuery{UID: alertDefinition.UID, OrgID: alertDefinition.OrgID}
+		require.NoError(t, dbstore.ListAlertDefinitionVersions(&q))
+		require.Len(t, q.Result, 2)
+		assert.Equal(t, int64(2), q.Result[0].Version)
+		assert.Equal(t, "an updated alert definition", q.Result[0].Title)
+		assert.Equal(t, int64(1), q.Result[1].Version)
+		assert.Equal(t, originalTitle, q.Result[1].Title)
+	})
+
+	t.Run("can get a version", func(t *testing.T) {
+		q := models.GetAlertDefinitionVersionQuery{UID: alertDefinition.UID, OrgID: alertDefinition.OrgID, Version: 2}
+		require.NoError(t, dbstore.GetAlertDefinitionVersion(&q))
+		assert.Equal(t, alertDefinition.UID, q.Result.AlertDefinitionUID)
+		assert.Equal(t, interval, q.Result.IntervalSeconds)
+		assert.Equal(t, models.Alerting, q.Result.NoDataState)
+		assert.Equal(t, forDuration, q.Result.For)
+	})
+
+	t.Run("getting an unknown version fails", func(t *testing.T) {
+		q := models.GetAlertDefinitionVersionQuery{UID: alertDefinition.UID, OrgID: alertDefinition.OrgID, Version: 3}
+		require.ErrorIs(t, dbstore.GetAlertDefinitionVersion(&q), models.ErrAlertDefinitionVersionNotFound)
+	})
+
+	t.Run("getting a version of another organisation fails", func(t *testing.T) {
+		q := models.GetAlertDefinitionVersionQuery{UID: alertDefinition.UID, OrgID: alertDefinition.OrgID + 1, Version: 1}
+		require.ErrorIs(t, dbstore.GetAlertDefinitionVersion(&q), models.ErrAlertDefinitionNotFound)
+	})
+
+	t.Run("can restore a version as a new version", func(t *testing.T) {
+		cmd := models.RestoreAlertDefinitionVersionCommand{UID: alertDefinition.UID, OrgID: alertDefinition.OrgID, Version: 1}
+		require.NoError(t, dbstore.RestoreAlertDefinitionVersion(&cmd))
+		assert.Equal(t, int64(3), cmd.Result.Version)
+
+		q := models.GetAlertDefinitionByUIDQuery{UID: alertDefinition.UID, OrgID: alertDefinition.OrgID}
+		require.NoError(t, dbstore.GetAlertDefinitionByUID(&q))
+		assert.Equal(t, int64(3), q.Result.Version)
+		assert.Equal(t, originalTitle, q.Result.Title)
+		assert.Equal(t, int64(60), q.Result.IntervalSeconds)
+		assert.Equal(t, models.NoDataState(""), q.Result.NoDataState)
+		assert.Equal(t, time.Duration(0), q.Result.For)
+
+		vq := models.GetAlertDefinitionVersionQuery{UID: alertDefinition.UID, OrgID: alertDefinition.OrgID, Version: 3}
+		require.NoError(t, dbstore.GetAlertDefinitionVersion(&vq))
+		assert.Equal(t, int64(1), vq.Result.RestoredFrom)
+		assert.Equal(t, int64(2), vq.Result.ParentVersion)
+		assert.Equal(t, originalTitle, vq.Result.Title)
+	})
+
+	t.Run("restoring an unknown version fails", func(t *testing.T) {
+		cmd := models.RestoreAlertDefinitionVersionCommand{UID: alertDefinition.UID, OrgID: alertDefinition.OrgID, Version: 10}
+		require.ErrorIs(t, dbstore.RestoreAlertDefinitionVersion(&cmd), models.ErrAlertDefinitionVersionNotFound)
+	})
+}
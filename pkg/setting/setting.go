@@ -168,6 +168,22 @@ var (
 	AlertingMaxAttempts         int
 	AlertingMinInterval         int64
 
+	AlertingStateCacheWarmingPageSize    int
+	AlertingStateCacheWarmingParallelism int
+	AlertingMaxInstancesPerRule          int
+	AlertingStateMinWriteInterval        time.Duration
+	AlertingStateFlushInterval           time.Duration
+	AlertingStaleMissedEvaluations       int
+
+	AlertingStateWebhookURL         string
+	AlertingStateWebhookTemplate    string
+	AlertingStateWebhookSecret      string
+	AlertingStateWebhookMaxAttempts int
+
+	// Unified alerting
+	UnifiedAlertingEvaluationTimeout time.Duration
+	UnifiedAlertingEvaluationJitter  bool
+
 	// Explore UI
 	ExploreEnabled bool
 
@@ -871,6 +887,10 @@ func (cfg *Cfg) Load(args *CommandLineArgs) error {
 		return err
 	}
 
+	if err := readUnifiedAlertingSettings(iniFile); err != nil {
+		return err
+	}
+
 	explore := iniFile.Section("explore")
 	ExploreEnabled = explore.Key("enabled").MustBool(true)
 
@@ -1321,6 +1341,24 @@ func readAlertingSettings(iniFile *ini.File) error {
 	AlertingNotificationTimeout = time.Second * time.Duration(notificationTimeoutSeconds)
 	AlertingMaxAttempts = alerting.Key("max_attempts").MustInt(3)
 	AlertingMinInterval = alerting.Key("min_interval_seconds").MustInt64(1)
+	AlertingStateCacheWarmingPageSize = alerting.Key("state_cache_warming_page_size").MustInt(1000)
+	AlertingStateCacheWarmingParallelism = alerting.Key("state_cache_warming_parallelism").MustInt(1)
+	AlertingMaxInstancesPerRule = alerting.Key("max_instances_per_rule").MustInt(0)
+	AlertingStateMinWriteInterval = time.Second * time.Duration(alerting.Key("state_min_write_interval_seconds").MustInt64(0))
+	AlertingStateFlushInterval = time.Second * time.Duration(alerting.Key("state_flush_interval_seconds").MustInt64(60))
+	AlertingStaleMissedEvaluations = alerting.Key("stale_missed_evaluations").MustInt(0)
+	AlertingStateWebhookURL = valueAsString(alerting, "state_webhook_url", "")
+	AlertingStateWebhookTemplate = valueAsString(alerting, "state_webhook_template", "")
+	AlertingStateWebhookSecret = valueAsString(alerting, "state_webhook_secret", "")
+	AlertingStateWebhookMaxAttempts = alerting.Key("state_webhook_max_attempts").MustInt(3)
+
+	return nil
+}
+
+func readUnifiedAlertingSettings(iniFile *ini.File) error {
+	unifiedAlerting := iniFile.Section("unified_alerting")
+	UnifiedAlertingEvaluationTimeout = time.Second * time.Duration(unifiedAlerting.Key("evaluation_timeout_seconds").MustInt64(30))
+	UnifiedAlertingEvaluationJitter = unifiedAlerting.Key("evaluation_jitter").MustBool(false)
 
 	return nil
 }